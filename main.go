@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"fileflow/server/api"
@@ -22,6 +24,12 @@ import (
 var staticFiles embed.FS
 
 func main() {
+	// 子命令：重新加密数据密钥后退出
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		rotateKeys()
+		return
+	}
+
 	// 加载配置
 	cfg := config.Load()
 	log.Printf("FileFlow 启动中，端口: %s", cfg.Port)
@@ -31,9 +39,23 @@ func main() {
 		log.Fatalf("初始化存储失败: %v", err)
 	}
 
+	// 检查加密主密钥
+	if err := service.CheckEncryptionKeys(); err != nil {
+		log.Fatalf("加密配置无效: %v", err)
+	}
+
+	// 恢复进行中上传（直传和可恢复上传）的容量预留
+	service.RestoreReservations()
+
 	// 启动定时任务
 	service.StartScheduler()
 
+	// 恢复未完成的 URL 导入任务
+	service.StartImportJobs()
+
+	// 恢复未完成的 Webhook 投递
+	service.StartWebhookDeliveries()
+
 	// 设置 Gin 模式
 	gin.SetMode(gin.ReleaseMode)
 
@@ -80,6 +102,25 @@ func main() {
 	}
 }
 
+// rotateKeys 把所有账户中由旧主密钥加密的数据密钥改用 FILEFLOW_MASTER_KEY 重新加密
+// 旧主密钥通过 FILEFLOW_PREVIOUS_MASTER_KEYS 提供，全部完成后才可以移除
+func rotateKeys() {
+	config.Load()
+	if err := store.Init(); err != nil {
+		log.Fatalf("初始化存储失败: %v", err)
+	}
+
+	result, err := service.RotateEncryptionKeys(context.Background())
+	if err != nil {
+		log.Fatalf("轮换主密钥失败: %v", err)
+	}
+	log.Printf("主密钥轮换完成: 检查 %d 个对象，其中加密 %d 个，重新加密数据密钥 %d 个，失败 %d 个，无法列出的账户 %d 个",
+		result.Scanned, result.Encrypted, result.Rewrapped, result.Failed, result.FailedAccounts)
+	if result.Failed > 0 || result.FailedAccounts > 0 {
+		log.Fatal("仍有对象使用旧主密钥，请保留 FILEFLOW_PREVIOUS_MASTER_KEYS 并重新执行")
+	}
+}
+
 // corsMiddleware CORS 中间件
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -90,8 +131,16 @@ func corsMiddleware() gin.HandlerFunc {
 		}
 
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Token")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, HEAD, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Token, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata, Upload-Defer-Length")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Location, Tus-Resumable, Tus-Version, Tus-Extension, Upload-Offset, Upload-Length, Upload-Expires")
+
+		// tus 协议的 OPTIONS 请求用于查询服务端能力
+		if strings.HasPrefix(c.Request.URL.Path, "/api/upload/tus") {
+			c.Writer.Header().Set("Tus-Resumable", api.TusVersion)
+			c.Writer.Header().Set("Tus-Version", api.TusVersion)
+			c.Writer.Header().Set("Tus-Extension", api.TusExtensions)
+		}
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strconv"
+	"time"
 
 	"fileflow/server/service"
 	"fileflow/server/store"
@@ -13,113 +14,203 @@ import (
 
 // AccountRequest 创建/更新账户请求
 type AccountRequest struct {
-	Name            string                   `json:"name" binding:"required"`
-	IsActive        bool                     `json:"isActive"`
-	Description     string                   `json:"description"`
-	AccountID       string                   `json:"accountId" binding:"required"`
-	AccessKeyId     string                   `json:"accessKeyId"`     // 更新时可选，空则保留原值
-	SecretAccessKey string                   `json:"secretAccessKey"` // 更新时可选，空则保留原值
-	BucketName      string                   `json:"bucketName" binding:"required"`
-	Endpoint        string                   `json:"endpoint" binding:"required"`
-	PublicDomain    string                   `json:"publicDomain" binding:"required"`
-	APIToken        string                   `json:"apiToken"`
-	Quota           store.Quota              `json:"quota" binding:"required"`
-	Permissions     store.AccountPermissions `json:"permissions"`
+	Name               string                   `json:"name" binding:"required"`
+	IsActive           bool                     `json:"isActive"`
+	Description        string                   `json:"description"`
+	Provider           string                   `json:"provider"`        // 存储服务商，创建时为空表示 r2，更新时为空则保留原值
+	AccountID          string                   `json:"accountId"`       // Cloudflare Account ID，R2 账户必填
+	AccessKeyId        string                   `json:"accessKeyId"`     // 更新时可选，空则保留原值
+	SecretAccessKey    string                   `json:"secretAccessKey"` // 更新时可选，空则保留原值
+	BucketName         string                   `json:"bucketName" binding:"required"`
+	Endpoint           string                   `json:"endpoint"`     // S3 端点，AWS 可为空
+	Region             string                   `json:"region"`       // 签名区域，为空时按服务商默认
+	PathStyle          *bool                    `json:"pathStyle"`    // 路径风格访问，创建时为空按服务商默认，更新时为空则保留原值
+	PublicDomain       string                   `json:"publicDomain"` // public 模式下必填
+	APIToken           string                   `json:"apiToken"`
+	Quota              store.Quota              `json:"quota" binding:"required"`
+	Permissions        store.AccountPermissions `json:"permissions"`
+	LinkMode           string                   `json:"linkMode"`           // public 或 private，更新时为空则保留原值
+	PresignTTL         *int                     `json:"presignTtl"`         // 预签名链接有效期（秒），更新时为空则保留原值
+	KeyTemplate        *string                  `json:"keyTemplate"`        // 存储路径模板，更新时为空则保留原值，空字符串表示使用系统设置
+	StripImageMetadata *bool                    `json:"stripImageMetadata"` // 写入前移除图片元数据，更新时为空则保留原值
+	Encryption         *bool                    `json:"encryption"`         // 写入前加密对象内容，需要配置主密钥，更新时为空则保留原值
+	Weight             *int                     `json:"weight"`             // 放置权重，0 表示默认权重 1，更新时为空则保留原值
+}
+
+// maxAccountWeight 账户放置权重上限
+const maxAccountWeight = 100
+
+// validateLinkSettings 校验链接模式相关配置
+func validateLinkSettings(linkMode, publicDomain string, presignTTL int) string {
+	if linkMode != store.LinkModePublic && linkMode != store.LinkModePrivate {
+		return "链接模式只能为 public 或 private"
+	}
+	if linkMode == store.LinkModePublic && publicDomain == "" {
+		return "公开链接模式需要配置公开访问域名"
+	}
+	if presignTTL < 0 || time.Duration(presignTTL)*time.Second > service.MaxPresignTTL {
+		return "预签名链接有效期无效，范围为 0 到 604800 秒"
+	}
+	return ""
 }
 
 // AccountResponse 账户响应（隐藏敏感字段）
 type AccountResponse struct {
-	ID           string                   `json:"id"`
-	Name         string                   `json:"name"`
-	IsActive     bool                     `json:"isActive"`
-	Description  string                   `json:"description"`
-	AccountID    string                   `json:"accountId"`
-	BucketName   string                   `json:"bucketName"`
-	Endpoint     string                   `json:"endpoint"`
-	PublicDomain string                   `json:"publicDomain"`
-	HasAPIToken  bool                     `json:"hasApiToken"`
-	Quota        store.Quota              `json:"quota"`
-	Usage        store.Usage              `json:"usage"`
-	Permissions  store.AccountPermissions `json:"permissions"`
-	UsagePercent float64                  `json:"usagePercent"`
-	IsOverQuota  bool                     `json:"isOverQuota"`
-	IsOverOps    bool                     `json:"isOverOps"`
-	IsAvailable  bool                     `json:"isAvailable"`
-	CreatedAt    string                   `json:"createdAt"`
-	UpdatedAt    string                   `json:"updatedAt"`
+	ID                 string                   `json:"id"`
+	Name               string                   `json:"name"`
+	IsActive           bool                     `json:"isActive"`
+	Description        string                   `json:"description"`
+	Provider           string                   `json:"provider"`
+	AccountID          string                   `json:"accountId"`
+	BucketName         string                   `json:"bucketName"`
+	Endpoint           string                   `json:"endpoint"`
+	Region             string                   `json:"region"`
+	PathStyle          bool                     `json:"pathStyle"`
+	PublicDomain       string                   `json:"publicDomain"`
+	LinkMode           string                   `json:"linkMode"`
+	PresignTTL         int                      `json:"presignTtl"`
+	KeyTemplate        string                   `json:"keyTemplate"`
+	StripImageMetadata bool                     `json:"stripImageMetadata"`
+	Encryption         bool                     `json:"encryption"`
+	Weight             int                      `json:"weight"`
+	HasAPIToken        bool                     `json:"hasApiToken"`
+	Quota              store.Quota              `json:"quota"`
+	Usage              store.Usage              `json:"usage"`          // 上次同步的使用量
+	EstimatedUsage     store.Usage              `json:"estimatedUsage"` // 同步值加上之后本地记录的变化，配额检查使用该值
+	ReservedBytes      int64                    `json:"reservedBytes"`  // 进行中上传预留的容量
+	Health             service.AccountHealth    `json:"health"`         // 请求失败率、耗时和熔断状态
+	Permissions        store.AccountPermissions `json:"permissions"`
+	UsagePercent       float64                  `json:"usagePercent"`
+	IsOverQuota        bool                     `json:"isOverQuota"`
+	IsOverOps          bool                     `json:"isOverOps"`
+	IsOverReadOps      bool                     `json:"isOverReadOps"` // B 类操作达到配额，代理不再转发该账户的读取
+	OpsForecast        service.OpsForecast      `json:"opsForecast"`   // 本月操作次数和月底预测
+	IsAvailable        bool                     `json:"isAvailable"`
+	CreatedAt          string                   `json:"createdAt"`
+	UpdatedAt          string                   `json:"updatedAt"`
 }
 
 // AccountFullResponse 账户完整响应（包含敏感字段，用于编辑）
 type AccountFullResponse struct {
-	ID              string                   `json:"id"`
-	Name            string                   `json:"name"`
-	IsActive        bool                     `json:"isActive"`
-	Description     string                   `json:"description"`
-	AccountID       string                   `json:"accountId"`
-	AccessKeyId     string                   `json:"accessKeyId"`
-	SecretAccessKey string                   `json:"secretAccessKey"`
-	BucketName      string                   `json:"bucketName"`
-	Endpoint        string                   `json:"endpoint"`
-	PublicDomain    string                   `json:"publicDomain"`
-	APIToken        string                   `json:"apiToken"`
-	Quota           store.Quota              `json:"quota"`
-	Usage           store.Usage              `json:"usage"`
-	Permissions     store.AccountPermissions `json:"permissions"`
-	UsagePercent    float64                  `json:"usagePercent"`
-	IsOverQuota     bool                     `json:"isOverQuota"`
-	IsOverOps       bool                     `json:"isOverOps"`
-	IsAvailable     bool                     `json:"isAvailable"`
-	CreatedAt       string                   `json:"createdAt"`
-	UpdatedAt       string                   `json:"updatedAt"`
+	ID                 string                   `json:"id"`
+	Name               string                   `json:"name"`
+	IsActive           bool                     `json:"isActive"`
+	Description        string                   `json:"description"`
+	Provider           string                   `json:"provider"`
+	AccountID          string                   `json:"accountId"`
+	AccessKeyId        string                   `json:"accessKeyId"`
+	SecretAccessKey    string                   `json:"secretAccessKey"`
+	BucketName         string                   `json:"bucketName"`
+	Endpoint           string                   `json:"endpoint"`
+	Region             string                   `json:"region"`
+	PathStyle          bool                     `json:"pathStyle"`
+	PublicDomain       string                   `json:"publicDomain"`
+	LinkMode           string                   `json:"linkMode"`
+	PresignTTL         int                      `json:"presignTtl"`
+	KeyTemplate        string                   `json:"keyTemplate"`
+	StripImageMetadata bool                     `json:"stripImageMetadata"`
+	Encryption         bool                     `json:"encryption"`
+	Weight             int                      `json:"weight"`
+	APIToken           string                   `json:"apiToken"`
+	Quota              store.Quota              `json:"quota"`
+	Usage              store.Usage              `json:"usage"`          // 上次同步的使用量
+	EstimatedUsage     store.Usage              `json:"estimatedUsage"` // 同步值加上之后本地记录的变化，配额检查使用该值
+	ReservedBytes      int64                    `json:"reservedBytes"`  // 进行中上传预留的容量
+	Health             service.AccountHealth    `json:"health"`         // 请求失败率、耗时和熔断状态
+	Permissions        store.AccountPermissions `json:"permissions"`
+	UsagePercent       float64                  `json:"usagePercent"`
+	IsOverQuota        bool                     `json:"isOverQuota"`
+	IsOverOps          bool                     `json:"isOverOps"`
+	IsOverReadOps      bool                     `json:"isOverReadOps"` // B 类操作达到配额，代理不再转发该账户的读取
+	OpsForecast        service.OpsForecast      `json:"opsForecast"`   // 本月操作次数和月底预测
+	IsAvailable        bool                     `json:"isAvailable"`
+	CreatedAt          string                   `json:"createdAt"`
+	UpdatedAt          string                   `json:"updatedAt"`
+}
+
+// linkModeOf 返回账户的链接模式，未配置的旧账户按公开域名处理
+func linkModeOf(acc *store.Account) string {
+	if acc.IsPrivateLink() {
+		return store.LinkModePrivate
+	}
+	return store.LinkModePublic
 }
 
 // toAccountResponse 转换为响应对象
 func toAccountResponse(acc *store.Account) AccountResponse {
 	return AccountResponse{
-		ID:           acc.ID,
-		Name:         acc.Name,
-		IsActive:     acc.IsActive,
-		Description:  acc.Description,
-		AccountID:    acc.AccountID,
-		BucketName:   acc.BucketName,
-		Endpoint:     acc.Endpoint,
-		PublicDomain: acc.PublicDomain,
-		HasAPIToken:  acc.APIToken != "",
-		Quota:        acc.Quota,
-		Usage:        acc.Usage,
-		Permissions:  acc.Permissions,
-		UsagePercent: acc.GetUsagePercent(),
-		IsOverQuota:  acc.IsOverQuota(),
-		IsOverOps:    acc.IsOverOps(),
-		IsAvailable:  acc.IsAvailable(),
-		CreatedAt:    acc.CreatedAt,
-		UpdatedAt:    acc.UpdatedAt,
+		ID:                 acc.ID,
+		Name:               acc.Name,
+		IsActive:           acc.IsActive,
+		Description:        acc.Description,
+		Provider:           acc.GetProvider(),
+		AccountID:          acc.AccountID,
+		BucketName:         acc.BucketName,
+		Endpoint:           acc.Endpoint,
+		Region:             acc.Region,
+		PathStyle:          acc.PathStyle,
+		PublicDomain:       acc.PublicDomain,
+		LinkMode:           linkModeOf(acc),
+		PresignTTL:         acc.PresignTTL,
+		KeyTemplate:        acc.KeyTemplate,
+		StripImageMetadata: acc.StripImageMetadata,
+		Encryption:         acc.Encryption,
+		Weight:             acc.Weight,
+		HasAPIToken:        acc.APIToken != "",
+		Quota:              acc.Quota,
+		Usage:              acc.Usage,
+		EstimatedUsage:     acc.EstimatedUsage(),
+		ReservedBytes:      acc.ReservedBytes,
+		Health:             service.GetAccountHealth(acc.ID),
+		Permissions:        acc.Permissions,
+		UsagePercent:       acc.GetUsagePercent(),
+		IsOverQuota:        acc.IsOverQuota(),
+		IsOverOps:          acc.IsOverOps(),
+		IsOverReadOps:      acc.IsOverReadOps(),
+		OpsForecast:        service.ForecastAccountOps(acc, time.Now()),
+		IsAvailable:        acc.IsAvailable(),
+		CreatedAt:          acc.CreatedAt,
+		UpdatedAt:          acc.UpdatedAt,
 	}
 }
 
 // toAccountFullResponse 转换为完整响应对象（包含敏感字段）
 func toAccountFullResponse(acc *store.Account) AccountFullResponse {
 	return AccountFullResponse{
-		ID:              acc.ID,
-		Name:            acc.Name,
-		IsActive:        acc.IsActive,
-		Description:     acc.Description,
-		AccountID:       acc.AccountID,
-		AccessKeyId:     acc.AccessKeyId,
-		SecretAccessKey: acc.SecretAccessKey,
-		BucketName:      acc.BucketName,
-		Endpoint:        acc.Endpoint,
-		PublicDomain:    acc.PublicDomain,
-		APIToken:        acc.APIToken,
-		Quota:           acc.Quota,
-		Usage:           acc.Usage,
-		Permissions:     acc.Permissions,
-		UsagePercent:    acc.GetUsagePercent(),
-		IsOverQuota:     acc.IsOverQuota(),
-		IsOverOps:       acc.IsOverOps(),
-		IsAvailable:     acc.IsAvailable(),
-		CreatedAt:       acc.CreatedAt,
-		UpdatedAt:       acc.UpdatedAt,
+		ID:                 acc.ID,
+		Name:               acc.Name,
+		IsActive:           acc.IsActive,
+		Description:        acc.Description,
+		Provider:           acc.GetProvider(),
+		AccountID:          acc.AccountID,
+		AccessKeyId:        acc.AccessKeyId,
+		SecretAccessKey:    acc.SecretAccessKey,
+		BucketName:         acc.BucketName,
+		Endpoint:           acc.Endpoint,
+		Region:             acc.Region,
+		PathStyle:          acc.PathStyle,
+		PublicDomain:       acc.PublicDomain,
+		LinkMode:           linkModeOf(acc),
+		PresignTTL:         acc.PresignTTL,
+		KeyTemplate:        acc.KeyTemplate,
+		StripImageMetadata: acc.StripImageMetadata,
+		Encryption:         acc.Encryption,
+		Weight:             acc.Weight,
+		APIToken:           acc.APIToken,
+		Quota:              acc.Quota,
+		Usage:              acc.Usage,
+		EstimatedUsage:     acc.EstimatedUsage(),
+		ReservedBytes:      acc.ReservedBytes,
+		Health:             service.GetAccountHealth(acc.ID),
+		Permissions:        acc.Permissions,
+		UsagePercent:       acc.GetUsagePercent(),
+		IsOverQuota:        acc.IsOverQuota(),
+		IsOverOps:          acc.IsOverOps(),
+		IsOverReadOps:      acc.IsOverReadOps(),
+		OpsForecast:        service.ForecastAccountOps(acc, time.Now()),
+		IsAvailable:        acc.IsAvailable(),
+		CreatedAt:          acc.CreatedAt,
+		UpdatedAt:          acc.UpdatedAt,
 	}
 }
 
@@ -200,19 +291,79 @@ func CreateAccount(c *gin.Context) {
 		permissions = store.DefaultAccountPermissions()
 	}
 
+	linkMode := req.LinkMode
+	if linkMode == "" {
+		linkMode = store.LinkModePublic
+	}
+	presignTTL := 0
+	if req.PresignTTL != nil {
+		presignTTL = *req.PresignTTL
+	}
+	if msg := validateLinkSettings(linkMode, req.PublicDomain, presignTTL); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	keyTemplate := ""
+	if req.KeyTemplate != nil {
+		keyTemplate = *req.KeyTemplate
+	}
+	if err := service.ValidateKeyTemplate(keyTemplate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	encryption := req.Encryption != nil && *req.Encryption
+	if encryption && !service.EncryptionAvailable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrEncryptionKeyMissing.Error()})
+		return
+	}
+	weight := 0
+	if req.Weight != nil {
+		weight = *req.Weight
+	}
+	if weight < 0 || weight > maxAccountWeight {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "放置权重无效，范围为 0 到 100"})
+		return
+	}
+	provider := req.Provider
+	if provider == "" {
+		provider = store.ProviderR2
+	}
+	providerInfo, err := service.GetProviderInfo(provider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pathStyle := providerInfo.DefaultPathStyle
+	if req.PathStyle != nil {
+		pathStyle = *req.PathStyle
+	}
+
 	acc := &store.Account{
-		Name:            req.Name,
-		IsActive:        req.IsActive,
-		Description:     req.Description,
-		AccountID:       req.AccountID,
-		AccessKeyId:     req.AccessKeyId,
-		SecretAccessKey: req.SecretAccessKey,
-		BucketName:      req.BucketName,
-		Endpoint:        req.Endpoint,
-		PublicDomain:    req.PublicDomain,
-		APIToken:        req.APIToken,
-		Quota:           req.Quota,
-		Permissions:     permissions,
+		Name:               req.Name,
+		IsActive:           req.IsActive,
+		Description:        req.Description,
+		Provider:           provider,
+		AccountID:          req.AccountID,
+		AccessKeyId:        req.AccessKeyId,
+		SecretAccessKey:    req.SecretAccessKey,
+		BucketName:         req.BucketName,
+		Endpoint:           req.Endpoint,
+		Region:             req.Region,
+		PathStyle:          pathStyle,
+		PublicDomain:       req.PublicDomain,
+		APIToken:           req.APIToken,
+		Quota:              req.Quota,
+		Permissions:        permissions,
+		LinkMode:           linkMode,
+		PresignTTL:         presignTTL,
+		KeyTemplate:        keyTemplate,
+		StripImageMetadata: req.StripImageMetadata != nil && *req.StripImageMetadata,
+		Encryption:         encryption,
+		Weight:             weight,
+	}
+	if err := service.ValidateAccountConnection(acc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	if err := store.CreateAccount(acc); err != nil {
@@ -245,12 +396,60 @@ func UpdateAccount(c *gin.Context) {
 	existing.Name = req.Name
 	existing.IsActive = req.IsActive
 	existing.Description = req.Description
+	if req.Provider != "" {
+		existing.Provider = req.Provider
+	}
 	existing.AccountID = req.AccountID
 	existing.BucketName = req.BucketName
 	existing.Endpoint = req.Endpoint
+	existing.Region = req.Region
+	if req.PathStyle != nil {
+		existing.PathStyle = *req.PathStyle
+	}
+	if err := service.ValidateAccountConnection(existing); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	existing.PublicDomain = req.PublicDomain
 	existing.Quota = req.Quota
 	existing.Permissions = req.Permissions
+	if req.LinkMode != "" {
+		existing.LinkMode = req.LinkMode
+	} else if existing.LinkMode == "" {
+		existing.LinkMode = store.LinkModePublic
+	}
+	if req.PresignTTL != nil {
+		existing.PresignTTL = *req.PresignTTL
+	}
+	if msg := validateLinkSettings(existing.LinkMode, existing.PublicDomain, existing.PresignTTL); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if req.KeyTemplate != nil {
+		if err := service.ValidateKeyTemplate(*req.KeyTemplate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		existing.KeyTemplate = *req.KeyTemplate
+	}
+	if req.StripImageMetadata != nil {
+		existing.StripImageMetadata = *req.StripImageMetadata
+	}
+	// 关闭加密只影响之后写入的对象，已加密的对象仍可解密读取
+	if req.Encryption != nil {
+		if *req.Encryption && !service.EncryptionAvailable() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrEncryptionKeyMissing.Error()})
+			return
+		}
+		existing.Encryption = *req.Encryption
+	}
+	if req.Weight != nil {
+		if *req.Weight < 0 || *req.Weight > maxAccountWeight {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "放置权重无效，范围为 0 到 100"})
+			return
+		}
+		existing.Weight = *req.Weight
+	}
 
 	// 敏感字段：只有非空时才更新
 	if req.AccessKeyId != "" {
@@ -267,6 +466,8 @@ func UpdateAccount(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	// 密钥、端点等配置可能已修复，重新开始统计健康状态
+	service.ResetAccountHealth(id)
 
 	// 更新后返回完整信息（包含敏感字段）
 	c.JSON(http.StatusOK, toAccountFullResponse(existing))
@@ -280,6 +481,7 @@ func DeleteAccount(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	service.ResetAccountHealth(id)
 
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
@@ -318,8 +520,21 @@ func SyncAccounts(c *gin.Context) {
 	}
 }
 
+// AccountsStatsResponse 账户统计响应：容量、操作次数统计和健康状态统计
+type AccountsStatsResponse struct {
+	store.AccountsStats
+	service.HealthSummary
+}
+
+// GetProviders 获取支持的存储服务商及其默认配置
+func GetProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": service.Providers()})
+}
+
 // GetAccountsStats 获取账户统计信息
 func GetAccountsStats(c *gin.Context) {
-	stats := store.GetAccountsStats()
-	c.JSON(http.StatusOK, stats)
+	c.JSON(http.StatusOK, AccountsStatsResponse{
+		AccountsStats: store.GetAccountsStats(),
+		HealthSummary: service.GetHealthSummary(),
+	})
 }
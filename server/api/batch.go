@@ -0,0 +1,201 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+
+	"fileflow/server/middleware"
+	"fileflow/server/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchMaxFiles 批量上传单次请求最多包含的文件数
+const BatchMaxFiles = 500
+
+// BatchFileResult 批量上传中单个文件的结果
+type BatchFileResult struct {
+	FileName string                `json:"fileName"`
+	Result   *service.UploadResult `json:"result,omitempty"`
+	Error    string                `json:"error,omitempty"`
+}
+
+// BatchUploadResponse 批量上传响应
+type BatchUploadResponse struct {
+	Results   []BatchFileResult `json:"results"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+}
+
+// ArchiveUploadResponse 压缩包上传响应
+type ArchiveUploadResponse struct {
+	Archive   string            `json:"archive"`
+	Prefix    string            `json:"prefix"`
+	Results   []BatchFileResult `json:"results"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Error     string            `json:"error,omitempty"` // 解压中途终止的原因，已上传的文件保留
+}
+
+// add 记录单个文件的结果
+func (r *BatchUploadResponse) add(fileName string, result *service.UploadResult, err error) {
+	item := BatchFileResult{FileName: fileName, Result: result}
+	if err != nil {
+		item.Error = err.Error()
+		r.Failed++
+	} else {
+		r.Succeeded++
+	}
+	r.Results = append(r.Results, item)
+}
+
+// BatchUpload 一次请求上传多个文件（multipart/form-data 中的多个 files 或 file 字段）
+// path、idGroup（或 pool）、expirationDays 作用于所有文件，单个文件失败不影响其他文件；批量上传只写入 R2
+func BatchUpload(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	defer form.RemoveAll()
+
+	var headers []*multipart.FileHeader
+	headers = append(headers, form.File["files"]...)
+	headers = append(headers, form.File["file"]...)
+	if len(headers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请提供 files 参数"})
+		return
+	}
+	if len(headers) > BatchMaxFiles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("单次最多上传 %d 个文件", BatchMaxFiles)})
+		return
+	}
+
+	custom, tags, err := customMetadata(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target, ok := bindUploadTarget(c, c.PostForm("idGroup"), c.PostForm("pool"))
+	if !ok {
+		return
+	}
+	expirationDays := target.expirationDays(parseExpirationDays(c.PostForm("expirationDays")))
+	customPath := c.PostForm("path")
+
+	resp := BatchUploadResponse{Results: []BatchFileResult{}}
+	for _, header := range headers {
+		result, err := uploadFormFile(c, header, target, customPath, expirationDays, custom, tags)
+		resp.add(header.Filename, result, err)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// uploadFormFile 上传表单中的单个文件，custom 和 tags 为已校验的自定义元数据和标签
+func uploadFormFile(c *gin.Context, header *multipart.FileHeader, target uploadTarget, customPath string, expirationDays int, custom map[string]string, tags []string) (*service.UploadResult, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	ext := filepath.Ext(header.Filename)
+
+	naming := target.naming(uploadKeyNaming(c, header.Filename, ext, customPath))
+	meta := uploadMetadata(c, header.Filename, service.UploadSourceFile, "")
+	meta.KeepImageMetadata = keepImageMetadata(c)
+	meta.Custom = custom
+	meta.Tags = tags
+	meta.Striped = stripedRequested(c)
+	return storeUpload(c, target.AccountID, naming, meta, file, header.Size, contentType, expirationDays)
+}
+
+// ArchiveUpload 上传 zip、tar.gz 或 tar 压缩包，在服务端解压后逐个上传
+// 文件按压缩包内的相对路径存放在 path 前缀下（不使用路径模板），
+// 拒绝绝对路径和包含 .. 的条目，并限制文件数和解压后的总大小
+func ArchiveUpload(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请提供 file 参数"})
+		return
+	}
+	defer file.Close()
+
+	format := service.DetectArchiveFormat(header.Filename)
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "仅支持 .zip、.tar.gz、.tgz 和 .tar 压缩包"})
+		return
+	}
+
+	custom, tags, err := customMetadata(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target, ok := bindUploadTarget(c, c.PostForm("idGroup"), c.PostForm("pool"))
+	if !ok {
+		return
+	}
+	expirationDays := target.expirationDays(parseExpirationDays(c.PostForm("expirationDays")))
+	prefix := c.PostForm("path")
+	tokenID := c.GetString(middleware.ContextKeyTokenID)
+	keepMetadata := keepImageMetadata(c)
+	striped := stripedRequested(c)
+
+	batch := BatchUploadResponse{Results: []BatchFileResult{}}
+	handle := func(entry service.ArchiveEntry, body io.Reader, err error) {
+		if err != nil {
+			batch.add(entry.Path, nil, err)
+			return
+		}
+
+		contentType := mime.TypeByExtension(path.Ext(entry.Path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		naming := target.naming(service.KeyNaming{Key: path.Join(prefix, entry.Path), TokenID: tokenID})
+		meta := uploadMetadata(c, path.Base(entry.Path), service.UploadSourceArchive, "")
+		meta.KeepImageMetadata = keepMetadata
+		meta.Custom = custom
+		meta.Tags = tags
+		meta.Striped = striped
+		result, err := storeUpload(c, target.AccountID, naming, meta, body, entry.Size, contentType, expirationDays)
+		batch.add(entry.Path, result, err)
+	}
+
+	if format == service.ArchiveFormatZip {
+		err = service.ExtractZip(file, header.Size, handle)
+	} else {
+		err = service.ExtractTar(file, format == service.ArchiveFormatTarGz, handle)
+	}
+
+	resp := ArchiveUploadResponse{
+		Archive:   header.Filename,
+		Prefix:    prefix,
+		Results:   batch.Results,
+		Succeeded: batch.Succeeded,
+		Failed:    batch.Failed,
+	}
+	if err != nil {
+		// 尚未上传任何文件时按请求错误处理
+		if len(batch.Results) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		resp.Error = err.Error()
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
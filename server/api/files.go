@@ -1,15 +1,19 @@
 package api
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
-	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"fileflow/server/middleware"
 	"fileflow/server/service"
 	"fileflow/server/store"
 
@@ -17,128 +21,8 @@ import (
 	"github.com/google/uuid"
 )
 
-// DownloadResult URL 下载结果
-type DownloadResult struct {
-	Body        io.ReadCloser
-	Size        int64
-	ContentType string
-	Ext         string
-}
-
-// downloadFromURL 从 URL 下载文件
-func downloadFromURL(rawURL string) (*DownloadResult, error) {
-	// 验证 URL 格式
-	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
-		return nil, fmt.Errorf("无效的 URL，必须以 http:// 或 https:// 开头")
-	}
-
-	// 创建 HTTP 客户端（120秒超时）
-	client := &http.Client{
-		Timeout: 120 * time.Second,
-	}
-
-	// 发起 GET 请求
-	resp, err := client.Get(rawURL)
-	if err != nil {
-		return nil, fmt.Errorf("下载失败: %w", err)
-	}
-
-	// 检查响应状态
-	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("下载失败，HTTP 状态码: %d", resp.StatusCode)
-	}
-
-	// 获取 Content-Type
-	contentType := resp.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = "application/octet-stream"
-	}
-
-	// 确定文件扩展名（优先 URL 路径）
-	ext := getExtFromURL(rawURL)
-	if ext == "" {
-		ext = getExtFromContentType(contentType)
-	}
-
-	return &DownloadResult{
-		Body:        resp.Body,
-		Size:        resp.ContentLength,
-		ContentType: contentType,
-		Ext:         ext,
-	}, nil
-}
-
-// getExtFromURL 从 URL 路径提取扩展名
-func getExtFromURL(rawURL string) string {
-	parsed, err := url.Parse(rawURL)
-	if err != nil {
-		return ""
-	}
-	return filepath.Ext(parsed.Path)
-}
-
-// getExtFromContentType 从 Content-Type 推断扩展名
-func getExtFromContentType(contentType string) string {
-	// 移除参数部分（如 charset）
-	if idx := strings.Index(contentType, ";"); idx != -1 {
-		contentType = strings.TrimSpace(contentType[:idx])
-	}
-
-	mimeMap := map[string]string{
-		"image/jpeg":              ".jpg",
-		"image/png":               ".png",
-		"image/gif":               ".gif",
-		"image/webp":              ".webp",
-		"image/svg+xml":           ".svg",
-		"image/x-icon":            ".ico",
-		"image/bmp":               ".bmp",
-		"video/mp4":               ".mp4",
-		"video/webm":              ".webm",
-		"video/quicktime":         ".mov",
-		"audio/mpeg":              ".mp3",
-		"audio/wav":               ".wav",
-		"audio/ogg":               ".ogg",
-		"application/pdf":         ".pdf",
-		"application/zip":         ".zip",
-		"application/x-gzip":      ".gz",
-		"application/x-tar":       ".tar",
-		"text/plain":              ".txt",
-		"text/html":               ".html",
-		"text/css":                ".css",
-		"application/javascript":  ".js",
-		"application/json":        ".json",
-		"application/xml":         ".xml",
-		"application/octet-stream": "",
-	}
-
-	if ext, ok := mimeMap[contentType]; ok {
-		return ext
-	}
-	return ""
-}
-
-// generateFilenameFromURL 从 URL 生成文件名
-func generateFilenameFromURL(rawURL string, ext string) string {
-	parsed, err := url.Parse(rawURL)
-	if err != nil {
-		return "download" + ext
-	}
-
-	// 尝试从路径获取文件名
-	basename := filepath.Base(parsed.Path)
-	if basename != "" && basename != "/" && basename != "." {
-		return basename
-	}
-
-	// 使用域名作为文件名
-	hostname := parsed.Hostname()
-	if hostname != "" {
-		return hostname + ext
-	}
-
-	return "download" + ext
-}
+// urlUploadTimeout 同步 URL 上传的下载超时时间
+const urlUploadTimeout = 120 * time.Second
 
 // GetFiles 获取文件列表（懒加载+分页）
 func GetFiles(c *gin.Context) {
@@ -152,6 +36,9 @@ func GetFiles(c *gin.Context) {
 		limit = 50
 	}
 
+	// tag（逗号分隔或重复提供）和 meta.<键>=<值> 按自定义元数据筛选
+	filter := fileFilter(c)
+
 	// 解析 idGroup（逗号分隔）
 	var idGroup []string
 	if idGroupStr != "" {
@@ -163,6 +50,22 @@ func GetFiles(c *gin.Context) {
 		}
 	}
 
+	// pool 为账户池名称，与 idGroup 二选一；API Token 限定了账户池时只列出池成员
+	idGroup, status, err := scopedAccountIDs(c, idGroup, c.Query("pool"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if idGroup != nil && len(idGroup) == 0 {
+		c.JSON(http.StatusOK, []service.AccountFiles{})
+		return
+	}
+
+	if !filter.IsZero() {
+		c.JSON(http.StatusOK, service.FilterAccountsFiles(idGroup, prefix, filter, cursor, int32(limit)))
+		return
+	}
+
 	if len(idGroup) > 0 {
 		// 获取指定账户组的文件
 		result, err := service.ListAccountsFilesByIDs(c.Request.Context(), idGroup, prefix, cursor, int32(limit))
@@ -184,8 +87,28 @@ func GetFiles(c *gin.Context) {
 	}
 }
 
+// fileFilter 解析文件列表的筛选条件
+func fileFilter(c *gin.Context) service.FileFilter {
+	var filter service.FileFilter
+	for _, value := range c.QueryArray("tag") {
+		filter.Tags = append(filter.Tags, service.SplitTags(value)...)
+	}
+	for name, values := range c.Request.URL.Query() {
+		key, ok := strings.CutPrefix(name, "meta.")
+		if !ok || key == "" || len(values) == 0 {
+			continue
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = make(map[string]string)
+		}
+		filter.Metadata[strings.ToLower(key)] = values[0]
+	}
+	return filter
+}
+
 // Upload 上传文件（可指定账户，不指定则智能选择）
 // 支持两种方式：file 表单字段上传文件，或 url 参数从远程下载后上传
+// 可选的 sha256、crc32c 参数为期望的校验值，与实际内容不一致时上传失败
 func Upload(c *gin.Context) {
 	// 获取 url 参数
 	urlParam := c.PostForm("url")
@@ -205,37 +128,61 @@ func Upload(c *gin.Context) {
 		return
 	}
 
-	// 指定账户ID（可选，取第一个）
-	idGroup := c.PostForm("idGroup")
-	accountID := getFirstID(idGroup)
+	// 按安全策略检查 URL，ImgBB 从 URL 上传同样需要通过检查
+	if urlParam != "" {
+		if err := service.CheckURL(urlParam); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	// 解析到期天数（可选，默认使用系统设置）
-	expirationDaysStr := c.PostForm("expirationDays")
-	var expirationDays int
-	if expirationDaysStr != "" {
-		expirationDays, _ = strconv.Atoi(expirationDaysStr)
-		// -1 表示使用默认设置，0 表示永久
-		if expirationDays < -1 {
-			expirationDays = -1
+	expected, err := service.ParseChecksums(c.PostForm("sha256"), c.PostForm("crc32c"))
+	if err != nil {
+		if hasFile {
+			file.Close()
 		}
-	} else {
-		expirationDays = -1 // 使用默认设置
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 解析实际到期天数（用于 ImgBB 判断）
-	actualExpirationDays := expirationDays
-	if actualExpirationDays == -1 {
-		settings := store.GetSettings()
-		actualExpirationDays = settings.DefaultExpirationDays
+	// 自定义元数据和标签（可选）
+	custom, tags, err := customMetadata(c)
+	if err != nil {
+		if hasFile {
+			file.Close()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
+	// 指定账户ID（可选，取第一个）或账户池名称
+	target, ok := bindUploadTarget(c, c.PostForm("idGroup"), c.PostForm("pool"))
+	if !ok {
+		if hasFile {
+			file.Close()
+		}
+		return
+	}
+
+	// 解析到期天数（可选，默认使用账户池或系统设置）
+	expirationDays := parseExpirationDays(c.PostForm("expirationDays"))
+
+	// 解析实际到期天数（用于 ImgBB 判断）
+	actualExpirationDays := target.expirationDays(expirationDays)
+
+	// keepMetadata=true 时保留图片原始元数据，否则按 Token 和账户的隐私选项移除
+	keepMetadata := keepImageMetadata(c)
+	stripMetadata := service.StripImageMetadataRequired(c.GetString(middleware.ContextKeyTokenID), target.AccountID, target.poolName(), keepMetadata)
+
 	// 检查是否应该使用 ImgBB
 	settings := store.GetSettings()
 	useImgBB := false
 	imgbbExpirationDays := actualExpirationDays
-	var downloadResult *DownloadResult
+	var downloadResult *service.DownloadResult
 
-	if settings.ImgBBEnabled && settings.ImgBBPriority {
+	// 提供了期望校验值或自定义元数据时不使用 ImgBB，ImgBB 上传无法校验内容，也不能保存元数据；
+	// 指定账户池时只写入池成员
+	if settings.ImgBBEnabled && settings.ImgBBPriority && expected.IsZero() && len(custom) == 0 && len(tags) == 0 && target.Pool == nil {
 		var fileContentType string
 		var fileExt string
 
@@ -245,7 +192,7 @@ func Upload(c *gin.Context) {
 			fileExt = filepath.Ext(header.Filename)
 		} else if urlParam != "" {
 			// URL 上传：简单判断是否为图片 URL（从扩展名）
-			fileExt = getExtFromURL(urlParam)
+			fileExt = service.ExtFromURL(urlParam)
 			// 如果扩展名是图片类型，直接使用 ImgBB URL 上传
 			if isImageExtension(fileExt) {
 				fileContentType = "image/" + strings.TrimPrefix(fileExt, ".")
@@ -277,22 +224,41 @@ func Upload(c *gin.Context) {
 			defer file.Close()
 			imgbbFileName = header.Filename
 			imgbbFileSize = header.Size
-			imgbbResult, err = service.UploadToImgBB(file, imgbbExpirationDays, 60*time.Second)
+			var imgbbBody io.Reader = file
+			if stripMetadata {
+				if imgbbBody, imgbbFileSize, _, err = service.StripImageMetadataReader(file, header.Size, service.Checksums{}); err != nil {
+					c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+					return
+				}
+			}
+			imgbbResult, err = service.UploadToImgBB(imgbbBody, imgbbExpirationDays, 60*time.Second)
 		} else if urlParam != "" {
 			// URL 上传：让 ImgBB 直接从 URL 下载
-			imgbbFileName = generateFilenameFromURL(urlParam, getExtFromURL(urlParam))
+			// 需要移除元数据时不能由 ImgBB 直接获取原图，改为本地下载处理后上传
+			imgbbFileName = service.FilenameFromURL(urlParam, service.ExtFromURL(urlParam))
 			imgbbFileSize = 0 // URL 上传暂时无法获取大小
-			imgbbResult, err = service.UploadURLToImgBB(urlParam, imgbbExpirationDays, 60*time.Second)
+			if stripMetadata {
+				err = fmt.Errorf("需要移除图片元数据")
+			} else {
+				imgbbResult, err = service.UploadURLToImgBB(urlParam, imgbbExpirationDays, 60*time.Second)
+			}
 
 			// ImgBB URL 上传失败，尝试本地下载后再上传到 ImgBB
 			if err != nil {
 				fmt.Printf("[Upload] ImgBB URL 上传失败，尝试本地下载后上传: %v\n", err)
-				downloadResult, downloadErr := downloadFromURL(urlParam)
+				downloadResult, downloadErr := service.DownloadURL(c.Request.Context(), urlParam, urlUploadTimeout)
 				if downloadErr == nil {
 					defer downloadResult.Body.Close()
-					imgbbFileName = generateFilenameFromURL(urlParam, downloadResult.Ext)
+					imgbbFileName = service.FilenameFromURL(urlParam, downloadResult.Ext)
 					imgbbFileSize = downloadResult.Size
-					imgbbResult, err = service.UploadToImgBB(downloadResult.Body, imgbbExpirationDays, 60*time.Second)
+					var imgbbBody io.Reader = downloadResult.Body
+					if stripMetadata {
+						if imgbbBody, imgbbFileSize, _, err = service.StripImageMetadataReader(downloadResult.Body, downloadResult.Size, service.Checksums{}); err != nil {
+							c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+							return
+						}
+					}
+					imgbbResult, err = service.UploadToImgBB(imgbbBody, imgbbExpirationDays, 60*time.Second)
 					if err == nil {
 						fmt.Printf("[Upload] ImgBB 文件上传成功（URL回退模式）\n")
 					} else {
@@ -351,12 +317,13 @@ func Upload(c *gin.Context) {
 	var fileSize int64
 	var contentType string
 	var ext string
+	var fileName string
 
 	if urlParam != "" {
 		// 从 URL 下载文件（如果还没下载过）
 		if downloadResult == nil {
 			var err error
-			downloadResult, err = downloadFromURL(urlParam)
+			downloadResult, err = service.DownloadURL(c.Request.Context(), urlParam, urlUploadTimeout)
 			if err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
@@ -368,6 +335,7 @@ func Upload(c *gin.Context) {
 		fileSize = downloadResult.Size
 		contentType = downloadResult.ContentType
 		ext = downloadResult.Ext
+		fileName = service.FilenameFromURL(urlParam, ext)
 	} else if hasFile {
 		// file 表单处理逻辑
 		if !useImgBB {
@@ -378,74 +346,170 @@ func Upload(c *gin.Context) {
 		fileSize = header.Size
 		contentType = header.Header.Get("Content-Type")
 		ext = filepath.Ext(header.Filename)
+		fileName = header.Filename
 	}
 
-	// 生成文件路径（始终使用 uuid+时间戳 重命名）
-	customPath := c.PostForm("path")
+	// 存储路径在选定账户后按路径模板生成
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
-	newFilename := fmt.Sprintf("%s_%d%s", uuid.New().String(), time.Now().UnixMilli(), ext)
+	naming := target.naming(uploadKeyNaming(c, fileName, ext, c.PostForm("path")))
+	meta := uploadMetadata(c, fileName, service.UploadSourceFile, "")
+	if urlParam != "" {
+		meta = uploadMetadata(c, fileName, service.UploadSourceURL, urlParam)
+	}
+	meta.Expected = expected
+	meta.KeepImageMetadata = keepMetadata
+	meta.Custom = custom
+	meta.Tags = tags
+	meta.Striped = stripedRequested(c)
 
-	var key string
-	if customPath != "" {
-		// 自定义路径 + 重命名后的文件名
-		customPath = strings.TrimPrefix(customPath, "/")
-		customPath = strings.TrimSuffix(customPath, "/")
-		key = fmt.Sprintf("%s/%s", customPath, newFilename)
-	} else {
-		// 默认按日期组织
-		key = fmt.Sprintf("%s/%s", time.Now().Format("2006/01/02"), newFilename)
+	result, err := storeUpload(c, target.AccountID, naming, meta, fileReader, fileSize, contentType, actualExpirationDays)
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
 	}
 
+	c.JSON(http.StatusOK, result)
+}
+
+// storeUpload 按前端上传规则上传到 R2 并创建文件到期记录
+// expirationDays 为已解析的到期天数，0 表示永久
+func storeUpload(c *gin.Context, accountID string, naming service.KeyNaming, meta service.ObjectMetadata, body io.Reader, size int64, contentType string, expirationDays int) (*service.UploadResult, error) {
 	var result *service.UploadResult
 	var err error
 	if accountID != "" {
 		// 上传到指定账户（前端上传检查 client_upload 权限）
-		result, err = service.UploadToAccountForClient(c.Request.Context(), accountID, key, fileReader, contentType)
+		result, err = service.UploadToAccountForClient(c.Request.Context(), accountID, naming, meta, body, size, contentType)
 	} else {
 		// 智能上传（自动选择具有 client_upload 权限的账户）
-		result, err = service.SmartUploadForClient(c.Request.Context(), key, fileReader, fileSize, contentType)
+		result, err = service.SmartUploadForClient(c.Request.Context(), naming, meta, body, size, contentType)
 	}
-
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
+	}
+
+	// 创建文件到期记录，失败不影响上传结果，仅记录日志
+	if err := service.RecordUploadExpiration(result, expirationDays); err != nil {
+		fmt.Printf("[Upload] %v\n", err)
+	}
+	return result, nil
+}
+
+// uploadErrorStatus 上传错误对应的 HTTP 状态码
+func uploadErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrKeyConflict):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrChecksumMismatch), errors.Is(err, service.ErrIncompleteUpload),
+		errors.Is(err, service.ErrStripImageMetadata):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInsufficientSpace), errors.Is(err, service.ErrSpaceReserved):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, service.ErrCircuitOpen):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// keepImageMetadata 表单参数 keepMetadata=true 时保留图片原始元数据，不按隐私选项移除
+func keepImageMetadata(c *gin.Context) bool {
+	return c.PostForm("keepMetadata") == "true"
+}
+
+// stripedRequested 表单参数 striped=true 时把文件拆分到多个账户存储（仅自动选择账户时有效）
+func stripedRequested(c *gin.Context) bool {
+	return c.PostForm("striped") == "true"
+}
+
+// customMetadata 解析上传表单中的自定义元数据和标签
+// metadata 为字符串键值对的 JSON 对象；tags 以逗号分隔，也可以重复提供
+func customMetadata(c *gin.Context) (map[string]string, []string, error) {
+	var metadata map[string]string
+	if value := c.PostForm("metadata"); value != "" {
+		if err := json.Unmarshal([]byte(value), &metadata); err != nil {
+			return nil, nil, fmt.Errorf("%w: metadata 应为字符串键值对的 JSON 对象", service.ErrInvalidCustomMetadata)
+		}
+	}
+	var tags []string
+	for _, value := range c.PostFormArray("tags") {
+		tags = append(tags, service.SplitTags(value)...)
+	}
+	return service.NormalizeCustomMetadata(metadata, tags)
+}
+
+// uploadMetadata 构建随对象保存的上传信息
+// 上传者记录为 token:<Token 名称> 或 user:<后台用户名>
+func uploadMetadata(c *gin.Context, fileName, source, sourceURL string) service.ObjectMetadata {
+	uploader := "user:" + c.GetString(middleware.ContextKeyUser)
+	if tokenID := c.GetString(middleware.ContextKeyTokenID); tokenID != "" {
+		uploader = "token:" + tokenID
+		if t, err := store.GetTokenByID(tokenID); err == nil {
+			uploader = "token:" + t.Name
+		}
 	}
+	return service.ObjectMetadata{
+		OriginalName: fileName,
+		Uploader:     uploader,
+		Source:       source,
+		SourceURL:    sourceURL,
+	}
+}
 
-	// 创建文件到期记录
-	if expirationDays == -1 {
-		// 使用系统默认设置
-		settings := store.GetSettings()
-		expirationDays = settings.DefaultExpirationDays
+// PreviewUploadKey 预览上传文件的存储路径
+// 参数与 /api/upload 一致（fileName、contentType、idGroup、pool、path），
+// template 可试用未保存的模板，sha256 可提供文件哈希，未提供时 {sha256} 使用随机值
+func PreviewUploadKey(c *gin.Context) {
+	fileName := c.Query("fileName")
+	ext := filepath.Ext(fileName)
+	if ext == "" {
+		ext = service.ExtFromContentType(c.Query("contentType"))
 	}
-	if expirationDays > 0 {
-		// expirationDays > 0 才创建到期记录，0 表示永久不过期
-		if err := service.CreateFileExpirationRecord(result.ID, result.Key, expirationDays); err != nil {
-			// 到期记录创建失败不影响上传结果，仅记录日志
-			fmt.Printf("[Upload] 创建文件到期记录失败: %v\n", err)
+
+	hash := strings.ToLower(c.Query("sha256"))
+	if hash != "" {
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 64 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sha256 必须为 64 位十六进制字符串"})
+			return
 		}
 	}
 
-	c.JSON(http.StatusOK, result)
+	target, ok := bindUploadTarget(c, c.Query("idGroup"), c.Query("pool"))
+	if !ok {
+		return
+	}
+	naming := target.naming(uploadKeyNaming(c, fileName, ext, c.Query("path")))
+	naming.Template = c.Query("template")
+
+	preview, err := service.PreviewObjectKey(c.Request.Context(), target.AccountID, naming, hash)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
 }
 
 // DeleteFile 删除文件
 func DeleteFile(c *gin.Context) {
-	idGroup := c.Query("idGroup")
-	accountID := getFirstID(idGroup)
 	key := c.Query("key")
-
-	if accountID == "" || key == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 idGroup 或 key 参数"})
+	accountID, ok := resolveFileAccount(c, key)
+	if !ok {
 		return
 	}
 
-	if err := service.DeleteFile(c.Request.Context(), accountID, key); err != nil {
+	removed, err := service.ReleaseFile(c.Request.Context(), accountID, key)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 其他引用仍在使用该对象，保留对象及其到期记录
+	if !removed {
+		c.JSON(http.StatusOK, gin.H{"message": "已释放引用，对象仍被其他上传引用"})
+		return
+	}
+
 	// 删除对应的到期记录（如果存在）
 	service.DeleteFileExpirationRecord(accountID, key)
 
@@ -466,24 +530,138 @@ func DeleteFile(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
 
-// GetLink 获取文件直链
+// StatFile 获取文件元数据（大小、类型以及上传时保存的原始文件名、上传者、来源和哈希）
+func StatFile(c *gin.Context) {
+	key := c.Query("key")
+	accountID, ok := resolveFileAccount(c, key)
+	if !ok {
+		return
+	}
+
+	stat, err := service.StatFile(c.Request.Context(), accountID, key)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrFileNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stat)
+}
+
+// DownloadFile 通过 FileFlow 下载文件，支持 Range 请求
+// 分块文件从各分块所在账户读取后按顺序拼接，普通文件直接转发对象内容
+func DownloadFile(c *gin.Context) {
+	key := c.Query("key")
+	accountID, ok := resolveFileAccount(c, key)
+	if !ok {
+		return
+	}
+
+	file, err := service.OpenFile(c.Request.Context(), accountID, key)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrFileNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	if file.ETag != "" {
+		c.Header("ETag", `"`+file.ETag+`"`)
+	}
+	http.ServeContent(c.Writer, c.Request, file.Name, file.ModTime, file)
+}
+
+// FileMetadataRequest 修改文件自定义元数据和标签的请求，整体替换原有内容
+type FileMetadataRequest struct {
+	Metadata map[string]string `json:"metadata"`
+	Tags     []string          `json:"tags"`
+}
+
+// UpdateFileMetadata 修改文件的自定义元数据和标签
+func UpdateFileMetadata(c *gin.Context) {
+	key := c.Query("key")
+	accountID, ok := resolveFileAccount(c, key)
+	if !ok {
+		return
+	}
+
+	var req FileMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	fm, err := service.UpdateFileMetadata(c.Request.Context(), accountID, key, req.Metadata, req.Tags)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrInvalidCustomMetadata):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrFileNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, fm)
+}
+
+// GetLink 获取文件链接
+// 可选参数：mode（public/private）、ttl（预签名有效期，秒）、
+// disposition（覆盖 Content-Disposition，filename 为其简写）、contentType（覆盖 Content-Type）
 func GetLink(c *gin.Context) {
-	idGroup := c.Query("idGroup")
-	accountID := getFirstID(idGroup)
 	key := c.Query("key")
+	accountID, ok := resolveFileAccount(c, key)
+	if !ok {
+		return
+	}
 
-	if accountID == "" || key == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 idGroup 或 key 参数"})
+	mode := c.Query("mode")
+	if mode != "" && mode != store.LinkModePublic && mode != store.LinkModePrivate {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode 参数只能为 public 或 private"})
 		return
 	}
 
-	url, err := service.GetFileLink(accountID, key)
+	var ttl time.Duration
+	if value := c.Query("ttl"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > service.MaxPresignTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ttl 参数无效，范围为 1 到 604800 秒"})
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	disposition := c.Query("disposition")
+	if disposition == "" && c.Query("filename") != "" {
+		disposition = mime.FormatMediaType("attachment", map[string]string{"filename": c.Query("filename")})
+	}
+
+	link, err := service.GetFileLink(c.Request.Context(), accountID, key, service.LinkOptions{
+		Mode:               mode,
+		TTL:                ttl,
+		ContentDisposition: disposition,
+		ContentType:        c.Query("contentType"),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"url": url})
+	c.JSON(http.StatusOK, link)
 }
 
 // GetImgBBFiles 获取 ImgBB 文件列表
@@ -492,6 +670,38 @@ func GetImgBBFiles(c *gin.Context) {
 	c.JSON(http.StatusOK, files)
 }
 
+// parseExpirationDays 解析到期天数参数
+// 返回 -1 表示使用默认设置，0 表示永久
+func parseExpirationDays(value string) int {
+	if value == "" {
+		return -1
+	}
+	days, _ := strconv.Atoi(value)
+	if days < -1 {
+		days = -1
+	}
+	return days
+}
+
+// resolveExpirationDays 将 -1 解析为系统默认到期天数
+func resolveExpirationDays(days int) int {
+	if days == -1 {
+		return store.GetSettings().DefaultExpirationDays
+	}
+	return days
+}
+
+// uploadKeyNaming 构建上传文件的命名参数，存储路径由服务层按路径模板生成
+// 后台登录上传不关联 Token，API Token 上传时可使用 Token 配置的模板
+func uploadKeyNaming(c *gin.Context, fileName, ext, customPath string) service.KeyNaming {
+	return service.KeyNaming{
+		FileName: fileName,
+		Ext:      ext,
+		Path:     customPath,
+		TokenID:  c.GetString(middleware.ContextKeyTokenID),
+	}
+}
+
 // getFirstID 从逗号分隔的 ID 列表中获取第一个 ID
 func getFirstID(idGroup string) string {
 	if idGroup == "" {
@@ -635,17 +845,23 @@ func DeleteFileExpirationByID(c *gin.Context) {
 		return
 	}
 
-	// 删除 S3 文件
-	if err := service.DeleteFile(c.Request.Context(), target.AccountID, target.FileKey); err != nil {
+	// 释放一个引用，去重共享的对象在最后一个引用释放时才删除
+	removed, err := service.ReleaseFile(c.Request.Context(), target.AccountID, target.FileKey)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除文件失败: " + err.Error()})
 		return
 	}
 
-	// 删除到期记录
+	// 无论对象是否已删除都移除到期记录，一条记录最多释放一个引用，
+	// 避免重复调用耗尽其他上传的引用；仍被引用的对象不再自动到期
 	if err := store.DeleteFileExpirationByID(id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除记录失败: " + err.Error()})
 		return
 	}
 
+	if !removed {
+		c.JSON(http.StatusOK, gin.H{"message": "已释放引用并删除到期记录，对象仍被其他上传引用"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
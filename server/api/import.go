@@ -0,0 +1,205 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"fileflow/server/middleware"
+	"fileflow/server/service"
+	"fileflow/server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ImportMaxURLs 单次请求最多提交的 URL 数
+	ImportMaxURLs = 100
+	// importEventInterval 进度推送间隔
+	importEventInterval = time.Second
+)
+
+// ImportRequest 提交 URL 导入任务请求
+type ImportRequest struct {
+	URL            string   `json:"url"`
+	URLs           []string `json:"urls"`
+	IDGroup        string   `json:"idGroup"`
+	Pool           string   `json:"pool"`
+	Path           string   `json:"path"`
+	ExpirationDays *int     `json:"expirationDays"`
+	MaxAttempts    int      `json:"maxAttempts"`
+}
+
+// CreateImportJobs 提交一个或多个 URL 导入任务，立即返回任务 ID，下载和上传在后台执行
+// 导入任务按前端上传规则（client_upload）写入 R2，不使用 ImgBB
+func CreateImportJobs(c *gin.Context) {
+	var req ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	var urls []string
+	for _, u := range append([]string{req.URL}, req.URLs...) {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请提供 url 或 urls 参数"})
+		return
+	}
+	if len(urls) > ImportMaxURLs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("单次最多提交 %d 个 URL", ImportMaxURLs)})
+		return
+	}
+	for _, u := range urls {
+		if err := service.CheckURL(u); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error() + ": " + u})
+			return
+		}
+	}
+	if req.MaxAttempts < 0 || req.MaxAttempts > service.MaxImportMaxAttempts {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("maxAttempts 范围为 1 到 %d", service.MaxImportMaxAttempts)})
+		return
+	}
+
+	expirationDays := -1
+	if req.ExpirationDays != nil && *req.ExpirationDays >= -1 {
+		expirationDays = *req.ExpirationDays
+	}
+	target, ok := bindUploadTarget(c, req.IDGroup, req.Pool)
+	if !ok {
+		return
+	}
+	uploader := uploadMetadata(c, "", service.UploadSourceURL, "").Uploader
+
+	jobs := make([]*store.ImportJob, 0, len(urls))
+	for _, u := range urls {
+		jobs = append(jobs, &store.ImportJob{
+			URL:            u,
+			AccountID:      target.AccountID,
+			Pool:           target.poolName(),
+			Path:           req.Path,
+			ExpirationDays: target.expirationDays(expirationDays),
+			TokenID:        c.GetString(middleware.ContextKeyTokenID),
+			Uploader:       uploader,
+			MaxAttempts:    req.MaxAttempts,
+		})
+	}
+	if err := service.SubmitImportJobs(jobs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := make([]*service.ImportJobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		if info, err := service.GetImportJob(job.ID); err == nil {
+			result = append(result, info)
+		}
+	}
+	c.JSON(http.StatusAccepted, gin.H{"jobs": result})
+}
+
+// GetImportJobs 获取导入任务列表，API Token 只能看到自己创建的任务
+func GetImportJobs(c *gin.Context) {
+	tokenID := ""
+	if c.GetString(middleware.ContextKeyAuthType) != middleware.AuthTypeJWT {
+		tokenID = c.GetString(middleware.ContextKeyTokenID)
+	}
+	jobs := service.ListImportJobs(tokenID)
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs, "total": len(jobs)})
+}
+
+// GetImportJob 获取导入任务状态和进度，完成后 result 与 /api/upload 的返回一致
+func GetImportJob(c *gin.Context) {
+	info, ok := findImportJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// ImportJobEvents 以 Server-Sent Events 推送导入任务进度，任务结束后关闭连接
+func ImportJobEvents(c *gin.Context) {
+	info, ok := findImportJob(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	ticker := time.NewTicker(importEventInterval)
+	defer ticker.Stop()
+	for {
+		c.SSEvent("progress", info)
+		c.Writer.Flush()
+		if info.Finished() {
+			return
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		var err error
+		if info, err = service.GetImportJob(info.ID); err != nil {
+			return
+		}
+	}
+}
+
+// RetryImportJob 重新执行失败或已取消的导入任务
+func RetryImportJob(c *gin.Context) {
+	if _, ok := findImportJob(c); !ok {
+		return
+	}
+
+	info, err := service.RetryImportJob(c.Param("id"))
+	if err != nil {
+		c.JSON(importJobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// CancelImportJob 取消等待中或执行中的导入任务
+func CancelImportJob(c *gin.Context) {
+	if _, ok := findImportJob(c); !ok {
+		return
+	}
+
+	info, err := service.CancelImportJob(c.Param("id"))
+	if err != nil {
+		c.JSON(importJobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// findImportJob 查找路径参数指定的导入任务，不存在或无权访问时返回 404
+func findImportJob(c *gin.Context) (*service.ImportJobInfo, bool) {
+	info, err := service.GetImportJob(c.Param("id"))
+	if err != nil || !canAccessUpload(c, info.TokenID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrImportJobNotFound.Error()})
+		return nil, false
+	}
+	return info, true
+}
+
+// importJobErrorStatus 导入任务错误对应的 HTTP 状态码
+func importJobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrImportJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrImportJobFinished), errors.Is(err, service.ErrImportJobNotRetryable):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
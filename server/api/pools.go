@@ -0,0 +1,322 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"fileflow/server/middleware"
+	"fileflow/server/service"
+	"fileflow/server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// poolNamePattern 账户池名称：字母、数字、点、下划线和连字符，便于在查询参数中引用
+var poolNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// PoolRequest 创建/更新账户池请求
+type PoolRequest struct {
+	Name              string             `json:"name" binding:"required"`
+	Description       string             `json:"description"`
+	Members           []store.PoolMember `json:"members"`           // 成员账户和优先级（数值小的优先）
+	PlacementStrategy string             `json:"placementStrategy"` // 池内的放置策略，为空时按 Token、系统设置选择
+	ExpirationDays    *int               `json:"expirationDays"`    // 默认到期天数，为空或 -1 使用系统设置，0 表示永久
+}
+
+// validatePoolRequest 校验名称、成员和放置策略
+func validatePoolRequest(req *PoolRequest) string {
+	if !poolNamePattern.MatchString(req.Name) {
+		return "账户池名称只能包含字母、数字、点、下划线和连字符，最长 64 个字符"
+	}
+	seen := make(map[string]bool, len(req.Members))
+	for _, m := range req.Members {
+		if _, err := store.GetAccountByID(m.AccountID); err != nil {
+			return "成员账户不存在: " + m.AccountID
+		}
+		if seen[m.AccountID] {
+			return "成员账户重复: " + m.AccountID
+		}
+		seen[m.AccountID] = true
+	}
+	if err := service.ValidatePlacementStrategy(req.PlacementStrategy); err != nil {
+		return err.Error()
+	}
+	if req.ExpirationDays != nil && *req.ExpirationDays < -1 {
+		return "默认到期天数无效，-1 表示使用系统设置，0 表示永久"
+	}
+	return ""
+}
+
+// poolFromRequest 构建账户池，未提供默认到期天数时使用系统设置
+func poolFromRequest(id string, req *PoolRequest) *store.Pool {
+	expirationDays := -1
+	if req.ExpirationDays != nil {
+		expirationDays = *req.ExpirationDays
+	}
+	return &store.Pool{
+		ID:                id,
+		Name:              req.Name,
+		Description:       req.Description,
+		Members:           req.Members,
+		PlacementStrategy: req.PlacementStrategy,
+		ExpirationDays:    expirationDays,
+	}
+}
+
+// GetPools 获取所有账户池
+func GetPools(c *gin.Context) {
+	c.JSON(http.StatusOK, store.GetPools())
+}
+
+// CreatePool 创建账户池
+func CreatePool(c *gin.Context) {
+	var req PoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	if msg := validatePoolRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if _, err := store.GetPoolByName(req.Name); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "账户池名称已存在: " + req.Name})
+		return
+	}
+
+	pool := poolFromRequest("", &req)
+	if err := store.CreatePool(pool); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, pool)
+}
+
+// UpdatePool 更新账户池，重命名后 Token 中引用的池名称同步更新
+func UpdatePool(c *gin.Context) {
+	id := c.Param("id")
+
+	if _, err := store.GetPoolByID(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req PoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	if msg := validatePoolRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if other, err := store.GetPoolByName(req.Name); err == nil && other.ID != id {
+		c.JSON(http.StatusConflict, gin.H{"error": "账户池名称已存在: " + req.Name})
+		return
+	}
+
+	pool := poolFromRequest(id, &req)
+	if err := store.UpdatePool(pool); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pool)
+}
+
+// DeletePool 删除账户池，仍被 Token 引用时返回 409
+func DeletePool(c *gin.Context) {
+	id := c.Param("id")
+
+	if _, err := store.GetPoolByID(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.DeletePool(id); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// uploadTarget 上传目标：指定账户、账户池或在所有账户中自动选择
+type uploadTarget struct {
+	AccountID string
+	Pool      *store.Pool
+}
+
+// poolName 账户池名称，未指定账户池时为空
+func (t uploadTarget) poolName() string {
+	if t.Pool == nil {
+		return ""
+	}
+	return t.Pool.Name
+}
+
+// naming 在命名参数中记录账户池，自动选择账户时只在池成员中选择
+func (t uploadTarget) naming(n service.KeyNaming) service.KeyNaming {
+	n.Pool = t.poolName()
+	return n
+}
+
+// expirationDays 解析到期天数：-1 时依次使用账户池的默认值和系统设置
+func (t uploadTarget) expirationDays(days int) int {
+	if days == -1 && t.Pool != nil && t.Pool.ExpirationDays >= 0 {
+		return t.Pool.ExpirationDays
+	}
+	return resolveExpirationDays(days)
+}
+
+// errPoolScope 访问 API Token 账户池范围之外的账户或账户池
+var errPoolScope = errors.New("Token 无权访问该账户或账户池")
+
+// tokenPools 当前 API Token 限定的账户池，后台登录和未限定的 Token 返回 nil
+func tokenPools(c *gin.Context) []string {
+	if c.GetString(middleware.ContextKeyAuthType) != middleware.AuthTypeToken {
+		return nil
+	}
+	t, err := store.GetTokenByID(c.GetString(middleware.ContextKeyTokenID))
+	if err != nil {
+		return nil
+	}
+	return t.Pools
+}
+
+// accountInScope 检查账户是否在 API Token 的账户池范围内
+func accountInScope(c *gin.Context, accountID string) bool {
+	pools := tokenPools(c)
+	if len(pools) == 0 {
+		return true
+	}
+	for _, name := range pools {
+		if pool, err := store.GetPoolByName(name); err == nil && pool.HasMember(accountID) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveUploadTarget 解析上传接口的 idGroup（取第一个账户ID）和 pool 参数
+// 两者都未提供时，限定了账户池的 API Token 写入其第一个账户池
+func resolveUploadTarget(c *gin.Context, idGroup, poolName string) (uploadTarget, int, error) {
+	accountID := getFirstID(idGroup)
+	if accountID != "" && poolName != "" {
+		return uploadTarget{}, http.StatusBadRequest, errors.New("idGroup 和 pool 参数不能同时提供")
+	}
+	if accountID != "" {
+		if !accountInScope(c, accountID) {
+			return uploadTarget{}, http.StatusForbidden, errPoolScope
+		}
+		return uploadTarget{AccountID: accountID}, 0, nil
+	}
+
+	pools := tokenPools(c)
+	if poolName == "" {
+		if len(pools) == 0 {
+			return uploadTarget{}, 0, nil
+		}
+		poolName = pools[0]
+	}
+	pool, err := store.GetPoolByName(poolName)
+	if err != nil {
+		return uploadTarget{}, http.StatusBadRequest, err
+	}
+	for _, name := range pools {
+		if name == pool.Name {
+			return uploadTarget{Pool: pool}, 0, nil
+		}
+	}
+	if len(pools) > 0 {
+		return uploadTarget{}, http.StatusForbidden, errPoolScope
+	}
+	return uploadTarget{Pool: pool}, 0, nil
+}
+
+// bindUploadTarget 从表单或查询参数解析上传目标，失败时写入错误响应
+func bindUploadTarget(c *gin.Context, idGroup, poolName string) (uploadTarget, bool) {
+	target, status, err := resolveUploadTarget(c, idGroup, poolName)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return uploadTarget{}, false
+	}
+	return target, true
+}
+
+// resolveFileAccount 解析单个文件操作的账户：idGroup 取第一个账户ID，
+// 只提供 pool 时在池成员中查找包含该文件的账户；失败时写入错误响应
+func resolveFileAccount(c *gin.Context, key string) (string, bool) {
+	accountID := getFirstID(c.Query("idGroup"))
+	poolName := c.Query("pool")
+	if (accountID == "" && poolName == "") || key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 idGroup（或 pool）或 key 参数"})
+		return "", false
+	}
+	if accountID != "" {
+		if !accountInScope(c, accountID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": errPoolScope.Error()})
+			return "", false
+		}
+		return accountID, true
+	}
+
+	target, ok := bindUploadTarget(c, "", poolName)
+	if !ok {
+		return "", false
+	}
+	accountID, err := service.LocateFile(c.Request.Context(), target.Pool.AccountIDs(), key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return accountID, true
+}
+
+// scopedAccountIDs 解析文件列表的账户范围：idGroup 为逗号分隔的账户ID，pool 为账户池名称
+// 返回 nil 表示所有账户，空列表表示没有可列出的账户；API Token 限定了账户池时只包含池成员
+func scopedAccountIDs(c *gin.Context, idGroup []string, poolName string) ([]string, int, error) {
+	if len(idGroup) > 0 && poolName != "" {
+		return nil, http.StatusBadRequest, errors.New("idGroup 和 pool 参数不能同时提供")
+	}
+	if len(idGroup) > 0 {
+		for _, id := range idGroup {
+			if !accountInScope(c, id) {
+				return nil, http.StatusForbidden, errPoolScope
+			}
+		}
+		return idGroup, 0, nil
+	}
+
+	pools := tokenPools(c)
+	if poolName != "" {
+		target, status, err := resolveUploadTarget(c, "", poolName)
+		if err != nil {
+			return nil, status, err
+		}
+		return target.Pool.AccountIDs(), 0, nil
+	}
+	if len(pools) == 0 {
+		return nil, 0, nil
+	}
+
+	// 限定账户池的 Token 未指定范围时列出所有池成员
+	seen := make(map[string]bool)
+	ids := []string{}
+	for _, name := range pools {
+		pool, err := store.GetPoolByName(name)
+		if err != nil {
+			continue
+		}
+		for _, id := range pool.AccountIDs() {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, 0, nil
+}
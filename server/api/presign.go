@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"fileflow/server/middleware"
+	"fileflow/server/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresignUploadRequest 申请预签名直传请求
+type PresignUploadRequest struct {
+	FileName       string `json:"fileName"`
+	ContentType    string `json:"contentType"`
+	Size           *int64 `json:"size" binding:"required"`
+	IDGroup        string `json:"idGroup"`
+	Pool           string `json:"pool"`
+	Path           string `json:"path"`
+	ExpirationDays *int   `json:"expirationDays"`
+	Multipart      bool   `json:"multipart"`
+}
+
+// PresignUpload 选择账户并返回预签名上传地址，客户端直接上传到 R2
+// 后台登录按前端上传规则（client_upload）选择账户，API Token 按 API 上传规则（api_upload）选择
+func PresignUpload(c *gin.Context) {
+	var req PresignUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	if *req.Size < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件大小无效"})
+		return
+	}
+
+	ext := filepath.Ext(req.FileName)
+	if ext == "" {
+		ext = service.ExtFromContentType(req.ContentType)
+	}
+
+	expirationDays := -1
+	if req.ExpirationDays != nil && *req.ExpirationDays >= -1 {
+		expirationDays = *req.ExpirationDays
+	}
+
+	dest, ok := bindUploadTarget(c, req.IDGroup, req.Pool)
+	if !ok {
+		return
+	}
+
+	target, err := service.CreatePresignedUpload(c.Request.Context(), service.PresignUploadOptions{
+		AccountID:      dest.AccountID,
+		Naming:         dest.naming(uploadKeyNaming(c, req.FileName, ext, req.Path)),
+		Metadata:       uploadMetadata(c, req.FileName, service.UploadSourcePresign, ""),
+		Size:           *req.Size,
+		ContentType:    req.ContentType,
+		FileName:       req.FileName,
+		ExpirationDays: dest.expirationDays(expirationDays),
+		TokenID:        c.GetString(middleware.ContextKeyTokenID),
+		ForClient:      c.GetString(middleware.ContextKeyAuthType) == middleware.AuthTypeJWT,
+		Multipart:      req.Multipart,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrKeyConflict):
+			status = http.StatusConflict
+		case errors.Is(err, service.ErrEncryptedDirectUpload):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrSpaceReserved):
+			status = http.StatusInsufficientStorage
+		case errors.Is(err, service.ErrCircuitOpen):
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, target)
+}
+
+// CompletePresignedUpload 确认直传完成，返回与 /api/upload 相同的上传结果
+func CompletePresignedUpload(c *gin.Context) {
+	id := c.Param("id")
+	record, err := service.GetPresignedUpload(id)
+	if err != nil || !canAccessUpload(c, record.TokenID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrPresignedUploadNotFound.Error()})
+		return
+	}
+
+	result, err := service.CompletePresignedUpload(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, service.ErrPresignedUploadNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrUploadSessionLocked):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// CancelPresignedUpload 取消直传预留
+func CancelPresignedUpload(c *gin.Context) {
+	id := c.Param("id")
+	record, err := service.GetPresignedUpload(id)
+	if err != nil || !canAccessUpload(c, record.TokenID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrPresignedUploadNotFound.Error()})
+		return
+	}
+
+	if err := service.CancelPresignedUpload(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrUploadSessionLocked) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已取消"})
+}
@@ -3,16 +3,20 @@ package api
 import (
 	"io"
 	"net/http"
+	"regexp"
 	"time"
 
+	"fileflow/server/service"
 	"fileflow/server/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-var proxyClient = &http.Client{
-	Timeout: 60 * time.Second,
-}
+// proxyClient 禁止连接内网地址，不跟随重定向（3xx 原样返回给客户端）
+var proxyClient = service.NewSafeHTTPClient(60 * time.Second)
+
+// r2SubdomainPattern r2.dev 子域名只能是单个 DNS 标签，防止拼接出其他主机
+var r2SubdomainPattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
 
 // Proxy 反向代理 R2 文件
 func Proxy(c *gin.Context) {
@@ -25,11 +29,18 @@ func Proxy(c *gin.Context) {
 	subdomain := c.Param("subdomain")
 	path := c.Param("path")
 
-	if subdomain == "" || path == "" {
+	if subdomain == "" || path == "" || !r2SubdomainPattern.MatchString(subdomain) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
 
+	// B 类操作达到配额的账户不再转发读取，避免超出免费额度；无法对应到账户的子域名照常转发
+	acc := service.FindProxyAccount(subdomain)
+	if acc != nil && acc.IsOverReadOps() {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "该账户本月的读取操作次数已达到配额"})
+		return
+	}
+
 	// 构建原始 R2 URL
 	targetURL := "https://" + subdomain + ".r2.dev" + path
 
@@ -59,6 +70,11 @@ func Proxy(c *gin.Context) {
 	}
 	defer resp.Body.Close()
 
+	// r2.dev 公开访问的每次读取按 B 类操作计费
+	if acc != nil {
+		service.RecordProxyRead(acc.ID)
+	}
+
 	// 转发响应头
 	for key, values := range resp.Header {
 		for _, value := range values {
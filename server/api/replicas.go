@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"fileflow/server/service"
+	"fileflow/server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetReplicaSets 获取副本集列表及各状态数量，可按 status 筛选
+func GetReplicaSets(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", store.ReplicaSetHealthy, store.ReplicaSetDegraded, store.ReplicaSetLost:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的副本集状态: " + status})
+		return
+	}
+
+	sets, summary := service.ListReplicaSets(status)
+	c.JSON(http.StatusOK, gin.H{
+		"replicaSets":       sets,
+		"summary":           summary,
+		"replicationFactor": store.GetSettings().ReplicationFactor,
+	})
+}
+
+// RepairReplicaSets 在后台校验所有副本并补齐缺失的副本
+func RepairReplicaSets(c *gin.Context) {
+	if err := service.StartReplicaRepair(); err != nil {
+		if errors.Is(err, service.ErrReplicaRepairRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "副本修复任务已开始"})
+}
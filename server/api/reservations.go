@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	"fileflow/server/service"
+	"fileflow/server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetReservations 获取进行中上传的容量预留及各账户的预留合计
+func GetReservations(c *gin.Context) {
+	reservations, summary := service.ListReservations()
+	c.JSON(http.StatusOK, gin.H{
+		"reservations": reservations,
+		"summary":      summary,
+	})
+}
+
+// DeleteReservation 手动释放容量预留，不影响进行中的上传本身
+func DeleteReservation(c *gin.Context) {
+	if !store.ReleaseReservation(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "预留不存在或已过期"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "预留已释放"})
+}
@@ -26,8 +26,35 @@ func SetupRouter(r *gin.Engine) {
 		protected.GET("/files", middleware.RequirePermission("read"), GetFiles)
 		protected.GET("/imgbb-files", middleware.RequirePermission("read"), GetImgBBFiles)
 		protected.POST("/upload", middleware.RequirePermission("write"), Upload)
+		protected.POST("/upload/batch", middleware.RequirePermission("write"), BatchUpload)
+		protected.POST("/upload/archive", middleware.RequirePermission("write"), ArchiveUpload)
+		protected.GET("/upload/key-preview", middleware.RequirePermission("write"), PreviewUploadKey)
+
+		// 可恢复上传（tus 1.0 协议）
+		protected.POST("/upload/tus", middleware.RequirePermission("write"), CreateTusUpload)
+		protected.HEAD("/upload/tus/:id", middleware.RequirePermission("write"), HeadTusUpload)
+		protected.GET("/upload/tus/:id", middleware.RequirePermission("write"), GetTusUpload)
+		protected.PATCH("/upload/tus/:id", middleware.RequirePermission("write"), PatchTusUpload)
+		protected.DELETE("/upload/tus/:id", middleware.RequirePermission("write"), DeleteTusUpload)
+
+		// 预签名直传（客户端直接上传到 R2）
+		protected.POST("/upload/presign", middleware.RequirePermission("write"), PresignUpload)
+		protected.POST("/upload/presign/:id/complete", middleware.RequirePermission("write"), CompletePresignedUpload)
+		protected.DELETE("/upload/presign/:id", middleware.RequirePermission("write"), CancelPresignedUpload)
+
+		// 远程 URL 导入任务（后台下载后上传）
+		protected.POST("/upload/import", middleware.RequirePermission("write"), CreateImportJobs)
+		protected.GET("/upload/import", middleware.RequirePermission("write"), GetImportJobs)
+		protected.GET("/upload/import/:id", middleware.RequirePermission("write"), GetImportJob)
+		protected.GET("/upload/import/:id/events", middleware.RequirePermission("write"), ImportJobEvents)
+		protected.POST("/upload/import/:id/retry", middleware.RequirePermission("write"), RetryImportJob)
+		protected.DELETE("/upload/import/:id", middleware.RequirePermission("write"), CancelImportJob)
+
 		protected.DELETE("/file", middleware.RequirePermission("delete"), DeleteFile)
 		protected.GET("/link", middleware.RequirePermission("read"), GetLink)
+		protected.GET("/file/stat", middleware.RequirePermission("read"), StatFile)
+		protected.GET("/file/download", middleware.RequirePermission("read"), DownloadFile)
+		protected.PUT("/file/metadata", middleware.RequirePermission("write"), UpdateFileMetadata)
 	}
 
 	// 管理员专用接口（仅 JWT）
@@ -44,12 +71,20 @@ func SetupRouter(r *gin.Engine) {
 		admin.POST("/accounts/sync", SyncAccounts)
 		admin.POST("/accounts/:id/clear", ClearBucket)
 		admin.POST("/accounts/delete-old-files", DeleteOldFiles)
+		admin.GET("/providers", GetProviders)
 
 		// Token 管理
 		admin.GET("/tokens", GetTokens)
 		admin.POST("/tokens", CreateToken)
+		admin.PUT("/tokens/:id", UpdateToken)
 		admin.DELETE("/tokens/:id", DeleteToken)
 
+		// 账户池管理
+		admin.GET("/pools", GetPools)
+		admin.POST("/pools", CreatePool)
+		admin.PUT("/pools/:id", UpdatePool)
+		admin.DELETE("/pools/:id", DeletePool)
+
 		// WebDAV 凭证管理
 		admin.GET("/webdav-credentials", GetWebDAVCredentials)
 		admin.POST("/webdav-credentials", CreateWebDAVCredential)
@@ -63,5 +98,21 @@ func SetupRouter(r *gin.Engine) {
 		// 文件到期管理
 		admin.GET("/file-expirations", GetFileExpirations)
 		admin.DELETE("/file-expirations/:id", DeleteFileExpirationByID)
+
+		// Webhook 管理
+		admin.GET("/webhooks", GetWebhooks)
+		admin.POST("/webhooks", CreateWebhook)
+		admin.PUT("/webhooks/:id", UpdateWebhook)
+		admin.DELETE("/webhooks/:id", DeleteWebhook)
+		admin.GET("/webhooks/:id/deliveries", GetWebhookDeliveries)
+		admin.POST("/webhooks/:id/test", TestWebhook)
+
+		// 多副本管理
+		admin.GET("/replicas", GetReplicaSets)
+		admin.POST("/replicas/repair", RepairReplicaSets)
+
+		// 容量预留
+		admin.GET("/reservations", GetReservations)
+		admin.DELETE("/reservations/:id", DeleteReservation)
 	}
 }
@@ -47,6 +47,73 @@ func UpdateSettings(c *gin.Context) {
 		settings.ExpirationCheckMinutes = 1440
 	}
 
+	// 验证存储路径模板和冲突策略
+	if err := service.ValidateKeyTemplate(settings.KeyTemplate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if settings.KeyCollision == "" {
+		settings.KeyCollision = store.KeyCollisionSuffix
+	}
+	if err := service.ValidateKeyCollision(settings.KeyCollision); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 验证智能上传的放置策略
+	if settings.PlacementStrategy == "" {
+		settings.PlacementStrategy = store.PlacementLeastUsed
+	}
+	if err := service.ValidatePlacementStrategy(settings.PlacementStrategy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 验证 URL 上传安全策略（下载大小 1 MB - 100 GB，重定向 1-20 次）
+	if settings.URLMaxDownloadMB <= 0 {
+		settings.URLMaxDownloadMB = store.DefaultURLMaxDownloadMB
+	}
+	if settings.URLMaxDownloadMB > 102400 {
+		settings.URLMaxDownloadMB = 102400
+	}
+	if settings.URLMaxRedirects <= 0 {
+		settings.URLMaxRedirects = store.DefaultURLMaxRedirects
+	}
+	if settings.URLMaxRedirects > 20 {
+		settings.URLMaxRedirects = 20
+	}
+
+	// 验证缩略图设置（尺寸 16-4096 像素，最多 5 个；JPEG 质量 1-100）
+	if settings.ThumbnailSizes == "" {
+		settings.ThumbnailSizes = store.DefaultThumbnailSizes
+	}
+	if _, err := service.ParseThumbnailSizes(settings.ThumbnailSizes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if settings.ThumbnailQuality <= 0 {
+		settings.ThumbnailQuality = store.DefaultThumbnailQuality
+	}
+	if settings.ThumbnailQuality > 100 {
+		settings.ThumbnailQuality = 100
+	}
+
+	// 验证副本数（1-5）
+	if settings.ReplicationFactor <= 0 {
+		settings.ReplicationFactor = store.DefaultReplicationFactor
+	}
+	if settings.ReplicationFactor > store.MaxReplicationFactor {
+		settings.ReplicationFactor = store.MaxReplicationFactor
+	}
+
+	// 验证操作次数告警阈值（1-100）
+	if settings.OpsAlertPercent <= 0 {
+		settings.OpsAlertPercent = store.DefaultOpsAlertPercent
+	}
+	if settings.OpsAlertPercent > 100 {
+		settings.OpsAlertPercent = 100
+	}
+
 	if err := store.UpdateSettings(settings); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -3,24 +3,60 @@ package api
 import (
 	"net/http"
 
+	"fileflow/server/service"
 	"fileflow/server/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-// TokenRequest 创建 Token 请求
+// TokenRequest 创建/更新 Token 请求
 type TokenRequest struct {
-	Name        string   `json:"name" binding:"required"`
-	Permissions []string `json:"permissions" binding:"required"`
+	Name               string   `json:"name" binding:"required"`
+	Permissions        []string `json:"permissions" binding:"required"`
+	KeyTemplate        string   `json:"keyTemplate"`        // 存储路径模板，为空时使用账户或系统设置
+	StripImageMetadata bool     `json:"stripImageMetadata"` // 上传的图片移除 EXIF、XMP 和 GPS 信息
+	PlacementStrategy  string   `json:"placementStrategy"`  // 智能上传的放置策略，为空时使用系统设置
+	Pools              []string `json:"pools"`              // 允许访问的账户池，为空时不限制，第一个为默认上传目标
+}
+
+// validateTokenRequest 校验权限值、路径模板、放置策略和账户池
+func validateTokenRequest(req *TokenRequest) string {
+	validPerms := map[string]bool{"read": true, "write": true, "delete": true}
+	for _, p := range req.Permissions {
+		if !validPerms[p] {
+			return "无效的权限值: " + p
+		}
+	}
+	if err := service.ValidateKeyTemplate(req.KeyTemplate); err != nil {
+		return err.Error()
+	}
+	if err := service.ValidatePlacementStrategy(req.PlacementStrategy); err != nil {
+		return err.Error()
+	}
+	seen := make(map[string]bool, len(req.Pools))
+	for _, name := range req.Pools {
+		if _, err := store.GetPoolByName(name); err != nil {
+			return err.Error()
+		}
+		if seen[name] {
+			return "账户池重复: " + name
+		}
+		seen[name] = true
+	}
+	return ""
 }
 
 // TokenResponse Token 响应
 type TokenResponse struct {
-	ID          string   `json:"id"`
-	Name        string   `json:"name"`
-	Token       string   `json:"token"`
-	Permissions []string `json:"permissions"`
-	CreatedAt   string   `json:"createdAt"`
+	ID                 string   `json:"id"`
+	Name               string   `json:"name"`
+	Token              string   `json:"token"`
+	Permissions        []string `json:"permissions"`
+	KeyTemplate        string   `json:"keyTemplate"`
+	StripImageMetadata bool     `json:"stripImageMetadata"`
+	PlacementStrategy  string   `json:"placementStrategy"`
+	Pools              []string `json:"pools"`
+	CreatedAt          string   `json:"createdAt"`
 }
 
 // GetTokens 获取所有 Token
@@ -30,11 +66,15 @@ func GetTokens(c *gin.Context) {
 	var result []TokenResponse
 	for _, t := range tokens {
 		result = append(result, TokenResponse{
-			ID:          t.ID,
-			Name:        t.Name,
-			Token:       t.Token,
-			Permissions: t.Permissions,
-			CreatedAt:   t.CreatedAt,
+			ID:                 t.ID,
+			Name:               t.Name,
+			Token:              t.Token,
+			Permissions:        t.Permissions,
+			KeyTemplate:        t.KeyTemplate,
+			StripImageMetadata: t.StripImageMetadata,
+			PlacementStrategy:  t.PlacementStrategy,
+			Pools:              t.Pools,
+			CreatedAt:          t.CreatedAt,
 		})
 	}
 
@@ -49,18 +89,19 @@ func CreateToken(c *gin.Context) {
 		return
 	}
 
-	// 验证权限值
-	validPerms := map[string]bool{"read": true, "write": true, "delete": true}
-	for _, p := range req.Permissions {
-		if !validPerms[p] {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的权限值: " + p})
-			return
-		}
+	// 验证权限值、路径模板、放置策略和账户池
+	if msg := validateTokenRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
 	}
 
 	token := &store.Token{
-		Name:        req.Name,
-		Permissions: req.Permissions,
+		Name:               req.Name,
+		Permissions:        req.Permissions,
+		KeyTemplate:        req.KeyTemplate,
+		StripImageMetadata: req.StripImageMetadata,
+		PlacementStrategy:  req.PlacementStrategy,
+		Pools:              req.Pools,
 	}
 
 	if err := store.CreateToken(token); err != nil {
@@ -70,11 +111,57 @@ func CreateToken(c *gin.Context) {
 
 	// 创建时返回完整的 token 值
 	c.JSON(http.StatusCreated, TokenResponse{
-		ID:          token.ID,
-		Name:        token.Name,
-		Token:       token.Token,
-		Permissions: token.Permissions,
-		CreatedAt:   token.CreatedAt,
+		ID:                 token.ID,
+		Name:               token.Name,
+		Token:              token.Token,
+		Permissions:        token.Permissions,
+		KeyTemplate:        token.KeyTemplate,
+		StripImageMetadata: token.StripImageMetadata,
+		PlacementStrategy:  token.PlacementStrategy,
+		Pools:              token.Pools,
+		CreatedAt:          token.CreatedAt,
+	})
+}
+
+// UpdateToken 更新 Token 的名称、权限、路径模板、图片隐私设置、放置策略和账户池
+func UpdateToken(c *gin.Context) {
+	id := c.Param("id")
+
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	if msg := validateTokenRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	token := &store.Token{
+		ID:                 id,
+		Name:               req.Name,
+		Permissions:        req.Permissions,
+		KeyTemplate:        req.KeyTemplate,
+		StripImageMetadata: req.StripImageMetadata,
+		PlacementStrategy:  req.PlacementStrategy,
+		Pools:              req.Pools,
+	}
+	if err := store.UpdateToken(token); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		ID:                 token.ID,
+		Name:               token.Name,
+		Token:              token.Token,
+		Permissions:        token.Permissions,
+		KeyTemplate:        token.KeyTemplate,
+		StripImageMetadata: token.StripImageMetadata,
+		PlacementStrategy:  token.PlacementStrategy,
+		Pools:              token.Pools,
+		CreatedAt:          token.CreatedAt,
 	})
 }
 
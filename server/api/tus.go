@@ -0,0 +1,304 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fileflow/server/middleware"
+	"fileflow/server/service"
+	"fileflow/server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// TusVersion 支持的 tus 协议版本
+	TusVersion = "1.0.0"
+	// TusExtensions 支持的 tus 协议扩展
+	TusExtensions = "creation,termination,expiration"
+)
+
+// TusSessionResponse 可恢复上传会话状态
+type TusSessionResponse struct {
+	ID        string                `json:"id"`
+	AccountID string                `json:"accountId"`
+	Key       string                `json:"key"`
+	FileName  string                `json:"fileName"`
+	Size      int64                 `json:"size"`
+	Offset    int64                 `json:"offset"`
+	Completed bool                  `json:"completed"`
+	ExpiresAt string                `json:"expiresAt"`
+	Result    *service.UploadResult `json:"result,omitempty"`
+}
+
+// parseTusMetadata 解析 Upload-Metadata 头（逗号分隔的 "key base64(value)" 列表）
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, errors.New("Upload-Metadata 格式错误")
+		}
+		value := ""
+		if len(parts) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, errors.New("Upload-Metadata 值必须为 Base64 编码")
+			}
+			value = string(decoded)
+		}
+		metadata[parts[0]] = value
+	}
+	return metadata, nil
+}
+
+// tusError 返回错误响应，携带协议版本头
+func tusError(c *gin.Context, status int, message string) {
+	c.Header("Tus-Resumable", TusVersion)
+	if c.Request.Method == http.MethodHead {
+		c.Status(status)
+		return
+	}
+	c.JSON(status, gin.H{"error": message})
+}
+
+// checkTusVersion 校验客户端的 Tus-Resumable 头
+func checkTusVersion(c *gin.Context) bool {
+	if c.GetHeader("Tus-Resumable") != TusVersion {
+		c.Header("Tus-Version", TusVersion)
+		tusError(c, http.StatusPreconditionFailed, "不支持的 tus 协议版本")
+		return false
+	}
+	return true
+}
+
+// loadTusSession 获取上传会话并检查访问权限
+// API Token 只能访问自己创建的会话，后台登录可以访问所有会话
+func loadTusSession(c *gin.Context) (*store.UploadSession, bool) {
+	sess, err := service.GetResumableUpload(c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrUploadSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		tusError(c, status, err.Error())
+		return nil, false
+	}
+
+	if !canAccessUpload(c, sess.TokenID) {
+		tusError(c, http.StatusNotFound, service.ErrUploadSessionNotFound.Error())
+		return nil, false
+	}
+	return sess, true
+}
+
+// canAccessUpload 检查当前调用方是否可以访问指定 Token 创建的上传会话或直传预留
+func canAccessUpload(c *gin.Context, tokenID string) bool {
+	if c.GetString(middleware.ContextKeyAuthType) == middleware.AuthTypeJWT {
+		return true
+	}
+	return tokenID == c.GetString(middleware.ContextKeyTokenID)
+}
+
+// setTusOffsetHeaders 设置偏移量和过期时间响应头
+func setTusOffsetHeaders(c *gin.Context, sess *store.UploadSession) {
+	c.Header("Tus-Resumable", TusVersion)
+	c.Header("Upload-Offset", strconv.FormatInt(sess.Offset, 10))
+	if expiresAt, err := time.Parse(time.RFC3339, sess.ExpiresAt); err == nil {
+		c.Header("Upload-Expires", expiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// CreateTusUpload 创建可恢复上传（tus creation 扩展）
+// Upload-Metadata 支持 filename、filetype、idGroup、pool、path、expirationDays，含义与 /api/upload 一致
+func CreateTusUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		tusError(c, http.StatusBadRequest, "不支持延迟指定文件大小，请提供 Upload-Length")
+		return
+	}
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		tusError(c, http.StatusBadRequest, "Upload-Length 无效")
+		return
+	}
+
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		tusError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fileName := metadata["filename"]
+	contentType := metadata["filetype"]
+	ext := filepath.Ext(fileName)
+	if ext == "" {
+		ext = service.ExtFromContentType(contentType)
+	}
+
+	target, status, err := resolveUploadTarget(c, metadata["idGroup"], metadata["pool"])
+	if err != nil {
+		tusError(c, status, err.Error())
+		return
+	}
+
+	sess, err := service.CreateResumableUpload(c.Request.Context(), service.ResumableUploadOptions{
+		AccountID:      target.AccountID,
+		Naming:         target.naming(uploadKeyNaming(c, fileName, ext, metadata["path"])),
+		Metadata:       uploadMetadata(c, fileName, service.UploadSourceTus, ""),
+		Size:           size,
+		ContentType:    contentType,
+		FileName:       fileName,
+		ExpirationDays: target.expirationDays(parseExpirationDays(metadata["expirationDays"])),
+		TokenID:        c.GetString(middleware.ContextKeyTokenID),
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrKeyConflict):
+			status = http.StatusConflict
+		case errors.Is(err, service.ErrEncryptedDirectUpload):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrSpaceReserved):
+			status = http.StatusInsufficientStorage
+		case errors.Is(err, service.ErrCircuitOpen):
+			status = http.StatusServiceUnavailable
+		}
+		tusError(c, status, err.Error())
+		return
+	}
+
+	setTusOffsetHeaders(c, sess)
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+sess.ID)
+	c.Status(http.StatusCreated)
+}
+
+// HeadTusUpload 查询可恢复上传的当前偏移量
+func HeadTusUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	sess, ok := loadTusSession(c)
+	if !ok {
+		return
+	}
+
+	setTusOffsetHeaders(c, sess)
+	c.Header("Upload-Length", strconv.FormatInt(sess.Size, 10))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// PatchTusUpload 从指定偏移量继续上传数据
+// 不支持 tus checksum 扩展（Upload-Checksum 头被忽略），完成的上传也不参与 /api/upload 的 SHA-256/CRC32C 校验和内容去重：
+// 数据按分片直接写入存储桶，服务端没有完整文件的哈希，需要校验时由客户端在上传后自行比对
+func PatchTusUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		tusError(c, http.StatusUnsupportedMediaType, "Content-Type 必须为 application/offset+octet-stream")
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		tusError(c, http.StatusBadRequest, "Upload-Offset 无效")
+		return
+	}
+
+	sess, ok := loadTusSession(c)
+	if !ok {
+		return
+	}
+	if c.Request.ContentLength > 0 && offset+c.Request.ContentLength > sess.Size {
+		tusError(c, http.StatusRequestEntityTooLarge, "上传数据超出 Upload-Length")
+		return
+	}
+
+	sess, err = service.WriteResumableUpload(c.Request.Context(), sess.ID, offset, c.Request.Body)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrUploadSessionNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrUploadOffsetMismatch), errors.Is(err, service.ErrUploadSessionLocked):
+			status = http.StatusConflict
+		}
+		if sess != nil {
+			setTusOffsetHeaders(c, sess)
+		}
+		tusError(c, status, err.Error())
+		return
+	}
+
+	setTusOffsetHeaders(c, sess)
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteTusUpload 终止可恢复上传（tus termination 扩展）
+func DeleteTusUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	sess, ok := loadTusSession(c)
+	if !ok {
+		return
+	}
+
+	if err := service.TerminateResumableUpload(sess.ID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrUploadSessionLocked) {
+			status = http.StatusConflict
+		}
+		tusError(c, status, err.Error())
+		return
+	}
+
+	c.Header("Tus-Resumable", TusVersion)
+	c.Status(http.StatusNoContent)
+}
+
+// GetTusUpload 获取可恢复上传的状态，完成后返回与 /api/upload 相同的上传结果
+func GetTusUpload(c *gin.Context) {
+	sess, ok := loadTusSession(c)
+	if !ok {
+		return
+	}
+
+	resp := TusSessionResponse{
+		ID:        sess.ID,
+		AccountID: sess.AccountID,
+		Key:       sess.FileKey,
+		FileName:  sess.FileName,
+		Size:      sess.Size,
+		Offset:    sess.Offset,
+		Completed: sess.Completed,
+		ExpiresAt: sess.ExpiresAt,
+	}
+	if sess.Completed {
+		result, err := service.ResumableUploadResult(c.Request.Context(), sess)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		resp.Result = result
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
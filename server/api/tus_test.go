@@ -0,0 +1,362 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fileflow/server/middleware"
+	"fileflow/server/service"
+	"fileflow/server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tusTestBucket 内存中的 S3 服务，只实现 tus 上传用到的分片上传接口（路径风格）
+type tusTestBucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	keys    map[string]string
+	nextID  int
+}
+
+func newTusTestBucket(t *testing.T) (*tusTestBucket, *httptest.Server) {
+	t.Helper()
+	b := &tusTestBucket{objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}, keys: map[string]string{}}
+	srv := httptest.NewServer(http.HandlerFunc(b.serve))
+	t.Cleanup(srv.Close)
+	return b, srv
+}
+
+func (b *tusTestBucket) object(key string) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, ok := b.objects[key]
+	return data, ok
+}
+
+func (b *tusTestBucket) serve(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	q := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		b.nextID++
+		id := fmt.Sprintf("upload-%d", b.nextID)
+		b.uploads[id] = map[int][]byte{}
+		b.keys[id] = key
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, id)
+
+	case r.Method == http.MethodPut && q.Has("partNumber"):
+		parts := b.uploads[q.Get("uploadId")]
+		if parts == nil {
+			http.Error(w, "<Error><Code>NoSuchUpload</Code></Error>", http.StatusNotFound)
+			return
+		}
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		parts[n] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, n))
+
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts := b.uploads[q.Get("uploadId")]
+		if parts == nil {
+			http.Error(w, "<Error><Code>NoSuchUpload</Code></Error>", http.StatusNotFound)
+			return
+		}
+		var req struct {
+			Parts []struct {
+				PartNumber int
+			} `xml:"Part"`
+		}
+		xml.Unmarshal(body, &req)
+		numbers := make([]int, 0, len(req.Parts))
+		for _, p := range req.Parts {
+			numbers = append(numbers, p.PartNumber)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, n := range numbers {
+			data = append(data, parts[n]...)
+		}
+		b.objects[key] = data
+		delete(b.uploads, q.Get("uploadId"))
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Key>%s</Key><ETag>"done"</ETag></CompleteMultipartUploadResult>`, key)
+
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		delete(b.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodHead:
+		data, ok := b.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+
+	default:
+		http.Error(w, "<Error><Code>NotImplemented</Code></Error>", http.StatusNotImplemented)
+	}
+}
+
+// tusTestEnv 测试用账户和 tus 路由，调用方以后台登录身份访问
+type tusTestEnv struct {
+	bucket    *tusTestBucket
+	accountID string
+	router    *gin.Engine
+}
+
+func newTusTestEnv(t *testing.T) *tusTestEnv {
+	t.Helper()
+	bucket, srv := newTusTestBucket(t)
+	acc := &store.Account{
+		Name:            "tus-" + t.Name(),
+		Provider:        store.ProviderGeneric,
+		Endpoint:        srv.URL,
+		PathStyle:       true,
+		AccessKeyId:     "test",
+		SecretAccessKey: "test",
+		BucketName:      "bucket",
+		IsActive:        true,
+		Permissions:     store.AccountPermissions{ClientUpload: true},
+	}
+	if err := store.CreateAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.DeleteAccount(acc.ID) })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	tus := r.Group("/api/upload/tus", func(c *gin.Context) {
+		c.Set(middleware.ContextKeyAuthType, middleware.AuthTypeJWT)
+		c.Set(middleware.ContextKeyUser, "admin")
+	})
+	tus.POST("", CreateTusUpload)
+	tus.HEAD("/:id", HeadTusUpload)
+	tus.GET("/:id", GetTusUpload)
+	tus.PATCH("/:id", PatchTusUpload)
+	tus.DELETE("/:id", DeleteTusUpload)
+
+	return &tusTestEnv{bucket: bucket, accountID: acc.ID, router: r}
+}
+
+func (e *tusTestEnv) do(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+// create 创建指定大小的上传会话，返回会话地址
+func (e *tusTestEnv) create(t *testing.T, size int64) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/upload/tus", nil)
+	req.Header.Set("Tus-Resumable", TusVersion)
+	req.Header.Set("Upload-Length", strconv.FormatInt(size, 10))
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("file.bin"))+
+		",idGroup "+base64.StdEncoding.EncodeToString([]byte(e.accountID)))
+	w := e.do(req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建上传会话返回 %d: %s", w.Code, w.Body.String())
+	}
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, "/api/upload/tus/") {
+		t.Fatalf("Location %q 无效", location)
+	}
+	t.Cleanup(func() { service.TerminateResumableUpload(strings.TrimPrefix(location, "/api/upload/tus/")) })
+	return location
+}
+
+func tusPatchRequest(location string, offset int64, body io.Reader) *http.Request {
+	req := httptest.NewRequest(http.MethodPatch, location, body)
+	req.Header.Set("Tus-Resumable", TusVersion)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	return req
+}
+
+func (e *tusTestEnv) patch(location string, offset int64, data []byte) *httptest.ResponseRecorder {
+	return e.do(tusPatchRequest(location, offset, bytes.NewReader(data)))
+}
+
+func tusTestData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+func TestTusRejectsUnsupportedVersion(t *testing.T) {
+	env := newTusTestEnv(t)
+	location := env.create(t, 10)
+
+	for _, version := range []string{"", "0.2.2"} {
+		for _, method := range []string{http.MethodPost, http.MethodHead, http.MethodPatch, http.MethodDelete} {
+			path := location
+			if method == http.MethodPost {
+				path = "/api/upload/tus"
+			}
+			req := httptest.NewRequest(method, path, nil)
+			if version != "" {
+				req.Header.Set("Tus-Resumable", version)
+			}
+			req.Header.Set("Content-Type", "application/offset+octet-stream")
+			req.Header.Set("Upload-Offset", "0")
+			req.Header.Set("Upload-Length", "10")
+
+			w := env.do(req)
+			if w.Code != http.StatusPreconditionFailed {
+				t.Fatalf("%s Tus-Resumable=%q 返回 %d，应为 412", method, version, w.Code)
+			}
+			if w.Header().Get("Tus-Version") != TusVersion {
+				t.Fatalf("%s 412 响应缺少 Tus-Version 头", method)
+			}
+		}
+	}
+}
+
+func TestTusPatchOffsetMismatch(t *testing.T) {
+	env := newTusTestEnv(t)
+	location := env.create(t, 100)
+	data := tusTestData(100)
+
+	if w := env.patch(location, 10, data[10:20]); w.Code != http.StatusConflict {
+		t.Fatalf("偏移量不一致时返回 %d，应为 409", w.Code)
+	} else if w.Header().Get("Upload-Offset") != "0" {
+		t.Fatalf("409 响应的 Upload-Offset 为 %q，应返回服务端偏移量 0", w.Header().Get("Upload-Offset"))
+	}
+
+	if w := env.patch(location, 0, data[:40]); w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH 返回 %d: %s", w.Code, w.Body.String())
+	}
+	// 重复发送已写入的数据
+	if w := env.patch(location, 0, data[:40]); w.Code != http.StatusConflict {
+		t.Fatalf("重复发送时返回 %d，应为 409", w.Code)
+	} else if w.Header().Get("Upload-Offset") != "40" {
+		t.Fatalf("409 响应的 Upload-Offset 为 %q，应为 40", w.Header().Get("Upload-Offset"))
+	}
+}
+
+func TestTusPatchBeyondLength(t *testing.T) {
+	env := newTusTestEnv(t)
+	location := env.create(t, 100)
+	data := tusTestData(101)
+
+	if w := env.patch(location, 0, data); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("超出 Upload-Length 时返回 %d，应为 413", w.Code)
+	}
+	if w := env.patch(location, 0, data[:60]); w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH 返回 %d: %s", w.Code, w.Body.String())
+	}
+	if w := env.patch(location, 60, data[60:]); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("续传超出 Upload-Length 时返回 %d，应为 413", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodHead, location, nil)
+	req.Header.Set("Tus-Resumable", TusVersion)
+	if w := env.do(req); w.Header().Get("Upload-Offset") != "60" {
+		t.Fatalf("413 后 Upload-Offset 为 %q，应保持 60", w.Header().Get("Upload-Offset"))
+	}
+}
+
+// 同一会话的 PATCH 正在写入时，其他 PATCH 和 DELETE 返回 409
+func TestTusSessionLockConflict(t *testing.T) {
+	env := newTusTestEnv(t)
+	location := env.create(t, 100)
+	data := tusTestData(100)
+
+	pr, pw := io.Pipe()
+	req := tusPatchRequest(location, 0, pr)
+	req.ContentLength = int64(len(data))
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- env.do(req) }()
+
+	// 写入返回说明处理中的请求已经持有会话锁并开始读取数据
+	if _, err := pw.Write(data[:30]); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := env.patch(location, 0, data[:10]); w.Code != http.StatusConflict {
+		t.Fatalf("会话被占用时 PATCH 返回 %d，应为 409", w.Code)
+	}
+	del := httptest.NewRequest(http.MethodDelete, location, nil)
+	del.Header.Set("Tus-Resumable", TusVersion)
+	if w := env.do(del); w.Code != http.StatusConflict {
+		t.Fatalf("会话被占用时 DELETE 返回 %d，应为 409", w.Code)
+	}
+
+	pw.Close()
+	select {
+	case w := <-done:
+		if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "30" {
+			t.Fatalf("中断的 PATCH 返回 %d, Upload-Offset=%q，应为 204 和 30", w.Code, w.Header().Get("Upload-Offset"))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("PATCH 请求未结束")
+	}
+
+	// 锁释放后可以从已保存的偏移量继续
+	if w := env.patch(location, 30, data[30:50]); w.Code != http.StatusNoContent {
+		t.Fatalf("锁释放后 PATCH 返回 %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// 分多次上传超过一个分片的数据，完成后 GET 返回上传结果
+func TestTusCompletedSessionResult(t *testing.T) {
+	env := newTusTestEnv(t)
+	size := int(service.UploadPartSize) + 100
+	location := env.create(t, int64(size))
+	data := tusTestData(size)
+
+	for _, chunk := range [][2]int{{0, 1000}, {1000, size - 50}, {size - 50, size}} {
+		w := env.patch(location, int64(chunk[0]), data[chunk[0]:chunk[1]])
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("PATCH %d-%d 返回 %d: %s", chunk[0], chunk[1], w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Upload-Offset"); got != strconv.Itoa(chunk[1]) {
+			t.Fatalf("PATCH %d-%d 后 Upload-Offset 为 %s", chunk[0], chunk[1], got)
+		}
+	}
+
+	w := env.do(httptest.NewRequest(http.MethodGet, location, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET 返回 %d: %s", w.Code, w.Body.String())
+	}
+	var resp TusSessionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Completed || resp.Offset != int64(size) {
+		t.Fatalf("会话状态 completed=%v offset=%d，应已完成", resp.Completed, resp.Offset)
+	}
+	if resp.Result == nil {
+		t.Fatal("已完成的会话应返回上传结果")
+	}
+	if resp.Result.ID != env.accountID || resp.Result.Key != resp.Key || resp.Result.Size != int64(size) {
+		t.Fatalf("上传结果 %+v 与会话不一致", resp.Result)
+	}
+	if stored, ok := env.bucket.object(resp.Key); !ok || !bytes.Equal(stored, data) {
+		t.Fatal("存储桶中的对象与上传数据不一致")
+	}
+
+	// 完成后重复发送最后的偏移量不再写入
+	if w := env.patch(location, int64(size), nil); w.Code != http.StatusNoContent {
+		t.Fatalf("已完成会话 PATCH 返回 %d，应为 204", w.Code)
+	}
+}
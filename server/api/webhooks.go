@@ -0,0 +1,160 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"fileflow/server/service"
+	"fileflow/server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookRequest 创建/更新 Webhook 请求
+type WebhookRequest struct {
+	Name     string   `json:"name" binding:"required"`
+	URL      string   `json:"url" binding:"required"`
+	Secret   string   `json:"secret"`   // 签名密钥，创建时为空自动生成，更新时为空保留原值
+	Events   []string `json:"events"`   // 订阅的事件，为空表示全部
+	IsActive *bool    `json:"isActive"` // 为空时创建默认启用，更新保留原值
+}
+
+// validateWebhookRequest 校验回调地址和事件类型
+func validateWebhookRequest(req *WebhookRequest) string {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "回调地址必须是有效的 http 或 https URL"
+	}
+	for _, event := range req.Events {
+		if !service.IsWebhookEvent(event) {
+			return "无效的事件类型: " + event
+		}
+	}
+	return ""
+}
+
+// GetWebhooks 获取所有 Webhook 及可订阅的事件类型
+func GetWebhooks(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": store.GetWebhooks(),
+		"events":   service.WebhookEvents,
+	})
+}
+
+// CreateWebhook 创建 Webhook
+func CreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	if msg := validateWebhookRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	hook := &store.Webhook{
+		Name:     req.Name,
+		URL:      req.URL,
+		Secret:   req.Secret,
+		Events:   req.Events,
+		IsActive: req.IsActive == nil || *req.IsActive,
+	}
+	if err := store.CreateWebhook(hook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, hook)
+}
+
+// UpdateWebhook 更新 Webhook
+func UpdateWebhook(c *gin.Context) {
+	id := c.Param("id")
+
+	existing, err := store.GetWebhookByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	if msg := validateWebhookRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	hook := &store.Webhook{
+		ID:       id,
+		Name:     req.Name,
+		URL:      req.URL,
+		Secret:   req.Secret,
+		Events:   req.Events,
+		IsActive: existing.IsActive,
+	}
+	if req.IsActive != nil {
+		hook.IsActive = *req.IsActive
+	}
+	if err := store.UpdateWebhook(hook); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook 删除 Webhook
+func DeleteWebhook(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := store.DeleteWebhook(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// GetWebhookDeliveries 获取 Webhook 的投递记录，limit 默认 50，最大 200
+func GetWebhookDeliveries(c *gin.Context) {
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须为正整数"})
+			return
+		}
+		limit = min(n, 200)
+	}
+
+	deliveries, err := service.ListWebhookDeliveries(c.Param("id"), limit)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// TestWebhook 向 Webhook 发送测试事件，返回本次投递记录
+func TestWebhook(c *gin.Context) {
+	delivery, err := service.SendTestWebhookEvent(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
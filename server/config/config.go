@@ -15,6 +15,11 @@ type Config struct {
 	Port          string
 	DataDir       string
 	DatabaseURL   string
+
+	// MasterKey 加密账户使用的主密钥（32 字节，base64 或十六进制编码），用于加密每个对象的数据密钥
+	MasterKey string
+	// PreviousMasterKeys 轮换前的旧主密钥，逗号分隔，仅用于解密尚未重新加密的数据密钥
+	PreviousMasterKeys string
 }
 
 var cfg *Config
@@ -37,6 +42,9 @@ func Load() *Config {
 		Port:          getEnv("FILEFLOW_PORT", "8080"),
 		DataDir:       getEnv("FILEFLOW_DATA_DIR", "data"),
 		DatabaseURL:   getEnv("FILEFLOW_DATABASE_URL", ""),
+
+		MasterKey:          getEnv("FILEFLOW_MASTER_KEY", ""),
+		PreviousMasterKeys: getEnv("FILEFLOW_PREVIOUS_MASTER_KEYS", ""),
 	}
 
 	// 验证必要配置
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	// ArchiveMaxEntries 单个压缩包最多解压的文件数
+	ArchiveMaxEntries = 1000
+	// ArchiveMaxTotalSize 单个压缩包解压后的总大小上限（4 GiB）
+	ArchiveMaxTotalSize int64 = 4 << 30
+)
+
+// 支持的压缩包格式
+const (
+	ArchiveFormatZip   = "zip"
+	ArchiveFormatTarGz = "tar.gz"
+	ArchiveFormatTar   = "tar"
+)
+
+var (
+	// ErrArchiveTooManyEntries 压缩包文件数超过限制
+	ErrArchiveTooManyEntries = fmt.Errorf("压缩包文件数超过 %d 个", ArchiveMaxEntries)
+	// ErrArchiveTooLarge 压缩包解压后超过总大小限制
+	ErrArchiveTooLarge = fmt.Errorf("压缩包解压后超过 %d 字节", ArchiveMaxTotalSize)
+	// errArchiveEntryTooLarge 条目实际数据超过声明的大小
+	errArchiveEntryTooLarge = errors.New("文件实际大小超过压缩包中声明的大小")
+)
+
+// ArchiveEntry 压缩包中的文件
+type ArchiveEntry struct {
+	Path string // 清理后的相对路径
+	Size int64  // 声明的解压后大小
+}
+
+// ArchiveEntryFunc 处理单个压缩包条目
+// 条目无法解压（路径不安全、数据损坏等）时 body 为 nil，err 说明原因
+type ArchiveEntryFunc func(entry ArchiveEntry, body io.Reader, err error)
+
+// DetectArchiveFormat 根据文件名判断压缩包格式，不支持时返回空字符串
+func DetectArchiveFormat(fileName string) string {
+	name := strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveFormatZip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveFormatTarGz
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveFormatTar
+	}
+	return ""
+}
+
+// CleanArchivePath 清理压缩包条目路径
+// 拒绝绝对路径和包含 .. 的路径，防止解压到目标前缀之外
+func CleanArchivePath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "", fmt.Errorf("不允许绝对路径: %s", name)
+	}
+	for _, seg := range strings.Split(name, "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("不允许包含 .. 的路径: %s", name)
+		}
+	}
+	cleaned := sanitizeKey(path.Clean(name))
+	if cleaned == "" {
+		return "", fmt.Errorf("无效的路径: %s", name)
+	}
+	return cleaned, nil
+}
+
+// skipArchivePath 跳过系统生成的无用文件
+func skipArchivePath(p string) bool {
+	base := path.Base(p)
+	return strings.HasPrefix(p, "__MACOSX/") || base == ".DS_Store" || base == "Thumbs.db"
+}
+
+// archiveBudget 解压总量限制
+type archiveBudget struct {
+	entries int
+	size    int64
+}
+
+// take 占用一个条目的配额
+func (b *archiveBudget) take(size int64) error {
+	if b.entries >= ArchiveMaxEntries {
+		return ErrArchiveTooManyEntries
+	}
+	if size < 0 || b.size+size > ArchiveMaxTotalSize {
+		return ErrArchiveTooLarge
+	}
+	b.entries++
+	b.size += size
+	return nil
+}
+
+// limitedEntryReader 按声明大小限制读取，防止条目实际数据超过声明值
+type limitedEntryReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedEntryReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// 继续读取 1 字节确认数据已经结束
+		var probe [1]byte
+		if n, _ := l.r.Read(probe[:]); n > 0 {
+			return 0, errArchiveEntryTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// ExtractZip 依次处理 zip 压缩包中的文件
+// 先检查条目数和声明的总大小，超出限制时不处理任何条目
+func ExtractZip(r io.ReaderAt, size int64, fn ArchiveEntryFunc) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("读取 zip 压缩包失败: %w", err)
+	}
+
+	var budget archiveBudget
+	for _, f := range zr.File {
+		if f.FileInfo().Mode().IsRegular() {
+			if err := budget.take(int64(f.UncompressedSize64)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range zr.File {
+		if !f.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		entryPath, pathErr := CleanArchivePath(f.Name)
+		if pathErr == nil && skipArchivePath(entryPath) {
+			continue
+		}
+		entry := ArchiveEntry{Path: entryPath, Size: int64(f.UncompressedSize64)}
+		if pathErr != nil {
+			entry.Path = f.Name
+			fn(entry, nil, pathErr)
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			fn(entry, nil, fmt.Errorf("解压失败: %w", err))
+			continue
+		}
+		fn(entry, &limitedEntryReader{r: rc, remaining: entry.Size}, nil)
+		rc.Close()
+	}
+	return nil
+}
+
+// ExtractTar 依次处理 tar（可选 gzip 压缩）压缩包中的文件
+// tar 只能顺序读取，超出限制时停止并返回错误，已处理的条目保留
+func ExtractTar(r io.Reader, gzipped bool, fn ArchiveEntryFunc) error {
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("读取 gzip 压缩包失败: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var budget archiveBudget
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取 tar 压缩包失败: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		entryPath, pathErr := CleanArchivePath(hdr.Name)
+		if pathErr == nil && skipArchivePath(entryPath) {
+			continue
+		}
+		if err := budget.take(hdr.Size); err != nil {
+			return err
+		}
+
+		entry := ArchiveEntry{Path: entryPath, Size: hdr.Size}
+		if pathErr != nil {
+			entry.Path = hdr.Name
+			fn(entry, nil, pathErr)
+			continue
+		}
+		fn(entry, tr, nil)
+	}
+}
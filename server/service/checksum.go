@@ -0,0 +1,136 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrChecksumMismatch 上传内容与客户端提供的校验值不一致
+	ErrChecksumMismatch = errors.New("文件校验失败")
+	// ErrIncompleteUpload 实际读取的数据量与声明的大小不一致（连接中断导致的截断等）
+	ErrIncompleteUpload = errors.New("上传数据不完整")
+	// ErrInvalidChecksum 客户端提供的校验值格式错误
+	ErrInvalidChecksum = errors.New("校验值格式错误")
+)
+
+// crc32cTable CRC32C（Castagnoli）查找表
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksums 文件校验值（小写十六进制），为空表示未知或未提供
+type Checksums struct {
+	SHA256 string `json:"sha256,omitempty"`
+	CRC32C string `json:"crc32c,omitempty"`
+}
+
+// IsZero 是否没有任何校验值
+func (c Checksums) IsZero() bool {
+	return c.SHA256 == "" && c.CRC32C == ""
+}
+
+// ParseChecksums 解析客户端提供的 SHA-256（64 位十六进制）和 CRC32C（8 位十六进制）
+// 两者均可为空；CRC32C 也接受 S3 使用的 base64 形式
+func ParseChecksums(sha256Hex, crc32cValue string) (Checksums, error) {
+	var c Checksums
+	if sha256Hex = strings.ToLower(strings.TrimSpace(sha256Hex)); sha256Hex != "" {
+		if len(sha256Hex) != sha256.Size*2 || !isHex(sha256Hex) {
+			return c, fmt.Errorf("%w: sha256 应为 64 位十六进制", ErrInvalidChecksum)
+		}
+		c.SHA256 = sha256Hex
+	}
+	if crc32cValue = strings.TrimSpace(crc32cValue); crc32cValue != "" {
+		lower := strings.ToLower(crc32cValue)
+		switch {
+		case len(lower) == 8 && isHex(lower):
+			c.CRC32C = lower
+		default:
+			raw, err := base64.StdEncoding.DecodeString(crc32cValue)
+			if err != nil || len(raw) != 4 {
+				return c, fmt.Errorf("%w: crc32c 应为 8 位十六进制", ErrInvalidChecksum)
+			}
+			c.CRC32C = hex.EncodeToString(raw)
+		}
+	}
+	return c, nil
+}
+
+// ParseOCChecksum 解析 ownCloud/Nextcloud 客户端的 OC-Checksum 头
+// 格式为 "算法:值"，多个以空格分隔，如 "SHA256:<hex> CRC32C:<hex>"；不支持的算法（MD5、SHA1 等）忽略
+func ParseOCChecksum(value string) (Checksums, error) {
+	var sha256Hex, crc32cValue string
+	for _, field := range strings.Fields(value) {
+		algo, sum, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(algo) {
+		case "SHA256", "SHA-256":
+			sha256Hex = sum
+		case "CRC32C":
+			crc32cValue = sum
+		}
+	}
+	return ParseChecksums(sha256Hex, crc32cValue)
+}
+
+// OCChecksum 按 OC-Checksum 的格式输出校验值
+func (c Checksums) OCChecksum() string {
+	var fields []string
+	if c.SHA256 != "" {
+		fields = append(fields, "SHA256:"+c.SHA256)
+	}
+	if c.CRC32C != "" {
+		fields = append(fields, "CRC32C:"+c.CRC32C)
+	}
+	return strings.Join(fields, " ")
+}
+
+// verify 检查实际校验值是否与期望值一致，期望值为空的项不检查
+func (c Checksums) verify(actual Checksums) error {
+	if c.SHA256 != "" && c.SHA256 != actual.SHA256 {
+		return fmt.Errorf("%w: SHA-256 期望 %s，实际 %s", ErrChecksumMismatch, c.SHA256, actual.SHA256)
+	}
+	if c.CRC32C != "" && c.CRC32C != actual.CRC32C {
+		return fmt.Errorf("%w: CRC32C 期望 %s，实际 %s", ErrChecksumMismatch, c.CRC32C, actual.CRC32C)
+	}
+	return nil
+}
+
+// crc32cWriter 以 io.Writer 的形式累加 CRC32C
+type crc32cWriter struct {
+	crc uint32
+}
+
+func (w *crc32cWriter) Write(p []byte) (int, error) {
+	w.crc = crc32.Update(w.crc, crc32cTable, p)
+	return len(p), nil
+}
+
+// crc32cHex 将 CRC32C 格式化为 8 位十六进制
+func crc32cHex(crc uint32) string {
+	return fmt.Sprintf("%08x", crc)
+}
+
+// sha256Base64 计算数据的 SHA-256，按 S3 x-amz-checksum-sha256 的格式（base64）返回
+// 随请求发送后由存储端校验，数据在传输中损坏时请求会被拒绝
+func sha256Base64(data []byte) *string {
+	sum := sha256.Sum256(data)
+	return aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+// isHex 是否全部为小写十六进制字符
+func isHex(s string) bool {
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
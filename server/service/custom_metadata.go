@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// MetaTags 标签在对象用户元数据中的键，多个标签以逗号分隔
+const MetaTags = "tags"
+
+const (
+	// MaxCustomMetadataEntries 每个文件最多的自定义元数据条数
+	MaxCustomMetadataEntries = 20
+	// MaxCustomMetadataValueLength 自定义元数据值的最大长度（字节）
+	MaxCustomMetadataValueLength = 256
+	// MaxTags 每个文件最多的标签数量
+	MaxTags = 20
+	// MaxTagLength 单个标签的最大长度（字节）
+	MaxTagLength = 64
+	// maxCustomMetadataSize 自定义元数据和标签编码后的总大小上限
+	// S3 用户元数据总计不能超过 2 KB，剩余空间留给原始文件名等上传信息
+	maxCustomMetadataSize = 1024
+	// maxCopyObjectSize CopyObject 单次复制的大小上限，超过时无法改写对象元数据
+	maxCopyObjectSize int64 = 5 << 30
+)
+
+// ErrInvalidCustomMetadata 自定义元数据或标签不合法
+var ErrInvalidCustomMetadata = errors.New("自定义元数据不合法")
+
+// customMetadataKeyPattern 自定义元数据键：小写字母、数字、- 和 _，以字母或数字开头
+var customMetadataKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// reservedMetadataKeys 上传信息占用的元数据键，不能作为自定义键
+var reservedMetadataKeys = map[string]bool{
+	MetaOriginalName: true,
+	MetaUploader:     true,
+	MetaSource:       true,
+	MetaSourceURL:    true,
+	MetaSHA256:       true,
+	MetaCRC32C:       true,
+	MetaPresignID:    true,
+	MetaTags:         true,
+
+	MetaEncryption:      true,
+	MetaEncryptionKey:   true,
+	MetaEncryptionKeyID: true,
+}
+
+// FileFilter 按自定义元数据和标签筛选文件，所有条件都需满足
+type FileFilter struct {
+	Tags     []string
+	Metadata map[string]string
+}
+
+// IsZero 是否没有任何筛选条件
+func (f FileFilter) IsZero() bool {
+	return len(f.Tags) == 0 && len(f.Metadata) == 0
+}
+
+// match 记录是否满足筛选条件
+func (f FileFilter) match(fm *store.FileMetadata) bool {
+	for k, v := range f.Metadata {
+		if value, ok := fm.Metadata[k]; !ok || value != v {
+			return false
+		}
+	}
+	for _, tag := range f.Tags {
+		found := false
+		for _, t := range fm.Tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// SplitTags 解析逗号分隔的标签列表
+func SplitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// NormalizeCustomMetadata 校验并规范化自定义元数据和标签
+// 键统一为小写；标签去重后按字典序排列，不能包含逗号和控制字符
+func NormalizeCustomMetadata(metadata map[string]string, tags []string) (map[string]string, []string, error) {
+	if len(metadata) > MaxCustomMetadataEntries {
+		return nil, nil, fmt.Errorf("%w: 最多 %d 条自定义元数据", ErrInvalidCustomMetadata, MaxCustomMetadataEntries)
+	}
+	if len(tags) > MaxTags {
+		return nil, nil, fmt.Errorf("%w: 最多 %d 个标签", ErrInvalidCustomMetadata, MaxTags)
+	}
+
+	size := 0
+	normalized := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		key := strings.ToLower(strings.TrimSpace(k))
+		if !customMetadataKeyPattern.MatchString(key) {
+			return nil, nil, fmt.Errorf("%w: 键只能包含小写字母、数字、- 和 _，最长 64 个字符: %s", ErrInvalidCustomMetadata, k)
+		}
+		if reservedMetadataKeys[key] {
+			return nil, nil, fmt.Errorf("%w: 键 %s 为保留字段", ErrInvalidCustomMetadata, key)
+		}
+		if len(v) > MaxCustomMetadataValueLength || hasControlChar(v) {
+			return nil, nil, fmt.Errorf("%w: %s 的值不能超过 %d 字节或包含控制字符", ErrInvalidCustomMetadata, key, MaxCustomMetadataValueLength)
+		}
+		if _, ok := normalized[key]; ok {
+			return nil, nil, fmt.Errorf("%w: 键重复: %s", ErrInvalidCustomMetadata, key)
+		}
+		normalized[key] = v
+		size += len(key) + len(url.PathEscape(v))
+	}
+
+	seen := make(map[string]bool, len(tags))
+	var normalizedTags []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > MaxTagLength || strings.Contains(tag, ",") || hasControlChar(tag) {
+			return nil, nil, fmt.Errorf("%w: 标签不能超过 %d 字节或包含逗号、控制字符: %s", ErrInvalidCustomMetadata, MaxTagLength, tag)
+		}
+		seen[tag] = true
+		normalizedTags = append(normalizedTags, tag)
+	}
+	sort.Strings(normalizedTags)
+	if len(normalizedTags) > 0 {
+		size += len(MetaTags) + len(url.PathEscape(strings.Join(normalizedTags, ",")))
+	}
+
+	if size > maxCustomMetadataSize {
+		return nil, nil, fmt.Errorf("%w: 自定义元数据和标签编码后不能超过 %d 字节", ErrInvalidCustomMetadata, maxCustomMetadataSize)
+	}
+	return normalized, normalizedTags, nil
+}
+
+// hasControlChar 是否包含控制字符
+func hasControlChar(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCustomMetadata 从已解码的对象元数据中分离出自定义元数据和标签
+func splitCustomMetadata(meta map[string]string) (map[string]string, []string) {
+	custom := make(map[string]string)
+	for k, v := range meta {
+		if !reservedMetadataKeys[k] {
+			custom[k] = v
+		}
+	}
+	return custom, SplitTags(meta[MetaTags])
+}
+
+// recordCustomMetadata 上传完成后记录自定义元数据和标签并写入上传结果，记录失败只记录日志
+func recordCustomMetadata(result *UploadResult, meta ObjectMetadata) {
+	if len(meta.Custom) == 0 && len(meta.Tags) == 0 {
+		return
+	}
+	result.Metadata = meta.Custom
+	result.Tags = meta.Tags
+	err := store.SetFileMetadata(&store.FileMetadata{
+		AccountID: result.ID,
+		FileKey:   result.Key,
+		Size:      result.Size,
+		Metadata:  meta.Custom,
+		Tags:      meta.Tags,
+	})
+	if err != nil {
+		log.Printf("[Metadata] 记录自定义元数据失败 (key=%s): %v", result.Key, err)
+	}
+}
+
+// UpdateFileMetadata 替换文件的自定义元数据和标签
+// 通过复制对象到自身改写用户元数据，原始文件名等上传信息和 Content-Type 保持不变
+func UpdateFileMetadata(ctx context.Context, accountID, key string, metadata map[string]string, tags []string) (*store.FileMetadata, error) {
+	if accountID == "imgbb" {
+		return nil, fmt.Errorf("ImgBB 文件不支持自定义元数据")
+	}
+	metadata, tags, err := NormalizeCustomMetadata(metadata, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分块文件没有同名的物理对象，只更新存储中的记录
+	if sf, err := store.GetStripedFileByKey(accountID, key); err == nil {
+		fm := &store.FileMetadata{
+			AccountID: sf.AccountID,
+			FileKey:   sf.FileKey,
+			Size:      sf.Size,
+			Metadata:  metadata,
+			Tags:      tags,
+		}
+		if err := store.SetFileMetadata(fm); err != nil {
+			return nil, fmt.Errorf("保存自定义元数据失败: %w", err)
+		}
+		return fm, nil
+	}
+
+	acc, err := store.GetAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	client := getS3Client(acc)
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(acc.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("获取文件元数据失败: %w", err)
+	}
+	size := aws.ToInt64(head.ContentLength)
+	if size > maxCopyObjectSize {
+		return nil, fmt.Errorf("文件超过 5 GiB，无法修改对象元数据")
+	}
+
+	// 保留上传信息，替换自定义元数据和标签
+	objectMeta := make(map[string]string)
+	for k, v := range head.Metadata {
+		if k = strings.ToLower(k); reservedMetadataKeys[k] && k != MetaTags {
+			objectMeta[k] = v
+		}
+	}
+	for k, v := range (ObjectMetadata{Custom: metadata, Tags: tags}).toS3() {
+		objectMeta[k] = v
+	}
+
+	_, err = client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:             aws.String(acc.BucketName),
+		Key:                aws.String(key),
+		CopySource:         aws.String(acc.BucketName + "/" + escapeObjectKey(key)),
+		MetadataDirective:  types.MetadataDirectiveReplace,
+		Metadata:           objectMeta,
+		ContentType:        head.ContentType,
+		ContentDisposition: head.ContentDisposition,
+		CacheControl:       head.CacheControl,
+		ContentEncoding:    head.ContentEncoding,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("更新对象元数据失败: %w", err)
+	}
+
+	fm := &store.FileMetadata{
+		AccountID: acc.ID,
+		FileKey:   key,
+		Size:      size,
+		Metadata:  metadata,
+		Tags:      tags,
+	}
+	if err := store.SetFileMetadata(fm); err != nil {
+		return nil, fmt.Errorf("保存自定义元数据失败: %w", err)
+	}
+	return fm, nil
+}
+
+// escapeObjectKey 按路径段编码对象 key，用于 CopySource
+func escapeObjectKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// FilterAccountsFiles 在指定账户组（为空时为所有激活账户）中按自定义元数据和标签筛选文件
+func FilterAccountsFiles(ids []string, prefix string, filter FileFilter, cursor string, limit int32) []AccountFiles {
+	var accounts []store.Account
+	if len(ids) == 0 {
+		accounts = store.GetActiveAccounts()
+	} else {
+		for _, id := range ids {
+			if acc, err := store.GetAccountByID(id); err == nil && acc.IsActive {
+				accounts = append(accounts, *acc)
+			}
+		}
+	}
+
+	result := []AccountFiles{}
+	for _, acc := range accounts {
+		listResult := FilterFiles(&acc, prefix, filter, cursor, limit)
+		result = append(result, AccountFiles{
+			ID:          acc.ID,
+			AccountName: acc.Name,
+			Files:       listResult.Files,
+			SizeBytes:   acc.Usage.SizeBytes,
+			MaxSize:     acc.Quota.MaxSizeBytes,
+			NextCursor:  listResult.NextCursor,
+		})
+	}
+	return result
+}
+
+// FilterFiles 按自定义元数据和标签筛选账户中 prefix 下的文件（包括子目录）
+// 结果按路径排序，cursor 为上一页最后一个文件的路径
+func FilterFiles(acc *store.Account, prefix string, filter FileFilter, cursor string, limit int32) *ListFilesResult {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var matched []store.FileMetadata
+	for _, fm := range store.GetFileMetadataByAccount(acc.ID) {
+		if strings.HasPrefix(fm.FileKey, prefix) && fm.FileKey > cursor && filter.match(&fm) {
+			matched = append(matched, fm)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].FileKey < matched[j].FileKey })
+
+	result := &ListFilesResult{Files: []*FileNode{}}
+	for _, fm := range matched {
+		if len(result.Files) == int(limit) {
+			result.NextCursor = result.Files[len(result.Files)-1].Key
+			break
+		}
+		result.Files = append(result.Files, &FileNode{
+			Key:      fm.FileKey,
+			Name:     strings.TrimPrefix(fm.FileKey, prefix),
+			Size:     fm.Size,
+			Metadata: fm.Metadata,
+			Tags:     fm.Tags,
+		})
+	}
+	return result
+}
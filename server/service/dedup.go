@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// findDuplicate 按 SHA-256 查找内容相同的已有对象，找到后增加引用计数
+// 复用前通过 HeadObject 确认对象仍然存在且未被覆盖，失效的索引会被移除
+func findDuplicate(ctx context.Context, src *uploadSource, size int64) *store.FileObject {
+	if !src.dedup {
+		return nil
+	}
+
+	for _, obj := range store.GetFileObjectsByHash(src.sum()) {
+		if obj.Size != size {
+			continue
+		}
+		if !src.dedupScope[obj.AccountID] {
+			continue
+		}
+
+		acc, err := store.GetAccountByID(obj.AccountID)
+		if err != nil {
+			// 账户已删除，索引失效
+			store.DeleteFileObject(obj.ID)
+			continue
+		}
+		if !acc.IsActive {
+			continue
+		}
+
+		head, err := getS3Client(acc).HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(acc.BucketName),
+			Key:    aws.String(obj.FileKey),
+		})
+		if err != nil {
+			var notFound *types.NotFound
+			if errors.As(err, &notFound) {
+				store.DeleteFileObject(obj.ID)
+			} else {
+				log.Printf("[Dedup] 检查已有对象失败 (account=%s, key=%s): %v", acc.Name, obj.FileKey, err)
+			}
+			continue
+		}
+		if aws.ToInt64(head.ContentLength) != obj.Size || (obj.ETag != "" && aws.ToString(head.ETag) != obj.ETag) {
+			// 对象已被覆盖，内容不再对应该哈希
+			store.DeleteFileObject(obj.ID)
+			continue
+		}
+
+		ref, err := store.AddFileObjectRef(obj.ID)
+		if err != nil {
+			// 对象刚好被最后一个引用释放
+			continue
+		}
+		log.Printf("[Dedup] 内容已存在，复用 %s/%s（引用数 %d）", acc.Name, ref.FileKey, ref.RefCount)
+		return ref
+	}
+	return nil
+}
+
+// registerFileObject 记录新上传对象的内容哈希
+func registerFileObject(acc *store.Account, key, hash string, obj *storedObject) {
+	err := store.CreateFileObject(&store.FileObject{
+		Hash:      hash,
+		Size:      obj.Size,
+		AccountID: acc.ID,
+		FileKey:   key,
+		ETag:      obj.ETag,
+	})
+	if err != nil {
+		// 索引写入失败只影响后续去重，不影响本次上传
+		log.Printf("[Dedup] 记录内容索引失败 (key=%s): %v", key, err)
+	}
+}
+
+// duplicateUploadResult 构建指向已有对象的上传结果
+func duplicateUploadResult(ctx context.Context, obj *store.FileObject) (*UploadResult, error) {
+	acc, err := store.GetAccountByID(obj.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("账户不存在: %w", err)
+	}
+
+	result := newUploadResult(ctx, acc, obj.FileKey, obj.Size)
+	result.SHA256 = obj.Hash
+	result.Deduplicated = true
+	return result, nil
+}
+
+// ReleaseFile 释放文件的一个引用，最后一个引用释放时才删除物理对象
+// 返回物理对象是否已被删除；目录和未被索引的文件直接删除
+func ReleaseFile(ctx context.Context, accountID, key string) (bool, error) {
+	var size int64
+	if accountID != "imgbb" && !strings.HasSuffix(key, "/") {
+		// 最后一个引用释放时索引记录随之删除，先取出大小用于更新容量计数
+		if obj, err := store.GetFileObjectByKey(accountID, key); err == nil {
+			size = obj.Size
+		}
+		remaining, err := store.ReleaseFileObject(accountID, key)
+		if err != nil {
+			return false, fmt.Errorf("更新引用计数失败: %w", err)
+		}
+		if remaining > 0 {
+			return false, nil
+		}
+	}
+
+	if err := deleteFile(ctx, accountID, key, size); err != nil {
+		return false, err
+	}
+	PublishEvent(EventFileDeleted, FileEventData{AccountID: accountID, Key: key})
+	return true, nil
+}
+
+// MergeFileExpirationRecord 合并共享对象的到期时间
+// 共享对象的到期时间取所有引用中最晚的一个，任一引用为永久则对象永久保留，
+// 因此到期记录触发时所有引用均已到期，可以直接删除物理对象
+func MergeFileExpirationRecord(accountID, fileKey string, size int64, expirationDays int) error {
+	var existing *store.FileExpiration
+	for _, exp := range store.GetFileExpirations() {
+		if exp.AccountID == accountID && exp.FileKey == fileKey {
+			existing = &exp
+			break
+		}
+	}
+
+	// 没有到期记录说明已有引用为永久
+	if existing == nil {
+		return nil
+	}
+	if expirationDays <= 0 {
+		return store.DeleteFileExpiration(accountID, fileKey)
+	}
+
+	current, err := time.Parse(time.RFC3339, existing.ExpiresAt)
+	if err == nil && !time.Now().AddDate(0, 0, expirationDays).After(current) {
+		return nil
+	}
+	return CreateFileExpirationRecord(accountID, fileKey, size, expirationDays)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DownloadResult URL 下载结果
+type DownloadResult struct {
+	Body        io.ReadCloser
+	Size        int64 // 未知时为 -1
+	ContentType string
+	Ext         string
+}
+
+// DownloadURL 从 URL 下载文件，返回的 Body 由调用方关闭
+// timeout 为整个下载（含读取响应体）的超时时间，0 表示只受 ctx 控制。
+// 按系统设置的安全策略检查主机、重定向、文件大小和类型，并禁止连接内网地址
+func DownloadURL(ctx context.Context, rawURL string, timeout time.Duration) (*DownloadResult, error) {
+	policy := loadURLPolicy()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 URL: %w", err)
+	}
+	if err := policy.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	client := NewSafeHTTPClient(timeout)
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		if len(via) > policy.maxRedirects {
+			return fmt.Errorf("%w: 重定向次数超过 %d 次", ErrURLRejected, policy.maxRedirects)
+		}
+		return policy.checkURL(next.URL)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载失败: %w", err)
+	}
+
+	// 检查响应状态
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("下载失败，HTTP 状态码: %d", resp.StatusCode)
+	}
+
+	// 获取 Content-Type
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if err := policy.checkContentType(contentType); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	// 检查文件大小，未声明大小时在读取过程中限制
+	if resp.ContentLength > policy.maxSize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: 文件超过 %d MB 的下载大小限制", ErrURLRejected, policy.maxSize>>20)
+	}
+
+	// 确定文件扩展名（优先 URL 路径）
+	ext := ExtFromURL(rawURL)
+	if ext == "" {
+		ext = ExtFromContentType(contentType)
+	}
+
+	return &DownloadResult{
+		Body:        &limitedBody{ReadCloser: resp.Body, remaining: policy.maxSize, limit: policy.maxSize},
+		Size:        resp.ContentLength,
+		ContentType: contentType,
+		Ext:         ext,
+	}, nil
+}
+
+// ExtFromURL 从 URL 路径提取扩展名
+func ExtFromURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return filepath.Ext(parsed.Path)
+}
+
+// contentTypeExts 常见 Content-Type 对应的扩展名
+var contentTypeExts = map[string]string{
+	"image/jpeg":               ".jpg",
+	"image/png":                ".png",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/svg+xml":            ".svg",
+	"image/x-icon":             ".ico",
+	"image/bmp":                ".bmp",
+	"video/mp4":                ".mp4",
+	"video/webm":               ".webm",
+	"video/quicktime":          ".mov",
+	"audio/mpeg":               ".mp3",
+	"audio/wav":                ".wav",
+	"audio/ogg":                ".ogg",
+	"application/pdf":          ".pdf",
+	"application/zip":          ".zip",
+	"application/x-gzip":       ".gz",
+	"application/x-tar":        ".tar",
+	"text/plain":               ".txt",
+	"text/html":                ".html",
+	"text/css":                 ".css",
+	"application/javascript":   ".js",
+	"application/json":         ".json",
+	"application/xml":          ".xml",
+	"application/octet-stream": "",
+}
+
+// ExtFromContentType 从 Content-Type 推断扩展名
+func ExtFromContentType(contentType string) string {
+	// 移除参数部分（如 charset）
+	if idx := strings.Index(contentType, ";"); idx != -1 {
+		contentType = strings.TrimSpace(contentType[:idx])
+	}
+	return contentTypeExts[contentType]
+}
+
+// FilenameFromURL 从 URL 生成文件名
+func FilenameFromURL(rawURL string, ext string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "download" + ext
+	}
+
+	// 尝试从路径获取文件名
+	basename := filepath.Base(parsed.Path)
+	if basename != "" && basename != "/" && basename != "." {
+		return basename
+	}
+
+	// 使用域名作为文件名
+	hostname := parsed.Hostname()
+	if hostname != "" {
+		return hostname + ext
+	}
+
+	return "download" + ext
+}
@@ -0,0 +1,425 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"fileflow/server/config"
+	"fileflow/server/store"
+)
+
+const (
+	// EncryptionAlgorithm 对象加密方式：AES-256-GCM，按 64 KiB 明文分块加密
+	EncryptionAlgorithm = "aes-256-gcm-64k"
+	// EncryptionChunkSize 每个加密数据块的明文大小
+	EncryptionChunkSize = 64 << 10
+	// encryptionTagSize 每个加密数据块附带的 GCM 认证标签大小
+	encryptionTagSize = 16
+
+	// 加密对象的元数据键
+	MetaEncryption      = "encryption"        // 加密方式
+	MetaEncryptionKey   = "encryption-key"    // 主密钥加密后的数据密钥
+	MetaEncryptionKeyID = "encryption-key-id" // 加密数据密钥所用主密钥的标识
+)
+
+var (
+	// ErrEncryptionKeyMissing 未配置主密钥，无法加密或解密
+	ErrEncryptionKeyMissing = errors.New("未配置加密主密钥（FILEFLOW_MASTER_KEY）")
+	// ErrEncryptedDirectUpload 加密账户不支持客户端直传
+	ErrEncryptedDirectUpload = errors.New("账户已启用加密，不支持直传和断点续传")
+)
+
+// masterKey 用于加密数据密钥的主密钥
+type masterKey struct {
+	id   string // 密钥 SHA-256 的前 8 字节（十六进制），随对象保存以便轮换后找到旧密钥
+	aead cipher.AEAD
+}
+
+var (
+	masterKeysOnce sync.Once
+	masterKeys     []masterKey // 首个为当前主密钥，其余为轮换前的旧密钥
+	masterKeysErr  error
+)
+
+// loadMasterKeys 解析配置中的主密钥，只解析一次
+func loadMasterKeys() ([]masterKey, error) {
+	masterKeysOnce.Do(func() {
+		cfg := config.Get()
+		if cfg.MasterKey == "" {
+			if cfg.PreviousMasterKeys != "" {
+				masterKeysErr = fmt.Errorf("设置 FILEFLOW_PREVIOUS_MASTER_KEYS 时必须同时设置 FILEFLOW_MASTER_KEY")
+			}
+			return
+		}
+
+		values := []string{cfg.MasterKey}
+		for _, v := range strings.Split(cfg.PreviousMasterKeys, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		for i, v := range values {
+			key, err := parseMasterKey(v)
+			if err != nil {
+				name := "FILEFLOW_MASTER_KEY"
+				if i > 0 {
+					name = fmt.Sprintf("FILEFLOW_PREVIOUS_MASTER_KEYS 第 %d 项", i)
+				}
+				masterKeysErr = fmt.Errorf("%s 无效: %w", name, err)
+				masterKeys = nil
+				return
+			}
+			masterKeys = append(masterKeys, key)
+		}
+	})
+	return masterKeys, masterKeysErr
+}
+
+// parseMasterKey 解析 base64 或十六进制编码的 32 字节主密钥
+func parseMasterKey(value string) (masterKey, error) {
+	var raw []byte
+	for _, decode := range []func(string) ([]byte, error){
+		hex.DecodeString,
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		base64.URLEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+	} {
+		if b, err := decode(value); err == nil && len(b) == 32 {
+			raw = b
+			break
+		}
+	}
+	if raw == nil {
+		return masterKey{}, fmt.Errorf("需要 32 字节密钥的 base64 或十六进制编码")
+	}
+
+	aead, err := newGCM(raw)
+	if err != nil {
+		return masterKey{}, err
+	}
+	sum := sha256.Sum256(raw)
+	return masterKey{id: hex.EncodeToString(sum[:8]), aead: aead}, nil
+}
+
+// newGCM 创建 AES-256-GCM 加密器
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建加密器失败: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// CheckEncryptionKeys 校验主密钥配置，启动时调用
+// 密钥格式无效，或有账户启用加密但未配置主密钥时返回错误
+func CheckEncryptionKeys() error {
+	keys, err := loadMasterKeys()
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return nil
+	}
+	for _, acc := range store.GetAccounts() {
+		if acc.Encryption {
+			return fmt.Errorf("账户 %s 已启用加密: %w", acc.Name, ErrEncryptionKeyMissing)
+		}
+	}
+	return nil
+}
+
+// EncryptionAvailable 是否已配置主密钥
+func EncryptionAvailable() bool {
+	keys, err := loadMasterKeys()
+	return err == nil && len(keys) > 0
+}
+
+// envelope 单个对象的信封加密：每次写入生成随机数据密钥，数据密钥由主密钥加密后存入对象元数据
+// 明文按 EncryptionChunkSize 分块，每块独立加密，nonce 为数据块序号；
+// 最后一块的明文总是少于 EncryptionChunkSize（长度恰好整除时追加一个空块），
+// 并以附加数据标记为最后一块，截断或调换数据块都会导致校验失败
+type envelope struct {
+	aead    cipher.AEAD
+	wrapped string // 主密钥加密后的数据密钥（base64）
+	keyID   string
+}
+
+// newEnvelope 生成新的数据密钥，使用当前主密钥加密
+func newEnvelope() (*envelope, error) {
+	keys, err := loadMasterKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrEncryptionKeyMissing
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("生成数据密钥失败: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := wrapDataKey(keys[0], dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope{aead: aead, wrapped: wrapped, keyID: keys[0].id}, nil
+}
+
+// accountEnvelope 为写入账户的对象准备加密信封，账户未启用加密时返回 nil
+func accountEnvelope(acc *store.Account) (*envelope, error) {
+	if !acc.Encryption {
+		return nil, nil
+	}
+	env, err := newEnvelope()
+	if err != nil {
+		return nil, fmt.Errorf("账户 %s 已启用加密: %w", acc.Name, err)
+	}
+	return env, nil
+}
+
+// openEnvelope 从对象元数据（已解码）还原加密信封，对象未加密时返回 nil
+func openEnvelope(meta map[string]string) (*envelope, error) {
+	if meta[MetaEncryption] == "" {
+		return nil, nil
+	}
+	if meta[MetaEncryption] != EncryptionAlgorithm {
+		return nil, fmt.Errorf("不支持的加密方式: %s", meta[MetaEncryption])
+	}
+
+	keys, err := loadMasterKeys()
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := unwrapDataKey(keys, meta[MetaEncryptionKeyID], meta[MetaEncryptionKey])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope{aead: aead, wrapped: meta[MetaEncryptionKey], keyID: meta[MetaEncryptionKeyID]}, nil
+}
+
+// wrapDataKey 使用主密钥加密数据密钥，结果为 base64(nonce || 密文)
+func wrapDataKey(key masterKey, dataKey []byte) (string, error) {
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	sealed := key.aead.Seal(nonce, nonce, dataKey, []byte(EncryptionAlgorithm))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// unwrapDataKey 按主密钥标识找到对应的主密钥并解密数据密钥
+func unwrapDataKey(keys []masterKey, keyID, wrapped string) ([]byte, error) {
+	if len(keys) == 0 {
+		return nil, ErrEncryptionKeyMissing
+	}
+	for _, key := range keys {
+		if key.id != keyID {
+			continue
+		}
+		sealed, err := base64.RawURLEncoding.DecodeString(wrapped)
+		if err != nil || len(sealed) < key.aead.NonceSize() {
+			return nil, fmt.Errorf("数据密钥格式无效")
+		}
+		nonce := sealed[:key.aead.NonceSize()]
+		dataKey, err := key.aead.Open(nil, nonce, sealed[len(nonce):], []byte(EncryptionAlgorithm))
+		if err != nil {
+			return nil, fmt.Errorf("解密数据密钥失败: %w", err)
+		}
+		return dataKey, nil
+	}
+	return nil, fmt.Errorf("找不到加密该对象的主密钥 %s，请在 FILEFLOW_PREVIOUS_MASTER_KEYS 中保留旧密钥", keyID)
+}
+
+// metadata 随对象保存的加密信息
+func (e *envelope) metadata() map[string]string {
+	return map[string]string{
+		MetaEncryption:      EncryptionAlgorithm,
+		MetaEncryptionKey:   e.wrapped,
+		MetaEncryptionKeyID: e.keyID,
+	}
+}
+
+// seal 加密一段明文并追加到 dst，first 为这段数据第一个数据块的序号
+// final 表示这是对象的最后一段；其余各段的长度必须是 EncryptionChunkSize 的整数倍
+func (e *envelope) seal(dst, data []byte, first uint64, final bool) []byte {
+	index := first
+	for len(data) >= EncryptionChunkSize {
+		dst = e.aead.Seal(dst, chunkNonce(index), data[:EncryptionChunkSize], chunkAAD(false))
+		data = data[EncryptionChunkSize:]
+		index++
+	}
+	if final {
+		dst = e.aead.Seal(dst, chunkNonce(index), data, chunkAAD(true))
+	}
+	return dst
+}
+
+// chunkNonce 数据块的 nonce：4 字节 0 加 8 字节大端序号，数据密钥每个对象独立，不会重复
+func chunkNonce(index uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], index)
+	return nonce
+}
+
+// chunkAAD 数据块的附加数据，标记是否为最后一块
+func chunkAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// EncryptedSize 明文大小对应的加密对象大小
+func EncryptedSize(size int64) int64 {
+	return size + encryptionTagSize*(size/EncryptionChunkSize+1)
+}
+
+// PlaintextSize 加密对象大小对应的明文大小
+func PlaintextSize(size int64) int64 {
+	if size < encryptionTagSize {
+		return 0
+	}
+	chunks := (size-encryptionTagSize)/(EncryptionChunkSize+encryptionTagSize) + 1
+	return size - encryptionTagSize*chunks
+}
+
+// ListedObjectSize 列表中对象的显示大小
+// 列出对象时没有元数据，加密账户中的对象一律按密文推算明文大小，启用加密前写入的对象会略小于实际大小
+func ListedObjectSize(acc *store.Account, size int64) int64 {
+	if acc.Encryption {
+		return PlaintextSize(size)
+	}
+	return size
+}
+
+// encryptedOffset 明文位置所在数据块在加密对象中的起始位置
+func encryptedOffset(offset int64) int64 {
+	return offset / EncryptionChunkSize * (EncryptionChunkSize + encryptionTagSize)
+}
+
+// IsEncryptedObject 对象是否由 FileFlow 加密，raw 为 HeadObject 返回的用户元数据
+func IsEncryptedObject(raw map[string]string) bool {
+	return decodeObjectMetadata(raw)[MetaEncryption] != ""
+}
+
+// decryptReader 从明文位置 offset 所在的数据块开始解密，body 需从该数据块的起始位置读取
+type decryptReader struct {
+	env      *envelope
+	body     io.ReadCloser
+	index    uint64 // 下一个要读取的数据块
+	last     uint64 // 最后一个数据块的序号
+	lastSize int    // 最后一个数据块的明文大小
+	skip     int    // 首个数据块中需要跳过的明文字节数
+	buf      []byte // 密文缓冲区
+	out      []byte // 明文缓冲区
+	plain    []byte // 尚未读取的明文
+}
+
+// newDecryptReader 创建解密读取器，size 为对象的明文大小
+func newDecryptReader(env *envelope, body io.ReadCloser, size, offset int64) *decryptReader {
+	return &decryptReader{
+		env:      env,
+		body:     body,
+		index:    uint64(offset / EncryptionChunkSize),
+		last:     uint64(size / EncryptionChunkSize),
+		lastSize: int(size % EncryptionChunkSize),
+		skip:     int(offset % EncryptionChunkSize),
+		buf:      make([]byte, EncryptionChunkSize+encryptionTagSize),
+		out:      make([]byte, 0, EncryptionChunkSize),
+	}
+}
+
+// Read 读取解密后的数据，每个数据块校验通过后才返回其内容
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.index > d.last {
+			return 0, io.EOF
+		}
+		plain, err := d.readChunk()
+		if err != nil {
+			return 0, err
+		}
+		if d.skip > 0 {
+			plain = plain[min(d.skip, len(plain)):]
+			d.skip = 0
+		}
+		d.plain = plain
+
+		// 最后一块为空时立即校验，读取方读够数据后不会再读取，否则无法发现截断
+		if d.index == d.last && d.lastSize == 0 {
+			if _, err := d.readChunk(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// readChunk 读取并解密下一个数据块
+func (d *decryptReader) readChunk() ([]byte, error) {
+	final := d.index == d.last
+	size := EncryptionChunkSize
+	if final {
+		size = d.lastSize
+	}
+
+	sealed := d.buf[:size+encryptionTagSize]
+	if _, err := io.ReadFull(d.body, sealed); err != nil {
+		return nil, fmt.Errorf("读取加密数据块 %d 失败: %w", d.index, err)
+	}
+	plain, err := d.env.aead.Open(d.out[:0], chunkNonce(d.index), sealed, chunkAAD(final))
+	if err != nil {
+		return nil, fmt.Errorf("加密数据块 %d 校验失败，对象已损坏或被篡改", d.index)
+	}
+	d.index++
+	return plain, nil
+}
+
+// Close 关闭底层数据流
+func (d *decryptReader) Close() error {
+	return d.body.Close()
+}
+
+// withoutEncryptedAccounts 从直传候选账户中排除启用加密的账户
+// 预签名直传和断点续传的数据不经过 FileFlow 加密，指定的账户启用加密时返回错误
+func withoutEncryptedAccounts(accounts []store.Account, accountID string) ([]store.Account, error) {
+	if accountID != "" {
+		if len(accounts) > 0 && accounts[0].Encryption {
+			return nil, ErrEncryptedDirectUpload
+		}
+		return accounts, nil
+	}
+
+	result := make([]store.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		if !acc.Encryption {
+			result = append(result, acc)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("没有可用的存储账户（启用加密的账户不支持直传）")
+	}
+	return result, nil
+}
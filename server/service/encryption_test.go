@@ -0,0 +1,231 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testMasterKey 生成随机主密钥
+func testMasterKey(t *testing.T) masterKey {
+	t.Helper()
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatal(err)
+	}
+	key, err := parseMasterKey(hex.EncodeToString(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// testEnvelope 按 newEnvelope 的方式生成数据密钥并用 key 加密，不依赖配置中的主密钥
+func testEnvelope(t *testing.T, key masterKey) *envelope {
+	t.Helper()
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		t.Fatal(err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := wrapDataKey(key, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &envelope{aead: aead, wrapped: wrapped, keyID: key.id}
+}
+
+// testPlaintext 生成指定大小的随机明文
+func testPlaintext(t *testing.T, size int) []byte {
+	t.Helper()
+	plain := make([]byte, size)
+	if _, err := rand.Read(plain); err != nil {
+		t.Fatal(err)
+	}
+	return plain
+}
+
+// decryptFrom 模拟 FileReader 的 Range 读取：从 offset 所在数据块开始读取密文并解密
+func decryptFrom(env *envelope, sealed []byte, size, offset int64) ([]byte, error) {
+	body := io.NopCloser(bytes.NewReader(sealed[encryptedOffset(offset):]))
+	return io.ReadAll(newDecryptReader(env, body, size, offset))
+}
+
+var encryptionTestSizes = []struct {
+	name string
+	size int
+}{
+	{"empty", 0},
+	{"one byte", 1},
+	{"chunk minus one", EncryptionChunkSize - 1},
+	{"exact chunk", EncryptionChunkSize},
+	{"chunk plus one", EncryptionChunkSize + 1},
+	{"exact multiple", 3 * EncryptionChunkSize},
+	{"several chunks", 3*EncryptionChunkSize + 100},
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	env := testEnvelope(t, testMasterKey(t))
+	for _, tt := range encryptionTestSizes {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := testPlaintext(t, tt.size)
+			sealed := env.seal(nil, plain, 0, true)
+			if got, want := int64(len(sealed)), EncryptedSize(int64(tt.size)); got != want {
+				t.Fatalf("密文大小 %d，应为 %d", got, want)
+			}
+			if got := PlaintextSize(int64(len(sealed))); got != int64(tt.size) {
+				t.Fatalf("PlaintextSize = %d，应为 %d", got, tt.size)
+			}
+
+			got, err := decryptFrom(env, sealed, int64(tt.size), 0)
+			if err != nil {
+				t.Fatalf("解密失败: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Fatal("解密结果与明文不一致")
+			}
+		})
+	}
+}
+
+// 分片上传按分片调用 seal，结果应与一次加密整个文件相同
+func TestEnvelopeSealInParts(t *testing.T) {
+	env := testEnvelope(t, testMasterKey(t))
+	plain := testPlaintext(t, 5*EncryptionChunkSize+123)
+	whole := env.seal(nil, plain, 0, true)
+
+	partSize := 2 * EncryptionChunkSize
+	var parts []byte
+	for start := 0; start < len(plain); start += partSize {
+		end := min(start+partSize, len(plain))
+		parts = env.seal(parts, plain[start:end], uint64(start/EncryptionChunkSize), end == len(plain))
+	}
+	if !bytes.Equal(parts, whole) {
+		t.Fatal("分段加密结果与整体加密不一致")
+	}
+}
+
+func TestDecryptRangeReads(t *testing.T) {
+	env := testEnvelope(t, testMasterKey(t))
+	size := 3*EncryptionChunkSize + 100
+	plain := testPlaintext(t, size)
+	sealed := env.seal(nil, plain, 0, true)
+
+	offsets := []int64{
+		0,
+		1,
+		EncryptionChunkSize - 1,
+		EncryptionChunkSize,
+		EncryptionChunkSize + 1,
+		2*EncryptionChunkSize + 500,
+		3 * EncryptionChunkSize,
+		int64(size - 1),
+	}
+	for _, offset := range offsets {
+		got, err := decryptFrom(env, sealed, int64(size), offset)
+		if err != nil {
+			t.Fatalf("offset %d: 解密失败: %v", offset, err)
+		}
+		if !bytes.Equal(got, plain[offset:]) {
+			t.Fatalf("offset %d: 解密结果与明文不一致", offset)
+		}
+	}
+}
+
+func TestDecryptDetectsTampering(t *testing.T) {
+	env := testEnvelope(t, testMasterKey(t))
+
+	tests := []struct {
+		name   string
+		size   int
+		mutate func(sealed []byte) []byte
+		// 按篡改后的密文长度推算明文大小（与 HeadObject 的结果一致），否则使用原始大小
+		sizeFromCiphertext bool
+	}{
+		{
+			name:   "tampered tag",
+			size:   EncryptionChunkSize + 100,
+			mutate: func(s []byte) []byte { s[EncryptionChunkSize+encryptionTagSize-1] ^= 1; return s },
+		},
+		{
+			name:   "tampered final chunk",
+			size:   EncryptionChunkSize + 100,
+			mutate: func(s []byte) []byte { s[len(s)-1] ^= 1; return s },
+		},
+		{
+			name:   "truncated final chunk",
+			size:   EncryptionChunkSize + 100,
+			mutate: func(s []byte) []byte { return s[:len(s)-10] },
+		},
+		{
+			name:               "final chunk dropped",
+			size:               2*EncryptionChunkSize + 100,
+			mutate:             func(s []byte) []byte { return s[:encryptedOffset(2*EncryptionChunkSize)] },
+			sizeFromCiphertext: true,
+		},
+		{
+			name:               "empty final chunk dropped",
+			size:               2 * EncryptionChunkSize,
+			mutate:             func(s []byte) []byte { return s[:len(s)-encryptionTagSize] },
+			sizeFromCiphertext: true,
+		},
+		{
+			name: "chunks swapped",
+			size: 3 * EncryptionChunkSize,
+			mutate: func(s []byte) []byte {
+				n := EncryptionChunkSize + encryptionTagSize
+				first := append([]byte(nil), s[:n]...)
+				copy(s[:n], s[n:2*n])
+				copy(s[n:2*n], first)
+				return s
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed := tt.mutate(env.seal(nil, testPlaintext(t, tt.size), 0, true))
+			size := int64(tt.size)
+			if tt.sizeFromCiphertext {
+				size = PlaintextSize(int64(len(sealed)))
+			}
+			if _, err := decryptFrom(env, sealed, size, 0); err == nil {
+				t.Fatal("篡改后的密文应解密失败")
+			}
+		})
+	}
+}
+
+func TestDecryptWithWrongDataKey(t *testing.T) {
+	key := testMasterKey(t)
+	sealed := testEnvelope(t, key).seal(nil, testPlaintext(t, 100), 0, true)
+	if _, err := decryptFrom(testEnvelope(t, key), sealed, 100, 0); err == nil {
+		t.Fatal("使用其他数据密钥应解密失败")
+	}
+}
+
+func TestParseMasterKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{0xAB}, 32)
+	hexKey, err := parseMasterKey(hex.EncodeToString(raw))
+	if err != nil {
+		t.Fatalf("十六进制密钥解析失败: %v", err)
+	}
+	b64Key, err := parseMasterKey("q6urq6urq6urq6urq6urq6urq6urq6urq6urq6urq6s=")
+	if err != nil {
+		t.Fatalf("base64 密钥解析失败: %v", err)
+	}
+	if hexKey.id != b64Key.id {
+		t.Fatal("同一密钥的不同编码应得到相同标识")
+	}
+
+	for _, value := range []string{"", "short", strings.Repeat("a", 63)} {
+		if _, err := parseMasterKey(value); err == nil {
+			t.Fatalf("%q 应解析失败", value)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+
+	"fileflow/server/store"
+)
+
+// MaxImageMetadataStripSize 移除元数据时图片需要整体读入内存，超过该大小的图片拒绝上传
+const MaxImageMetadataStripSize int64 = 64 << 20
+
+// ErrStripImageMetadata 要求移除元数据但图片无法处理（过大或格式损坏），为避免泄露位置信息拒绝上传
+var ErrStripImageMetadata = errors.New("无法移除图片元数据")
+
+// 图片格式
+const (
+	imageFormatJPEG = "jpeg"
+	imageFormatPNG  = "png"
+	imageFormatWebP = "webp"
+)
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	exifHeader   = []byte("Exif\x00\x00")
+	xmpHeader    = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	xmpExtHeader = []byte("http://ns.adobe.com/xmp/extension/\x00")
+)
+
+// imageMagicSize 识别图片格式需要的文件头长度
+const imageMagicSize = 12
+
+// pngMetadataChunks PNG 中需要移除的块：EXIF、文本（XMP 保存在 iTXt 中）和修改时间
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// StripImageMetadataRequired 判断上传是否需要移除图片元数据
+// keep 为上传时显式要求保留原图，优先级最高；否则 Token 或账户开启了隐私选项即移除。
+// accountID 为空时检查账户池的成员，未指定账户池时检查所有激活账户（智能上传的候选账户）
+func StripImageMetadataRequired(tokenID, accountID, pool string, keep bool) bool {
+	var accounts []store.Account
+	switch {
+	case accountID != "":
+		if acc, err := store.GetAccountByID(accountID); err == nil {
+			accounts = append(accounts, *acc)
+		}
+	case pool != "":
+		ids, _ := PoolAccountIDs(pool)
+		for _, id := range ids {
+			if acc, err := store.GetAccountByID(id); err == nil {
+				accounts = append(accounts, *acc)
+			}
+		}
+	default:
+		accounts = store.GetActiveAccounts()
+	}
+	return shouldStripImageMetadata(keep, tokenID, accounts)
+}
+
+// shouldStripImageMetadata 按保留标记、Token 和候选账户的设置判断是否移除图片元数据
+// 上传前无法确定最终写入的账户，任一候选账户开启即移除
+func shouldStripImageMetadata(keep bool, tokenID string, accounts []store.Account) bool {
+	if keep {
+		return false
+	}
+	if tokenID != "" {
+		if t, err := store.GetTokenByID(tokenID); err == nil && t.StripImageMetadata {
+			return true
+		}
+	}
+	for i := range accounts {
+		if accounts[i].StripImageMetadata {
+			return true
+		}
+	}
+	return false
+}
+
+// stripImageMetadataForUpload 按 Token 和候选账户的设置在上传前移除图片元数据
+// 智能上传在决定是否分块前调用，分块和普通上传都使用处理后的数据；已处理过的数据不再重复处理
+func stripImageMetadataForUpload(accounts []store.Account, naming KeyNaming, meta ObjectMetadata, body io.Reader, size int64) (io.Reader, int64, ObjectMetadata, error) {
+	if meta.imageMetadataStripped || !shouldStripImageMetadata(meta.KeepImageMetadata, naming.TokenID, accounts) {
+		return body, size, meta, nil
+	}
+	body, size, meta, err := stripUploadImageMetadata(body, size, meta)
+	if err != nil {
+		return nil, 0, meta, err
+	}
+	meta.imageMetadataStripped = true
+	return body, size, meta, nil
+}
+
+// StripImageMetadataReader 移除 JPEG、PNG、WebP 图片中的 EXIF、XMP 和 GPS 信息
+// 通过文件头识别格式，不是这三种图片时原样返回数据（isImage 为 false）。
+// 图片会整体读入内存后按块重写，不重新编码，画质不变；JPEG 的方向信息会保留。
+// expected 为客户端提供的期望校验值，针对原始数据校验，处理后的数据不再与之相同
+func StripImageMetadataReader(body io.Reader, size int64, expected Checksums) (r io.Reader, newSize int64, isImage bool, err error) {
+	magic := make([]byte, imageMagicSize)
+	n, err := io.ReadFull(body, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, 0, false, fmt.Errorf("读取文件内容失败: %w", err)
+	}
+	magic = magic[:n]
+
+	format := detectImageFormat(magic)
+	if format == "" {
+		// 可回退的数据源（表单临时文件等）退回原位置，保留失败后切换账户重试的能力
+		if seeker, ok := body.(io.Seeker); ok {
+			if _, err := seeker.Seek(int64(-n), io.SeekCurrent); err == nil {
+				return body, size, false, nil
+			}
+		}
+		return io.MultiReader(bytes.NewReader(magic), body), size, false, nil
+	}
+
+	if size > MaxImageMetadataStripSize {
+		return nil, 0, true, fmt.Errorf("%w: 图片超过 %d MB", ErrStripImageMetadata, MaxImageMetadataStripSize>>20)
+	}
+	rest, err := io.ReadAll(io.LimitReader(body, MaxImageMetadataStripSize+1-int64(n)))
+	if err != nil {
+		return nil, 0, true, fmt.Errorf("读取文件内容失败: %w", err)
+	}
+	data := append(magic, rest...)
+	if int64(len(data)) > MaxImageMetadataStripSize {
+		return nil, 0, true, fmt.Errorf("%w: 图片超过 %d MB", ErrStripImageMetadata, MaxImageMetadataStripSize>>20)
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return nil, 0, true, fmt.Errorf("%w: 声明大小 %d 字节，实际收到 %d 字节", ErrIncompleteUpload, size, len(data))
+	}
+
+	if !expected.IsZero() {
+		sum := sha256.Sum256(data)
+		actual := Checksums{
+			SHA256: hex.EncodeToString(sum[:]),
+			CRC32C: crc32cHex(crc32.Checksum(data, crc32cTable)),
+		}
+		if err := expected.verify(actual); err != nil {
+			return nil, 0, true, err
+		}
+	}
+
+	stripped, err := stripImageMetadata(format, data)
+	if err != nil {
+		return nil, 0, true, fmt.Errorf("%w: %v", ErrStripImageMetadata, err)
+	}
+	if len(stripped) != len(data) {
+		log.Printf("[Upload] 已移除图片元数据 (%s, %d -> %d 字节)", format, len(data), len(stripped))
+	}
+	return bytes.NewReader(stripped), int64(len(stripped)), true, nil
+}
+
+// stripUploadImageMetadata 上传前移除图片元数据
+// 期望校验值已针对原始数据校验，处理后的图片内容会变化，因此清空
+func stripUploadImageMetadata(body io.Reader, size int64, meta ObjectMetadata) (io.Reader, int64, ObjectMetadata, error) {
+	r, newSize, isImage, err := StripImageMetadataReader(body, size, meta.Expected)
+	if err != nil {
+		return nil, 0, meta, err
+	}
+	if isImage {
+		meta.Expected = Checksums{}
+	}
+	return r, newSize, meta, nil
+}
+
+// detectImageFormat 根据文件头识别支持移除元数据的图片格式
+func detectImageFormat(magic []byte) string {
+	switch {
+	case len(magic) >= 3 && magic[0] == 0xFF && magic[1] == 0xD8 && magic[2] == 0xFF:
+		return imageFormatJPEG
+	case bytes.HasPrefix(magic, pngSignature):
+		return imageFormatPNG
+	case len(magic) >= 12 && string(magic[0:4]) == "RIFF" && string(magic[8:12]) == "WEBP":
+		return imageFormatWebP
+	}
+	return ""
+}
+
+// stripImageMetadata 按格式移除元数据
+func stripImageMetadata(format string, data []byte) ([]byte, error) {
+	switch format {
+	case imageFormatJPEG:
+		return stripJPEGMetadata(data)
+	case imageFormatPNG:
+		return stripPNGMetadata(data)
+	case imageFormatWebP:
+		return stripWebPMetadata(data)
+	}
+	return data, nil
+}
+
+// stripJPEGMetadata 移除 JPEG 的 APP1（EXIF/XMP）、APP13（Photoshop/IPTC）和注释段
+// 图像数据（SOS 之后）原样保留；原 EXIF 中的方向标记会写入一个只含方向的最小 EXIF 段，避免图片被转向
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	pos := 2
+	exifWritten := false
+
+	for {
+		// 标记前可以有多个填充字节 0xFF
+		if pos >= len(data) || data[pos] != 0xFF {
+			return nil, errors.New("JPEG 段结构错误")
+		}
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			return nil, errors.New("JPEG 段结构错误")
+		}
+		marker := data[pos]
+		pos++
+
+		// 无长度的独立标记
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out = append(out, 0xFF, marker)
+			continue
+		}
+		if marker == 0xD9 {
+			out = append(out, 0xFF, marker)
+			return out, nil
+		}
+
+		if pos+2 > len(data) {
+			return nil, errors.New("JPEG 段长度错误")
+		}
+		length := int(binary.BigEndian.Uint16(data[pos:]))
+		if length < 2 || pos+length > len(data) {
+			return nil, errors.New("JPEG 段长度错误")
+		}
+		payload := data[pos+2 : pos+length]
+		segment := data[pos-2 : pos+length]
+		pos += length
+
+		switch {
+		case marker == 0xDA:
+			// 扫描开始，之后为图像数据，原样复制
+			out = append(out, segment...)
+			return append(out, data[pos:]...), nil
+		case marker == 0xE1 && bytes.HasPrefix(payload, exifHeader):
+			if !exifWritten {
+				if orientation := exifOrientation(payload[len(exifHeader):]); orientation > 1 {
+					out = append(out, orientationEXIF(orientation)...)
+				}
+				exifWritten = true
+			}
+		case marker == 0xE1 && (bytes.HasPrefix(payload, xmpHeader) || bytes.HasPrefix(payload, xmpExtHeader)):
+		case marker == 0xED, marker == 0xFE:
+		default:
+			out = append(out, segment...)
+		}
+	}
+}
+
+// exifOrientation 读取 EXIF（TIFF 结构）IFD0 中的方向标记，没有或无法解析时返回 0
+func exifOrientation(tiff []byte) uint16 {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		// 0x0112 Orientation，类型 SHORT
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			if v := order.Uint16(tiff[entry+8:]); v >= 1 && v <= 8 {
+				return v
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// orientationEXIF 生成只含方向标记的 APP1 EXIF 段
+func orientationEXIF(orientation uint16) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // TIFF 头，IFD0 紧随其后
+		0x00, 0x01, // 1 个条目
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, // Orientation, SHORT, 1 个值
+		byte(orientation >> 8), byte(orientation), 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, // 没有下一个 IFD
+	}
+	length := 2 + len(exifHeader) + len(tiff)
+	segment := []byte{0xFF, 0xE1, byte(length >> 8), byte(length)}
+	segment = append(segment, exifHeader...)
+	return append(segment, tiff...)
+}
+
+// stripPNGMetadata 移除 PNG 的 eXIf、文本和时间块，其余块原样保留
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	pos := len(pngSignature)
+
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, errors.New("PNG 块结构错误")
+		}
+		length := int64(binary.BigEndian.Uint32(data[pos:]))
+		end := int64(pos) + 12 + length
+		if end > int64(len(data)) {
+			return nil, errors.New("PNG 块长度错误")
+		}
+		chunkType := string(data[pos+4 : pos+8])
+		if !pngMetadataChunks[chunkType] {
+			out = append(out, data[pos:end]...)
+		}
+		pos = int(end)
+		if chunkType == "IEND" {
+			return out, nil
+		}
+	}
+	return nil, errors.New("PNG 缺少 IEND 块")
+}
+
+// stripWebPMetadata 移除 WebP 的 EXIF 和 XMP 块，同时清除 VP8X 中对应的标记并修正 RIFF 长度
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	riffSize := int64(binary.LittleEndian.Uint32(data[4:8]))
+	if riffSize+8 > int64(len(data)) || riffSize < 4 {
+		return nil, errors.New("WebP 文件长度错误")
+	}
+	data = data[:riffSize+8]
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	pos := int64(12)
+	vp8x := -1
+
+	for pos < int64(len(data)) {
+		if pos+8 > int64(len(data)) {
+			return nil, errors.New("WebP 块结构错误")
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int64(binary.LittleEndian.Uint32(data[pos+4:]))
+		// 块数据长度为奇数时补齐一个字节
+		end := pos + 8 + size + size&1
+		if end > int64(len(data)) {
+			if pos+8+size != int64(len(data)) {
+				return nil, errors.New("WebP 块长度错误")
+			}
+			end = int64(len(data))
+		}
+		switch fourCC {
+		case "EXIF", "XMP ":
+		default:
+			if fourCC == "VP8X" && size >= 10 {
+				vp8x = len(out) + 8
+			}
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+
+	if vp8x >= 0 {
+		// VP8X 标记位：0x08 EXIF，0x04 XMP
+		out[vp8x] &^= 0x08 | 0x04
+	}
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -26,7 +27,7 @@ func CheckAndDeleteExpiredFiles(ctx context.Context) {
 
 	for _, exp := range expiredFiles {
 		// 删除 S3 中的文件
-		err := DeleteFile(ctx, exp.AccountID, exp.FileKey)
+		err := deleteFile(ctx, exp.AccountID, exp.FileKey, exp.Size)
 		if err != nil {
 			log.Printf("[Expiration] 删除文件失败 (accountId=%s, key=%s): %v", exp.AccountID, exp.FileKey, err)
 			failCount++
@@ -39,6 +40,7 @@ func CheckAndDeleteExpiredFiles(ctx context.Context) {
 			// 文件已删除，记录删除失败也计入成功
 		}
 
+		PublishEvent(EventFileExpired, FileEventData{AccountID: exp.AccountID, Key: exp.FileKey})
 		successCount++
 		log.Printf("[Expiration] 已删除过期文件: %s/%s", exp.AccountID, exp.FileKey)
 	}
@@ -56,8 +58,8 @@ func CleanupExpiredFilesByAccount(ctx context.Context, accountID string) {
 	}
 }
 
-// CreateFileExpirationRecord 创建文件到期记录
-func CreateFileExpirationRecord(accountID, fileKey string, expirationDays int) error {
+// CreateFileExpirationRecord 创建文件到期记录，size 为文件大小，到期删除时用于更新容量计数
+func CreateFileExpirationRecord(accountID, fileKey string, size int64, expirationDays int) error {
 	if expirationDays <= 0 {
 		// 永久文件，不创建到期记录
 		return nil
@@ -67,6 +69,7 @@ func CreateFileExpirationRecord(accountID, fileKey string, expirationDays int) e
 	return store.CreateFileExpiration(&store.FileExpiration{
 		AccountID: accountID,
 		FileKey:   fileKey,
+		Size:      size,
 		ExpiresAt: expiresAt,
 	})
 }
@@ -75,3 +78,18 @@ func CreateFileExpirationRecord(accountID, fileKey string, expirationDays int) e
 func DeleteFileExpirationRecord(accountID, fileKey string) error {
 	return store.DeleteFileExpiration(accountID, fileKey)
 }
+
+// RecordUploadExpiration 为上传结果创建文件到期记录
+// 复用的已有对象按所有引用中最晚的到期时间保留，新对象仅在 expirationDays > 0 时创建记录
+func RecordUploadExpiration(result *UploadResult, expirationDays int) error {
+	if result.Deduplicated {
+		if err := MergeFileExpirationRecord(result.ID, result.Key, result.Size, expirationDays); err != nil {
+			return fmt.Errorf("更新文件到期记录失败: %w", err)
+		}
+		return nil
+	}
+	if err := CreateFileExpirationRecord(result.ID, result.Key, result.Size, expirationDays); err != nil {
+		return fmt.Errorf("创建文件到期记录失败: %w", err)
+	}
+	return nil
+}
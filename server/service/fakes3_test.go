@@ -0,0 +1,237 @@
+package service
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"fileflow/server/store"
+)
+
+// fakeObject 测试用存储桶中的对象
+type fakeObject struct {
+	data        []byte
+	metadata    map[string]string // 小写键，不含 x-amz-meta- 前缀
+	contentType string
+	etag        string
+}
+
+// fakeMultipart 进行中的分片上传
+type fakeMultipart struct {
+	key         string
+	metadata    map[string]string
+	contentType string
+	parts       map[int][]byte
+}
+
+// fakeS3 内存中的 S3 服务，只实现上传、读取和删除测试需要的接口（路径风格）
+type fakeS3 struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	objects  map[string]*fakeObject
+	uploads  map[string]*fakeMultipart
+	nextID   int
+	requests []string // 按顺序记录的操作，如 "PutObject"、"UploadPart"
+}
+
+// newFakeS3 启动测试用 S3 服务，测试结束时关闭
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+	f := &fakeS3{t: t, objects: map[string]*fakeObject{}, uploads: map[string]*fakeMultipart{}}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// account 返回指向该服务的账户，不写入存储
+func (f *fakeS3) account(id string) *store.Account {
+	return &store.Account{
+		ID:              id,
+		Name:            id,
+		Provider:        store.ProviderGeneric,
+		Endpoint:        f.srv.URL,
+		PathStyle:       true,
+		AccessKeyId:     "test",
+		SecretAccessKey: "test",
+		BucketName:      "bucket",
+		IsActive:        true,
+	}
+}
+
+// object 返回对象，不存在时为 nil
+func (f *fakeS3) object(key string) *fakeObject {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.objects[key]
+}
+
+// count 返回指定操作的请求次数
+func (f *fakeS3) count(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if r == op {
+			n++
+		}
+	}
+	return n
+}
+
+// pendingUploads 返回尚未完成或中止的分片上传数
+func (f *fakeS3) pendingUploads() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.uploads)
+}
+
+func (f *fakeS3) record(op string) {
+	f.requests = append(f.requests, op)
+}
+
+func (f *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	_, key, _ := strings.Cut(path, "/")
+	q := r.URL.Query()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.record("CreateMultipartUpload")
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[id] = &fakeMultipart{
+			key:         key,
+			metadata:    fakeMetadata(r.Header),
+			contentType: r.Header.Get("Content-Type"),
+			parts:       map[int][]byte{},
+		}
+		writeFakeXML(w, fmt.Sprintf("<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, id))
+
+	case r.Method == http.MethodPut && q.Has("partNumber"):
+		f.record("UploadPart")
+		up := f.uploads[q.Get("uploadId")]
+		if up == nil {
+			fakeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		up.parts[n] = body
+		w.Header().Set("ETag", fakeETag(body))
+
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		f.record("CompleteMultipartUpload")
+		up := f.uploads[q.Get("uploadId")]
+		if up == nil {
+			fakeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var req struct {
+			Parts []struct {
+				PartNumber int
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &req); err != nil {
+			fakeError(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		numbers := make([]int, 0, len(req.Parts))
+		for _, p := range req.Parts {
+			numbers = append(numbers, p.PartNumber)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, n := range numbers {
+			data = append(data, up.parts[n]...)
+		}
+		obj := &fakeObject{data: data, metadata: up.metadata, contentType: up.contentType, etag: fakeETag(data)}
+		f.objects[up.key] = obj
+		delete(f.uploads, q.Get("uploadId"))
+		writeFakeXML(w, fmt.Sprintf("<CompleteMultipartUploadResult><Key>%s</Key><ETag>%s</ETag></CompleteMultipartUploadResult>", up.key, obj.etag))
+
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		f.record("AbortMultipartUpload")
+		delete(f.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		f.record("PutObject")
+		obj := &fakeObject{data: body, metadata: fakeMetadata(r.Header), contentType: r.Header.Get("Content-Type"), etag: fakeETag(body)}
+		f.objects[key] = obj
+		w.Header().Set("ETag", obj.etag)
+
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		if r.Method == http.MethodHead {
+			f.record("HeadObject")
+		} else {
+			f.record("GetObject")
+		}
+		obj := f.objects[key]
+		if obj == nil {
+			fakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		for k, v := range obj.metadata {
+			w.Header().Set("x-amz-meta-"+k, v)
+		}
+		w.Header().Set("ETag", obj.etag)
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+
+	case r.Method == http.MethodDelete:
+		f.record("DeleteObject")
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		fakeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// fakeMetadata 提取请求中的用户元数据
+func fakeMetadata(h http.Header) map[string]string {
+	meta := map[string]string{}
+	for k, v := range h {
+		if lower := strings.ToLower(k); strings.HasPrefix(lower, "x-amz-meta-") {
+			meta[strings.TrimPrefix(lower, "x-amz-meta-")] = v[0]
+		}
+	}
+	return meta
+}
+
+func fakeETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeFakeXML(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>`+body)
+}
+
+func fakeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
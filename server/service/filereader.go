@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fileSegment 文件的一段数据，对应某个账户中的一个对象
+type fileSegment struct {
+	acc     *store.Account
+	key     string
+	offset  int64 // 在文件中的起始位置
+	size    int64 // 明文大小
+	env     *envelope
+	checked bool // 是否已读取对象元数据，分块在首次读取时才检查是否加密
+}
+
+// FileReader 可 Seek 的文件读取器，读取时按当前位置对所在对象发起 Range 请求
+// 普通文件只有一段，分块文件按分块顺序拼接，可直接用于 http.ServeContent
+// 加密对象从所在数据块开始读取并解密，Size 和读取位置均为明文
+type FileReader struct {
+	Name        string    // 下载时使用的文件名
+	ContentType string    // 文件类型
+	ETag        string    // 实体标签（不含引号）
+	Size        int64     // 文件大小（字节）
+	ModTime     time.Time // 修改时间
+
+	ctx      context.Context
+	segments []fileSegment
+	pos      int64
+	seg      int               // body 所属的分段
+	body     io.ReadCloser     // 当前分段从 pos 开始的数据，Seek 后重新打开
+	metadata map[string]string // 单个对象的原始用户元数据，复制到其他账户时使用
+}
+
+// OpenFile 打开账户中的文件用于下载，分块文件会从各分块所在账户读取
+func OpenFile(ctx context.Context, accountID, key string) (*FileReader, error) {
+	if accountID == "imgbb" {
+		return nil, fmt.Errorf("ImgBB 文件不支持通过 FileFlow 下载")
+	}
+
+	if sf, err := store.GetStripedFileByKey(accountID, key); err == nil {
+		return openStripedFile(ctx, sf)
+	}
+
+	acc, err := store.GetAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return openObject(ctx, acc, key)
+}
+
+// openObject 打开账户中的单个对象
+func openObject(ctx context.Context, acc *store.Account, key string) (*FileReader, error) {
+	head, err := getS3Client(acc).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(acc.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("获取文件元数据失败: %w", err)
+	}
+
+	meta := decodeObjectMetadata(head.Metadata)
+	env, err := openEnvelope(meta)
+	if err != nil {
+		return nil, err
+	}
+	size := aws.ToInt64(head.ContentLength)
+	if env != nil {
+		size = PlaintextSize(size)
+	}
+
+	name := meta[MetaOriginalName]
+	if name == "" {
+		name = path.Base(key)
+	}
+	return &FileReader{
+		Name:        name,
+		ContentType: aws.ToString(head.ContentType),
+		ETag:        strings.Trim(aws.ToString(head.ETag), `"`),
+		Size:        size,
+		ModTime:     aws.ToTime(head.LastModified),
+		ctx:         ctx,
+		segments:    []fileSegment{{acc: acc, key: key, size: size, env: env, checked: true}},
+		metadata:    head.Metadata,
+	}, nil
+}
+
+// openStripedFile 按分块清单打开分块文件
+func openStripedFile(ctx context.Context, sf *store.StripedFile) (*FileReader, error) {
+	segments := make([]fileSegment, 0, len(sf.Chunks))
+	for _, chunk := range sf.Chunks {
+		acc, err := store.GetAccountByID(chunk.AccountID)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个分块所在的账户不存在: %w", chunk.Index+1, err)
+		}
+		segments = append(segments, fileSegment{acc: acc, key: chunk.Key, offset: chunk.Offset, size: chunk.Size})
+	}
+
+	name := sf.OriginalName
+	if name == "" {
+		name = path.Base(sf.FileKey)
+	}
+	return &FileReader{
+		Name:        name,
+		ContentType: sf.ContentType,
+		ETag:        sf.SHA256,
+		Size:        sf.Size,
+		ModTime:     stripedFileModTime(sf),
+		ctx:         ctx,
+		segments:    segments,
+	}, nil
+}
+
+// Read 读取当前位置的数据，读到分段末尾时关闭该分段，下次读取打开下一段
+func (r *FileReader) Read(p []byte) (int, error) {
+	if r.pos >= r.Size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	seg := r.segments[r.seg]
+	end := seg.offset + seg.size
+	if remaining := end - r.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.body.Read(p)
+	r.pos += int64(n)
+
+	if r.pos == end {
+		r.body.Close()
+		r.body = nil
+		if err == io.EOF {
+			err = nil
+		}
+	} else if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// open 打开当前位置所在的分段，从该位置读取到分段末尾
+// 加密对象从该位置所在的数据块读取到对象末尾，解密后跳过数据块中位置之前的部分
+func (r *FileReader) open() error {
+	for i := range r.segments {
+		seg := &r.segments[i]
+		if r.pos < seg.offset || r.pos >= seg.offset+seg.size {
+			continue
+		}
+		if !seg.checked {
+			if err := seg.check(r.ctx); err != nil {
+				return err
+			}
+		}
+
+		offset := r.pos - seg.offset
+		rng := fmt.Sprintf("bytes=%d-%d", offset, seg.size-1)
+		if seg.env != nil {
+			rng = fmt.Sprintf("bytes=%d-", encryptedOffset(offset))
+		}
+		out, err := getS3Client(seg.acc).GetObject(r.ctx, &s3.GetObjectInput{
+			Bucket: aws.String(seg.acc.BucketName),
+			Key:    aws.String(seg.key),
+			Range:  aws.String(rng),
+		})
+		if err != nil {
+			return fmt.Errorf("读取账户 %s 中的文件失败: %w", seg.acc.Name, err)
+		}
+		r.seg = i
+		r.body = out.Body
+		if seg.env != nil {
+			r.body = newDecryptReader(seg.env, out.Body, seg.size, offset)
+		}
+		return nil
+	}
+	return fmt.Errorf("文件分块不完整，缺少位置 %d 的数据", r.pos)
+}
+
+// check 读取分段对象的元数据，确认是否加密以及大小与清单一致
+func (seg *fileSegment) check(ctx context.Context) error {
+	head, err := getS3Client(seg.acc).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(seg.acc.BucketName),
+		Key:    aws.String(seg.key),
+	})
+	if err != nil {
+		return fmt.Errorf("获取账户 %s 中的分块元数据失败: %w", seg.acc.Name, err)
+	}
+	env, err := openEnvelope(decodeObjectMetadata(head.Metadata))
+	if err != nil {
+		return err
+	}
+	size := aws.ToInt64(head.ContentLength)
+	if env != nil {
+		size = PlaintextSize(size)
+	}
+	if size != seg.size {
+		return fmt.Errorf("账户 %s 中的分块大小为 %d 字节，应为 %d 字节", seg.acc.Name, size, seg.size)
+	}
+	seg.env = env
+	seg.checked = true
+	return nil
+}
+
+// Seek 移动读取位置，位置变化时丢弃已打开的分段
+func (r *FileReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.Size + offset
+	default:
+		return 0, fmt.Errorf("无效的 whence: %d", whence)
+	}
+	if pos < 0 {
+		return 0, fmt.Errorf("无效的读取位置: %d", pos)
+	}
+
+	if pos != r.pos && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+// Close 关闭当前打开的分段
+func (r *FileReader) Close() error {
+	if r.body != nil {
+		err := r.body.Close()
+		r.body = nil
+		return err
+	}
+	return nil
+}
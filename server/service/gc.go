@@ -3,7 +3,9 @@ package service
 import (
 	"context"
 	"log"
+	"path"
 	"sort"
+	"strings"
 
 	"fileflow/server/store"
 
@@ -31,6 +33,9 @@ func RunGC(ctx context.Context, acc *store.Account) error {
 		return err
 	}
 
+	// 缩略图随原图一起删除，不单独作为候选（原图已不存在的缩略图除外）
+	files, thumbSizes := groupThumbnailsForGC(files)
+
 	// 按 LastModified 升序排列（最旧的在前）
 	sort.Slice(files, func(i, j int) bool {
 		return files[i].LastModified.Before(files[j].LastModified)
@@ -38,7 +43,7 @@ func RunGC(ctx context.Context, acc *store.Account) error {
 
 	// 计算需要删除多少容量才能降到 99.5%
 	targetSize := int64(float64(acc.Quota.MaxSizeBytes) * GCThreshold / 100)
-	currentSize := acc.Usage.SizeBytes
+	currentSize := acc.EstimatedUsage().SizeBytes
 	needToDelete := currentSize - targetSize
 
 	if needToDelete <= 0 {
@@ -47,6 +52,7 @@ func RunGC(ctx context.Context, acc *store.Account) error {
 
 	var deletedSize int64
 	var deletedFiles []string
+	var evicted []GCEvictedFile
 
 	for _, f := range files {
 		if deletedSize >= needToDelete {
@@ -63,14 +69,36 @@ func RunGC(ctx context.Context, acc *store.Account) error {
 			continue
 		}
 
+		RecordStoredBytes(acc.ID, -f.Size)
+		// 物理对象已删除，所有引用随之失效：移除内容索引和到期记录
+		if obj, err := store.GetFileObjectByKey(acc.ID, f.Key); err == nil {
+			store.DeleteFileObject(obj.ID)
+		}
+		DeleteFileExpirationRecord(acc.ID, f.Key)
+		store.DeleteFileMetadata(acc.ID, f.Key)
+		// 其他账户中的副本保留，由修复任务补齐副本数
+		store.RemoveReplicaAccount(acc.ID, f.Key)
 		deletedSize += f.Size
 		deletedFiles = append(deletedFiles, f.Key)
+		evicted = append(evicted, GCEvictedFile{Key: f.Key, Size: f.Size})
+		if size, ok := thumbSizes[f.Key]; ok {
+			deleteThumbnails(ctx, client, acc, f.Key)
+			deletedSize += size
+		}
 		log.Printf("[GC] 已删除: %s (%.2f KB)", f.Key, float64(f.Size)/1024)
 	}
 
 	log.Printf("[GC] 账户 %s GC 完成，共删除 %d 个文件，释放 %.2f MB",
 		acc.Name, len(deletedFiles), float64(deletedSize)/1024/1024)
 
+	if len(evicted) > 0 {
+		PublishEvent(EventGCEvicted, GCEventData{
+			AccountID:   acc.ID,
+			AccountName: acc.Name,
+			Files:       evicted,
+			FreedBytes:  deletedSize,
+		})
+	}
 	return nil
 }
 
@@ -103,6 +131,10 @@ func listAllFilesForGC(ctx context.Context, client *s3.Client, bucket string) ([
 		}
 
 		for _, obj := range page.Contents {
+			// 分块对象属于分块文件的一部分，单独删除会破坏整个文件
+			if IsStripeChunkKey(aws.ToString(obj.Key)) {
+				continue
+			}
 			files = append(files, FileInfo{
 				Key:          aws.ToString(obj.Key),
 				Size:         aws.ToInt64(obj.Size),
@@ -113,3 +145,28 @@ func listAllFilesForGC(ctx context.Context, client *s3.Client, bucket string) ([
 
 	return files, nil
 }
+
+// groupThumbnailsForGC 从候选文件中移除缩略图，返回每个原图的缩略图总大小
+// 原图不在列表中的缩略图保留为普通候选，由 GC 按时间单独删除
+func groupThumbnailsForGC(files []FileInfo) ([]FileInfo, map[string]int64) {
+	originals := make(map[string]bool, len(files))
+	for _, f := range files {
+		if !IsThumbnailKey(f.Key) {
+			originals[f.Key] = true
+		}
+	}
+
+	thumbSizes := make(map[string]int64)
+	candidates := files[:0]
+	for _, f := range files {
+		if IsThumbnailKey(f.Key) {
+			original := strings.TrimSuffix(path.Dir(f.Key), ThumbnailDirSuffix)
+			if originals[original] {
+				thumbSizes[original] += f.Size
+				continue
+			}
+		}
+		candidates = append(candidates, f)
+	}
+	return candidates, thumbSizes
+}
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// 账户健康状态和熔断
+// 通过 SDK 发出的每个请求（包括重试）按结果和耗时计入账户的健康统计。连续失败或最近请求的失败率过高时
+// 熔断器打开，账户暂时不参与自动选择账户和文件列表；之后按退避间隔在后台用 HeadBucket 探测，成功后恢复。
+// 健康状态只保存在内存中，服务重启后所有账户从正常状态开始
+
+const (
+	// HealthStateHealthy 账户正常
+	HealthStateHealthy = "healthy"
+	// HealthStateOpen 熔断中，等待后台探测恢复
+	HealthStateOpen = "open"
+)
+
+const (
+	// healthWindowSize 计算失败率的最近请求数
+	healthWindowSize = 20
+	// healthMinRequests 按失败率熔断所需的最少请求数
+	healthMinRequests = 10
+	// healthMaxFailureRate 最近请求的失败率达到该值时熔断
+	healthMaxFailureRate = 0.5
+	// healthMaxConsecutiveFailures 连续失败达到该次数时熔断
+	healthMaxConsecutiveFailures = 5
+	// circuitBaseCooldown 熔断后首次探测的等待时间，探测失败时翻倍
+	circuitBaseCooldown = 30 * time.Second
+	// circuitMaxCooldown 探测等待时间的上限
+	circuitMaxCooldown = 5 * time.Minute
+	// healthProbeTimeout 单次探测的超时时间
+	healthProbeTimeout = 10 * time.Second
+	// latencyWeight 请求耗时指数移动平均中最新一次请求的权重
+	latencyWeight = 0.2
+)
+
+// ErrCircuitOpen 候选账户都处于熔断状态
+var ErrCircuitOpen = errors.New("候选账户均因请求连续失败暂停使用，请稍后重试")
+
+// AccountHealth 账户健康状态
+type AccountHealth struct {
+	State               string  `json:"state"`                 // healthy 或 open（熔断中）
+	Requests            int     `json:"requests"`              // 统计窗口内的请求数
+	Failures            int     `json:"failures"`              // 统计窗口内的失败数
+	ErrorRate           float64 `json:"errorRate"`             // 统计窗口内的失败率（0-1）
+	ConsecutiveFailures int     `json:"consecutiveFailures"`   // 连续失败次数
+	AvgLatencyMs        float64 `json:"avgLatencyMs"`          // 请求耗时的指数移动平均（毫秒）
+	LastError           string  `json:"lastError,omitempty"`   // 最近一次失败的错误
+	LastErrorAt         string  `json:"lastErrorAt,omitempty"` // 最近一次失败的时间
+	OpenedAt            string  `json:"openedAt,omitempty"`    // 熔断开始时间
+	NextProbeAt         string  `json:"nextProbeAt,omitempty"` // 下次探测时间
+}
+
+// HealthSummary 账户健康状态统计（只统计激活的账户）
+type HealthSummary struct {
+	HealthyCount     int `json:"healthyCount"`
+	CircuitOpenCount int `json:"circuitOpenCount"`
+}
+
+// accountHealth 单个账户的健康统计
+type accountHealth struct {
+	window      [healthWindowSize]bool // 最近请求是否失败（环形缓冲）
+	count, next int
+	consecutive int
+	latencyMs   float64
+	lastError   string
+	lastErrorAt time.Time
+	open        bool
+	openedAt    time.Time
+	cooldown    time.Duration
+	nextProbe   time.Time
+	probe       *time.Timer
+}
+
+var (
+	healthMu     sync.Mutex
+	healthStates = make(map[string]*accountHealth)
+)
+
+// failures 统计窗口内的失败数
+func (h *accountHealth) failures() int {
+	n := 0
+	for i := 0; i < h.count; i++ {
+		if h.window[i] {
+			n++
+		}
+	}
+	return n
+}
+
+// snapshot 转换为接口返回的健康状态
+func (h *accountHealth) snapshot() AccountHealth {
+	result := AccountHealth{
+		State:               HealthStateHealthy,
+		Requests:            h.count,
+		Failures:            h.failures(),
+		ConsecutiveFailures: h.consecutive,
+		AvgLatencyMs:        h.latencyMs,
+		LastError:           h.lastError,
+	}
+	if h.count > 0 {
+		result.ErrorRate = float64(result.Failures) / float64(h.count)
+	}
+	if !h.lastErrorAt.IsZero() {
+		result.LastErrorAt = h.lastErrorAt.UTC().Format(time.RFC3339)
+	}
+	if h.open {
+		result.State = HealthStateOpen
+		result.OpenedAt = h.openedAt.UTC().Format(time.RFC3339)
+		result.NextProbeAt = h.nextProbe.UTC().Format(time.RFC3339)
+	}
+	return result
+}
+
+// WithHealthTracking 为账户的 S3 客户端启用健康统计
+// 每次 HTTP 请求（包括重试）计入一次结果和耗时；调用方取消的请求不计入
+func WithHealthTracking(accountID string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("FileFlowHealth",
+				func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+					start := time.Now()
+					out, metadata, err := next.HandleDeserialize(ctx, in)
+					if ctx.Err() == nil {
+						status := 0
+						if resp, ok := out.RawResponse.(*smithyhttp.Response); ok {
+							status = resp.StatusCode
+						}
+						recordRequestHealth(accountID, time.Since(start), status, err)
+					}
+					return out, metadata, err
+				}), middleware.After)
+		})
+	}
+}
+
+// isAccountFailure 判断请求结果是否说明账户不可用：没有收到响应（网络错误）、5xx、限流以及认证失败（密钥被吊销等）
+// 对象不存在、条件请求失败等其他 4xx 说明账户本身可以正常响应
+func isAccountFailure(status int, err error) bool {
+	if status == 0 {
+		return err != nil && !errors.Is(err, context.Canceled)
+	}
+	return status >= 500 || status == 401 || status == 403 || status == 429
+}
+
+// recordRequestHealth 记录一次请求的结果，正常状态下满足熔断条件时打开熔断器
+// status 为响应状态码，没有收到响应时为 0
+func recordRequestHealth(accountID string, latency time.Duration, status int, err error) {
+	failed := isAccountFailure(status, err)
+
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	h := healthStates[accountID]
+	if h == nil {
+		h = &accountHealth{}
+		healthStates[accountID] = h
+	}
+
+	ms := float64(latency) / float64(time.Millisecond)
+	if h.count == 0 && h.latencyMs == 0 {
+		h.latencyMs = ms
+	} else {
+		h.latencyMs = latencyWeight*ms + (1-latencyWeight)*h.latencyMs
+	}
+
+	h.window[h.next] = failed
+	h.next = (h.next + 1) % healthWindowSize
+	h.count = min(h.count+1, healthWindowSize)
+	if !failed {
+		h.consecutive = 0
+		return
+	}
+	h.consecutive++
+	if err != nil {
+		h.lastError = err.Error()
+	} else {
+		h.lastError = fmt.Sprintf("HTTP %d", status)
+	}
+	h.lastErrorAt = time.Now()
+
+	if h.open {
+		return
+	}
+	if h.consecutive >= healthMaxConsecutiveFailures ||
+		(h.count >= healthMinRequests && float64(h.failures())/float64(h.count) >= healthMaxFailureRate) {
+		openCircuit(accountID, h)
+	}
+}
+
+// openCircuit 打开熔断器并安排后台探测（调用方持有 healthMu）
+func openCircuit(accountID string, h *accountHealth) {
+	h.open = true
+	h.openedAt = time.Now()
+	h.cooldown = circuitBaseCooldown
+	scheduleProbe(accountID, h)
+
+	name := accountID
+	if acc, err := store.GetAccountByID(accountID); err == nil {
+		name = acc.Name
+	}
+	log.Printf("[Health] 账户 %s 请求连续失败，暂停使用 %s（最后错误: %s）", name, h.cooldown, h.lastError)
+}
+
+// scheduleProbe 在冷却时间后探测账户（调用方持有 healthMu）
+func scheduleProbe(accountID string, h *accountHealth) {
+	h.nextProbe = time.Now().Add(h.cooldown)
+	if h.probe != nil {
+		h.probe.Stop()
+	}
+	h.probe = time.AfterFunc(h.cooldown, func() { probeAccount(accountID) })
+}
+
+// probeAccount 用 HeadBucket 探测熔断中的账户，成功时恢复，失败时加倍等待时间后再次探测
+// 账户已删除或停用时丢弃其健康状态
+func probeAccount(accountID string) {
+	acc, err := store.GetAccountByID(accountID)
+	if err != nil || !acc.IsActive {
+		ResetAccountHealth(accountID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthProbeTimeout)
+	_, err = getS3Client(acc).HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(acc.BucketName),
+	})
+	cancel()
+
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	h := healthStates[accountID]
+	if h == nil || !h.open {
+		return
+	}
+	var re interface{ HTTPStatusCode() int }
+	if err == nil || (errors.As(err, &re) && !isAccountFailure(re.HTTPStatusCode(), err)) {
+		// 恢复后清空统计窗口，避免熔断前的失败立即再次触发
+		*h = accountHealth{latencyMs: h.latencyMs, lastError: h.lastError, lastErrorAt: h.lastErrorAt}
+		log.Printf("[Health] 账户 %s 探测成功，恢复使用", acc.Name)
+		return
+	}
+	h.cooldown = min(h.cooldown*2, circuitMaxCooldown)
+	scheduleProbe(accountID, h)
+	log.Printf("[Health] 账户 %s 探测失败: %v，%s 后重试", acc.Name, err, h.cooldown)
+}
+
+// ResetAccountHealth 清空账户的健康统计并关闭熔断器，账户配置更新或删除后调用
+func ResetAccountHealth(accountID string) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	if h := healthStates[accountID]; h != nil {
+		if h.probe != nil {
+			h.probe.Stop()
+		}
+		delete(healthStates, accountID)
+	}
+}
+
+// GetAccountHealth 获取账户的健康状态，没有请求记录时为正常
+func GetAccountHealth(accountID string) AccountHealth {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	if h := healthStates[accountID]; h != nil {
+		return h.snapshot()
+	}
+	return AccountHealth{State: HealthStateHealthy}
+}
+
+// IsCircuitOpen 账户是否处于熔断状态
+func IsCircuitOpen(accountID string) bool {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	h := healthStates[accountID]
+	return h != nil && h.open
+}
+
+// GetHealthSummary 统计激活账户的健康状态
+func GetHealthSummary() HealthSummary {
+	var summary HealthSummary
+	for _, acc := range store.GetActiveAccounts() {
+		if IsCircuitOpen(acc.ID) {
+			summary.CircuitOpenCount++
+		} else {
+			summary.HealthyCount++
+		}
+	}
+	return summary
+}
+
+// withoutOpenCircuits 排除熔断中的账户，全部熔断时返回 ErrCircuitOpen
+func withoutOpenCircuits(accounts []store.Account) ([]store.Account, error) {
+	result := make([]store.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		if !IsCircuitOpen(acc.ID) {
+			result = append(result, acc)
+		}
+	}
+	if len(result) == 0 && len(accounts) > 0 {
+		return nil, ErrCircuitOpen
+	}
+	return result, nil
+}
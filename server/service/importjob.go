@@ -0,0 +1,456 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fileflow/server/store"
+)
+
+const (
+	// ImportJobWorkers 同时执行的 URL 导入任务数
+	ImportJobWorkers = 3
+	// DefaultImportMaxAttempts 默认最多执行次数（含首次）
+	DefaultImportMaxAttempts = 3
+	// MaxImportMaxAttempts 允许设置的最多执行次数
+	MaxImportMaxAttempts = 10
+	// ImportJobRetention 已结束任务的保留时间
+	ImportJobRetention = 7 * 24 * time.Hour
+	// importRetryDelay 重试等待时间，按已执行次数递增
+	importRetryDelay = 10 * time.Second
+	// importCancelWait 取消任务时等待正在进行的上传中止的最长时间
+	importCancelWait = 10 * time.Second
+)
+
+var (
+	// ErrImportJobNotFound 导入任务不存在
+	ErrImportJobNotFound = errors.New("导入任务不存在")
+	// ErrImportJobFinished 导入任务已结束，无法取消
+	ErrImportJobFinished = errors.New("导入任务已结束")
+	// ErrImportJobNotRetryable 只有失败或已取消的任务可以重试
+	ErrImportJobNotRetryable = errors.New("只有失败或已取消的任务可以重试")
+)
+
+// ImportJobInfo 导入任务状态，执行中的任务包含实时进度
+type ImportJobInfo struct {
+	ID              string        `json:"id"`
+	URL             string        `json:"url"`
+	Status          string        `json:"status"`
+	AccountID       string        `json:"accountId,omitempty"`
+	Pool            string        `json:"pool,omitempty"`
+	Path            string        `json:"path,omitempty"`
+	ExpirationDays  int           `json:"expirationDays"`
+	TokenID         string        `json:"tokenId,omitempty"`
+	Attempts        int           `json:"attempts"`
+	MaxAttempts     int           `json:"maxAttempts"`
+	TotalBytes      int64         `json:"totalBytes"` // 源文件大小，未知时为 -1
+	DownloadedBytes int64         `json:"downloadedBytes"`
+	UploadedBytes   int64         `json:"uploadedBytes"`
+	TargetAccountID string        `json:"targetAccountId,omitempty"`
+	Result          *UploadResult `json:"result,omitempty"` // 完成后与 /api/upload 的返回一致
+	Error           string        `json:"error,omitempty"`
+	CreatedAt       string        `json:"createdAt"`
+	UpdatedAt       string        `json:"updatedAt"`
+	FinishedAt      string        `json:"finishedAt,omitempty"`
+}
+
+// Finished 任务是否已结束
+func (j *ImportJobInfo) Finished() bool {
+	return importJobFinished(j.Status)
+}
+
+// importJobRun 正在调度的任务，进度只保存在内存中，状态变化时才写入存储
+type importJobRun struct {
+	cancel     context.CancelFunc
+	canceled   atomic.Bool
+	done       chan struct{}
+	total      atomic.Int64
+	downloaded atomic.Int64
+	uploaded   atomic.Int64
+	account    atomic.Value // string
+}
+
+var (
+	importRuns     = make(map[string]*importJobRun)
+	importRunsLock sync.Mutex
+	importSlots    = make(chan struct{}, ImportJobWorkers)
+)
+
+// importJobFinished 状态是否为结束状态
+func importJobFinished(status string) bool {
+	return status == store.ImportJobSucceeded || status == store.ImportJobFailed || status == store.ImportJobCanceled
+}
+
+// StartImportJobs 恢复服务重启前未完成的导入任务
+// 执行中断的任务重新从头下载，已执行次数保留
+func StartImportJobs() {
+	count := 0
+	for _, job := range store.GetImportJobs() {
+		if importJobFinished(job.Status) {
+			continue
+		}
+		if job.Status == store.ImportJobRunning {
+			job.Status = store.ImportJobPending
+			if err := store.UpdateImportJob(&job); err != nil {
+				log.Printf("[Import] 恢复导入任务失败 (id=%s): %v", job.ID, err)
+				continue
+			}
+		}
+		startImportJob(job.ID)
+		count++
+	}
+	if count > 0 {
+		log.Printf("[Import] 已恢复 %d 个未完成的导入任务", count)
+	}
+}
+
+// SubmitImportJobs 创建导入任务并加入执行队列
+// MaxAttempts 为 0 时使用默认值，超出范围时取上限
+func SubmitImportJobs(jobs []*store.ImportJob) error {
+	for _, job := range jobs {
+		job.Status = store.ImportJobPending
+		job.TotalBytes = -1
+		if job.MaxAttempts <= 0 {
+			job.MaxAttempts = DefaultImportMaxAttempts
+		}
+		if job.MaxAttempts > MaxImportMaxAttempts {
+			job.MaxAttempts = MaxImportMaxAttempts
+		}
+	}
+	if err := store.CreateImportJobs(jobs); err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		startImportJob(job.ID)
+	}
+	return nil
+}
+
+// GetImportJob 获取导入任务状态
+func GetImportJob(id string) (*ImportJobInfo, error) {
+	job, err := store.GetImportJobByID(id)
+	if err != nil {
+		return nil, ErrImportJobNotFound
+	}
+	info := importJobInfo(job)
+	return &info, nil
+}
+
+// ListImportJobs 获取导入任务列表（按创建时间倒序），tokenID 非空时只返回该 Token 创建的任务
+func ListImportJobs(tokenID string) []ImportJobInfo {
+	jobs := store.GetImportJobs()
+	result := make([]ImportJobInfo, 0, len(jobs))
+	for i := range jobs {
+		if tokenID != "" && jobs[i].TokenID != tokenID {
+			continue
+		}
+		result = append(result, importJobInfo(&jobs[i]))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt > result[j].CreatedAt
+	})
+	return result
+}
+
+// CancelImportJob 取消导入任务，已写入的分片上传会被中止
+func CancelImportJob(id string) (*ImportJobInfo, error) {
+	job, err := store.GetImportJobByID(id)
+	if err != nil {
+		return nil, ErrImportJobNotFound
+	}
+	if importJobFinished(job.Status) {
+		return nil, ErrImportJobFinished
+	}
+
+	importRunsLock.Lock()
+	run := importRuns[id]
+	importRunsLock.Unlock()
+
+	if run != nil {
+		run.canceled.Store(true)
+		run.cancel()
+		select {
+		case <-run.done:
+		case <-time.After(importCancelWait):
+		}
+	} else {
+		// 未在调度中的任务直接标记为已取消
+		job.Status = store.ImportJobCanceled
+		job.FinishedAt = store.NowString()
+		if err := store.UpdateImportJob(job); err != nil {
+			return nil, err
+		}
+	}
+	return GetImportJob(id)
+}
+
+// RetryImportJob 重新执行失败或已取消的导入任务，执行次数重新计算
+func RetryImportJob(id string) (*ImportJobInfo, error) {
+	job, err := store.GetImportJobByID(id)
+	if err != nil {
+		return nil, ErrImportJobNotFound
+	}
+	if job.Status != store.ImportJobFailed && job.Status != store.ImportJobCanceled {
+		return nil, ErrImportJobNotRetryable
+	}
+
+	job.Status = store.ImportJobPending
+	job.Attempts = 0
+	job.TotalBytes = -1
+	job.DownloadedBytes = 0
+	job.UploadedBytes = 0
+	job.TargetAccountID = ""
+	job.Result = ""
+	job.Error = ""
+	job.FinishedAt = ""
+	if err := store.UpdateImportJob(job); err != nil {
+		return nil, err
+	}
+
+	startImportJob(id)
+	return GetImportJob(id)
+}
+
+// CleanupFinishedImportJobs 删除超过保留时间的已结束任务
+func CleanupFinishedImportJobs() {
+	count, err := store.DeleteFinishedImportJobs(time.Now().Add(-ImportJobRetention))
+	if err != nil {
+		log.Printf("[Import] 清理已结束的导入任务失败: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("[Import] 已清理 %d 个已结束的导入任务", count)
+	}
+}
+
+// importJobInfo 转换为任务状态，执行中的任务使用内存中的实时进度
+func importJobInfo(job *store.ImportJob) ImportJobInfo {
+	info := ImportJobInfo{
+		ID:              job.ID,
+		URL:             job.URL,
+		Status:          job.Status,
+		AccountID:       job.AccountID,
+		Pool:            job.Pool,
+		Path:            job.Path,
+		ExpirationDays:  job.ExpirationDays,
+		TokenID:         job.TokenID,
+		Attempts:        job.Attempts,
+		MaxAttempts:     job.MaxAttempts,
+		TotalBytes:      job.TotalBytes,
+		DownloadedBytes: job.DownloadedBytes,
+		UploadedBytes:   job.UploadedBytes,
+		TargetAccountID: job.TargetAccountID,
+		Error:           job.Error,
+		CreatedAt:       job.CreatedAt,
+		UpdatedAt:       job.UpdatedAt,
+		FinishedAt:      job.FinishedAt,
+	}
+	if job.Result != "" {
+		var result UploadResult
+		if err := json.Unmarshal([]byte(job.Result), &result); err == nil {
+			info.Result = &result
+		}
+	}
+
+	if job.Status == store.ImportJobRunning {
+		importRunsLock.Lock()
+		run := importRuns[job.ID]
+		importRunsLock.Unlock()
+		if run != nil {
+			info.TotalBytes = run.total.Load()
+			info.DownloadedBytes = run.downloaded.Load()
+			info.UploadedBytes = run.uploaded.Load()
+			if account, _ := run.account.Load().(string); account != "" {
+				info.TargetAccountID = account
+			}
+		}
+	}
+	return info
+}
+
+// startImportJob 为任务启动调度协程，同一任务只会有一个协程
+func startImportJob(id string) {
+	importRunsLock.Lock()
+	defer importRunsLock.Unlock()
+
+	if _, ok := importRuns[id]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &importJobRun{cancel: cancel, done: make(chan struct{})}
+	importRuns[id] = run
+
+	go func() {
+		defer func() {
+			importRunsLock.Lock()
+			delete(importRuns, id)
+			importRunsLock.Unlock()
+			cancel()
+			close(run.done)
+		}()
+		runImportJob(ctx, id, run)
+	}()
+}
+
+// runImportJob 执行导入任务直到成功、失败（重试次数用尽）或被取消
+func runImportJob(ctx context.Context, id string, run *importJobRun) {
+	for {
+		// 等待空闲的执行槽位
+		select {
+		case importSlots <- struct{}{}:
+		case <-ctx.Done():
+			finishImportJob(id, run, nil, nil)
+			return
+		}
+
+		job, err := store.GetImportJobByID(id)
+		if err != nil {
+			<-importSlots
+			return
+		}
+
+		job.Status = store.ImportJobRunning
+		job.Attempts++
+		job.Error = ""
+		if err := store.UpdateImportJob(job); err != nil {
+			log.Printf("[Import] 更新导入任务失败 (id=%s): %v", id, err)
+		}
+
+		run.total.Store(-1)
+		run.downloaded.Store(0)
+		run.uploaded.Store(0)
+		run.account.Store("")
+		result, err := executeImportJob(ctx, job, run)
+		<-importSlots
+
+		if err == nil || run.canceled.Load() || job.Attempts >= job.MaxAttempts ||
+			errors.Is(err, ErrKeyConflict) || errors.Is(err, ErrURLRejected) {
+			finishImportJob(id, run, result, err)
+			return
+		}
+
+		// 等待后重试
+		log.Printf("[Import] 导入任务第 %d 次执行失败，稍后重试 (id=%s): %v", job.Attempts, id, err)
+		if err := updateImportJob(id, run, func(job *store.ImportJob) {
+			job.Status = store.ImportJobPending
+			job.Error = err.Error()
+		}); err != nil {
+			log.Printf("[Import] 更新导入任务失败 (id=%s): %v", id, err)
+		}
+
+		select {
+		case <-time.After(time.Duration(job.Attempts) * importRetryDelay):
+		case <-ctx.Done():
+			finishImportJob(id, run, nil, nil)
+			return
+		}
+	}
+}
+
+// executeImportJob 下载 URL 并按前端上传规则上传到 R2
+func executeImportJob(ctx context.Context, job *store.ImportJob, run *importJobRun) (*UploadResult, error) {
+	download, err := DownloadURL(ctx, job.URL, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer download.Body.Close()
+	run.total.Store(download.Size)
+
+	fileName := FilenameFromURL(job.URL, download.Ext)
+	naming := KeyNaming{
+		FileName: fileName,
+		Ext:      download.Ext,
+		Path:     job.Path,
+		TokenID:  job.TokenID,
+		Pool:     job.Pool,
+	}
+	meta := ObjectMetadata{
+		OriginalName: fileName,
+		Uploader:     job.Uploader,
+		Source:       UploadSourceURL,
+		SourceURL:    job.URL,
+	}
+
+	body := &countingReader{r: download.Body, n: &run.downloaded}
+	uploadCtx := WithUploadProgress(ctx, func(accountID string, uploaded int64) {
+		run.account.Store(accountID)
+		run.uploaded.Store(uploaded)
+	})
+
+	var result *UploadResult
+	if job.AccountID != "" {
+		result, err = UploadToAccountForClient(uploadCtx, job.AccountID, naming, meta, body, download.Size, download.ContentType)
+	} else {
+		result, err = SmartUploadForClient(uploadCtx, naming, meta, body, download.Size, download.ContentType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 到期记录创建失败不影响导入结果，仅记录日志
+	if err := RecordUploadExpiration(result, job.ExpirationDays); err != nil {
+		log.Printf("[Import] %v", err)
+	}
+	return result, nil
+}
+
+// finishImportJob 记录任务的最终状态
+// run 被取消时记为已取消，否则 err 为空记为成功，非空记为失败
+func finishImportJob(id string, run *importJobRun, result *UploadResult, err error) {
+	updateErr := updateImportJob(id, run, func(job *store.ImportJob) {
+		switch {
+		case run.canceled.Load():
+			job.Status = store.ImportJobCanceled
+		case err != nil:
+			job.Status = store.ImportJobFailed
+			job.Error = err.Error()
+		default:
+			job.Status = store.ImportJobSucceeded
+			if data, jsonErr := json.Marshal(result); jsonErr == nil {
+				job.Result = string(data)
+			}
+			job.TargetAccountID = result.ID
+		}
+		job.FinishedAt = store.NowString()
+	})
+	if updateErr != nil {
+		log.Printf("[Import] 更新导入任务失败 (id=%s): %v", id, updateErr)
+	}
+}
+
+// updateImportJob 将内存中的进度和 fn 的修改一并写入存储
+func updateImportJob(id string, run *importJobRun, fn func(job *store.ImportJob)) error {
+	job, err := store.GetImportJobByID(id)
+	if err != nil {
+		return err
+	}
+	if job.Attempts > 0 {
+		job.TotalBytes = run.total.Load()
+		job.DownloadedBytes = run.downloaded.Load()
+		job.UploadedBytes = run.uploaded.Load()
+		if account, _ := run.account.Load().(string); account != "" {
+			job.TargetAccountID = account
+		}
+	}
+	fn(job)
+	return store.UpdateImportJob(job)
+}
+
+// countingReader 统计已读取的字节数
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// KeyRotationResult 主密钥轮换的统计
+type KeyRotationResult struct {
+	Scanned        int // 检查的对象数
+	Encrypted      int // 其中加密的对象数
+	Rewrapped      int // 数据密钥已改用当前主密钥加密的对象数
+	Failed         int // 处理失败的对象数（不计入 Encrypted）
+	FailedAccounts int // 无法列出文件的账户数
+}
+
+// rotationStatus 单个对象的轮换结果
+type rotationStatus int
+
+const (
+	rotationPlain     rotationStatus = iota // 未加密
+	rotationCurrent                         // 已使用当前主密钥
+	rotationRewrapped                       // 已重新加密数据密钥
+)
+
+// copyPartSize 分片复制替换元数据时每个分片的大小
+const copyPartSize int64 = 1 << 30
+
+// RotateEncryptionKeys 把所有账户中由旧主密钥加密的数据密钥改用当前主密钥加密
+// 对象内容不变，只通过复制到自身原地替换元数据（超过 5 GiB 时使用分片复制）；
+// 结果中没有失败的对象后，才可以从 FILEFLOW_PREVIOUS_MASTER_KEYS 中移除旧密钥
+func RotateEncryptionKeys(ctx context.Context) (*KeyRotationResult, error) {
+	keys, err := loadMasterKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrEncryptionKeyMissing
+	}
+
+	result := &KeyRotationResult{}
+	for _, acc := range store.GetAccounts() {
+		if err := rotateAccountKeys(ctx, &acc, keys, result); err != nil {
+			log.Printf("[Encryption] 列出账户 %s 的文件失败: %v", acc.Name, err)
+			result.FailedAccounts++
+		}
+	}
+	return result, nil
+}
+
+// rotateAccountKeys 检查账户中的所有对象并重新加密旧的数据密钥
+// 启用加密前写入或关闭加密后写入的对象都可能存在，因此不论账户当前设置都会检查
+func rotateAccountKeys(ctx context.Context, acc *store.Account, keys []masterKey, result *KeyRotationResult) error {
+	client := getS3Client(acc)
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(acc.BucketName),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if strings.HasSuffix(key, "/") {
+				continue
+			}
+			result.Scanned++
+
+			status, err := rotateObjectKey(ctx, client, acc, key, keys)
+			if err != nil {
+				log.Printf("[Encryption] 重新加密数据密钥失败 (账户=%s, key=%s): %v", acc.Name, key, err)
+				result.Failed++
+				continue
+			}
+			switch status {
+			case rotationCurrent:
+				result.Encrypted++
+			case rotationRewrapped:
+				result.Encrypted++
+				result.Rewrapped++
+			}
+		}
+	}
+	return nil
+}
+
+// rotateObjectKey 对象的数据密钥不是由当前主密钥加密时，解密后用当前主密钥重新加密并写回元数据
+// 其余元数据和 Content-Type 等响应头保持不变
+func rotateObjectKey(ctx context.Context, client *s3.Client, acc *store.Account, key string, keys []masterKey) (rotationStatus, error) {
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(acc.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return rotationPlain, fmt.Errorf("获取文件元数据失败: %w", err)
+	}
+
+	meta := decodeObjectMetadata(head.Metadata)
+	if meta[MetaEncryption] == "" {
+		return rotationPlain, nil
+	}
+	current := keys[0]
+	if meta[MetaEncryptionKeyID] == current.id {
+		return rotationCurrent, nil
+	}
+
+	dataKey, err := unwrapDataKey(keys, meta[MetaEncryptionKeyID], meta[MetaEncryptionKey])
+	if err != nil {
+		return rotationPlain, err
+	}
+	wrapped, err := wrapDataKey(current, dataKey)
+	if err != nil {
+		return rotationPlain, err
+	}
+
+	objectMeta := make(map[string]string, len(head.Metadata))
+	for k, v := range head.Metadata {
+		objectMeta[strings.ToLower(k)] = v
+	}
+	objectMeta[MetaEncryptionKey] = wrapped
+	objectMeta[MetaEncryptionKeyID] = current.id
+
+	if err := replaceObjectMetadata(ctx, client, acc.BucketName, key, head, objectMeta); err != nil {
+		return rotationPlain, fmt.Errorf("更新对象元数据失败: %w", err)
+	}
+	return rotationRewrapped, nil
+}
+
+// replaceObjectMetadata 把对象复制到自身以替换用户元数据，Content-Type 等响应头保持不变
+// 不超过 5 GiB 时使用 CopyObject，否则通过 UploadPartCopy 分片复制，完成前原对象保持不变
+func replaceObjectMetadata(ctx context.Context, client *s3.Client, bucket, key string, head *s3.HeadObjectOutput, metadata map[string]string) error {
+	source := aws.String(bucket + "/" + escapeObjectKey(key))
+	size := aws.ToInt64(head.ContentLength)
+	if size <= maxCopyObjectSize {
+		_, err := client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:             aws.String(bucket),
+			Key:                aws.String(key),
+			CopySource:         source,
+			CopySourceIfMatch:  head.ETag,
+			MetadataDirective:  types.MetadataDirectiveReplace,
+			Metadata:           metadata,
+			ContentType:        head.ContentType,
+			ContentDisposition: head.ContentDisposition,
+			CacheControl:       head.CacheControl,
+			ContentEncoding:    head.ContentEncoding,
+		})
+		return err
+	}
+
+	created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(bucket),
+		Key:                aws.String(key),
+		Metadata:           metadata,
+		ContentType:        head.ContentType,
+		ContentDisposition: head.ContentDisposition,
+		CacheControl:       head.CacheControl,
+		ContentEncoding:    head.ContentEncoding,
+	})
+	if err != nil {
+		return fmt.Errorf("创建分片复制失败: %w", err)
+	}
+	uploadID := aws.ToString(created.UploadId)
+
+	var parts []types.CompletedPart
+	for offset, n := int64(0), int32(1); offset < size; offset, n = offset+copyPartSize, n+1 {
+		end := min(offset+copyPartSize, size) - 1
+		out, err := client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:            aws.String(bucket),
+			Key:               aws.String(key),
+			UploadId:          aws.String(uploadID),
+			PartNumber:        aws.Int32(n),
+			CopySource:        source,
+			CopySourceIfMatch: head.ETag,
+			CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", offset, end)),
+		})
+		if err != nil {
+			abortMultipartUpload(client, bucket, key, uploadID)
+			return fmt.Errorf("复制第 %d 个分片失败: %w", n, err)
+		}
+		parts = append(parts, types.CompletedPart{
+			ETag:       out.CopyPartResult.ETag,
+			PartNumber: aws.Int32(n),
+		})
+	}
+
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		abortMultipartUpload(client, bucket, key, uploadID)
+		return fmt.Errorf("完成分片复制失败: %w", err)
+	}
+	return nil
+}
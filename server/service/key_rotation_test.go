@@ -0,0 +1,91 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// 轮换后旧主密钥移到列表后面，旧对象仍可通过旧密钥解密
+func TestUnwrapWithRotatedKey(t *testing.T) {
+	oldKey, newKey := testMasterKey(t), testMasterKey(t)
+	env := testEnvelope(t, oldKey)
+	plain := testPlaintext(t, 2*EncryptionChunkSize+10)
+	sealed := env.seal(nil, plain, 0, true)
+
+	dataKey, err := unwrapDataKey([]masterKey{newKey, oldKey}, env.keyID, env.wrapped)
+	if err != nil {
+		t.Fatalf("使用旧主密钥解密数据密钥失败: %v", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decryptFrom(&envelope{aead: aead}, sealed, int64(len(plain)), 0)
+	if err != nil {
+		t.Fatalf("解密失败: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatal("解密结果与明文不一致")
+	}
+}
+
+// 按 rotateObjectKey 的方式重新加密数据密钥后，移除旧主密钥仍可解密
+func TestRewrapDataKey(t *testing.T) {
+	oldKey, newKey := testMasterKey(t), testMasterKey(t)
+	env := testEnvelope(t, oldKey)
+	plain := testPlaintext(t, EncryptionChunkSize+1)
+	sealed := env.seal(nil, plain, 0, true)
+
+	dataKey, err := unwrapDataKey([]masterKey{newKey, oldKey}, env.keyID, env.wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := wrapDataKey(newKey, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rewrapped, err := unwrapDataKey([]masterKey{newKey}, newKey.id, wrapped)
+	if err != nil {
+		t.Fatalf("只保留新主密钥时解密数据密钥失败: %v", err)
+	}
+	aead, err := newGCM(rewrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decryptFrom(&envelope{aead: aead}, sealed, int64(len(plain)), 0)
+	if err != nil {
+		t.Fatalf("解密失败: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatal("解密结果与明文不一致")
+	}
+}
+
+func TestUnwrapDataKeyErrors(t *testing.T) {
+	oldKey, newKey := testMasterKey(t), testMasterKey(t)
+	env := testEnvelope(t, oldKey)
+
+	// 旧主密钥已移除
+	_, err := unwrapDataKey([]masterKey{newKey}, env.keyID, env.wrapped)
+	if err == nil || !strings.Contains(err.Error(), "FILEFLOW_PREVIOUS_MASTER_KEYS") {
+		t.Fatalf("缺少旧主密钥时应提示保留旧密钥，实际: %v", err)
+	}
+
+	// 标识对应的密钥与加密数据密钥的密钥不一致
+	if _, err := unwrapDataKey([]masterKey{{id: env.keyID, aead: newKey.aead}}, env.keyID, env.wrapped); err == nil {
+		t.Fatal("使用错误的主密钥应解密失败")
+	}
+
+	// 数据密钥被篡改
+	tampered := []byte(env.wrapped)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := unwrapDataKey([]masterKey{oldKey}, env.keyID, string(tampered)); err == nil {
+		t.Fatal("篡改的数据密钥应解密失败")
+	}
+
+	if _, err := unwrapDataKey(nil, env.keyID, env.wrapped); err != ErrEncryptionKeyMissing {
+		t.Fatalf("没有主密钥时应返回 ErrEncryptionKeyMissing，实际: %v", err)
+	}
+}
@@ -0,0 +1,429 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultKeyTemplate 默认存储路径模板，与早期版本的 uuid+时间戳 命名一致
+	DefaultKeyTemplate = "{yyyy}/{mm}/{dd}/{uuid}_{timestamp}.{ext}"
+	// MaxKeyLength S3 对象 key 的最大长度（字节）
+	MaxKeyLength = 1024
+	// maxKeySuffix suffix 模式下尝试的最大序号
+	maxKeySuffix = 100
+)
+
+// 模板来源，用于预览时说明生效的是哪一级配置
+const (
+	KeyTemplateSourceRequest  = "request"
+	KeyTemplateSourceToken    = "token"
+	KeyTemplateSourceAccount  = "account"
+	KeyTemplateSourceSettings = "settings"
+	KeyTemplateSourceDefault  = "default"
+)
+
+// ErrKeyConflict 目标路径已存在且冲突策略为 reject
+var ErrKeyConflict = errors.New("目标路径已存在")
+
+// keyPlaceholderPattern 匹配 {name} 或 {name:N} 形式的占位符
+var keyPlaceholderPattern = regexp.MustCompile(`\{([a-z0-9]+)(?::([0-9]+))?\}`)
+
+// keyPlaceholders 支持的占位符，值表示是否接受长度参数
+var keyPlaceholders = map[string]bool{
+	"yyyy":      false,
+	"mm":        false,
+	"dd":        false,
+	"hh":        false,
+	"timestamp": false,
+	"uuid":      false,
+	"sha256":    true,
+	"original":  false,
+	"name":      false,
+	"ext":       false,
+	"token":     false,
+}
+
+// KeyNaming 上传文件的命名参数，存储路径在选定账户后按模板生成
+type KeyNaming struct {
+	FileName string // 原始文件名
+	Ext      string // 扩展名（含点），为空时从 FileName 获取
+	Path     string // 自定义目录，非空时替换模板中的目录部分
+	TokenID  string // 调用方的 API Token ID，为空表示后台登录
+	Template string // 指定模板，为空时按 Token、账户、系统设置的顺序选择
+	Key      string // 固定存储路径（如压缩包内的相对路径），非空时不使用模板
+	Pool     string // 账户池名称，自动选择账户时只在池成员中选择
+}
+
+// KeyPreview 存储路径预览结果
+type KeyPreview struct {
+	Template    string `json:"template"`
+	Source      string `json:"source"` // 模板来源：request、token、account、settings 或 default
+	AccountID   string `json:"accountId"`
+	AccountName string `json:"accountName"`
+	Key         string `json:"key"`       // 冲突处理后的最终路径
+	Rendered    string `json:"rendered"`  // 按模板生成的原始路径
+	Collision   string `json:"collision"` // 冲突处理策略
+	Conflict    bool   `json:"conflict"`  // 原始路径是否已存在
+	Error       string `json:"error,omitempty"`
+}
+
+// ValidateKeyTemplate 校验存储路径模板，空模板表示不覆盖上一级配置
+func ValidateKeyTemplate(tpl string) error {
+	if tpl == "" {
+		return nil
+	}
+	if len(tpl) > MaxKeyLength {
+		return fmt.Errorf("路径模板过长，最多 %d 字节", MaxKeyLength)
+	}
+
+	for _, m := range keyPlaceholderPattern.FindAllStringSubmatch(tpl, -1) {
+		takesArg, ok := keyPlaceholders[m[1]]
+		if !ok {
+			return fmt.Errorf("不支持的占位符: {%s}", m[1])
+		}
+		if m[2] == "" {
+			continue
+		}
+		if !takesArg {
+			return fmt.Errorf("占位符 {%s} 不支持长度参数", m[1])
+		}
+		n, err := strconv.Atoi(m[2])
+		if err != nil || n < 1 || n > 64 {
+			return fmt.Errorf("占位符 {%s} 的长度必须在 1-64 之间", m[1])
+		}
+	}
+
+	rest := keyPlaceholderPattern.ReplaceAllString(tpl, "")
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("路径模板包含无法识别的占位符")
+	}
+	return nil
+}
+
+// ValidateKeyCollision 校验路径冲突处理策略
+func ValidateKeyCollision(mode string) error {
+	switch mode {
+	case store.KeyCollisionSuffix, store.KeyCollisionOverwrite, store.KeyCollisionReject:
+		return nil
+	default:
+		return fmt.Errorf("无效的路径冲突策略: %s", mode)
+	}
+}
+
+// keyTemplateUsesHash 模板是否需要文件的 SHA-256
+func keyTemplateUsesHash(tpl string) bool {
+	for _, m := range keyPlaceholderPattern.FindAllStringSubmatch(tpl, -1) {
+		if m[1] == "sha256" {
+			return true
+		}
+	}
+	return false
+}
+
+// template 按优先级选择生效的模板：请求指定 > Token > 账户 > 系统设置 > 默认模板
+func (n KeyNaming) template(acc *store.Account) (string, string) {
+	if n.Template != "" {
+		return n.Template, KeyTemplateSourceRequest
+	}
+	if n.TokenID != "" {
+		if t, err := store.GetTokenByID(n.TokenID); err == nil && t.KeyTemplate != "" {
+			return t.KeyTemplate, KeyTemplateSourceToken
+		}
+	}
+	if acc != nil && acc.KeyTemplate != "" {
+		return acc.KeyTemplate, KeyTemplateSourceAccount
+	}
+	if tpl := store.GetSettings().KeyTemplate; tpl != "" {
+		return tpl, KeyTemplateSourceSettings
+	}
+	return DefaultKeyTemplate, KeyTemplateSourceDefault
+}
+
+// tokenName 返回 {token} 占位符的值，后台登录上传为 admin
+func (n KeyNaming) tokenName() string {
+	if n.TokenID == "" {
+		return "admin"
+	}
+	if t, err := store.GetTokenByID(n.TokenID); err == nil {
+		return t.Name
+	}
+	return "unknown"
+}
+
+// renderKey 按模板生成存储路径
+// hash 为空时 {sha256} 使用随机值代替（直传和可恢复上传无法预先获得文件哈希）
+func renderKey(tpl string, n KeyNaming, hash string, now time.Time) (string, error) {
+	fileName := filepath.Base(strings.ReplaceAll(n.FileName, "\\", "/"))
+	if fileName == "." || fileName == "/" {
+		fileName = ""
+	}
+	ext := n.Ext
+	if ext == "" {
+		ext = filepath.Ext(fileName)
+	}
+	ext = strings.TrimPrefix(ext, ".")
+	name := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+
+	id := uuid.New().String()
+	if name == "" {
+		name = id
+	}
+	if hash == "" {
+		hash = randomHex(32)
+	}
+
+	// 没有扩展名时去掉 ".{ext}"，避免生成以点结尾的文件名
+	if ext == "" {
+		tpl = strings.ReplaceAll(tpl, ".{ext}", "")
+	}
+
+	key := keyPlaceholderPattern.ReplaceAllStringFunc(tpl, func(ph string) string {
+		m := keyPlaceholderPattern.FindStringSubmatch(ph)
+		switch m[1] {
+		case "yyyy":
+			return now.Format("2006")
+		case "mm":
+			return now.Format("01")
+		case "dd":
+			return now.Format("02")
+		case "hh":
+			return now.Format("15")
+		case "timestamp":
+			return strconv.FormatInt(now.UnixMilli(), 10)
+		case "uuid":
+			return id
+		case "sha256":
+			if m[2] != "" {
+				if l, err := strconv.Atoi(m[2]); err == nil && l < len(hash) {
+					return hash[:l]
+				}
+			}
+			return hash
+		case "original":
+			if fileName == "" {
+				if ext == "" {
+					return id
+				}
+				return id + "." + ext
+			}
+			return sanitizeKeySegment(fileName)
+		case "name":
+			return sanitizeKeySegment(name)
+		case "ext":
+			return sanitizeKeySegment(ext)
+		case "token":
+			return sanitizeKeySegment(n.tokenName())
+		}
+		return ph
+	})
+
+	key = sanitizeKey(key)
+	if n.Path != "" {
+		if dir := sanitizeKey(n.Path); dir != "" {
+			key = dir + "/" + path.Base(key)
+		}
+	}
+
+	if key == "" {
+		return "", fmt.Errorf("生成的存储路径为空")
+	}
+	if len(key) > MaxKeyLength {
+		return "", fmt.Errorf("生成的存储路径过长，最多 %d 字节", MaxKeyLength)
+	}
+	return key, nil
+}
+
+// sanitizeKeySegment 清理占位符的值，占位符不能引入新的目录层级
+func sanitizeKeySegment(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+// sanitizeKey 清理存储路径：移除控制字符，统一分隔符，去掉空段以及 . 和 .. 段
+func sanitizeKey(key string) string {
+	key = strings.ToValidUTF8(key, "_")
+	key = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		if r == '\\' {
+			return '/'
+		}
+		return r
+	}, key)
+
+	var segments []string
+	for _, seg := range strings.Split(key, "/") {
+		seg = strings.TrimSpace(seg)
+		if seg == "" || seg == "." || seg == ".." {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// randomHex 生成指定字节数的随机十六进制字符串
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// buildObjectKey 为选定账户生成存储路径并处理路径冲突
+// hashFn 仅在模板使用 {sha256} 时调用，为 nil 时使用随机值代替
+func buildObjectKey(ctx context.Context, acc *store.Account, n KeyNaming, hashFn func() (string, error)) (string, error) {
+	if n.Key != "" {
+		key := sanitizeKey(n.Key)
+		if key == "" || len(key) > MaxKeyLength {
+			return "", fmt.Errorf("存储路径无效: %s", n.Key)
+		}
+		key, _, err := resolveKeyCollision(ctx, acc, key, "")
+		return key, err
+	}
+
+	tpl, _ := n.template(acc)
+
+	var hash string
+	if hashFn != nil && keyTemplateUsesHash(tpl) {
+		var err error
+		if hash, err = hashFn(); err != nil {
+			return "", err
+		}
+	}
+
+	key, err := renderKey(tpl, n, hash, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	key, _, err = resolveKeyCollision(ctx, acc, key, tpl)
+	return key, err
+}
+
+// skipsCollisionCheck 冲突策略为覆盖或模板包含 {uuid} 时不检查路径是否已存在
+func skipsCollisionCheck(tpl string) bool {
+	return store.GetSettings().KeyCollision == store.KeyCollisionOverwrite || strings.Contains(tpl, "{uuid}")
+}
+
+// namingSkipsCollisionCheck 检查 buildObjectKey 生成的路径是否未经过存在性检查
+func namingSkipsCollisionCheck(acc *store.Account, n KeyNaming) bool {
+	if n.Key != "" {
+		return skipsCollisionCheck("")
+	}
+	tpl, _ := n.template(acc)
+	return skipsCollisionCheck(tpl)
+}
+
+// resolveKeyCollision 按系统设置的冲突策略处理已存在的路径
+// 模板包含 {uuid} 时路径不会重复，跳过检查以节省 HeadObject 请求
+// 返回最终路径以及原始路径是否已存在
+func resolveKeyCollision(ctx context.Context, acc *store.Account, key, tpl string) (string, bool, error) {
+	mode := store.GetSettings().KeyCollision
+	if skipsCollisionCheck(tpl) {
+		return key, false, nil
+	}
+
+	exists, err := objectExists(ctx, acc, key)
+	if err != nil || !exists {
+		return key, false, err
+	}
+	if mode == store.KeyCollisionReject {
+		return key, true, fmt.Errorf("%w: %s", ErrKeyConflict, key)
+	}
+
+	dir, file := path.Split(key)
+	ext := path.Ext(file)
+	base := strings.TrimSuffix(file, ext)
+	for i := 1; i <= maxKeySuffix; i++ {
+		candidate := fmt.Sprintf("%s%s-%d%s", dir, base, i, ext)
+		exists, err := objectExists(ctx, acc, candidate)
+		if err != nil {
+			return key, true, err
+		}
+		if !exists {
+			return candidate, true, nil
+		}
+	}
+	return key, true, fmt.Errorf("%w: %s（已尝试 %d 个序号）", ErrKeyConflict, key, maxKeySuffix)
+}
+
+// objectExists 检查对象是否存在
+func objectExists(ctx context.Context, acc *store.Account, key string) (bool, error) {
+	_, err := getS3Client(acc).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(acc.BucketName),
+		Key:    aws.String(key),
+	})
+	if err == nil {
+		return true, nil
+	}
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	return false, fmt.Errorf("检查目标路径失败: %w", err)
+}
+
+// PreviewObjectKey 预览上传文件的存储路径
+// 未指定账户时按前端上传规则选择首个候选账户（指定账户池时为优先级最高的成员）；sha256 为空时使用随机值代替
+func PreviewObjectKey(ctx context.Context, accountID string, n KeyNaming, sha256 string) (*KeyPreview, error) {
+	if n.Template != "" {
+		if err := ValidateKeyTemplate(n.Template); err != nil {
+			return nil, err
+		}
+	}
+
+	var accounts []store.Account
+	var err error
+	if accountID == "" {
+		accounts, err = autoUploadAccounts(n, true)
+	} else {
+		accounts, err = clientUploadAccounts(accountID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	acc := &accounts[0]
+
+	tpl, source := n.template(acc)
+	preview := &KeyPreview{
+		Template:    tpl,
+		Source:      source,
+		AccountID:   acc.ID,
+		AccountName: acc.Name,
+		Collision:   store.GetSettings().KeyCollision,
+	}
+
+	preview.Rendered, err = renderKey(tpl, n, sha256, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	preview.Key, preview.Conflict, err = resolveKeyCollision(ctx, acc, preview.Rendered, tpl)
+	if err != nil {
+		preview.Error = err.Error()
+	}
+	return preview, nil
+}
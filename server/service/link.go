@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	// DefaultPresignTTL 预签名下载链接默认有效期
+	DefaultPresignTTL = time.Hour
+	// MaxPresignTTL 预签名下载链接最长有效期（S3 签名 V4 上限）
+	MaxPresignTTL = 7 * 24 * time.Hour
+)
+
+// LinkModeDownload 通过 FileFlow 下载接口访问的链接，需要认证，用于分块文件和加密对象
+const LinkModeDownload = "download"
+
+// LinkOptions 生成文件链接的选项，零值表示使用账户配置
+type LinkOptions struct {
+	Mode               string        // public 或 private，为空时使用账户的链接模式
+	TTL                time.Duration // 预签名链接有效期，为 0 时使用账户配置
+	ContentDisposition string        // 覆盖响应的 Content-Disposition（仅预签名链接）
+	ContentType        string        // 覆盖响应的 Content-Type（仅预签名链接）
+}
+
+// FileLink 文件访问链接
+type FileLink struct {
+	URL       string `json:"url"`
+	Mode      string `json:"mode"`
+	ExpiresAt string `json:"expiresAt,omitempty"` // 预签名链接的过期时间
+}
+
+// GetFileLink 获取文件链接
+// 按请求或账户配置返回公开域名直链或限时的预签名链接
+func GetFileLink(ctx context.Context, accountID, key string, opts LinkOptions) (*FileLink, error) {
+	// 特殊处理：ImgBB 文件
+	if accountID == "imgbb" {
+		if opts.Mode == store.LinkModePrivate {
+			return nil, fmt.Errorf("ImgBB 文件不支持预签名链接")
+		}
+		// ImgBB 文件的 key 就是直接访问 URL
+		return &FileLink{URL: key, Mode: store.LinkModePublic}, nil
+	}
+
+	// 分块文件只能通过 FileFlow 下载接口重新拼接
+	if sf, err := store.GetStripedFileByKey(accountID, key); err == nil {
+		return stripedFileLink(sf), nil
+	}
+
+	// 文件有多个副本时从最健康的副本生成链接
+	if link := replicaFileLink(ctx, accountID, key, opts); link != nil {
+		return link, nil
+	}
+
+	acc, err := store.GetAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildFileLink(ctx, acc, key, opts)
+}
+
+// buildFileLink 为账户中的文件生成链接
+// 未指定模式但要求覆盖响应头时使用预签名链接，公开域名无法覆盖响应头
+// 加密账户中的对象只能通过 FileFlow 下载接口解密后读取
+func buildFileLink(ctx context.Context, acc *store.Account, key string, opts LinkOptions) (*FileLink, error) {
+	if acc.Encryption {
+		return downloadLink(acc.ID, key), nil
+	}
+
+	overrides := opts.ContentDisposition != "" || opts.ContentType != ""
+
+	mode := opts.Mode
+	if mode == "" {
+		mode = store.LinkModePublic
+		if acc.IsPrivateLink() || overrides {
+			mode = store.LinkModePrivate
+		}
+	}
+
+	switch mode {
+	case store.LinkModePublic:
+		if overrides {
+			return nil, fmt.Errorf("公开链接不支持覆盖响应头，请使用 private 模式")
+		}
+		if acc.PublicDomain == "" {
+			return nil, fmt.Errorf("账户未配置公开访问域名")
+		}
+		return &FileLink{URL: buildPublicURL(acc, key), Mode: mode}, nil
+	case store.LinkModePrivate:
+		ttl := presignTTL(acc, opts.TTL)
+		url, err := presignGetURL(ctx, acc, key, ttl, opts.ContentDisposition, opts.ContentType)
+		if err != nil {
+			return nil, err
+		}
+		return &FileLink{
+			URL:       url,
+			Mode:      mode,
+			ExpiresAt: time.Now().Add(ttl).UTC().Format(time.RFC3339),
+		}, nil
+	default:
+		return nil, fmt.Errorf("不支持的链接模式: %s", mode)
+	}
+}
+
+// downloadLink 指向需要认证的 FileFlow 下载接口的链接
+func downloadLink(accountID, key string) *FileLink {
+	return &FileLink{
+		URL:  "/api/file/download?idGroup=" + url.QueryEscape(accountID) + "&key=" + url.QueryEscape(key),
+		Mode: LinkModeDownload,
+	}
+}
+
+// presignTTL 计算预签名链接有效期，优先使用请求值，其次为账户配置
+func presignTTL(acc *store.Account, ttl time.Duration) time.Duration {
+	if ttl <= 0 && acc.PresignTTL > 0 {
+		ttl = time.Duration(acc.PresignTTL) * time.Second
+	}
+	if ttl <= 0 {
+		ttl = DefaultPresignTTL
+	}
+	if ttl > MaxPresignTTL {
+		ttl = MaxPresignTTL
+	}
+	return ttl
+}
+
+// presignGetURL 生成预签名 GET 链接，可覆盖响应的 Content-Disposition 和 Content-Type
+func presignGetURL(ctx context.Context, acc *store.Account, key string, ttl time.Duration, disposition, contentType string) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(acc.BucketName),
+		Key:    aws.String(key),
+	}
+	if disposition != "" {
+		input.ResponseContentDisposition = aws.String(disposition)
+	}
+	if contentType != "" {
+		input.ResponseContentType = aws.String(contentType)
+	}
+
+	presignClient := s3.NewPresignClient(getS3Client(acc))
+	req, err := presignClient.PresignGetObject(ctx, input, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("生成预签名下载链接失败: %w", err)
+	}
+	return req.URL, nil
+}
+
+// newUploadResult 构建上传结果，链接类型由账户的链接模式决定
+// 文件已经上传成功，链接生成失败时仅记录日志并返回空链接
+func newUploadResult(ctx context.Context, acc *store.Account, key string, size int64) *UploadResult {
+	result := &UploadResult{
+		ID:          acc.ID,
+		AccountName: acc.Name,
+		Key:         key,
+		Size:        size,
+	}
+
+	link, err := buildFileLink(ctx, acc, key, LinkOptions{})
+	if err != nil {
+		log.Printf("[Link] 生成文件链接失败 (account=%s, key=%s): %v", acc.Name, key, err)
+		return result
+	}
+	result.URL = link.URL
+	result.LinkMode = link.Mode
+	result.LinkExpiresAt = link.ExpiresAt
+	return result
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"fileflow/server/config"
+	"fileflow/server/store"
+)
+
+// TestMain 使用临时数据目录初始化配置和存储（SQLite），测试结束后删除
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "fileflow-service-test-*")
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Setenv("FILEFLOW_DATA_DIR", dir)
+	os.Unsetenv("FILEFLOW_DATABASE_URL")
+	os.Setenv("FILEFLOW_ADMIN_PASSWORD", "test")
+	os.Setenv("FILEFLOW_JWT_SECRET", "test")
+	config.Load()
+	if err := store.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	store.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"time"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// 写入对象的用户元数据键（x-amz-meta-*）
+const (
+	MetaOriginalName = "original-name"
+	MetaUploader     = "uploader"
+	MetaSource       = "source"
+	MetaSourceURL    = "source-url"
+	MetaSHA256       = "sha256"
+	MetaCRC32C       = "crc32c"
+	MetaPresignID    = "presign-id" // 单次预签名直传的预留ID，用于确认对象由该直传写入
+)
+
+// 上传来源
+const (
+	UploadSourceFile    = "file"
+	UploadSourceURL     = "url"
+	UploadSourceTus     = "tus"
+	UploadSourcePresign = "presign"
+	UploadSourceArchive = "archive"
+	UploadSourceWebDAV  = "webdav"
+)
+
+// ErrFileNotFound 文件不存在
+var ErrFileNotFound = errors.New("文件不存在")
+
+// ObjectMetadata 随对象一起保存的上传信息
+type ObjectMetadata struct {
+	OriginalName      string            // 原始文件名
+	Uploader          string            // 上传者：token:<名称> 或 user:<用户名>
+	Source            string            // 上传来源：file、url、tus、presign、archive、webdav
+	SourceURL         string            // URL 上传的源地址
+	SHA256            string            // 文件哈希，上传前无法获得时为空
+	CRC32C            string            // 文件 CRC32C，上传前无法获得时为空
+	Expected          Checksums         // 客户端提供的期望校验值，只用于校验，不写入元数据
+	KeepImageMetadata bool              // 上传时要求保留图片原始元数据，忽略 Token 和账户的隐私选项，不写入元数据
+	Custom            map[string]string // 自定义元数据（已规范化），同时记录到存储中用于筛选
+	Tags              []string          // 标签（已规范化），以逗号分隔写入 tags 元数据
+	Striped           bool              // 要求跨账户分块存储，不写入元数据
+
+	imageMetadataStripped bool // 已在选择上传方式前移除图片元数据，不再重复处理
+}
+
+// FileStat 文件元数据
+type FileStat struct {
+	AccountID          string            `json:"accountId"`
+	Key                string            `json:"key"`
+	Size               int64             `json:"size"`
+	ContentType        string            `json:"contentType"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	ETag               string            `json:"etag"`
+	LastModified       string            `json:"lastModified"`
+	OriginalName       string            `json:"originalName,omitempty"`
+	Uploader           string            `json:"uploader,omitempty"`
+	Source             string            `json:"source,omitempty"`
+	SourceURL          string            `json:"sourceUrl,omitempty"`
+	SHA256             string            `json:"sha256,omitempty"`
+	CRC32C             string            `json:"crc32c,omitempty"`
+	Metadata           map[string]string `json:"metadata"`            // 全部用户元数据（已解码）
+	CustomMetadata     map[string]string `json:"customMetadata"`      // 其中的自定义元数据
+	Tags               []string          `json:"tags"`                // 标签
+	Chunks             int               `json:"chunks,omitempty"`    // 分块数量（跨账户分块存储的文件）
+	Encrypted          bool              `json:"encrypted,omitempty"` // 是否由 FileFlow 加密存储，Size 为明文大小
+}
+
+// toS3 转换为 S3 用户元数据
+// 元数据通过 HTTP 头传输，只能包含 ASCII 字符，文件名和 URL 按百分号编码保存
+func (m ObjectMetadata) toS3() map[string]string {
+	meta := make(map[string]string)
+	set := func(key, value string) {
+		if value != "" {
+			meta[key] = url.PathEscape(value)
+		}
+	}
+	set(MetaOriginalName, m.OriginalName)
+	set(MetaUploader, m.Uploader)
+	set(MetaSource, m.Source)
+	set(MetaSourceURL, m.SourceURL)
+	set(MetaSHA256, m.SHA256)
+	set(MetaCRC32C, m.CRC32C)
+	for k, v := range m.Custom {
+		set(k, v)
+	}
+	set(MetaTags, strings.Join(m.Tags, ","))
+	return meta
+}
+
+// contentDisposition 生成带原始文件名的 Content-Disposition，下载时使用原始文件名保存
+func (m ObjectMetadata) contentDisposition() *string {
+	if m.OriginalName == "" {
+		return nil
+	}
+	value := mime.FormatMediaType("inline", map[string]string{"filename": m.OriginalName})
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
+
+// decodeObjectMetadata 解码 HeadObject 返回的用户元数据，键统一为小写
+func decodeObjectMetadata(raw map[string]string) map[string]string {
+	meta := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if decoded, err := url.PathUnescape(v); err == nil {
+			v = decoded
+		}
+		meta[strings.ToLower(k)] = v
+	}
+	return meta
+}
+
+// StatFile 通过 HeadObject 获取文件的大小、类型和上传时保存的元数据
+func StatFile(ctx context.Context, accountID, key string) (*FileStat, error) {
+	if accountID == "imgbb" {
+		return nil, fmt.Errorf("ImgBB 文件不支持查询元数据")
+	}
+	if sf, err := store.GetStripedFileByKey(accountID, key); err == nil {
+		return stripedFileStat(sf), nil
+	}
+
+	acc, err := store.GetAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := getS3Client(acc).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(acc.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("获取文件元数据失败: %w", err)
+	}
+
+	meta := decodeObjectMetadata(head.Metadata)
+	custom, tags := splitCustomMetadata(meta)
+	size := aws.ToInt64(head.ContentLength)
+	encrypted := meta[MetaEncryption] != ""
+	if encrypted {
+		size = PlaintextSize(size)
+		delete(meta, MetaEncryptionKey)
+	}
+	stat := &FileStat{
+		AccountID:          acc.ID,
+		Key:                key,
+		Size:               size,
+		ContentType:        aws.ToString(head.ContentType),
+		ContentDisposition: aws.ToString(head.ContentDisposition),
+		ETag:               aws.ToString(head.ETag),
+		OriginalName:       meta[MetaOriginalName],
+		Uploader:           meta[MetaUploader],
+		Source:             meta[MetaSource],
+		SourceURL:          meta[MetaSourceURL],
+		SHA256:             meta[MetaSHA256],
+		CRC32C:             meta[MetaCRC32C],
+		Metadata:           meta,
+		CustomMetadata:     custom,
+		Tags:               tags,
+		Encrypted:          encrypted,
+	}
+	if head.LastModified != nil {
+		stat.LastModified = head.LastModified.UTC().Format(time.RFC3339)
+	}
+	return stat, nil
+}
@@ -0,0 +1,610 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fileflow/server/config"
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// UploadPartSize 默认分片大小（8 MiB），同时也是单次 PutObject 的上限
+	UploadPartSize int64 = 8 << 20
+	// MinUploadPartSize S3 协议要求的最小分片大小（最后一个分片除外）
+	MinUploadPartSize int64 = 5 << 20
+	// MaxUploadParts S3 协议允许的最大分片数量
+	MaxUploadParts = 10000
+	// StaleMultipartUploadAge 未完成分片上传的清理阈值
+	StaleMultipartUploadAge = 24 * time.Hour
+)
+
+// errSourceNotReplayable 数据源已被部分消费且不可回退，无法切换账户重试
+var errSourceNotReplayable = errors.New("上传数据已部分发送且无法重新读取")
+
+// partBufferPool 分片缓冲区池，限制每个上传同时持有的内存
+var partBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, UploadPartSize)
+		return &buf
+	},
+}
+
+// uploadSource 上传数据源
+// 预先缓存首个分片：小文件直接单次上传；若在读取后续数据前失败，可直接切换账户重试。
+// 如果底层 Reader 支持 Seek（表单临时文件等），即使已发送部分分片也可以回退重试。
+// 读取过程中同时计算 SHA-256 和 CRC32C，用于内容去重和完整性校验。
+type uploadSource struct {
+	body       io.Reader
+	size       int64 // 声明的文件大小，未知时为 -1
+	seeker     io.Seeker
+	restPos    int64  // 首个分片之后的数据在 seeker 中的偏移
+	head       []byte // 首个分片
+	headOnly   bool   // 数据已在首个分片内读完
+	readRest   bool   // 是否已读取首个分片之后的数据
+	partSize   int64
+	hash       hash.Hash
+	headState  []byte            // 读完首个分片时的哈希状态，回退时恢复
+	crc        uint32            // 已读取数据的 CRC32C
+	headCRC    uint32            // 首个分片的 CRC32C，回退时恢复
+	dedup      bool              // 是否启用内容去重
+	dedupScope map[string]bool   // 去重范围：只复用这些账户中的已有对象
+	spool      *os.File          // 为预先计算哈希而缓存剩余数据的临时文件
+	fullHash   string            // 预先计算的文件哈希
+	fullCRC    string            // 预先计算的文件 CRC32C
+	meta       ObjectMetadata    // 随对象保存的上传信息
+	thumbnails *thumbnailOptions // 上传成功后为图片生成缩略图，nil 表示不生成
+}
+
+// newUploadSource 创建上传数据源并读取首个分片
+func newUploadSource(body io.Reader, size int64) (*uploadSource, error) {
+	partSize := partSizeFor(size)
+
+	src := &uploadSource{
+		body:     body,
+		size:     size,
+		partSize: partSize,
+	}
+
+	// 按实际大小分配，避免小文件也占用整个分片的内存
+	var head bytes.Buffer
+	if size > 0 && size < partSize {
+		head.Grow(int(size))
+	}
+	_, err := io.CopyN(&head, body, partSize)
+	switch err {
+	case nil:
+	case io.EOF:
+		src.headOnly = true
+	default:
+		return nil, fmt.Errorf("读取文件内容失败: %w", err)
+	}
+	src.head = head.Bytes()
+
+	src.hash = sha256.New()
+	src.hash.Write(src.head)
+	if m, ok := src.hash.(encoding.BinaryMarshaler); ok {
+		src.headState, _ = m.MarshalBinary()
+	}
+	src.headCRC = crc32.Update(0, crc32cTable, src.head)
+	src.crc = src.headCRC
+
+	// 记录首个分片之后的位置，用于失败后回退
+	if seeker, ok := body.(io.Seeker); ok && !src.headOnly {
+		if pos, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			src.seeker = seeker
+			src.restPos = pos
+		}
+	}
+
+	return src, nil
+}
+
+// rewind 将数据源恢复到首个分片之后，供下一个账户重试
+func (s *uploadSource) rewind() error {
+	if !s.readRest {
+		return nil
+	}
+	if s.seeker == nil {
+		return errSourceNotReplayable
+	}
+	if _, err := s.seeker.Seek(s.restPos, io.SeekStart); err != nil {
+		return fmt.Errorf("回退上传数据失败: %w", err)
+	}
+	if err := s.hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(s.headState); err != nil {
+		return fmt.Errorf("回退上传数据失败: %w", err)
+	}
+	s.crc = s.headCRC
+	s.readRest = false
+	return nil
+}
+
+// sum 返回已读取数据的 SHA-256（十六进制），数据全部读完后即为文件哈希
+func (s *uploadSource) sum() string {
+	return hex.EncodeToString(s.hash.Sum(nil))
+}
+
+// checksums 返回已读取数据的校验值，数据全部读完后即为文件校验值
+func (s *uploadSource) checksums() Checksums {
+	return Checksums{SHA256: s.sum(), CRC32C: crc32cHex(s.crc)}
+}
+
+// write 记录读取到的数据，更新哈希和 CRC32C
+func (s *uploadSource) write(data []byte) {
+	s.hash.Write(data)
+	s.crc = crc32.Update(s.crc, crc32cTable, data)
+}
+
+// verifySize 检查实际读取的数据量是否与声明的大小一致
+func (s *uploadSource) verifySize(total int64) error {
+	if s.size >= 0 && total != s.size {
+		return fmt.Errorf("%w: 声明大小 %d 字节，实际收到 %d 字节", ErrIncompleteUpload, s.size, total)
+	}
+	return nil
+}
+
+// precomputeSum 在上传前计算整个文件的 SHA-256（同时计算 CRC32C），供路径模板中的 {sha256} 使用
+// 不可 Seek 的数据源会先把首个分片之后的数据缓存到临时文件
+func (s *uploadSource) precomputeSum() (string, error) {
+	if s.headOnly {
+		return s.sum(), nil
+	}
+	if s.fullHash != "" {
+		return s.fullHash, nil
+	}
+	if err := s.rewind(); err != nil {
+		return "", err
+	}
+
+	if s.seeker == nil {
+		if err := os.MkdirAll(filepath.Join(config.Get().DataDir, "uploads"), 0755); err != nil {
+			return "", fmt.Errorf("创建临时目录失败: %w", err)
+		}
+		f, err := os.CreateTemp(filepath.Join(config.Get().DataDir, "uploads"), "spool-*")
+		if err != nil {
+			return "", fmt.Errorf("创建临时文件失败: %w", err)
+		}
+		s.spool = f
+		if _, err := io.Copy(f, s.body); err != nil {
+			return "", fmt.Errorf("读取文件内容失败: %w", err)
+		}
+		s.body = f
+		s.seeker = f
+		s.restPos = 0
+	}
+	if _, err := s.seeker.Seek(s.restPos, io.SeekStart); err != nil {
+		return "", fmt.Errorf("读取文件内容失败: %w", err)
+	}
+
+	h := sha256.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(s.headState); err != nil {
+		return "", fmt.Errorf("计算文件哈希失败: %w", err)
+	}
+	crc := &crc32cWriter{crc: s.headCRC}
+	n, err := io.Copy(io.MultiWriter(h, crc), s.body)
+	if err != nil {
+		return "", fmt.Errorf("读取文件内容失败: %w", err)
+	}
+	if err := s.verifySize(int64(len(s.head)) + n); err != nil {
+		return "", err
+	}
+	if _, err := s.seeker.Seek(s.restPos, io.SeekStart); err != nil {
+		return "", fmt.Errorf("回退上传数据失败: %w", err)
+	}
+	s.fullHash = hex.EncodeToString(h.Sum(nil))
+	s.fullCRC = crc32cHex(crc.crc)
+	return s.fullHash, nil
+}
+
+// metadata 返回写入对象的元数据
+// 文件哈希在上传前已知时（单分片、路径模板已预先计算，或写入副本时已由首个账户的上传得到）一并写入；
+// 其他分片上传不为此额外读取一遍文件，完整性由每个分片的 SHA-256 校验值（合并后为组合校验值）保证，
+// 文件哈希在上传结果中返回
+func (s *uploadSource) metadata() ObjectMetadata {
+	meta := s.meta
+	switch {
+	case s.headOnly:
+		meta.SHA256 = s.sum()
+		meta.CRC32C = crc32cHex(s.headCRC)
+	case s.fullHash != "":
+		meta.SHA256 = s.fullHash
+		meta.CRC32C = s.fullCRC
+	}
+	return meta
+}
+
+// close 释放数据源持有的临时文件
+func (s *uploadSource) close() {
+	if s.spool != nil {
+		s.spool.Close()
+		os.Remove(s.spool.Name())
+	}
+}
+
+// partSizeFor 根据文件大小计算分片大小，保证分片数不超过 MaxUploadParts
+// 分片大小取加密数据块的整数倍，加密后除最后一个分片外大小仍然一致
+func partSizeFor(size int64) int64 {
+	partSize := UploadPartSize
+	if size > partSize*MaxUploadParts {
+		partSize = (size + MaxUploadParts - 1) / MaxUploadParts
+		partSize = (partSize + EncryptionChunkSize - 1) / EncryptionChunkSize * EncryptionChunkSize
+	}
+	if partSize < MinUploadPartSize {
+		partSize = MinUploadPartSize
+	}
+	return partSize
+}
+
+// UploadProgressFunc 上传进度回调，uploaded 为已写入目标账户的字节数
+// 切换账户重试时会以新的账户和 0 重新开始报告
+type UploadProgressFunc func(accountID string, uploaded int64)
+
+type uploadProgressKey struct{}
+
+// WithUploadProgress 返回携带上传进度回调的 context
+func WithUploadProgress(ctx context.Context, fn UploadProgressFunc) context.Context {
+	return context.WithValue(ctx, uploadProgressKey{}, fn)
+}
+
+// uploadProgress 返回报告指定账户上传进度的函数，context 未携带回调时为空操作
+func uploadProgress(ctx context.Context, accountID string) func(int64) {
+	fn, ok := ctx.Value(uploadProgressKey{}).(UploadProgressFunc)
+	if !ok || fn == nil {
+		return func(int64) {}
+	}
+	return func(uploaded int64) { fn(accountID, uploaded) }
+}
+
+// storedObject 流式上传的结果
+type storedObject struct {
+	Size      int64
+	ETag      string
+	Duplicate *store.FileObject // 内容已存在时指向已有对象，本次数据未写入存储桶
+}
+
+// streamUpload 流式上传到指定账户
+// 数据不超过一个分片时使用 PutObject，否则使用分片上传，任何一步失败都会中止分片上传
+// 每个请求都携带 SHA-256 校验值，数据在传输中损坏时由存储端拒绝；
+// 数据全部读完后检查大小和客户端提供的期望校验值，不一致时不会生成对象
+// 启用去重时，在写入对象前（单次上传）或合并分片前（分片上传）检查内容是否已存在
+// 账户启用加密时写入密文，去重只比较明文哈希，因此加密账户不参与去重
+func streamUpload(ctx context.Context, acc *store.Account, key string, src *uploadSource, contentType string) (*storedObject, error) {
+	client := getS3Client(acc)
+	meta := src.metadata()
+	env, err := accountEnvelope(acc)
+	if err != nil {
+		return nil, err
+	}
+	objectMeta := meta.toS3()
+	if env != nil {
+		for k, v := range env.metadata() {
+			objectMeta[k] = v
+		}
+	}
+	progress := uploadProgress(ctx, acc.ID)
+	progress(0)
+
+	// 上传前已知文件校验值时先核对，避免发送注定失败的数据
+	if meta.SHA256 != "" {
+		if err := meta.Expected.verify(Checksums{SHA256: meta.SHA256, CRC32C: meta.CRC32C}); err != nil {
+			return nil, err
+		}
+	}
+
+	if src.headOnly {
+		size := int64(len(src.head))
+		if err := src.verifySize(size); err != nil {
+			return nil, err
+		}
+		body := src.head
+		if env != nil {
+			body = env.seal(make([]byte, 0, EncryptedSize(size)), src.head, 0, true)
+		} else if dup := findDuplicate(ctx, src, size); dup != nil {
+			return &storedObject{Size: size, Duplicate: dup}, nil
+		}
+
+		out, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:             aws.String(acc.BucketName),
+			Key:                aws.String(key),
+			Body:               bytes.NewReader(body),
+			ContentLength:      aws.Int64(int64(len(body))),
+			ContentType:        aws.String(contentType),
+			ContentDisposition: meta.contentDisposition(),
+			Metadata:           objectMeta,
+			ChecksumAlgorithm:  types.ChecksumAlgorithmSha256,
+			ChecksumSHA256:     sha256Base64(body),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("上传失败: %w", err)
+		}
+		progress(size)
+		return &storedObject{Size: size, ETag: aws.ToString(out.ETag)}, nil
+	}
+
+	created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(acc.BucketName),
+		Key:                aws.String(key),
+		ContentType:        aws.String(contentType),
+		ContentDisposition: meta.contentDisposition(),
+		Metadata:           objectMeta,
+		ChecksumAlgorithm:  types.ChecksumAlgorithmSha256,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建分片上传失败: %w", err)
+	}
+	uploadID := aws.ToString(created.UploadId)
+
+	size, parts, err := uploadParts(ctx, client, acc.BucketName, key, uploadID, src, env, progress)
+	if err == nil {
+		err = src.verifySize(size)
+	}
+	if err == nil {
+		err = meta.Expected.verify(src.checksums())
+	}
+	if err != nil {
+		abortMultipartUpload(client, acc.BucketName, key, uploadID)
+		return nil, err
+	}
+
+	// 内容已存在时放弃合并，已上传的分片随中止一并释放
+	if env == nil {
+		if dup := findDuplicate(ctx, src, size); dup != nil {
+			abortMultipartUpload(client, acc.BucketName, key, uploadID)
+			return &storedObject{Size: size, Duplicate: dup}, nil
+		}
+	}
+
+	out, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(acc.BucketName),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		abortMultipartUpload(client, acc.BucketName, key, uploadID)
+		return nil, fmt.Errorf("完成分片上传失败: %w", err)
+	}
+
+	return &storedObject{Size: size, ETag: aws.ToString(out.ETag)}, nil
+}
+
+// uploadParts 依次上传所有分片，内存中最多同时持有首个分片和一个分片缓冲区
+// 每个分片完成后通过 progress 报告累计上传字节数（明文）
+// env 非空时逐个分片加密；数据恰好在分片边界结束时，额外上传一个只含结束数据块的分片
+func uploadParts(ctx context.Context, client *s3.Client, bucket, key, uploadID string, src *uploadSource, env *envelope, progress func(int64)) (int64, []types.CompletedPart, error) {
+	var parts []types.CompletedPart
+	var total int64
+	var sealed []byte
+	var chunk uint64
+
+	upload := func(partNumber int32, plain []byte, final bool) error {
+		data := plain
+		if env != nil {
+			sealed = env.seal(sealed[:0], plain, chunk, final)
+			chunk += uint64(len(plain) / EncryptionChunkSize)
+			data = sealed
+		}
+		checksum := sha256Base64(data)
+		out, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:            aws.String(bucket),
+			Key:               aws.String(key),
+			UploadId:          aws.String(uploadID),
+			PartNumber:        aws.Int32(partNumber),
+			Body:              bytes.NewReader(data),
+			ContentLength:     aws.Int64(int64(len(data))),
+			ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+			ChecksumSHA256:    checksum,
+		})
+		if err != nil {
+			return fmt.Errorf("上传分片 %d 失败: %w", partNumber, err)
+		}
+		parts = append(parts, types.CompletedPart{
+			ETag:           out.ETag,
+			PartNumber:     aws.Int32(partNumber),
+			ChecksumSHA256: checksum,
+		})
+		total += int64(len(plain))
+		progress(total)
+		return nil
+	}
+
+	if err := upload(1, src.head, false); err != nil {
+		return 0, nil, err
+	}
+
+	bufPtr := partBufferPool.Get().(*[]byte)
+	defer partBufferPool.Put(bufPtr)
+	buf := *bufPtr
+	if int64(len(buf)) < src.partSize {
+		buf = make([]byte, src.partSize)
+	}
+	buf = buf[:src.partSize]
+
+	for partNumber := int32(2); ; partNumber++ {
+		if partNumber > MaxUploadParts {
+			return 0, nil, fmt.Errorf("文件过大，超过 %d 个分片", MaxUploadParts)
+		}
+
+		src.readRest = true
+		n, err := io.ReadFull(src.body, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("读取文件内容失败: %w", err)
+		}
+		final := err != nil
+		if n > 0 || (final && env != nil) {
+			src.write(buf[:n])
+			if uploadErr := upload(partNumber, buf[:n], final); uploadErr != nil {
+				return 0, nil, uploadErr
+			}
+		}
+		if final {
+			break
+		}
+	}
+
+	return total, parts, nil
+}
+
+// abortMultipartUpload 中止分片上传，释放已上传的分片
+// 使用独立的 context，避免请求取消后无法清理
+func abortMultipartUpload(client *s3.Client, bucket, key, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		log.Printf("[Upload] 中止分片上传失败 (key=%s, uploadId=%s): %v", key, uploadID, err)
+	}
+}
+
+// uploadWithFallback 依次尝试账户列表直到上传成功
+// 失败后仅在数据源可回退时切换账户，不会为重试缓存整个文件
+// accountID 为调用方指定的账户，非空时内容去重仅在该账户内查找
+// 存储路径按选中账户的路径模板生成，meta 随对象一起写入
+// 智能上传（accountID 为空）且副本数大于 1 时，成功后把同一路径写入后续的候选账户
+// 大小已知时写入前在账户上预留容量（见 reservation.go），写入结束后释放
+func uploadWithFallback(ctx context.Context, accountID string, accounts []store.Account, naming KeyNaming, meta ObjectMetadata, body io.Reader, size int64, contentType string) (*UploadResult, error) {
+	body, size, meta, err := stripImageMetadataForUpload(accounts, naming, meta, body, size)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := newUploadSource(body, size)
+	if err != nil {
+		return nil, err
+	}
+	defer src.close()
+	src.meta = meta
+	src.dedup = store.GetSettings().DedupEnabled
+	// 只复用本次可以写入的账户中的对象，账户池和限定账户的上传不会命中范围外的文件
+	src.dedupScope = make(map[string]bool, len(accounts))
+	for _, acc := range accounts {
+		src.dedupScope[acc.ID] = true
+	}
+	src.thumbnails = loadThumbnailOptions()
+
+	// 智能上传写入多个副本；文件哈希在首个账户上传时边读边计算，副本按该值校验
+	factor := 1
+	if accountID == "" {
+		factor = replicationFactor()
+	}
+
+	var lastErr error
+	for i := range accounts {
+		acc := &accounts[i]
+		if err := src.rewind(); err != nil {
+			return nil, fmt.Errorf("上传失败且无法切换账户重试: %w（最后错误: %v）", err, lastErr)
+		}
+
+		key, err := buildObjectKey(ctx, acc, naming, src.precomputeSum)
+		if err != nil {
+			if errors.Is(err, ErrKeyConflict) || errors.Is(err, ErrIncompleteUpload) {
+				return nil, err
+			}
+			lastErr = err
+			log.Printf("生成账户 %s 的存储路径失败: %v，尝试下一个账户", acc.Name, err)
+			continue
+		}
+
+		// 大小已知时先预留容量，自动选择账户时空间不足则尝试下一个账户
+		reservationID, err := reserveUpload(acc, size, meta.Source, key, accountID != "")
+		if err != nil {
+			lastErr = err
+			log.Printf("账户 %s 剩余空间不足: %v，尝试下一个账户", acc.Name, err)
+			continue
+		}
+
+		result, err := doUpload(ctx, acc, key, src, contentType)
+		releaseReservation(reservationID)
+		if err == nil {
+			if factor > 1 && !result.Deduplicated {
+				replicateUpload(ctx, acc, accounts[i+1:], src, result, contentType, factor, naming.Pool)
+			}
+			return result, nil
+		}
+		// 数据本身有问题，换账户重试也不会成功
+		if errors.Is(err, ErrChecksumMismatch) || errors.Is(err, ErrIncompleteUpload) || errors.Is(err, ErrStripImageMetadata) {
+			return nil, err
+		}
+		lastErr = err
+		log.Printf("上传到账户 %s 失败: %v，尝试下一个账户", acc.Name, err)
+	}
+
+	return nil, fmt.Errorf("所有账户上传均失败: %w", lastErr)
+}
+
+// CleanupStaleMultipartUploads 中止所有激活账户中超过阈值的未完成分片上传
+// 可恢复上传会话持有的分片上传由会话过期清理负责，这里会跳过
+func CleanupStaleMultipartUploads(ctx context.Context) {
+	accounts := store.GetActiveAccounts()
+	before := time.Now().Add(-StaleMultipartUploadAge)
+	skip := activeUploadIDs()
+
+	for _, acc := range accounts {
+		count, err := abortStaleMultipartUploads(ctx, &acc, before, skip)
+		if err != nil {
+			log.Printf("[Multipart] 账户 %s 清理未完成分片上传失败: %v", acc.Name, err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("[Multipart] 账户 %s 已中止 %d 个未完成分片上传", acc.Name, count)
+		}
+	}
+}
+
+// abortStaleMultipartUploads 中止指定账户中早于 before 发起的分片上传（skip 中的除外）
+func abortStaleMultipartUploads(ctx context.Context, acc *store.Account, before time.Time, skip map[string]bool) (int, error) {
+	client := getS3Client(acc)
+
+	input := &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(acc.BucketName),
+	}
+
+	count := 0
+	for {
+		output, err := client.ListMultipartUploads(ctx, input)
+		if err != nil {
+			return count, fmt.Errorf("列出分片上传失败: %w", err)
+		}
+
+		for _, upload := range output.Uploads {
+			if upload.Initiated == nil || upload.Initiated.After(before) {
+				continue
+			}
+			if skip[aws.ToString(upload.UploadId)] {
+				continue
+			}
+			abortMultipartUpload(client, acc.BucketName, aws.ToString(upload.Key), aws.ToString(upload.UploadId))
+			count++
+		}
+
+		if !aws.ToBool(output.IsTruncated) {
+			break
+		}
+		input.KeyMarker = output.NextKeyMarker
+		input.UploadIdMarker = output.NextUploadIdMarker
+	}
+
+	return count, nil
+}
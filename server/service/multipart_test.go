@@ -0,0 +1,305 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// readerOnly 隐藏底层 Reader 的 Seek 方法，模拟 URL 下载等不可回退的数据源
+type readerOnly struct {
+	io.Reader
+}
+
+// uploadSourceCase 上传数据源测试用例：不同大小和是否可 Seek 的组合
+type uploadSourceCase struct {
+	name     string
+	size     int
+	seekable bool
+}
+
+func uploadSourceCases() []uploadSourceCase {
+	sizes := []struct {
+		name string
+		size int
+	}{
+		{"smaller than part", 100},
+		{"exact part", int(UploadPartSize)},
+		{"part plus one", int(UploadPartSize) + 1},
+	}
+	var cases []uploadSourceCase
+	for _, s := range sizes {
+		cases = append(cases,
+			uploadSourceCase{s.name + "/seekable", s.size, true},
+			uploadSourceCase{s.name + "/stream", s.size, false},
+		)
+	}
+	return cases
+}
+
+// data 生成确定的随机数据
+func (c uploadSourceCase) data() []byte {
+	data := make([]byte, c.size)
+	rand.New(rand.NewSource(int64(c.size))).Read(data)
+	return data
+}
+
+// reader 按用例返回可 Seek 或不可 Seek 的 Reader
+func (c uploadSourceCase) reader(data []byte) io.Reader {
+	if c.seekable {
+		return bytes.NewReader(data)
+	}
+	return readerOnly{bytes.NewReader(data)}
+}
+
+// oneShotChecksums 一次性计算整个数据的校验值
+func oneShotChecksums(data []byte) Checksums {
+	sum := sha256.Sum256(data)
+	return Checksums{
+		SHA256: hex.EncodeToString(sum[:]),
+		CRC32C: crc32cHex(crc32.Checksum(data, crc32cTable)),
+	}
+}
+
+// readRest 按 uploadParts 的方式读取首个分片之后的数据并更新校验值，数据在首个分片内时不读取
+func readRest(t *testing.T, src *uploadSource) []byte {
+	t.Helper()
+	if src.headOnly {
+		return nil
+	}
+	src.readRest = true
+	rest, err := io.ReadAll(src.body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.write(rest)
+	return rest
+}
+
+func TestNewUploadSource(t *testing.T) {
+	for _, tt := range uploadSourceCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data()
+			src, err := newUploadSource(tt.reader(data), int64(tt.size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.close()
+
+			headOnly := int64(tt.size) < UploadPartSize
+			if src.headOnly != headOnly {
+				t.Fatalf("headOnly = %v，应为 %v", src.headOnly, headOnly)
+			}
+			wantHead := data[:min(int64(tt.size), UploadPartSize)]
+			if !bytes.Equal(src.head, wantHead) {
+				t.Fatalf("首个分片 %d 字节，应为 %d 字节", len(src.head), len(wantHead))
+			}
+			if replayable := src.seeker != nil; replayable != (tt.seekable && !headOnly) {
+				t.Fatalf("可回退 = %v，应为 %v", replayable, tt.seekable && !headOnly)
+			}
+			if headOnly {
+				if got := src.checksums(); got != oneShotChecksums(data) {
+					t.Fatalf("校验值 %+v，应为 %+v", got, oneShotChecksums(data))
+				}
+			}
+		})
+	}
+}
+
+func TestUploadSourceChecksums(t *testing.T) {
+	for _, tt := range uploadSourceCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data()
+			src, err := newUploadSource(tt.reader(data), int64(tt.size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.close()
+
+			rest := readRest(t, src)
+			if got := append(append([]byte(nil), src.head...), rest...); !bytes.Equal(got, data) {
+				t.Fatal("读取的数据与原始数据不一致")
+			}
+			if got, want := src.checksums(), oneShotChecksums(data); got != want {
+				t.Fatalf("校验值 %+v，应为 %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestUploadSourceRewind(t *testing.T) {
+	for _, tt := range uploadSourceCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data()
+			src, err := newUploadSource(tt.reader(data), int64(tt.size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.close()
+
+			// 只读取了首个分片时总能回退
+			if err := src.rewind(); err != nil {
+				t.Fatalf("读取后续数据前回退失败: %v", err)
+			}
+
+			readRest(t, src)
+			err = src.rewind()
+			if src.headOnly {
+				if err != nil {
+					t.Fatalf("数据在首个分片内时回退失败: %v", err)
+				}
+				return
+			}
+			if !tt.seekable {
+				if !errors.Is(err, errSourceNotReplayable) {
+					t.Fatalf("不可 Seek 的数据源回退应返回 errSourceNotReplayable，实际: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("回退失败: %v", err)
+			}
+
+			// 回退后重新读取，数据和校验值与第一次相同
+			if got, want := src.checksums(), oneShotChecksums(src.head); got != want {
+				t.Fatalf("回退后校验值 %+v，应恢复为首个分片的 %+v", got, want)
+			}
+			rest := readRest(t, src)
+			if !bytes.Equal(rest, data[len(src.head):]) {
+				t.Fatal("回退后读取的数据不一致")
+			}
+			if got, want := src.checksums(), oneShotChecksums(data); got != want {
+				t.Fatalf("回退后校验值 %+v，应为 %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestUploadSourcePrecomputeSum(t *testing.T) {
+	for _, tt := range uploadSourceCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data()
+			src, err := newUploadSource(tt.reader(data), int64(tt.size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.close()
+
+			want := oneShotChecksums(data)
+			sum, err := src.precomputeSum()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sum != want.SHA256 {
+				t.Fatalf("SHA-256 %s，应为 %s", sum, want.SHA256)
+			}
+			meta := src.metadata()
+			if meta.SHA256 != want.SHA256 || meta.CRC32C != want.CRC32C {
+				t.Fatalf("元数据校验值 %s/%s，应为 %s/%s", meta.SHA256, meta.CRC32C, want.SHA256, want.CRC32C)
+			}
+			if !src.headOnly && !tt.seekable && src.spool == nil {
+				t.Fatal("不可 Seek 的数据源应缓存到临时文件")
+			}
+
+			// 预先计算后数据仍可完整读取，且可以回退重试
+			rest := readRest(t, src)
+			if !bytes.Equal(rest, data[len(src.head):]) {
+				t.Fatal("预先计算后读取的数据不一致")
+			}
+			if got := src.checksums(); got != want {
+				t.Fatalf("读取后校验值 %+v，应为 %+v", got, want)
+			}
+			if err := src.rewind(); err != nil {
+				t.Fatalf("预先计算后回退失败: %v", err)
+			}
+		})
+	}
+}
+
+func TestUploadSourceSizeMismatch(t *testing.T) {
+	tt := uploadSourceCase{size: int(UploadPartSize) + 1}
+	data := tt.data()
+	src, err := newUploadSource(bytes.NewReader(data), int64(len(data))+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.close()
+	if _, err := src.precomputeSum(); !errors.Is(err, ErrIncompleteUpload) {
+		t.Fatalf("声明大小不符时应返回 ErrIncompleteUpload，实际: %v", err)
+	}
+}
+
+func TestStreamUpload(t *testing.T) {
+	for _, tt := range uploadSourceCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeS3(t)
+			acc := fake.account("stream-upload")
+			data := tt.data()
+			src, err := newUploadSource(tt.reader(data), int64(tt.size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.close()
+
+			obj, err := streamUpload(context.Background(), acc, "file.bin", src, "application/octet-stream")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.Size != int64(tt.size) {
+				t.Fatalf("上传大小 %d，应为 %d", obj.Size, tt.size)
+			}
+			stored := fake.object("file.bin")
+			if stored == nil || !bytes.Equal(stored.data, data) {
+				t.Fatal("存储的对象与原始数据不一致")
+			}
+			if got, want := src.checksums(), oneShotChecksums(data); got != want {
+				t.Fatalf("校验值 %+v，应为 %+v", got, want)
+			}
+
+			wantParts := (tt.size + int(UploadPartSize) - 1) / int(UploadPartSize)
+			if src.headOnly {
+				if fake.count("PutObject") != 1 || fake.count("UploadPart") != 0 {
+					t.Fatal("小于一个分片的文件应使用单次 PutObject")
+				}
+				if stored.metadata[MetaSHA256] != oneShotChecksums(data).SHA256 {
+					t.Fatal("单次上传应在元数据中写入 SHA-256")
+				}
+			} else if got := fake.count("UploadPart"); got != wantParts {
+				t.Fatalf("上传了 %d 个分片，应为 %d 个", got, wantParts)
+			}
+			if fake.pendingUploads() != 0 {
+				t.Fatal("存在未完成的分片上传")
+			}
+		})
+	}
+}
+
+// 声明大小与实际数据不符时中止分片上传，不生成对象
+func TestStreamUploadIncomplete(t *testing.T) {
+	fake := newFakeS3(t)
+	acc := fake.account("stream-incomplete")
+	tt := uploadSourceCase{size: int(UploadPartSize) + 1}
+	data := tt.data()
+	src, err := newUploadSource(readerOnly{bytes.NewReader(data)}, int64(len(data))+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.close()
+
+	_, err = streamUpload(context.Background(), acc, "file.bin", src, "application/octet-stream")
+	if !errors.Is(err, ErrIncompleteUpload) {
+		t.Fatalf("应返回 ErrIncompleteUpload，实际: %v", err)
+	}
+	if fake.object("file.bin") != nil {
+		t.Fatal("数据不完整时不应生成对象")
+	}
+	if fake.count("AbortMultipartUpload") != 1 || fake.pendingUploads() != 0 {
+		t.Fatal("数据不完整时应中止分片上传")
+	}
+}
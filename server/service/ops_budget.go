@@ -0,0 +1,201 @@
+package service
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fileflow/server/store"
+)
+
+// 操作次数预算
+// A/B 类操作次数按自然月（UTC）计费。每次用量同步后，根据同步历史估算最近的消耗速率并预测月底的操作次数；
+// 当前用量或预测值达到配额的告警百分比时发送 account.ops_budget_warning 事件。
+// 用量达到配额后账户不再作为上传目标（见 Account.IsOverOps），B 类用量达到配额后代理不再转发该账户的读取
+
+const (
+	// OpsBudgetOK 用量和预测值都低于告警阈值
+	OpsBudgetOK = "ok"
+	// OpsBudgetWarning 用量或月底预测值达到告警阈值
+	OpsBudgetWarning = "warning"
+	// OpsBudgetExceeded 用量已达到配额
+	OpsBudgetExceeded = "exceeded"
+)
+
+const (
+	// OpsAlertUsage 当前用量达到告警阈值
+	OpsAlertUsage = "usage"
+	// OpsAlertProjected 预计月底用量达到告警阈值
+	OpsAlertProjected = "projected"
+)
+
+// opsMinBurnWindow 按同步历史计算消耗速率所需的最短时间跨度，不足时按本月平均速率计算
+const opsMinBurnWindow = time.Hour
+
+// OpsClassForecast 单个操作类别的本月用量和预测
+type OpsClassForecast struct {
+	Used             int64   `json:"used"`                 // 本月估算用量
+	Limit            int64   `json:"limit"`                // 配额，0 表示不限制
+	Percent          float64 `json:"percent"`              // 当前用量占配额的百分比
+	PerHour          float64 `json:"perHour"`              // 消耗速率（次/小时）
+	Projected        int64   `json:"projected"`            // 按消耗速率预计的月底用量
+	ProjectedPercent float64 `json:"projectedPercent"`     // 预计月底用量占配额的百分比
+	Status           string  `json:"status"`               // ok、warning 或 exceeded，不限制时为 ok
+	Reason           string  `json:"reason,omitempty"`     // warning 的原因：usage 或 projected
+	ExhaustsAt       string  `json:"exhaustsAt,omitempty"` // 按消耗速率预计用完配额的时间，月底前用不完时为空
+}
+
+// OpsForecast 账户本月的操作次数预算
+type OpsForecast struct {
+	Month        string           `json:"month"`        // 计费月份（UTC），如 2026-01
+	ClassA       OpsClassForecast `json:"classA"`       // A 类操作（写入、列表）
+	ClassB       OpsClassForecast `json:"classB"`       // B 类操作（读取）
+	Basis        string           `json:"basis"`        // 消耗速率的依据：history（同步历史）或 month（本月平均）
+	AlertPercent int              `json:"alertPercent"` // 告警阈值（占配额的百分比）
+}
+
+// OpsBudgetEventData account.ops_budget_warning 事件的数据
+type OpsBudgetEventData struct {
+	AccountEventData
+	Class    string           `json:"class"` // A 或 B
+	Forecast OpsClassForecast `json:"forecast"`
+}
+
+var (
+	opsAlertsMu sync.Mutex
+	// opsAlerts 已发送的告警（账户、类别和原因）对应的计费月份，同一个月只发送一次
+	opsAlerts = make(map[string]string)
+)
+
+// billingMonthStart 计费月份（UTC 自然月）的开始时间
+func billingMonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ForecastAccountOps 按同步历史预测账户本月的操作次数
+// 消耗速率取本月最早的历史样本到当前估算用量之间的平均值，历史不足 1 小时时使用本月平均速率
+func ForecastAccountOps(acc *store.Account, now time.Time) OpsForecast {
+	now = now.UTC()
+	monthStart := billingMonthStart(now)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	alertPercent := store.GetSettings().OpsAlertPercent
+	forecast := OpsForecast{
+		Month:        monthStart.Format("2006-01"),
+		Basis:        "month",
+		AlertPercent: alertPercent,
+	}
+
+	// 上次同步不在本月时，同步结果是上个月的用量，本月只有本地计数
+	usage := acc.EstimatedUsage()
+	if !sameBillingMonth(acc.Usage.LastSyncAt, now) {
+		usage.ClassAOps = acc.PendingUsage.ClassAOps
+		usage.ClassBOps = acc.PendingUsage.ClassBOps
+	}
+
+	start := monthStart
+	var baseA, baseB int64
+	for _, s := range acc.UsageHistory {
+		t, err := time.Parse(time.RFC3339, s.SyncedAt)
+		if err != nil || t.Before(monthStart) {
+			continue
+		}
+		if now.Sub(t) >= opsMinBurnWindow && s.ClassAOps <= usage.ClassAOps && s.ClassBOps <= usage.ClassBOps {
+			start, baseA, baseB = t, s.ClassAOps, s.ClassBOps
+			forecast.Basis = "history"
+		}
+		break
+	}
+
+	hours := now.Sub(start).Hours()
+	remaining := monthEnd.Sub(now).Hours()
+	forecast.ClassA = forecastOpsClass(usage.ClassAOps, baseA, acc.Quota.MaxClassAOps, true, hours, remaining, now, alertPercent)
+	forecast.ClassB = forecastOpsClass(usage.ClassBOps, baseB, acc.Quota.MaxClassBOps, false, hours, remaining, now, alertPercent)
+	return forecast
+}
+
+// forecastOpsClass 计算单个操作类别的预测
+// zeroMeansExhausted 为 true 时配额 0 视为已用完（与 IsOverOps 对 A 类的判断一致），否则视为不限制
+func forecastOpsClass(used, base, limit int64, zeroMeansExhausted bool, hours, remaining float64, now time.Time, alertPercent int) OpsClassForecast {
+	f := OpsClassForecast{Used: used, Limit: limit, Status: OpsBudgetOK}
+	if hours > 0 {
+		f.PerHour = float64(used-base) / hours
+	}
+	f.Projected = used + int64(f.PerHour*remaining)
+
+	if limit <= 0 {
+		if zeroMeansExhausted {
+			f.Status = OpsBudgetExceeded
+		}
+		return f
+	}
+	f.Percent = float64(used) / float64(limit) * 100
+	f.ProjectedPercent = float64(f.Projected) / float64(limit) * 100
+
+	switch {
+	case used >= limit:
+		f.Status = OpsBudgetExceeded
+	case f.Percent >= float64(alertPercent):
+		f.Status, f.Reason = OpsBudgetWarning, OpsAlertUsage
+	case f.ProjectedPercent >= float64(alertPercent):
+		f.Status, f.Reason = OpsBudgetWarning, OpsAlertProjected
+	}
+	if used < limit && f.PerHour > 0 && f.Projected >= limit {
+		hoursLeft := float64(limit-used) / f.PerHour
+		f.ExhaustsAt = now.Add(time.Duration(hoursLeft * float64(time.Hour))).Format(time.RFC3339)
+	}
+	return f
+}
+
+// checkOpsBudget 同步后检查账户的操作次数预算，达到告警阈值时发送 account.ops_budget_warning 事件
+// 同一账户、类别和原因每个计费月份只告警一次；达到配额由 account.over_quota 事件通知
+func checkOpsBudget(acc *store.Account) {
+	forecast := ForecastAccountOps(acc, time.Now())
+	for _, c := range []struct {
+		class    string
+		forecast OpsClassForecast
+	}{
+		{"A", forecast.ClassA},
+		{"B", forecast.ClassB},
+	} {
+		if c.forecast.Status != OpsBudgetWarning {
+			continue
+		}
+		key := strings.Join([]string{acc.ID, c.class, c.forecast.Reason}, ":")
+		opsAlertsMu.Lock()
+		alerted := opsAlerts[key] == forecast.Month
+		opsAlerts[key] = forecast.Month
+		opsAlertsMu.Unlock()
+		if alerted {
+			continue
+		}
+
+		if c.forecast.Reason == OpsAlertProjected {
+			log.Printf("[Ops] 账户 %s 的 %s 类操作预计月底达到 %d 次（配额 %d）", acc.Name, c.class, c.forecast.Projected, c.forecast.Limit)
+		} else {
+			log.Printf("[Ops] 账户 %s 的 %s 类操作已使用 %.1f%%（%d/%d）", acc.Name, c.class, c.forecast.Percent, c.forecast.Used, c.forecast.Limit)
+		}
+		PublishEvent(EventAccountOpsBudgetWarning, OpsBudgetEventData{
+			AccountEventData: newAccountEventData(acc),
+			Class:            c.class,
+			Forecast:         c.forecast,
+		})
+	}
+}
+
+// FindProxyAccount 根据代理路径中的 r2.dev 子域名查找 R2 账户，没有匹配的账户时返回 nil
+func FindProxyAccount(subdomain string) *store.Account {
+	for _, acc := range store.GetAccounts() {
+		if acc.IsR2() && acc.PublicDomain != "" && strings.EqualFold(publicDomainSubdomain(publicDomainHost(acc.PublicDomain)), subdomain) {
+			return &acc
+		}
+	}
+	return nil
+}
+
+// RecordProxyRead 记录一次经过代理的读取：r2.dev 公开访问按 B 类操作计费
+func RecordProxyRead(accountID string) {
+	recordUsage(accountID, store.UsageDelta{ClassBOps: 1})
+}
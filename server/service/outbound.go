@@ -0,0 +1,237 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"fileflow/server/store"
+)
+
+// ErrURLRejected URL 或其响应被安全策略拒绝，重试不会改变结果
+var ErrURLRejected = errors.New("URL 被安全策略拒绝")
+
+// blockedPrefixes 禁止服务端访问的地址段：回环、私有网络、链路本地（含云厂商元数据地址）、
+// 运营商级 NAT、组播、保留地址，以及可能路由到内网 IPv4 主机的 IPv6 地址段
+// （IPv4 兼容地址、NAT64、6to4、Teredo）和丢弃地址段
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/96"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// isBlockedIP 是否为禁止访问的地址
+// IPv4 映射地址（::ffff:a.b.c.d）按内嵌的 IPv4 地址检查；去掉 IPv6 区域标识，否则 Contains 不会匹配任何地址段
+func isBlockedIP(ip netip.Addr) bool {
+	ip = ip.Unmap().WithZone("")
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// safeDialControl 在 DNS 解析之后、建立连接之前检查实际连接的地址
+// 每次连接（包括重定向后的连接）都会检查，可以防止 DNS 重绑定
+func safeDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: 无效的地址 %s", ErrURLRejected, address)
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || isBlockedIP(ip) {
+		return fmt.Errorf("%w: 不允许访问内网地址 %s", ErrURLRejected, host)
+	}
+	return nil
+}
+
+// safeTransport 禁止连接内网地址的共享 Transport
+// 不使用环境变量中的代理，确保检查的是实际连接的地址
+var safeTransport = &http.Transport{
+	DialContext: (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   safeDialControl,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+}
+
+// NewSafeHTTPClient 创建禁止连接内网地址的 HTTP 客户端
+// 默认不跟随重定向，直接返回 3xx 响应
+func NewSafeHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: safeTransport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// urlPolicy URL 上传的安全策略（来自系统设置）
+type urlPolicy struct {
+	allowedHosts []string
+	maxSize      int64
+	maxRedirects int
+	allowedTypes []string
+	deniedTypes  []string
+}
+
+// loadURLPolicy 读取当前的 URL 上传安全策略
+func loadURLPolicy() urlPolicy {
+	settings := store.GetSettings()
+	return urlPolicy{
+		allowedHosts: splitSettingList(settings.URLAllowedHosts),
+		maxSize:      int64(settings.URLMaxDownloadMB) << 20,
+		maxRedirects: settings.URLMaxRedirects,
+		allowedTypes: splitSettingList(settings.URLAllowedTypes),
+		deniedTypes:  splitSettingList(settings.URLDeniedTypes),
+	}
+}
+
+// splitSettingList 拆分逗号或换行分隔的设置项，统一为小写
+func splitSettingList(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	}) {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// checkURL 检查协议、主机白名单以及直接使用 IP 的地址
+// 域名解析后的地址在建立连接时由 safeDialControl 检查
+func (p urlPolicy) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: 只支持 http:// 和 https:// 地址", ErrURLRejected)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return fmt.Errorf("%w: 缺少主机名", ErrURLRejected)
+	}
+
+	if len(p.allowedHosts) > 0 {
+		allowed := false
+		for _, pattern := range p.allowedHosts {
+			if matchHost(pattern, host) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: 主机 %s 不在允许列表中", ErrURLRejected, host)
+		}
+	}
+
+	if ip, err := netip.ParseAddr(host); err == nil && isBlockedIP(ip) {
+		return fmt.Errorf("%w: 不允许访问内网地址 %s", ErrURLRejected, host)
+	}
+	return nil
+}
+
+// matchHost 匹配主机名，*.example.com 匹配 example.com 的所有子域名
+func matchHost(pattern, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// checkContentType 按允许和拒绝列表检查响应的 Content-Type，拒绝列表优先
+func (p urlPolicy) checkContentType(contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	for _, pattern := range p.deniedTypes {
+		if matchContentType(pattern, mediaType) {
+			return fmt.Errorf("%w: 不允许下载 %s 类型的文件", ErrURLRejected, mediaType)
+		}
+	}
+	if len(p.allowedTypes) == 0 {
+		return nil
+	}
+	for _, pattern := range p.allowedTypes {
+		if matchContentType(pattern, mediaType) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: 不允许下载 %s 类型的文件", ErrURLRejected, mediaType)
+}
+
+// matchContentType 匹配媒体类型，image/* 匹配所有 image 类型
+func matchContentType(pattern, mediaType string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+	return pattern == mediaType
+}
+
+// CheckURL 按当前的安全策略检查 URL（协议、主机白名单、内网 IP）
+// 用于在请求前尽早拒绝，域名解析后的地址仍会在下载时检查
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: 无效的 URL", ErrURLRejected)
+	}
+	return loadURLPolicy().checkURL(u)
+}
+
+// limitedBody 限制下载大小，超过时返回错误而不是截断
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// 继续读取 1 字节确认数据已经结束
+		var probe [1]byte
+		if n, _ := l.ReadCloser.Read(probe[:]); n > 0 {
+			return 0, fmt.Errorf("%w: 文件超过 %d MB 的下载大小限制", ErrURLRejected, l.limit>>20)
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"fileflow/server/store"
+)
+
+// ErrInvalidPlacementStrategy 放置策略不存在
+var ErrInvalidPlacementStrategy = errors.New("无效的放置策略")
+
+// PlacementRequest 放置策略的输入
+type PlacementRequest struct {
+	Prefix string // 文件所在的目录，未指定目录时为文件名
+	Size   int64  // 文件大小，未知时为 -1
+}
+
+// PlacementStrategy 智能上传的账户放置策略
+// Order 返回候选账户的尝试顺序：首个账户优先写入，其余账户在写入失败时依次重试，
+// 多副本时按顺序写入后续账户。accounts 已排除停用、超额和没有上传权限的账户
+type PlacementStrategy interface {
+	Order(accounts []store.Account, req PlacementRequest) []store.Account
+}
+
+var (
+	placementMu         sync.RWMutex
+	placementStrategies = map[string]PlacementStrategy{
+		store.PlacementLeastUsed:          leastUsedPlacement{},
+		store.PlacementLeastOps:           leastOpsPlacement{},
+		store.PlacementWeightedRoundRobin: &weightedRoundRobinPlacement{current: make(map[string]int)},
+		store.PlacementConsistentHash:     consistentHashPlacement{},
+		store.PlacementRandom:             randomPlacement{},
+	}
+)
+
+// RegisterPlacementStrategy 注册放置策略，同名策略会被替换
+func RegisterPlacementStrategy(name string, strategy PlacementStrategy) {
+	placementMu.Lock()
+	defer placementMu.Unlock()
+	placementStrategies[name] = strategy
+}
+
+// PlacementStrategyNames 获取所有已注册的放置策略名称
+func PlacementStrategyNames() []string {
+	placementMu.RLock()
+	defer placementMu.RUnlock()
+
+	names := make([]string, 0, len(placementStrategies))
+	for name := range placementStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidatePlacementStrategy 检查放置策略是否存在，空字符串表示使用默认策略
+func ValidatePlacementStrategy(name string) error {
+	if name == "" {
+		return nil
+	}
+	placementMu.RLock()
+	_, ok := placementStrategies[name]
+	placementMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s，可选值为 %s", ErrInvalidPlacementStrategy, name, strings.Join(PlacementStrategyNames(), "、"))
+	}
+	return nil
+}
+
+// placementStrategyFor 按账户池、Token、系统设置的顺序选择放置策略，未注册的名称按 least-used 处理
+func placementStrategyFor(pool *store.Pool, tokenID string) PlacementStrategy {
+	name := store.GetSettings().PlacementStrategy
+	if pool != nil && pool.PlacementStrategy != "" {
+		name = pool.PlacementStrategy
+	} else if tokenID != "" {
+		if t, err := store.GetTokenByID(tokenID); err == nil && t.PlacementStrategy != "" {
+			name = t.PlacementStrategy
+		}
+	}
+
+	placementMu.RLock()
+	defer placementMu.RUnlock()
+	if strategy, ok := placementStrategies[name]; ok {
+		return strategy
+	}
+	return placementStrategies[store.PlacementLeastUsed]
+}
+
+// placeAccounts 按调用方的放置策略排列自动选择的候选账户
+// 指定账户池时先按成员优先级分组，优先级相同的成员再按放置策略排列
+func placeAccounts(accounts []store.Account, naming KeyNaming, size int64) []store.Account {
+	if len(accounts) <= 1 {
+		return accounts
+	}
+	req := PlacementRequest{
+		Prefix: placementPrefix(naming),
+		Size:   size,
+	}
+
+	var pool *store.Pool
+	if naming.Pool != "" {
+		pool, _ = store.GetPoolByName(naming.Pool)
+	}
+	strategy := placementStrategyFor(pool, naming.TokenID)
+	if pool == nil {
+		return strategy.Order(accounts, req)
+	}
+
+	priority := make(map[string]int, len(pool.Members))
+	for _, m := range pool.Members {
+		priority[m.AccountID] = m.Priority
+	}
+	sorted := append([]store.Account(nil), accounts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return priority[sorted[i].ID] < priority[sorted[j].ID]
+	})
+
+	result := make([]store.Account, 0, len(sorted))
+	for start := 0; start < len(sorted); {
+		end := start + 1
+		for end < len(sorted) && priority[sorted[end].ID] == priority[sorted[start].ID] {
+			end++
+		}
+		result = append(result, strategy.Order(sorted[start:end], req)...)
+		start = end
+	}
+	return result
+}
+
+// placementPrefix 一致性哈希使用的路径前缀：固定路径或自定义目录，都没有时为文件名
+func placementPrefix(n KeyNaming) string {
+	switch {
+	case n.Key != "":
+		return path.Dir(n.Key)
+	case strings.Trim(n.Path, "/") != "":
+		return strings.Trim(n.Path, "/")
+	default:
+		return n.FileName
+	}
+}
+
+// sortByUsage 按容量使用率从低到高排序
+func sortByUsage(accounts []store.Account) {
+	sort.SliceStable(accounts, func(i, j int) bool {
+		return accounts[i].GetCommittedPercent() < accounts[j].GetCommittedPercent()
+	})
+}
+
+// leastUsedPlacement 按容量使用率从低到高
+type leastUsedPlacement struct{}
+
+func (leastUsedPlacement) Order(accounts []store.Account, _ PlacementRequest) []store.Account {
+	result := append([]store.Account(nil), accounts...)
+	sortByUsage(result)
+	return result
+}
+
+// leastOpsPlacement 按 A 类操作次数使用率从低到高，相同时按容量使用率
+type leastOpsPlacement struct{}
+
+func (leastOpsPlacement) Order(accounts []store.Account, _ PlacementRequest) []store.Account {
+	result := append([]store.Account(nil), accounts...)
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i].GetOpsPercent(), result[j].GetOpsPercent()
+		if a != b {
+			return a < b
+		}
+		return result[i].GetCommittedPercent() < result[j].GetCommittedPercent()
+	})
+	return result
+}
+
+// weightedRoundRobinPlacement 平滑加权轮询：每次选出当前权重最高的账户，
+// 权重为 2 的账户被选中的次数是权重为 1 的两倍，且不会连续集中在同一账户；其余账户按容量使用率排列
+type weightedRoundRobinPlacement struct {
+	mu      sync.Mutex
+	current map[string]int // 账户 ID -> 当前权重
+}
+
+func (p *weightedRoundRobinPlacement) Order(accounts []store.Account, _ PlacementRequest) []store.Account {
+	p.mu.Lock()
+	total, best := 0, 0
+	for i := range accounts {
+		weight := accounts[i].GetWeight()
+		total += weight
+		p.current[accounts[i].ID] += weight
+		if p.current[accounts[i].ID] > p.current[accounts[best].ID] {
+			best = i
+		}
+	}
+	p.current[accounts[best].ID] -= total
+	p.mu.Unlock()
+
+	rest := make([]store.Account, 0, len(accounts)-1)
+	rest = append(rest, accounts[:best]...)
+	rest = append(rest, accounts[best+1:]...)
+	sortByUsage(rest)
+	return append([]store.Account{accounts[best]}, rest...)
+}
+
+// consistentHashPlacement 按路径前缀的加权最高随机权重哈希（rendezvous hashing）排列账户
+// 同一前缀总是得到相同的顺序；增删账户时只有原本落在该账户上的前缀会改变首选账户
+type consistentHashPlacement struct{}
+
+func (consistentHashPlacement) Order(accounts []store.Account, req PlacementRequest) []store.Account {
+	scores := make(map[string]float64, len(accounts))
+	for i := range accounts {
+		sum := sha256.Sum256([]byte(req.Prefix + "\x00" + accounts[i].ID))
+		// 映射到 (0, 1) 的均匀分布，score = weight / -ln(u)
+		u := (float64(binary.BigEndian.Uint64(sum[:8])>>11) + 0.5) / (1 << 53)
+		scores[accounts[i].ID] = float64(accounts[i].GetWeight()) / -math.Log(u)
+	}
+
+	result := append([]store.Account(nil), accounts...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return scores[result[i].ID] > scores[result[j].ID]
+	})
+	return result
+}
+
+// randomPlacement 在剩余空间能放下文件且未超出操作次数的账户中按权重随机排列，
+// 其余账户按容量使用率排在后面
+type randomPlacement struct{}
+
+func (randomPlacement) Order(accounts []store.Account, req PlacementRequest) []store.Account {
+	var fits, rest []store.Account
+	keys := make(map[string]float64, len(accounts))
+	for _, acc := range accounts {
+		if acc.FreeBytes() > max(req.Size, 0) && !acc.IsOverOps() {
+			// 加权随机排列：key = u^(1/weight)，按 key 从大到小
+			keys[acc.ID] = math.Pow(rand.Float64(), 1/float64(acc.GetWeight()))
+			fits = append(fits, acc)
+		} else {
+			rest = append(rest, acc)
+		}
+	}
+
+	sort.SliceStable(fits, func(i, j int) bool {
+		return keys[fits[i].ID] > keys[fits[j].ID]
+	})
+	sortByUsage(rest)
+	return append(fits, rest...)
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"fileflow/server/store"
+)
+
+// ErrPoolNotFound 账户池不存在
+var ErrPoolNotFound = errors.New("账户池不存在")
+
+// autoUploadAccounts 获取自动选择账户时的候选账户
+// 指定账户池时为池中可上传的成员，否则为所有可自动上传（API）或前端上传的账户，排除熔断中的账户；
+// 尝试顺序由 placeAccounts 决定
+func autoUploadAccounts(naming KeyNaming, forClient bool) ([]store.Account, error) {
+	var accounts []store.Account
+	var err error
+	switch {
+	case naming.Pool != "":
+		accounts, err = poolUploadAccounts(naming.Pool, forClient)
+	case forClient:
+		accounts, err = clientUploadAccounts("")
+	default:
+		accounts, err = apiUploadAccounts("")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return withoutOpenCircuits(accounts)
+}
+
+// poolUploadAccounts 获取账户池中可上传的成员账户，按成员优先级和使用率升序
+// 成员是调用方显式选择的账户，与指定账户上传一样检查 api_upload 或 client_upload 权限，不要求 auto_upload
+func poolUploadAccounts(name string, forClient bool) ([]store.Account, error) {
+	pool, err := store.GetPoolByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrPoolNotFound, name)
+	}
+
+	priority := make(map[string]int, len(pool.Members))
+	var accounts []store.Account
+	for _, m := range pool.Members {
+		acc, err := store.GetAccountByID(m.AccountID)
+		if err != nil {
+			continue
+		}
+		if (forClient && acc.IsAvailableForClientUpload()) || (!forClient && acc.IsAvailableForAPIUpload()) {
+			priority[acc.ID] = m.Priority
+			accounts = append(accounts, *acc)
+		}
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("账户池 %s 中没有可用的存储账户", name)
+	}
+
+	sort.SliceStable(accounts, func(i, j int) bool {
+		if priority[accounts[i].ID] != priority[accounts[j].ID] {
+			return priority[accounts[i].ID] < priority[accounts[j].ID]
+		}
+		return accounts[i].GetCommittedPercent() < accounts[j].GetCommittedPercent()
+	})
+	return accounts, nil
+}
+
+// PoolAccountIDs 获取账户池的成员账户ID
+func PoolAccountIDs(name string) ([]string, error) {
+	pool, err := store.GetPoolByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrPoolNotFound, name)
+	}
+	return pool.AccountIDs(), nil
+}
+
+// LocateFile 按顺序查找包含文件（含分块文件）的账户，用于只提供账户池名称的文件操作，跳过熔断中的账户
+func LocateFile(ctx context.Context, accountIDs []string, key string) (string, error) {
+	for _, id := range accountIDs {
+		if _, err := store.GetStripedFileByKey(id, key); err == nil {
+			return id, nil
+		}
+		acc, err := store.GetAccountByID(id)
+		if err != nil || !acc.IsActive || IsCircuitOpen(id) {
+			continue
+		}
+		exists, err := objectExists(ctx, acc, key)
+		if err != nil {
+			log.Printf("[Pool] 在账户 %s 中查找文件失败: %v", acc.Name, err)
+			continue
+		}
+		if exists {
+			return id, nil
+		}
+	}
+	return "", ErrFileNotFound
+}
@@ -0,0 +1,484 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+)
+
+const (
+	// PresignedUploadTTL 预签名上传地址的有效期
+	PresignedUploadTTL = time.Hour
+	// PresignedUploadGrace 上传地址过期后保留预留记录的时间，供客户端调用完成接口
+	PresignedUploadGrace = time.Hour
+	// PresignedMultipartThreshold 超过该大小时改用分片直传
+	PresignedMultipartThreshold int64 = 64 << 20
+)
+
+// ErrPresignedUploadNotFound 直传预留记录不存在或已过期
+var ErrPresignedUploadNotFound = errors.New("直传预留记录不存在或已过期")
+
+// PresignUploadOptions 创建预签名直传的参数
+type PresignUploadOptions struct {
+	AccountID      string         // 指定账户ID（可选，为空时自动选择）
+	Naming         KeyNaming      // 存储路径命名参数
+	Metadata       ObjectMetadata // 随对象保存的上传信息
+	Size           int64          // 文件大小
+	ContentType    string
+	FileName       string
+	ExpirationDays int    // 文件到期天数，0 表示永久
+	TokenID        string // 创建者的 API Token ID
+	ForClient      bool   // 前端上传（检查 client_upload 权限），否则按 API 上传规则选择账户
+	Multipart      bool   // 强制使用分片直传
+}
+
+// PresignedPart 分片上传地址
+type PresignedPart struct {
+	PartNumber int32  `json:"partNumber"`
+	URL        string `json:"url"`
+	Size       int64  `json:"size"`
+}
+
+// PresignedUploadTarget 预签名直传目标
+type PresignedUploadTarget struct {
+	ID          string            `json:"id"`
+	AccountID   string            `json:"accountId"`
+	AccountName string            `json:"accountName"`
+	Key         string            `json:"key"`
+	Method      string            `json:"method"`
+	URL         string            `json:"url,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Multipart   bool              `json:"multipart"`
+	PartSize    int64             `json:"partSize,omitempty"`
+	Parts       []PresignedPart   `json:"parts,omitempty"`
+	ExpiresAt   string            `json:"expiresAt"`
+}
+
+// CreatePresignedUpload 选择账户并生成预签名上传地址
+// 小文件返回单个 PUT 地址，大文件发起分片上传并返回每个分片的 PUT 地址
+func CreatePresignedUpload(ctx context.Context, opts PresignUploadOptions) (*PresignedUploadTarget, error) {
+	var accounts []store.Account
+	var err error
+	switch {
+	case opts.AccountID == "":
+		accounts, err = autoUploadAccounts(opts.Naming, opts.ForClient)
+	case opts.ForClient:
+		accounts, err = clientUploadAccounts(opts.AccountID)
+	default:
+		accounts, err = apiUploadAccounts(opts.AccountID)
+	}
+	if err == nil {
+		accounts, err = withoutEncryptedAccounts(accounts, opts.AccountID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.AccountID == "" {
+		accounts = placeAccounts(accounts, opts.Naming, opts.Size)
+	}
+
+	if opts.ContentType == "" {
+		opts.ContentType = "application/octet-stream"
+	}
+	multipart := opts.Size > 0 && (opts.Multipart || opts.Size > PresignedMultipartThreshold)
+
+	urlExpiresAt := time.Now().Add(PresignedUploadTTL)
+	// 预先生成记录ID，作为容量预留的ID
+	record := &store.PresignedUpload{
+		ID:             uuid.New().String(),
+		Size:           opts.Size,
+		ContentType:    opts.ContentType,
+		FileName:       opts.FileName,
+		ExpirationDays: opts.ExpirationDays,
+		TokenID:        opts.TokenID,
+		ExpiresAt:      urlExpiresAt.Add(PresignedUploadGrace).UTC().Format(time.RFC3339),
+	}
+
+	var acc *store.Account
+	var lastErr error
+	for i := range accounts {
+		candidate := &accounts[i]
+
+		// 文件哈希在上传完成前未知，模板中的 {sha256} 使用随机值
+		key, err := buildObjectKey(ctx, candidate, opts.Naming, nil)
+		if err != nil {
+			if errors.Is(err, ErrKeyConflict) {
+				return nil, err
+			}
+			lastErr = err
+			log.Printf("生成账户 %s 的存储路径失败: %v，尝试下一个账户", candidate.Name, err)
+			continue
+		}
+		// 直传过期或取消时会删除客户端写入的对象，不能指向已有文件（即使冲突策略为覆盖）
+		if namingSkipsCollisionCheck(candidate, opts.Naming) {
+			exists, err := objectExists(ctx, candidate, key)
+			if err != nil {
+				lastErr = err
+				log.Printf("检查账户 %s 的存储路径失败: %v，尝试下一个账户", candidate.Name, err)
+				continue
+			}
+			if exists {
+				return nil, fmt.Errorf("%w: %s（直传不能覆盖已有文件）", ErrKeyConflict, key)
+			}
+		}
+		record.FileKey = key
+
+		// 预留到记录过期为止，指定账户时不检查剩余空间
+		if _, err := reserveSpace(candidate, record.ID, opts.Size, UploadSourcePresign, key, PresignedUploadTTL+PresignedUploadGrace, opts.AccountID != ""); err != nil {
+			lastErr = err
+			log.Printf("账户 %s 剩余空间不足: %v，尝试下一个账户", candidate.Name, err)
+			continue
+		}
+		if !multipart {
+			acc = candidate
+			break
+		}
+
+		created, err := getS3Client(candidate).CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket:             aws.String(candidate.BucketName),
+			Key:                aws.String(key),
+			ContentType:        aws.String(opts.ContentType),
+			ContentDisposition: opts.Metadata.contentDisposition(),
+			Metadata:           opts.Metadata.toS3(),
+		})
+		if err != nil {
+			releaseReservation(record.ID)
+			lastErr = fmt.Errorf("创建分片上传失败: %w", err)
+			log.Printf("上传到账户 %s 失败: %v，尝试下一个账户", candidate.Name, err)
+			continue
+		}
+		acc = candidate
+		record.UploadID = aws.ToString(created.UploadId)
+		record.PartSize = partSizeFor(opts.Size)
+		break
+	}
+	if acc == nil {
+		return nil, fmt.Errorf("所有账户上传均失败: %w", lastErr)
+	}
+	record.AccountID = acc.ID
+
+	target, err := presignUploadTarget(ctx, acc, record, opts.Metadata)
+	if err != nil {
+		releaseReservation(record.ID)
+		if record.UploadID != "" {
+			abortMultipartUpload(getS3Client(acc), acc.BucketName, record.FileKey, record.UploadID)
+		}
+		return nil, err
+	}
+
+	if err := store.CreatePresignedUpload(record); err != nil {
+		releaseReservation(record.ID)
+		if record.UploadID != "" {
+			abortMultipartUpload(getS3Client(acc), acc.BucketName, record.FileKey, record.UploadID)
+		}
+		return nil, fmt.Errorf("保存直传预留记录失败: %w", err)
+	}
+
+	target.ID = record.ID
+	target.ExpiresAt = urlExpiresAt.UTC().Format(time.RFC3339)
+	return target, nil
+}
+
+// presignUploadTarget 生成预签名上传地址
+// 单次直传的元数据参与签名，客户端需要按 Headers 原样携带
+func presignUploadTarget(ctx context.Context, acc *store.Account, record *store.PresignedUpload, meta ObjectMetadata) (*PresignedUploadTarget, error) {
+	presigner := s3.NewPresignClient(getS3Client(acc))
+	withTTL := s3.WithPresignExpires(PresignedUploadTTL)
+
+	target := &PresignedUploadTarget{
+		AccountID:   acc.ID,
+		AccountName: acc.Name,
+		Key:         record.FileKey,
+		Method:      "PUT",
+	}
+
+	if record.UploadID == "" {
+		// 预留ID随元数据参与签名，只有通过该地址写入的对象带有此ID
+		metadata := meta.toS3()
+		metadata[MetaPresignID] = record.ID
+		disposition := meta.contentDisposition()
+		req, err := presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+			Bucket:             aws.String(acc.BucketName),
+			Key:                aws.String(record.FileKey),
+			ContentType:        aws.String(record.ContentType),
+			ContentLength:      aws.Int64(record.Size),
+			ContentDisposition: disposition,
+			Metadata:           metadata,
+		}, withTTL)
+		if err != nil {
+			return nil, fmt.Errorf("生成预签名上传地址失败: %w", err)
+		}
+		target.URL = req.URL
+		target.Headers = map[string]string{"Content-Type": record.ContentType}
+		if disposition != nil {
+			target.Headers["Content-Disposition"] = *disposition
+		}
+		for k, v := range metadata {
+			target.Headers["x-amz-meta-"+k] = v
+		}
+		return target, nil
+	}
+
+	target.Multipart = true
+	target.PartSize = record.PartSize
+	for offset, partNumber := int64(0), int32(1); offset < record.Size; partNumber++ {
+		partSize := record.PartSize
+		if remaining := record.Size - offset; remaining < partSize {
+			partSize = remaining
+		}
+
+		req, err := presigner.PresignUploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(acc.BucketName),
+			Key:        aws.String(record.FileKey),
+			UploadId:   aws.String(record.UploadID),
+			PartNumber: aws.Int32(partNumber),
+		}, withTTL)
+		if err != nil {
+			return nil, fmt.Errorf("生成分片 %d 上传地址失败: %w", partNumber, err)
+		}
+		target.Parts = append(target.Parts, PresignedPart{
+			PartNumber: partNumber,
+			URL:        req.URL,
+			Size:       partSize,
+		})
+		offset += partSize
+	}
+
+	return target, nil
+}
+
+// GetPresignedUpload 获取未过期的直传预留记录
+func GetPresignedUpload(id string) (*store.PresignedUpload, error) {
+	record, err := store.GetPresignedUploadByID(id)
+	if err != nil {
+		return nil, ErrPresignedUploadNotFound
+	}
+	if record.ExpiresAt <= store.NowString() {
+		return nil, ErrPresignedUploadNotFound
+	}
+	return record, nil
+}
+
+// CompletePresignedUpload 确认客户端直传完成
+// 分片直传会先合并分片；随后通过 HeadObject 校验对象大小，创建文件到期记录并返回上传结果
+func CompletePresignedUpload(ctx context.Context, id string) (*UploadResult, error) {
+	lock := sessionLock(id)
+	if !lock.TryLock() {
+		return nil, ErrUploadSessionLocked
+	}
+	defer lock.Unlock()
+
+	record, err := GetPresignedUpload(id)
+	if err != nil {
+		return nil, err
+	}
+
+	acc, err := store.GetAccountByID(record.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("账户不存在: %w", err)
+	}
+	client := getS3Client(acc)
+
+	// 客户端直接发送的 PutObject / UploadPart 请求不经过 FileFlow，确认完成时计入操作次数
+	clientOps := int64(1)
+	if record.UploadID != "" {
+		parts, err := listUploadedParts(ctx, client, acc.BucketName, record.FileKey, record.UploadID)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 0 {
+			return nil, fmt.Errorf("尚未上传任何分片")
+		}
+		clientOps = int64(len(parts))
+
+		_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(acc.BucketName),
+			Key:             aws.String(record.FileKey),
+			UploadId:        aws.String(record.UploadID),
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("完成分片上传失败: %w", err)
+		}
+	}
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(acc.BucketName),
+		Key:    aws.String(record.FileKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("文件尚未上传或无法访问: %w", err)
+	}
+
+	size := aws.ToInt64(head.ContentLength)
+	if size != record.Size {
+		// 大小不符的对象无法确认完整性，删除后需要重新申请上传；单次直传只删除通过该预留写入的对象
+		if record.UploadID != "" || writtenByPresign(record, head.Metadata) {
+			if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(acc.BucketName),
+				Key:    aws.String(record.FileKey),
+			}); err != nil {
+				log.Printf("[Presign] 删除大小不符的对象失败 (key=%s): %v", record.FileKey, err)
+			}
+		}
+		store.DeletePresignedUpload(record.ID)
+		releaseReservation(record.ID)
+		return nil, fmt.Errorf("文件大小不符：期望 %d 字节，实际 %d 字节", record.Size, size)
+	}
+
+	recordUsage(acc.ID, store.UsageDelta{SizeBytes: size, ClassAOps: clientOps})
+	releaseReservation(record.ID)
+
+	if err := CreateFileExpirationRecord(acc.ID, record.FileKey, size, record.ExpirationDays); err != nil {
+		// 到期记录创建失败不影响上传结果，仅记录日志
+		log.Printf("[Presign] 创建文件到期记录失败: %v", err)
+	}
+	if err := store.DeletePresignedUpload(record.ID); err != nil {
+		log.Printf("[Presign] 删除直传预留记录失败: %v", err)
+	}
+	uploadSessionLocks.Delete(id)
+
+	result := newUploadResult(ctx, acc, record.FileKey, size)
+	publishUploadEvent(result, UploadSourcePresign)
+	return result, nil
+}
+
+// CancelPresignedUpload 取消直传预留，中止分片上传并删除已上传的对象
+func CancelPresignedUpload(id string) error {
+	lock := sessionLock(id)
+	if !lock.TryLock() {
+		return ErrUploadSessionLocked
+	}
+	defer func() {
+		lock.Unlock()
+		uploadSessionLocks.Delete(id)
+	}()
+
+	record, err := store.GetPresignedUploadByID(id)
+	if err != nil {
+		return ErrPresignedUploadNotFound
+	}
+
+	discardPresignedUpload(context.Background(), record)
+	if err := store.DeletePresignedUpload(id); err != nil {
+		return fmt.Errorf("删除直传预留记录失败: %w", err)
+	}
+	return nil
+}
+
+// CleanupExpiredPresignedUploads 清理过期未完成的直传预留
+func CleanupExpiredPresignedUploads(ctx context.Context) {
+	now := store.NowString()
+	count := 0
+
+	for _, record := range store.GetPresignedUploads() {
+		if ctx.Err() != nil {
+			return
+		}
+		if record.ExpiresAt > now {
+			continue
+		}
+
+		lock := sessionLock(record.ID)
+		if !lock.TryLock() {
+			continue
+		}
+		discardPresignedUpload(ctx, &record)
+		if err := store.DeletePresignedUpload(record.ID); err != nil {
+			log.Printf("[Presign] 删除过期直传预留 %s 失败: %v", record.ID, err)
+		} else {
+			count++
+		}
+		lock.Unlock()
+		uploadSessionLocks.Delete(record.ID)
+	}
+
+	if count > 0 {
+		log.Printf("[Presign] 已清理 %d 个过期直传预留", count)
+	}
+}
+
+// discardPresignedUpload 释放容量预留，中止分片上传，并删除客户端可能已写入但未确认的对象
+func discardPresignedUpload(ctx context.Context, record *store.PresignedUpload) {
+	releaseReservation(record.ID)
+	acc, err := store.GetAccountByID(record.AccountID)
+	if err != nil {
+		return
+	}
+	client := getS3Client(acc)
+
+	if record.UploadID != "" {
+		abortMultipartUpload(client, acc.BucketName, record.FileKey, record.UploadID)
+		return
+	}
+
+	// 只删除通过该预留写入的对象，不影响同一路径上的其他文件
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(acc.BucketName),
+		Key:    aws.String(record.FileKey),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if !errors.As(err, &notFound) {
+			log.Printf("[Presign] 检查未确认的对象失败 (key=%s): %v", record.FileKey, err)
+		}
+		return
+	}
+	if !writtenByPresign(record, head.Metadata) {
+		return
+	}
+
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(acc.BucketName),
+		Key:    aws.String(record.FileKey),
+	})
+	if err != nil {
+		log.Printf("[Presign] 删除未确认的对象失败 (key=%s): %v", record.FileKey, err)
+	}
+}
+
+// writtenByPresign 检查对象是否通过直传预留的上传地址写入
+// 按元数据中的预留ID判断，不比较服务端与存储的时间，时钟偏差不会导致误删其他文件
+func writtenByPresign(record *store.PresignedUpload, metadata map[string]string) bool {
+	return decodeObjectMetadata(metadata)[MetaPresignID] == record.ID
+}
+
+// listUploadedParts 列出分片上传中已上传的全部分片
+func listUploadedParts(ctx context.Context, client *s3.Client, bucket, key, uploadID string) ([]types.CompletedPart, error) {
+	input := &s3.ListPartsInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}
+
+	var parts []types.CompletedPart
+	for {
+		output, err := client.ListParts(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("列出已上传分片失败: %w", err)
+		}
+
+		for _, part := range output.Parts {
+			parts = append(parts, types.CompletedPart{
+				ETag:       part.ETag,
+				PartNumber: part.PartNumber,
+			})
+		}
+
+		if !aws.ToBool(output.IsTruncated) {
+			break
+		}
+		input.PartNumberMarker = output.NextPartNumberMarker
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"fileflow/server/store"
+)
+
+// 过期或取消的单次直传只删除带有该预留ID的对象，与对象的修改时间无关
+func TestDiscardPresignedUpload(t *testing.T) {
+	tests := []struct {
+		name    string
+		meta    map[string]string
+		deleted bool
+	}{
+		{"written by presign", map[string]string{MetaPresignID: "presign-1"}, true},
+		{"other presign", map[string]string{MetaPresignID: "presign-2"}, false},
+		{"other upload", map[string]string{MetaSource: UploadSourceFile}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeS3(t)
+			acc := storedFakeAccount(t, fake, "presign-"+tt.name)
+			fake.objects["file.bin"] = &fakeObject{data: []byte("data"), metadata: tt.meta, etag: fakeETag([]byte("data"))}
+
+			record := &store.PresignedUpload{ID: "presign-1", AccountID: acc.ID, FileKey: "file.bin", Size: 4}
+			discardPresignedUpload(context.Background(), record)
+			if deleted := fake.object("file.bin") == nil; deleted != tt.deleted {
+				t.Fatalf("对象已删除 = %v，应为 %v", deleted, tt.deleted)
+			}
+		})
+	}
+}
+
+func TestPresignUploadTargetMetadata(t *testing.T) {
+	fake := newFakeS3(t)
+	acc := fake.account("presign-target")
+	record := &store.PresignedUpload{ID: "presign-1", FileKey: "file.bin", Size: 4, ContentType: "text/plain"}
+
+	target, err := presignUploadTarget(context.Background(), acc, record, ObjectMetadata{Source: UploadSourcePresign})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := target.Headers["x-amz-meta-"+MetaPresignID]; got != record.ID {
+		t.Fatalf("上传地址应要求携带预留ID，实际 %q", got)
+	}
+}
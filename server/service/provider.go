@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// 存储服务商
+// 账户可以是 Cloudflare R2 或任意 S3 兼容服务。服务商决定默认的签名区域和访问风格，以及用量同步时
+// 如何获取容量和操作次数：R2 通过 Cloudflare GraphQL 分析接口获取操作次数，其他服务商没有统计接口，
+// 容量通过列出对象统计，操作次数使用本地计数
+
+// ErrInvalidProvider 服务商不存在
+var ErrInvalidProvider = errors.New("无效的存储服务商")
+
+// ErrOpsUnavailable 服务商没有提供操作次数统计接口
+var ErrOpsUnavailable = errors.New("服务商不提供操作次数统计")
+
+// ProviderInfo 服务商的默认配置
+type ProviderInfo struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	DefaultRegion    string `json:"defaultRegion"`    // 未配置 region 时使用的签名区域，为空表示从端点提取
+	DefaultPathStyle bool   `json:"defaultPathStyle"` // 创建账户时未指定 pathStyle 的默认值
+	EndpointRequired bool   `json:"endpointRequired"` // 是否必须配置端点（AWS 可按区域解析）
+	EndpointExample  string `json:"endpointExample"`
+	OpsMetrics       bool   `json:"opsMetrics"` // 是否可以从服务商获取操作次数
+}
+
+// providers 支持的服务商，按 ID 索引
+var providers = map[string]ProviderInfo{
+	store.ProviderR2: {
+		ID: store.ProviderR2, Name: "Cloudflare R2", DefaultRegion: "auto",
+		EndpointRequired: true, EndpointExample: "https://{accountid}.r2.cloudflarestorage.com", OpsMetrics: true,
+	},
+	store.ProviderAWS: {
+		ID: store.ProviderAWS, Name: "Amazon S3", DefaultRegion: store.DefaultS3Region,
+		EndpointExample: "https://s3.{region}.amazonaws.com",
+	},
+	store.ProviderMinIO: {
+		ID: store.ProviderMinIO, Name: "MinIO", DefaultRegion: store.DefaultS3Region, DefaultPathStyle: true,
+		EndpointRequired: true, EndpointExample: "http://127.0.0.1:9000",
+	},
+	store.ProviderB2: {
+		ID: store.ProviderB2, Name: "Backblaze B2",
+		EndpointRequired: true, EndpointExample: "https://s3.us-west-004.backblazeb2.com",
+	},
+	store.ProviderWasabi: {
+		ID: store.ProviderWasabi, Name: "Wasabi",
+		EndpointRequired: true, EndpointExample: "https://s3.us-east-1.wasabisys.com",
+	},
+	store.ProviderGeneric: {
+		ID: store.ProviderGeneric, Name: "其他 S3 兼容服务", DefaultRegion: store.DefaultS3Region,
+		EndpointRequired: true, EndpointExample: "https://s3.example.com",
+	},
+}
+
+// Providers 获取支持的服务商列表
+func Providers() []ProviderInfo {
+	result := make([]ProviderInfo, 0, len(providers))
+	for _, p := range providers {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// GetProviderInfo 获取服务商的默认配置，空字符串表示 R2
+func GetProviderInfo(provider string) (ProviderInfo, error) {
+	if provider == "" {
+		provider = store.ProviderR2
+	}
+	p, ok := providers[provider]
+	if !ok {
+		ids := make([]string, 0, len(providers))
+		for id := range providers {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		return ProviderInfo{}, fmt.Errorf("%w: %s，可选值为 %s", ErrInvalidProvider, provider, strings.Join(ids, "、"))
+	}
+	return p, nil
+}
+
+// ValidateAccountConnection 校验账户的服务商和连接配置
+func ValidateAccountConnection(acc *store.Account) error {
+	p, err := GetProviderInfo(acc.Provider)
+	if err != nil {
+		return err
+	}
+	if acc.IsR2() && acc.AccountID == "" {
+		return errors.New("R2 账户需要填写 Cloudflare Account ID")
+	}
+	if acc.Endpoint == "" {
+		if p.EndpointRequired {
+			return fmt.Errorf("%s 账户需要配置端点，如 %s", p.Name, p.EndpointExample)
+		}
+		return nil
+	}
+	u, err := url.Parse(acc.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("端点必须是 http 或 https 地址，如 %s", p.EndpointExample)
+	}
+	return nil
+}
+
+// s3ClientOptions 按服务商配置 S3 客户端：区域、端点、访问风格，以及校验和
+// R2 和 AWS 支持 SDK 默认附加的 CRC 校验和，其他 S3 兼容服务只在操作要求时计算，避免不支持的服务拒绝请求
+func s3ClientOptions(acc *store.Account) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.Region = acc.GetRegion()
+		if acc.Endpoint != "" {
+			o.BaseEndpoint = aws.String(acc.Endpoint)
+		}
+		o.UsePathStyle = acc.PathStyle
+		switch acc.GetProvider() {
+		case store.ProviderR2, store.ProviderAWS:
+		default:
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+		}
+	}
+}
+
+// UsageCollector 服务商的用量获取方式
+type UsageCollector interface {
+	// StorageSize 获取存储桶中对象的总大小
+	StorageSize(ctx context.Context, acc *store.Account) (int64, error)
+	// Operations 获取本月的 A 类和 B 类操作次数，没有统计接口时返回 ErrOpsUnavailable
+	Operations(ctx context.Context, acc *store.Account) (classA int64, classB int64, err error)
+}
+
+var (
+	usageCollectorsMu sync.RWMutex
+	usageCollectors   = map[string]UsageCollector{
+		store.ProviderR2: r2UsageCollector{},
+	}
+)
+
+// RegisterUsageCollector 注册服务商的用量获取方式，同一服务商会被替换
+func RegisterUsageCollector(provider string, collector UsageCollector) {
+	usageCollectorsMu.Lock()
+	defer usageCollectorsMu.Unlock()
+	usageCollectors[provider] = collector
+}
+
+// usageCollectorFor 获取账户的用量获取方式，未注册的服务商通过列出对象统计容量
+func usageCollectorFor(acc *store.Account) UsageCollector {
+	usageCollectorsMu.RLock()
+	defer usageCollectorsMu.RUnlock()
+	if c, ok := usageCollectors[acc.GetProvider()]; ok {
+		return c
+	}
+	return listObjectsUsageCollector{}
+}
+
+// listObjectsUsageCollector 通过列出对象统计容量，不提供操作次数
+type listObjectsUsageCollector struct{}
+
+func (listObjectsUsageCollector) StorageSize(ctx context.Context, acc *store.Account) (int64, error) {
+	return GetAccountStorageSize(ctx, acc)
+}
+
+func (listObjectsUsageCollector) Operations(context.Context, *store.Account) (int64, int64, error) {
+	return 0, 0, ErrOpsUnavailable
+}
+
+// r2UsageCollector 通过列出对象统计容量，通过 Cloudflare GraphQL 分析接口获取操作次数
+type r2UsageCollector struct {
+	listObjectsUsageCollector
+}
+
+func (r2UsageCollector) Operations(ctx context.Context, acc *store.Account) (int64, int64, error) {
+	return getAccountOps(ctx, acc)
+}
@@ -0,0 +1,556 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// ReplicaVerifyInterval 副本校验间隔，修复任务只对超过该间隔未校验的副本集逐个 HeadObject
+	ReplicaVerifyInterval = 24 * time.Hour
+	// replicaRepairSchedule 副本修复任务的执行间隔
+	replicaRepairSchedule = "@every 30m"
+)
+
+var (
+	// ErrReplicaRepairRunning 副本修复任务正在运行
+	ErrReplicaRepairRunning = errors.New("副本修复任务正在运行")
+
+	errReplicaMissing  = errors.New("副本不存在")
+	errReplicaMismatch = errors.New("副本内容与记录不一致")
+)
+
+// replicaRepairRunning 同一时间只运行一个修复任务
+var replicaRepairRunning atomic.Bool
+
+// ReplicaRepairResult 副本修复结果
+type ReplicaRepairResult struct {
+	Checked  int `json:"checked"`  // 检查的副本集数量
+	Created  int `json:"created"`  // 新建的副本数量
+	Dropped  int `json:"dropped"`  // 移除的失效副本数量
+	Degraded int `json:"degraded"` // 检查后仍然副本不足的副本集数量
+	Lost     int `json:"lost"`     // 没有可用副本的副本集数量
+}
+
+// replicationFactor 返回智能上传要求的副本数
+func replicationFactor() int {
+	factor := store.GetSettings().ReplicationFactor
+	if factor < 1 {
+		return 1
+	}
+	return min(factor, store.MaxReplicationFactor)
+}
+
+// replicaStatus 按有效副本数计算副本集状态
+func replicaStatus(set *store.ReplicaSet) string {
+	switch {
+	case len(set.Replicas) == 0:
+		return store.ReplicaSetLost
+	case len(set.Replicas) < set.Factor:
+		return store.ReplicaSetDegraded
+	default:
+		return store.ReplicaSetHealthy
+	}
+}
+
+// replicateUpload 把刚写入 primary 的文件依次写入其他候选账户，直到满足副本数，并记录副本集
+// 副本使用相同的路径，不参与去重，也不生成缩略图；写入失败只记录日志，
+// 副本数不足时副本集标记为 degraded，由修复任务补齐；pool 为上传指定的账户池，修复时只在池成员中补齐
+// 可回退的数据源重新读取写入，已读完且无法回退的数据流（如 URL 下载）从 primary 复制，都按上传得到的 SHA-256 校验
+func replicateUpload(ctx context.Context, primary *store.Account, candidates []store.Account, src *uploadSource, result *UploadResult, contentType string, factor int, pool string) {
+	set := &store.ReplicaSet{
+		AccountID: primary.ID,
+		FileKey:   result.Key,
+		Size:      result.Size,
+		SHA256:    result.SHA256,
+		Factor:    factor,
+		Replicas:  []string{primary.ID},
+		Pool:      pool,
+	}
+
+	// 副本不单独报告上传进度
+	src.dedup = false
+	ctx = WithUploadProgress(ctx, nil)
+	// 文件哈希已由首个账户的上传得到，写入副本元数据，并校验重新读取的数据
+	src.meta.SHA256 = result.SHA256
+	src.meta.CRC32C = result.CRC32C
+	src.meta.Expected = Checksums{SHA256: result.SHA256, CRC32C: result.CRC32C}
+
+	var failures []string
+	for i := range candidates {
+		if len(set.Replicas) >= factor {
+			break
+		}
+		acc := &candidates[i]
+		if acc.ID == primary.ID {
+			continue
+		}
+		err := placeReplica(ctx, acc, set, func() error {
+			if err := src.rewind(); errors.Is(err, errSourceNotReplayable) {
+				return copyObjectBetweenAccounts(ctx, primary, acc, set)
+			} else if err != nil {
+				return err
+			}
+			_, err := streamUpload(ctx, acc, set.FileKey, src, contentType)
+			return err
+		})
+		if err != nil {
+			log.Printf("[Replica] 写入账户 %s 的副本失败 (key=%s): %v", acc.Name, set.FileKey, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", acc.Name, err))
+			continue
+		}
+		set.Replicas = append(set.Replicas, acc.ID)
+	}
+
+	set.Status = replicaStatus(set)
+	if set.Status != store.ReplicaSetHealthy {
+		set.LastError = fmt.Sprintf("可用账户不足，仅写入 %d/%d 个副本", len(set.Replicas), factor)
+		if len(failures) > 0 {
+			set.LastError += ": " + strings.Join(failures, "; ")
+		}
+	}
+	if err := store.CreateReplicaSet(set); err != nil {
+		log.Printf("[Replica] 记录副本集失败 (key=%s): %v", set.FileKey, err)
+	}
+	result.Replicas = set.Replicas
+}
+
+// replicaCandidates 返回可以补齐副本的账户，与原上传的账户范围一致
+func replicaCandidates(set *store.ReplicaSet) []store.Account {
+	var candidates []store.Account
+	var err error
+	if set.Pool != "" {
+		candidates, err = poolUploadAccounts(set.Pool, false)
+	} else {
+		candidates, err = apiUploadAccounts("")
+	}
+	if err != nil {
+		log.Printf("[Replica] 获取补齐副本的候选账户失败 (key=%s): %v", set.FileKey, err)
+		return nil
+	}
+	candidates, _ = withoutOpenCircuits(candidates)
+	return candidates
+}
+
+// placeReplica 在账户中放置副本
+// 目标路径已有内容相同的对象时直接采用，已有其他文件时放弃该账户，不存在时预留容量后调用 write 写入
+func placeReplica(ctx context.Context, acc *store.Account, set *store.ReplicaSet, write func() error) error {
+	err := verifyReplica(ctx, acc, set)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errReplicaMissing):
+		reservationID, err := reserveUpload(acc, set.Size, "replica", set.FileKey, false)
+		if err != nil {
+			return err
+		}
+		defer releaseReservation(reservationID)
+		return write()
+	case errors.Is(err, errReplicaMismatch):
+		return fmt.Errorf("目标路径已存在其他文件")
+	default:
+		return err
+	}
+}
+
+// verifyReplica 通过 HeadObject 校验账户中的副本：大小一致，且对象记录了 SHA-256 时与副本集一致
+func verifyReplica(ctx context.Context, acc *store.Account, set *store.ReplicaSet) error {
+	head, err := getS3Client(acc).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(acc.BucketName),
+		Key:    aws.String(set.FileKey),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return errReplicaMissing
+		}
+		return fmt.Errorf("获取副本元数据失败: %w", err)
+	}
+	size := aws.ToInt64(head.ContentLength)
+	if IsEncryptedObject(head.Metadata) {
+		size = PlaintextSize(size)
+	}
+	if size != set.Size {
+		return fmt.Errorf("%w: 大小 %d 字节，应为 %d 字节", errReplicaMismatch, size, set.Size)
+	}
+	// 边上传边计算哈希的分片上传在对象中没有记录 SHA-256，只校验大小
+	if recorded := decodeObjectMetadata(head.Metadata)[MetaSHA256]; set.SHA256 != "" && recorded != "" && recorded != set.SHA256 {
+		return fmt.Errorf("%w: SHA-256 不匹配", errReplicaMismatch)
+	}
+	return nil
+}
+
+// rankReplicaAccounts 返回副本集中可用于读取的账户，按健康程度排序：
+// 未熔断的优先，其次未超出操作次数限制，再次未超出容量配额，最后按容量使用率从低到高
+func rankReplicaAccounts(set *store.ReplicaSet) []store.Account {
+	var accounts []store.Account
+	for _, id := range set.Replicas {
+		if acc, err := store.GetAccountByID(id); err == nil && acc.IsActive {
+			accounts = append(accounts, *acc)
+		}
+	}
+	sort.SliceStable(accounts, func(i, j int) bool {
+		a, b := &accounts[i], &accounts[j]
+		if IsCircuitOpen(a.ID) != IsCircuitOpen(b.ID) {
+			return !IsCircuitOpen(a.ID)
+		}
+		if a.IsOverOps() != b.IsOverOps() {
+			return !a.IsOverOps()
+		}
+		if a.IsOverQuota() != b.IsOverQuota() {
+			return !a.IsOverQuota()
+		}
+		return a.GetUsagePercent() < b.GetUsagePercent()
+	})
+	return accounts
+}
+
+// replicaFileLink 文件有多个副本时从最健康的副本生成链接，没有副本集或所有副本都无法生成链接时返回 nil
+func replicaFileLink(ctx context.Context, accountID, key string, opts LinkOptions) *FileLink {
+	set, err := store.GetReplicaSetByKey(accountID, key)
+	if err != nil {
+		return nil
+	}
+	for _, acc := range rankReplicaAccounts(set) {
+		link, err := buildFileLink(ctx, &acc, key, opts)
+		if err == nil {
+			return link
+		}
+		log.Printf("[Replica] 账户 %s 的副本无法生成链接 (key=%s): %v", acc.Name, key, err)
+	}
+	return nil
+}
+
+// DeleteReplicas 文件或目录被删除后删除其他账户中的副本并移除副本集
+// key 以 / 结尾时处理该目录下的所有副本集
+func DeleteReplicas(ctx context.Context, accountID, key string) {
+	var sets []store.ReplicaSet
+	if strings.HasSuffix(key, "/") {
+		sets = store.GetReplicaSetsByPrefix(accountID, key)
+	} else if set, err := store.GetReplicaSetByKey(accountID, key); err == nil {
+		sets = append(sets, *set)
+	}
+	if len(sets) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(sets))
+	for _, set := range sets {
+		for _, id := range set.Replicas {
+			if id == accountID {
+				continue
+			}
+			acc, err := store.GetAccountByID(id)
+			if err != nil {
+				continue
+			}
+			_, err = getS3Client(acc).DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(acc.BucketName),
+				Key:    aws.String(set.FileKey),
+			})
+			if err != nil {
+				log.Printf("[Replica] 删除账户 %s 的副本失败 (key=%s): %v", acc.Name, set.FileKey, err)
+				continue
+			}
+			RecordStoredBytes(acc.ID, -set.Size)
+			// 通过副本账户删除时，首次写入账户的内容索引和自定义元数据一并移除
+			if obj, err := store.GetFileObjectByKey(acc.ID, set.FileKey); err == nil {
+				store.DeleteFileObject(obj.ID)
+			}
+			store.DeleteFileMetadata(acc.ID, set.FileKey)
+		}
+		ids = append(ids, set.ID)
+	}
+	if err := store.DeleteReplicaSets(ids); err != nil {
+		log.Printf("[Replica] 删除副本集失败: %v", err)
+	}
+}
+
+// MoveReplicas 文件或目录移动后同步移动其他账户中的副本，from 以 / 结尾时视为目录
+// 副本在各自账户内通过 CopyObject 移动，移动失败的副本从副本集中移除，由修复任务重新创建
+func MoveReplicas(ctx context.Context, accountID, from, to string) {
+	var sets []store.ReplicaSet
+	if strings.HasSuffix(from, "/") {
+		sets = store.GetReplicaSetsByPrefix(accountID, from)
+	} else if set, err := store.GetReplicaSetByKey(accountID, from); err == nil {
+		sets = append(sets, *set)
+	}
+
+	for _, set := range sets {
+		oldKey := set.FileKey
+		newKey := to
+		if strings.HasSuffix(from, "/") {
+			newKey = to + strings.TrimPrefix(oldKey, from)
+		}
+
+		replicas := []string{}
+		for _, id := range set.Replicas {
+			if id == accountID {
+				replicas = append(replicas, id)
+				continue
+			}
+			acc, err := store.GetAccountByID(id)
+			if err != nil {
+				continue
+			}
+			if err := moveObject(ctx, acc, oldKey, newKey); err != nil {
+				log.Printf("[Replica] 移动账户 %s 的副本失败 (%s -> %s): %v", acc.Name, oldKey, newKey, err)
+				continue
+			}
+			replicas = append(replicas, id)
+		}
+
+		set.FileKey = newKey
+		set.Replicas = replicas
+		set.Status = replicaStatus(&set)
+		if err := store.UpdateReplicaSet(&set); err != nil {
+			log.Printf("[Replica] 更新副本集失败 (key=%s): %v", newKey, err)
+		}
+	}
+}
+
+// moveObject 在账户内把对象复制到新路径后删除原对象
+func moveObject(ctx context.Context, acc *store.Account, from, to string) error {
+	client := getS3Client(acc)
+	_, err := client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(acc.BucketName),
+		Key:        aws.String(to),
+		CopySource: aws.String(acc.BucketName + "/" + escapeObjectKey(from)),
+	})
+	if err != nil {
+		return fmt.Errorf("复制对象失败: %w", err)
+	}
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(acc.BucketName),
+		Key:    aws.String(from),
+	})
+	if err != nil {
+		return fmt.Errorf("删除原对象失败: %w", err)
+	}
+	return nil
+}
+
+// RepairReplicas 检查所有副本集，移除失效的副本并在其他账户上补齐
+// force 为 true 时校验所有副本，否则只校验超过 ReplicaVerifyInterval 未校验或副本不足的副本集
+func RepairReplicas(ctx context.Context, force bool) (*ReplicaRepairResult, error) {
+	if !replicaRepairRunning.CompareAndSwap(false, true) {
+		return nil, ErrReplicaRepairRunning
+	}
+	defer replicaRepairRunning.Store(false)
+	return repairReplicas(ctx, force), nil
+}
+
+// StartReplicaRepair 在后台校验所有副本并补齐缺失的副本
+func StartReplicaRepair() error {
+	if !replicaRepairRunning.CompareAndSwap(false, true) {
+		return ErrReplicaRepairRunning
+	}
+	go func() {
+		defer replicaRepairRunning.Store(false)
+		repairReplicas(context.Background(), true)
+	}()
+	return nil
+}
+
+// repairReplicas 依次修复所有副本集
+func repairReplicas(ctx context.Context, force bool) *ReplicaRepairResult {
+	result := &ReplicaRepairResult{}
+	for _, set := range store.GetReplicaSets() {
+		repairReplicaSet(ctx, &set, force, result)
+	}
+	if result.Checked > 0 {
+		log.Printf("[Replica] 修复完成: 检查 %d 个副本集，新建 %d 个副本，移除 %d 个失效副本，%d 个副本不足，%d 个没有可用副本",
+			result.Checked, result.Created, result.Dropped, result.Degraded, result.Lost)
+	}
+	return result
+}
+
+// repairReplicaSet 修复单个副本集
+// 账户已删除或停用的副本直接移除；需要校验时逐个 HeadObject，缺失、内容不一致或无法访问的副本也会移除；
+// 之后从最健康的副本读取数据，写入其他可用于自动上传的账户（上传时指定了账户池的只写入池成员），直到满足副本数
+func repairReplicaSet(ctx context.Context, set *store.ReplicaSet, force bool, result *ReplicaRepairResult) {
+	// 没有任何副本时无法修复，只在手动修复时重新统计
+	if len(set.Replicas) == 0 && !force {
+		return
+	}
+	now := time.Now()
+	verify := force || set.Status != store.ReplicaSetHealthy
+	if checked, err := time.Parse(time.RFC3339, set.LastCheckedAt); err != nil || now.Sub(checked) >= ReplicaVerifyInterval {
+		verify = true
+	}
+
+	var healthy []string
+	var problems []string
+	failed := make(map[string]bool)
+	for _, id := range set.Replicas {
+		acc, err := store.GetAccountByID(id)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("账户 %s 已删除", id))
+			continue
+		}
+		if !acc.IsActive {
+			problems = append(problems, fmt.Sprintf("账户 %s 已停用", acc.Name))
+			failed[id] = true
+			continue
+		}
+		// 熔断中的账户暂不校验，避免临时故障导致副本被移除
+		if verify && !IsCircuitOpen(id) {
+			if err := verifyReplica(ctx, acc, set); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", acc.Name, err))
+				failed[id] = true
+				continue
+			}
+		}
+		healthy = append(healthy, id)
+	}
+
+	// 不需要校验且副本都在可用账户上时无需处理
+	if !verify && len(problems) == 0 && len(healthy) >= set.Factor {
+		return
+	}
+	result.Checked++
+	result.Dropped += len(set.Replicas) - len(healthy)
+	for _, p := range problems {
+		log.Printf("[Replica] 移除失效副本 (key=%s): %s", set.FileKey, p)
+	}
+	set.Replicas = healthy
+
+	if len(healthy) > 0 && len(healthy) < set.Factor {
+		sources := rankReplicaAccounts(set)
+		candidates := replicaCandidates(set)
+		for i := range candidates {
+			if len(set.Replicas) >= set.Factor {
+				break
+			}
+			target := &candidates[i]
+			if set.HasReplica(target.ID) || failed[target.ID] {
+				continue
+			}
+			if err := copyReplica(ctx, sources, target, set); err != nil {
+				log.Printf("[Replica] 在账户 %s 创建副本失败 (key=%s): %v", target.Name, set.FileKey, err)
+				problems = append(problems, fmt.Sprintf("%s: %v", target.Name, err))
+				continue
+			}
+			set.Replicas = append(set.Replicas, target.ID)
+			result.Created++
+			log.Printf("[Replica] 已在账户 %s 创建副本 (key=%s)", target.Name, set.FileKey)
+		}
+	}
+
+	set.Status = replicaStatus(set)
+	switch set.Status {
+	case store.ReplicaSetHealthy:
+		set.LastError = ""
+	case store.ReplicaSetDegraded:
+		result.Degraded++
+		set.LastError = fmt.Sprintf("仅有 %d/%d 个副本", len(set.Replicas), set.Factor)
+	case store.ReplicaSetLost:
+		result.Lost++
+		set.LastError = "没有可用副本"
+		log.Printf("[Replica] 文件没有可用副本 (key=%s)", set.FileKey)
+	}
+	if set.Status != store.ReplicaSetHealthy && len(problems) > 0 {
+		set.LastError += ": " + strings.Join(problems, "; ")
+	}
+	if verify {
+		set.LastCheckedAt = now.UTC().Format(time.RFC3339)
+	}
+	if err := store.UpdateReplicaSet(set); err != nil {
+		log.Printf("[Replica] 更新副本集失败 (key=%s): %v", set.FileKey, err)
+	}
+}
+
+// copyReplica 从 sources 中第一个可读取的副本下载文件并写入 target，写入时校验 SHA-256
+// 对象的 Content-Type 和上传信息保持不变
+func copyReplica(ctx context.Context, sources []store.Account, target *store.Account, set *store.ReplicaSet) error {
+	return placeReplica(ctx, target, set, func() error {
+		var lastErr error
+		for i := range sources {
+			if err := copyObjectBetweenAccounts(ctx, &sources[i], target, set); err != nil {
+				lastErr = err
+				continue
+			}
+			return nil
+		}
+		return lastErr
+	})
+}
+
+// copyObjectBetweenAccounts 以流式方式把对象从一个账户复制到另一个账户的相同路径
+// 加密对象读取时解密，写入时按目标账户的设置重新加密，元数据记录副本集的 SHA-256
+func copyObjectBetweenAccounts(ctx context.Context, from, to *store.Account, set *store.ReplicaSet) error {
+	file, err := openObject(ctx, from, set.FileKey)
+	if err != nil {
+		return fmt.Errorf("读取账户 %s 的副本失败: %w", from.Name, err)
+	}
+	defer file.Close()
+
+	src, err := newUploadSource(file, file.Size)
+	if err != nil {
+		return err
+	}
+	defer src.close()
+	src.meta = objectMetadataFromS3(file.metadata)
+	src.meta.SHA256 = set.SHA256
+	src.meta.Expected = Checksums{SHA256: set.SHA256}
+
+	_, err = streamUpload(WithUploadProgress(ctx, nil), to, set.FileKey, src, file.ContentType)
+	return err
+}
+
+// objectMetadataFromS3 从对象的用户元数据还原上传信息
+func objectMetadataFromS3(raw map[string]string) ObjectMetadata {
+	meta := decodeObjectMetadata(raw)
+	custom, tags := splitCustomMetadata(meta)
+	return ObjectMetadata{
+		OriginalName: meta[MetaOriginalName],
+		Uploader:     meta[MetaUploader],
+		Source:       meta[MetaSource],
+		SourceURL:    meta[MetaSourceURL],
+		SHA256:       meta[MetaSHA256],
+		CRC32C:       meta[MetaCRC32C],
+		Custom:       custom,
+		Tags:         tags,
+	}
+}
+
+// ReplicaSetSummary 副本集统计
+type ReplicaSetSummary struct {
+	Total    int `json:"total"`
+	Healthy  int `json:"healthy"`
+	Degraded int `json:"degraded"`
+	Lost     int `json:"lost"`
+}
+
+// ListReplicaSets 获取副本集列表及各状态数量，status 非空时只返回该状态的副本集
+func ListReplicaSets(status string) ([]store.ReplicaSet, ReplicaSetSummary) {
+	var summary ReplicaSetSummary
+	result := []store.ReplicaSet{}
+	for _, set := range store.GetReplicaSets() {
+		summary.Total++
+		switch set.Status {
+		case store.ReplicaSetHealthy:
+			summary.Healthy++
+		case store.ReplicaSetDegraded:
+			summary.Degraded++
+		case store.ReplicaSetLost:
+			summary.Lost++
+		}
+		if status == "" || set.Status == status {
+			result = append(result, set)
+		}
+	}
+	return result, summary
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"fileflow/server/store"
+)
+
+// storedFakeAccount 在存储中创建指向 fakeS3 的账户，测试结束时删除
+func storedFakeAccount(t *testing.T, f *fakeS3, name string) *store.Account {
+	t.Helper()
+	acc := f.account(name)
+	acc.Quota.MaxSizeBytes = 1 << 30
+	if err := store.CreateAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.DeleteAccount(acc.ID) })
+	return acc
+}
+
+// 副本不预先缓存数据：可 Seek 的数据源重新读取，数据流从首个账户复制，副本都记录首个账户上传得到的 SHA-256
+func TestReplicateUpload(t *testing.T) {
+	for _, tt := range uploadSourceCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			primaryS3, replicaS3 := newFakeS3(t), newFakeS3(t)
+			primary := storedFakeAccount(t, primaryS3, "primary-"+tt.name)
+			replica := storedFakeAccount(t, replicaS3, "replica-"+tt.name)
+			data := tt.data()
+			src, err := newUploadSource(tt.reader(data), int64(tt.size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.close()
+
+			ctx := context.Background()
+			result, err := doUpload(ctx, primary, "file.bin", src, "application/octet-stream")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := oneShotChecksums(data)
+			if result.SHA256 != want.SHA256 {
+				t.Fatalf("上传结果 SHA-256 %s，应为 %s", result.SHA256, want.SHA256)
+			}
+
+			replicateUpload(ctx, primary, []store.Account{*replica}, src, result, "application/octet-stream", 2, "")
+			if src.spool != nil {
+				t.Fatal("写入副本不应缓存数据到临时文件")
+			}
+			if len(result.Replicas) != 2 {
+				t.Fatalf("副本账户 %v，应写入 2 个副本", result.Replicas)
+			}
+			stored := replicaS3.object("file.bin")
+			if stored == nil || !bytes.Equal(stored.data, data) {
+				t.Fatal("副本内容与原始数据不一致")
+			}
+			if stored.metadata[MetaSHA256] != want.SHA256 {
+				t.Fatalf("副本元数据 SHA-256 %q，应为 %s", stored.metadata[MetaSHA256], want.SHA256)
+			}
+			// 不可回退的数据流从首个账户读取
+			fromPrimary := primaryS3.count("GetObject") > 0
+			if wantCopy := !tt.seekable && !src.headOnly; fromPrimary != wantCopy {
+				t.Fatalf("从首个账户复制 = %v，应为 %v", fromPrimary, wantCopy)
+			}
+
+			set, err := store.GetReplicaSetByKey(primary.ID, "file.bin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.DeleteReplicaSets([]string{set.ID}) })
+			if set.Status != store.ReplicaSetHealthy || set.SHA256 != want.SHA256 {
+				t.Fatalf("副本集状态 %s、SHA-256 %s，应为 healthy 和 %s", set.Status, set.SHA256, want.SHA256)
+			}
+			for _, acc := range []*store.Account{primary, replica} {
+				if err := verifyReplica(ctx, acc, set); err != nil {
+					t.Fatalf("校验账户 %s 的副本失败: %v", acc.Name, err)
+				}
+			}
+		})
+	}
+}
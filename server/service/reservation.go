@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"fileflow/server/store"
+)
+
+// 容量预留
+// 大小已知的上传在写入前按对象大小在选中的账户上预留容量，写入完成（用量已计入本地计数）或失败后释放；
+// 自动选择账户时预留失败视为空间不足并尝试下一个账户，放置策略和分块规划按扣除预留后的剩余空间计算。
+// 调用方指定账户时只记录预留不检查剩余空间，与之前一样由调用方决定是否写入
+
+// ErrSpaceReserved 账户扣除进行中上传的预留后剩余空间不足
+var ErrSpaceReserved = errors.New("账户剩余空间不足（已扣除进行中上传的预留）")
+
+// uploadReservationTTL 普通上传的预留有效期，写入异常中断未释放时到期自动失效
+const uploadReservationTTL = time.Hour
+
+// reserveUpload 在账户上预留写入 size 字节明文所需的容量，返回预留 ID
+// 大小未知或为 0 时不预留，返回空 ID；force 为 true 时不检查剩余空间
+func reserveUpload(acc *store.Account, size int64, source, key string, force bool) (string, error) {
+	return reserveSpace(acc, "", size, source, key, uploadReservationTTL, force)
+}
+
+// reserveSpace 使用指定的预留 ID 和有效期预留容量，id 为空时自动生成
+func reserveSpace(acc *store.Account, id string, size int64, source, key string, ttl time.Duration, force bool) (string, error) {
+	if size <= 0 {
+		return "", nil
+	}
+	if acc.Encryption {
+		size = EncryptedSize(size)
+	}
+	r := &store.Reservation{
+		ID:        id,
+		AccountID: acc.ID,
+		Size:      size,
+		Source:    source,
+		Key:       key,
+	}
+	if force {
+		store.AddReservation(r, ttl)
+		return r.ID, nil
+	}
+	if !store.ReserveAccountSpace(r, ttl) {
+		return "", fmt.Errorf("%w: 账户 %s 需要 %d 字节", ErrSpaceReserved, acc.Name, size)
+	}
+	return r.ID, nil
+}
+
+// releaseReservation 释放预留，id 为空时不做任何处理
+func releaseReservation(id string) {
+	if id != "" {
+		store.ReleaseReservation(id)
+	}
+}
+
+// RestoreReservations 恢复服务重启前进行中的上传的预留：未完成的直传预留按声明大小预留到其过期时间，
+// 未完成的可恢复上传会话按剩余大小预留；在存储初始化后调用
+func RestoreReservations() {
+	count := 0
+	now := time.Now()
+	for _, record := range store.GetPresignedUploads() {
+		expiresAt, err := time.Parse(time.RFC3339, record.ExpiresAt)
+		if err != nil || !expiresAt.After(now) || record.Size <= 0 {
+			continue
+		}
+		acc, err := store.GetAccountByID(record.AccountID)
+		if err != nil {
+			continue
+		}
+		if _, err := reserveSpace(acc, record.ID, record.Size, UploadSourcePresign, record.FileKey, expiresAt.Sub(now), true); err == nil {
+			count++
+		}
+	}
+	for _, sess := range store.GetUploadSessions() {
+		if sess.Completed || sess.UploadID == "" {
+			continue
+		}
+		acc, err := store.GetAccountByID(sess.AccountID)
+		if err != nil {
+			continue
+		}
+		if _, err := reserveSpace(acc, sess.ID, sess.Size-sessionUploadedBytes(&sess), UploadSourceTus, sess.FileKey, UploadSessionTTL, true); err == nil {
+			count++
+		}
+	}
+	if count > 0 {
+		log.Printf("[Reservation] 已恢复 %d 个进行中上传的容量预留", count)
+	}
+}
+
+// AccountReservations 单个账户的预留合计
+type AccountReservations struct {
+	AccountID     string `json:"accountId"`
+	AccountName   string `json:"accountName"`
+	ReservedBytes int64  `json:"reservedBytes"`
+	Count         int    `json:"count"`
+}
+
+// ListReservations 获取所有未过期的预留以及按账户汇总的预留容量
+func ListReservations() ([]store.Reservation, []AccountReservations) {
+	reservations := store.GetReservations()
+
+	index := make(map[string]int)
+	summary := []AccountReservations{}
+	for _, r := range reservations {
+		i, ok := index[r.AccountID]
+		if !ok {
+			name := ""
+			if acc, err := store.GetAccountByID(r.AccountID); err == nil {
+				name = acc.Name
+			}
+			i = len(summary)
+			index[r.AccountID] = i
+			summary = append(summary, AccountReservations{AccountID: r.AccountID, AccountName: name})
+		}
+		summary[i].ReservedBytes += r.Size
+		summary[i].Count++
+	}
+	return reservations, summary
+}
+
+// CleanupExpiredReservations 释放异常中断后未释放且已过期的预留
+func CleanupExpiredReservations() {
+	if count := store.PruneExpiredReservations(); count > 0 {
+		log.Printf("[Reservation] 已释放 %d 个过期的容量预留", count)
+	}
+}
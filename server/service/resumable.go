@@ -0,0 +1,559 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fileflow/server/config"
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+)
+
+// UploadSessionTTL 可恢复上传会话的有效期，每次写入数据后顺延
+const UploadSessionTTL = 24 * time.Hour
+
+var (
+	// ErrUploadSessionNotFound 上传会话不存在或已过期
+	ErrUploadSessionNotFound = errors.New("上传会话不存在或已过期")
+	// ErrUploadOffsetMismatch 客户端提供的偏移量与服务端记录不一致
+	ErrUploadOffsetMismatch = errors.New("上传偏移量不匹配")
+	// ErrUploadSessionLocked 上传会话正在被其他请求写入
+	ErrUploadSessionLocked = errors.New("上传会话正在写入中，请稍后重试")
+)
+
+// uploadSessionLocks 上传会话及直传预留的操作锁，防止同一记录被并发处理
+var uploadSessionLocks sync.Map
+
+// ResumableUploadOptions 创建可恢复上传的参数
+type ResumableUploadOptions struct {
+	AccountID      string         // 指定账户ID（可选，为空时自动选择）
+	Naming         KeyNaming      // 存储路径命名参数
+	Metadata       ObjectMetadata // 随对象保存的上传信息
+	Size           int64          // 文件总大小
+	ContentType    string
+	FileName       string
+	ExpirationDays int    // 文件到期天数，0 表示永久
+	TokenID        string // 创建者的 API Token ID
+}
+
+// sessionLock 获取上传会话或直传预留的操作锁
+func sessionLock(id string) *sync.Mutex {
+	lock, _ := uploadSessionLocks.LoadOrStore(id, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// sessionTailPath 会话暂存数据（不足一个分片的尾部数据）的本地路径
+func sessionTailPath(id string) string {
+	return filepath.Join(config.Get().DataDir, "uploads", id+".part")
+}
+
+// CreateResumableUpload 创建可恢复上传会话
+// 按前端上传规则选择账户并发起分片上传，失败时依次尝试下一个账户
+func CreateResumableUpload(ctx context.Context, opts ResumableUploadOptions) (*store.UploadSession, error) {
+	var accounts []store.Account
+	var err error
+	if opts.AccountID == "" {
+		accounts, err = autoUploadAccounts(opts.Naming, true)
+	} else {
+		accounts, err = clientUploadAccounts(opts.AccountID)
+	}
+	if err == nil {
+		accounts, err = withoutEncryptedAccounts(accounts, opts.AccountID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.AccountID == "" {
+		accounts = placeAccounts(accounts, opts.Naming, opts.Size)
+	}
+
+	if opts.ContentType == "" {
+		opts.ContentType = "application/octet-stream"
+	}
+
+	// 预先生成会话ID，作为容量预留的ID
+	sess := &store.UploadSession{
+		ID:             uuid.New().String(),
+		Size:           opts.Size,
+		PartSize:       partSizeFor(opts.Size),
+		Parts:          []store.UploadPart{},
+		ContentType:    opts.ContentType,
+		FileName:       opts.FileName,
+		ExpirationDays: opts.ExpirationDays,
+		TokenID:        opts.TokenID,
+		ExpiresAt:      time.Now().Add(UploadSessionTTL).UTC().Format(time.RFC3339),
+	}
+
+	var lastErr error
+	for i := range accounts {
+		acc := &accounts[i]
+		client := getS3Client(acc)
+
+		// 文件哈希在上传完成前未知，模板中的 {sha256} 使用随机值
+		key, err := buildObjectKey(ctx, acc, opts.Naming, nil)
+		if err != nil {
+			if errors.Is(err, ErrKeyConflict) {
+				return nil, err
+			}
+			lastErr = err
+			log.Printf("生成账户 %s 的存储路径失败: %v，尝试下一个账户", acc.Name, err)
+			continue
+		}
+
+		// 空文件无需分片，直接写入
+		if opts.Size == 0 {
+			_, err := client.PutObject(ctx, &s3.PutObjectInput{
+				Bucket:             aws.String(acc.BucketName),
+				Key:                aws.String(key),
+				Body:               bytes.NewReader(nil),
+				ContentLength:      aws.Int64(0),
+				ContentType:        aws.String(opts.ContentType),
+				ContentDisposition: opts.Metadata.contentDisposition(),
+				Metadata:           opts.Metadata.toS3(),
+			})
+			if err != nil {
+				lastErr = fmt.Errorf("上传失败: %w", err)
+				log.Printf("上传到账户 %s 失败: %v，尝试下一个账户", acc.Name, err)
+				continue
+			}
+			sess.AccountID = acc.ID
+			sess.FileKey = key
+			sess.Completed = true
+			if err := CreateFileExpirationRecord(acc.ID, key, opts.Size, opts.ExpirationDays); err != nil {
+				log.Printf("[Resumable] 创建文件到期记录失败: %v", err)
+			}
+			PublishEvent(EventFileUploaded, FileEventData{AccountID: acc.ID, Key: key, Source: UploadSourceTus})
+			break
+		}
+
+		// 会话期间按未写入的大小保留预留，指定账户时不检查剩余空间
+		if _, err := reserveSpace(acc, sess.ID, opts.Size, UploadSourceTus, key, UploadSessionTTL, opts.AccountID != ""); err != nil {
+			lastErr = err
+			log.Printf("账户 %s 剩余空间不足: %v，尝试下一个账户", acc.Name, err)
+			continue
+		}
+
+		created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket:             aws.String(acc.BucketName),
+			Key:                aws.String(key),
+			ContentType:        aws.String(opts.ContentType),
+			ContentDisposition: opts.Metadata.contentDisposition(),
+			Metadata:           opts.Metadata.toS3(),
+		})
+		if err != nil {
+			releaseReservation(sess.ID)
+			lastErr = fmt.Errorf("创建分片上传失败: %w", err)
+			log.Printf("上传到账户 %s 失败: %v，尝试下一个账户", acc.Name, err)
+			continue
+		}
+		sess.AccountID = acc.ID
+		sess.FileKey = key
+		sess.UploadID = aws.ToString(created.UploadId)
+		break
+	}
+
+	if sess.AccountID == "" {
+		return nil, fmt.Errorf("所有账户上传均失败: %w", lastErr)
+	}
+
+	if err := store.CreateUploadSession(sess); err != nil {
+		releaseReservation(sess.ID)
+		if sess.UploadID != "" {
+			if acc, accErr := store.GetAccountByID(sess.AccountID); accErr == nil {
+				abortMultipartUpload(getS3Client(acc), acc.BucketName, sess.FileKey, sess.UploadID)
+			}
+		}
+		return nil, fmt.Errorf("保存上传会话失败: %w", err)
+	}
+
+	return sess, nil
+}
+
+// GetResumableUpload 获取上传会话，并根据本地暂存数据校正偏移量
+func GetResumableUpload(id string) (*store.UploadSession, error) {
+	sess, err := getActiveUploadSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sess.Completed {
+		lock := sessionLock(id)
+		if lock.TryLock() {
+			err = syncSessionOffset(sess)
+			lock.Unlock()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sess, nil
+}
+
+// WriteResumableUpload 从 offset 处继续写入上传数据
+// 数据按分片大小攒满后立即上传到 R2，不足一个分片的尾部数据暂存在本地，
+// 收到全部数据后完成分片上传并创建文件到期记录
+func WriteResumableUpload(ctx context.Context, id string, offset int64, body io.Reader) (*store.UploadSession, error) {
+	lock := sessionLock(id)
+	if !lock.TryLock() {
+		return nil, ErrUploadSessionLocked
+	}
+	defer lock.Unlock()
+
+	sess, err := getActiveUploadSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if sess.Completed {
+		if offset != sess.Offset {
+			return sess, ErrUploadOffsetMismatch
+		}
+		return sess, nil
+	}
+
+	if err := syncSessionOffset(sess); err != nil {
+		return nil, err
+	}
+	if offset != sess.Offset {
+		return sess, ErrUploadOffsetMismatch
+	}
+
+	acc, err := store.GetAccountByID(sess.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("账户不存在: %w", err)
+	}
+	client := getS3Client(acc)
+
+	bufPtr := partBufferPool.Get().(*[]byte)
+	defer partBufferPool.Put(bufPtr)
+	buf := *bufPtr
+	if int64(len(buf)) < sess.PartSize {
+		buf = make([]byte, sess.PartSize)
+	}
+	buf = buf[:sess.PartSize]
+
+	// 恢复上次暂存的尾部数据
+	n, err := readSessionTail(sess.ID, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	sess.ExpiresAt = time.Now().Add(UploadSessionTTL).UTC().Format(time.RFC3339)
+	reader := io.LimitReader(body, sess.Size-sess.Offset)
+
+	var readErr error
+	for {
+		m, err := io.ReadFull(reader, buf[n:])
+		n += m
+		sess.Offset += int64(m)
+
+		if err == nil {
+			// 缓冲区已满，上传一个完整分片
+			if uploadErr := uploadSessionPart(ctx, client, acc, sess, buf[:n]); uploadErr != nil {
+				return sess, saveSessionProgress(sess, buf[:n], uploadErr)
+			}
+			n = 0
+			if err := saveSessionProgress(sess, nil, nil); err != nil {
+				return sess, err
+			}
+			continue
+		}
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			readErr = fmt.Errorf("读取上传数据失败: %w", err)
+		}
+		break
+	}
+
+	if readErr != nil || sess.Offset < sess.Size {
+		// 数据未传完（或连接中断），暂存尾部数据等待下一次请求
+		return sess, saveSessionProgress(sess, buf[:n], readErr)
+	}
+
+	// 全部数据已接收，上传最后一个分片并完成上传
+	if n > 0 {
+		if err := uploadSessionPart(ctx, client, acc, sess, buf[:n]); err != nil {
+			return sess, saveSessionProgress(sess, buf[:n], err)
+		}
+	}
+	if err := completeUploadSession(ctx, client, acc, sess); err != nil {
+		return sess, saveSessionProgress(sess, nil, err)
+	}
+
+	return sess, nil
+}
+
+// TerminateResumableUpload 终止上传会话
+// 未完成的会话会中止分片上传并清理暂存数据，已完成的会话仅删除记录
+func TerminateResumableUpload(id string) error {
+	lock := sessionLock(id)
+	if !lock.TryLock() {
+		return ErrUploadSessionLocked
+	}
+	defer lock.Unlock()
+
+	sess, err := store.GetUploadSessionByID(id)
+	if err != nil {
+		return ErrUploadSessionNotFound
+	}
+
+	discardUploadSession(sess)
+	if err := store.DeleteUploadSession(id); err != nil {
+		return fmt.Errorf("删除上传会话失败: %w", err)
+	}
+	uploadSessionLocks.Delete(id)
+	return nil
+}
+
+// ResumableUploadResult 获取已完成会话的上传结果
+func ResumableUploadResult(ctx context.Context, sess *store.UploadSession) (*UploadResult, error) {
+	acc, err := store.GetAccountByID(sess.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("账户不存在: %w", err)
+	}
+
+	return newUploadResult(ctx, acc, sess.FileKey, sess.Size), nil
+}
+
+// CleanupExpiredUploadSessions 清理已过期的上传会话
+func CleanupExpiredUploadSessions(ctx context.Context) {
+	now := store.NowString()
+	count := 0
+
+	for _, sess := range store.GetUploadSessions() {
+		if ctx.Err() != nil {
+			return
+		}
+		if sess.ExpiresAt > now {
+			continue
+		}
+
+		lock := sessionLock(sess.ID)
+		if !lock.TryLock() {
+			continue
+		}
+		discardUploadSession(&sess)
+		if err := store.DeleteUploadSession(sess.ID); err != nil {
+			log.Printf("[Resumable] 删除过期上传会话 %s 失败: %v", sess.ID, err)
+		} else {
+			count++
+		}
+		lock.Unlock()
+		uploadSessionLocks.Delete(sess.ID)
+	}
+
+	if count > 0 {
+		log.Printf("[Resumable] 已清理 %d 个过期上传会话", count)
+	}
+}
+
+// activeUploadIDs 返回仍由上传会话或直传预留持有的分片上传ID，清理任务需要跳过它们
+func activeUploadIDs() map[string]bool {
+	ids := make(map[string]bool)
+	for _, sess := range store.GetUploadSessions() {
+		if !sess.Completed && sess.UploadID != "" {
+			ids[sess.UploadID] = true
+		}
+	}
+	for _, record := range store.GetPresignedUploads() {
+		if record.UploadID != "" {
+			ids[record.UploadID] = true
+		}
+	}
+	return ids
+}
+
+// getActiveUploadSession 获取未过期的上传会话
+func getActiveUploadSession(id string) (*store.UploadSession, error) {
+	sess, err := store.GetUploadSessionByID(id)
+	if err != nil {
+		return nil, ErrUploadSessionNotFound
+	}
+	if sess.ExpiresAt <= store.NowString() {
+		return nil, ErrUploadSessionNotFound
+	}
+	return sess, nil
+}
+
+// syncSessionOffset 以已上传分片和本地暂存数据为准校正偏移量
+// 进程在写入暂存数据后、保存会话前退出时，两者可能不一致
+func syncSessionOffset(sess *store.UploadSession) error {
+	committed := sessionUploadedBytes(sess)
+
+	var tail int64
+	info, err := os.Stat(sessionTailPath(sess.ID))
+	if err == nil {
+		tail = info.Size()
+		if tail > sess.PartSize {
+			// 暂存数据损坏，丢弃后由客户端重新发送
+			os.Remove(sessionTailPath(sess.ID))
+			tail = 0
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("读取暂存数据失败: %w", err)
+	}
+
+	if committed+tail != sess.Offset {
+		sess.Offset = committed + tail
+		if err := store.UpdateUploadSession(sess); err != nil {
+			return fmt.Errorf("保存上传会话失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// readSessionTail 将暂存的尾部数据读入 buf，返回读取的字节数
+func readSessionTail(id string, buf []byte) (int, error) {
+	f, err := os.Open(sessionTailPath(id))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("读取暂存数据失败: %w", err)
+	}
+	defer f.Close()
+
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, fmt.Errorf("读取暂存数据失败: %w", err)
+	}
+	return n, nil
+}
+
+// writeSessionTail 原子地写入暂存的尾部数据，数据为空时删除暂存文件
+func writeSessionTail(id string, tail []byte) error {
+	path := sessionTailPath(id)
+	if len(tail) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除暂存数据失败: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建暂存目录失败: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, tail, 0644); err != nil {
+		return fmt.Errorf("写入暂存数据失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入暂存数据失败: %w", err)
+	}
+	return nil
+}
+
+// saveSessionProgress 保存暂存数据和会话进度，返回 cause 或保存过程中的错误
+func saveSessionProgress(sess *store.UploadSession, tail []byte, cause error) error {
+	if err := writeSessionTail(sess.ID, tail); err != nil {
+		// 暂存失败时回退偏移量，客户端需要重新发送这部分数据
+		sess.Offset -= int64(len(tail))
+		if cause == nil {
+			cause = err
+		}
+	}
+	if err := store.UpdateUploadSession(sess); err != nil && cause == nil {
+		cause = fmt.Errorf("保存上传会话失败: %w", err)
+	}
+	// 已上传的分片计入用量，预留只保留尚未写入的部分
+	store.UpdateReservation(sess.ID, sess.Size-sessionUploadedBytes(sess), UploadSessionTTL)
+	return cause
+}
+
+// sessionUploadedBytes 会话已上传到 R2 的分片大小合计（不含本地暂存数据）
+func sessionUploadedBytes(sess *store.UploadSession) int64 {
+	var uploaded int64
+	for _, part := range sess.Parts {
+		uploaded += part.Size
+	}
+	return uploaded
+}
+
+// uploadSessionPart 上传会话的下一个分片
+func uploadSessionPart(ctx context.Context, client *s3.Client, acc *store.Account, sess *store.UploadSession, data []byte) error {
+	partNumber := int32(len(sess.Parts) + 1)
+	if partNumber > MaxUploadParts {
+		return fmt.Errorf("文件过大，超过 %d 个分片", MaxUploadParts)
+	}
+
+	out, err := client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(acc.BucketName),
+		Key:           aws.String(sess.FileKey),
+		UploadId:      aws.String(sess.UploadID),
+		PartNumber:    aws.Int32(partNumber),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	})
+	if err != nil {
+		return fmt.Errorf("上传分片 %d 失败: %w", partNumber, err)
+	}
+
+	sess.Parts = append(sess.Parts, store.UploadPart{
+		PartNumber: partNumber,
+		ETag:       aws.ToString(out.ETag),
+		Size:       int64(len(data)),
+	})
+	return nil
+}
+
+// completeUploadSession 完成分片上传并创建文件到期记录
+func completeUploadSession(ctx context.Context, client *s3.Client, acc *store.Account, sess *store.UploadSession) error {
+	parts := make([]types.CompletedPart, len(sess.Parts))
+	for i, part := range sess.Parts {
+		parts[i] = types.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int32(part.PartNumber),
+		}
+	}
+
+	_, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(acc.BucketName),
+		Key:             aws.String(sess.FileKey),
+		UploadId:        aws.String(sess.UploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return fmt.Errorf("完成分片上传失败: %w", err)
+	}
+
+	sess.Completed = true
+	releaseReservation(sess.ID)
+	if err := writeSessionTail(sess.ID, nil); err != nil {
+		log.Printf("[Resumable] %v", err)
+	}
+	if err := CreateFileExpirationRecord(acc.ID, sess.FileKey, sess.Size, sess.ExpirationDays); err != nil {
+		// 到期记录创建失败不影响上传结果，仅记录日志
+		log.Printf("[Resumable] 创建文件到期记录失败: %v", err)
+	}
+
+	if err := store.UpdateUploadSession(sess); err != nil {
+		return fmt.Errorf("保存上传会话失败: %w", err)
+	}
+	PublishEvent(EventFileUploaded, FileEventData{AccountID: acc.ID, Key: sess.FileKey, Size: sess.Size, Source: UploadSourceTus})
+	return nil
+}
+
+// discardUploadSession 中止未完成会话的分片上传，释放容量预留并删除暂存数据
+func discardUploadSession(sess *store.UploadSession) {
+	releaseReservation(sess.ID)
+	if !sess.Completed && sess.UploadID != "" {
+		if acc, err := store.GetAccountByID(sess.AccountID); err == nil {
+			abortMultipartUpload(getS3Client(acc), acc.BucketName, sess.FileKey, sess.UploadID)
+		}
+	}
+	if err := writeSessionTail(sess.ID, nil); err != nil {
+		log.Printf("[Resumable] %v", err)
+	}
+}
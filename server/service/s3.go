@@ -27,12 +27,15 @@ type FileInfo struct {
 
 // FileNode 文件树节点
 type FileNode struct {
-	Key          string      `json:"key"`
-	Name         string      `json:"name"`
-	Size         int64       `json:"size,omitempty"`
-	LastModified *time.Time  `json:"lastModified,omitempty"`
-	IsDir        bool        `json:"isDir"`
-	Children     []*FileNode `json:"children,omitempty"`
+	Key          string            `json:"key"`
+	Name         string            `json:"name"`
+	Size         int64             `json:"size,omitempty"`
+	LastModified *time.Time        `json:"lastModified,omitempty"`
+	IsDir        bool              `json:"isDir"`
+	Children     []*FileNode       `json:"children,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"` // 自定义元数据
+	Tags         []string          `json:"tags,omitempty"`     // 标签
+	Striped      bool              `json:"striped,omitempty"`  // 跨账户分块存储的文件
 }
 
 // TreeNode 构建文件树时的辅助结构
@@ -59,17 +62,26 @@ type ListFilesResult struct {
 
 // UploadResult 上传结果
 type UploadResult struct {
-	ID          string `json:"id"`
-	AccountName string `json:"accountName"`
-	Key         string `json:"key"`
-	Size        int64  `json:"size"`
-	URL         string `json:"url"`
+	ID            string            `json:"id"`
+	AccountName   string            `json:"accountName"`
+	Key           string            `json:"key"`
+	Size          int64             `json:"size"`
+	URL           string            `json:"url"`
+	LinkMode      string            `json:"linkMode"`                // 链接类型：public 或 private
+	LinkExpiresAt string            `json:"linkExpiresAt,omitempty"` // 预签名链接的过期时间
+	SHA256        string            `json:"sha256,omitempty"`        // 文件内容的 SHA-256
+	CRC32C        string            `json:"crc32c,omitempty"`        // 文件内容的 CRC32C
+	Deduplicated  bool              `json:"deduplicated,omitempty"`  // 内容已存在，返回的是已有对象
+	Thumbnails    []Thumbnail       `json:"thumbnails,omitempty"`    // 图片缩略图（启用缩略图时）
+	Metadata      map[string]string `json:"metadata,omitempty"`      // 自定义元数据
+	Tags          []string          `json:"tags,omitempty"`          // 标签
+	Replicas      []string          `json:"replicas,omitempty"`      // 持有副本的账户ID（启用多副本时）
+	Chunks        int               `json:"chunks,omitempty"`        // 分块数量（跨账户分块存储时）
 }
 
 // getS3Client 获取账户的 S3 客户端
 func getS3Client(acc *store.Account) *s3.Client {
 	cfg := aws.Config{
-		Region: "auto",
 		Credentials: credentials.NewStaticCredentialsProvider(
 			acc.AccessKeyId,
 			acc.SecretAccessKey,
@@ -77,148 +89,209 @@ func getS3Client(acc *store.Account) *s3.Client {
 		),
 	}
 
-	return s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(acc.Endpoint)
-	})
+	return s3.NewFromConfig(cfg, s3ClientOptions(acc), WithUsageTracking(acc.ID), WithHealthTracking(acc.ID))
+}
+
+// NewS3Client 创建账户的 S3 客户端（按服务商配置，启用本地用量计数和健康统计），供 WebDAV 使用
+func NewS3Client(acc *store.Account) *s3.Client {
+	return getS3Client(acc)
 }
 
 // SmartUpload 智能上传文件（自动选择可用账户，失败自动重试其他账户）
-// 使用具有 auto_upload 权限的账户
-func SmartUpload(ctx context.Context, key string, body io.Reader, size int64, contentType string) (*UploadResult, error) {
-	accounts := store.GetAvailableAccountsForAutoUpload()
-	if len(accounts) == 0 {
-		return nil, fmt.Errorf("没有可用的存储账户（需要 auto_upload 权限）")
+// 使用具有 auto_upload 权限的账户（指定账户池时为池成员），按放置策略决定尝试顺序
+func SmartUpload(ctx context.Context, naming KeyNaming, meta ObjectMetadata, body io.Reader, size int64, contentType string) (*UploadResult, error) {
+	accounts, err := autoUploadAccounts(naming, false)
+	if err != nil {
+		return nil, err
 	}
-
-	// 按使用率排序，优先使用使用率低的账户
-	sort.Slice(accounts, func(i, j int) bool {
-		return accounts[i].GetUsagePercent() < accounts[j].GetUsagePercent()
-	})
-
-	// 需要将 body 读取到内存，以便重试
-	bodyBytes, err := io.ReadAll(body)
+	// 先移除图片元数据，分块上传同样使用处理后的数据
+	body, size, meta, err = stripImageMetadataForUpload(accounts, naming, meta, body, size)
 	if err != nil {
-		return nil, fmt.Errorf("读取文件内容失败: %w", err)
+		return nil, err
 	}
+	accounts = placeAccounts(accounts, naming, size)
 
-	var lastErr error
-	for _, acc := range accounts {
-		result, err := doUpload(ctx, &acc, key, bodyBytes, contentType)
-		if err == nil {
-			return result, nil
-		}
-		lastErr = err
-		log.Printf("上传到账户 %s 失败: %v，尝试下一个账户", acc.Name, err)
+	// 文件超过任一账户的剩余空间时拆分到多个账户
+	if shouldStripe(accounts, size, meta) {
+		return stripedUpload(ctx, accounts, naming, meta, body, size, contentType)
 	}
-
-	return nil, fmt.Errorf("所有账户上传均失败: %w", lastErr)
+	return uploadWithFallback(ctx, "", accounts, naming, meta, body, size, contentType)
 }
 
 // UploadToAccount 上传文件到指定账户
 // 检查账户是否具有 api_upload 权限
-func UploadToAccount(ctx context.Context, accountID string, key string, body io.Reader, contentType string) (*UploadResult, error) {
-	acc, err := store.GetAccountByID(accountID)
+func UploadToAccount(ctx context.Context, accountID string, naming KeyNaming, meta ObjectMetadata, body io.Reader, size int64, contentType string) (*UploadResult, error) {
+	accounts, err := apiUploadAccounts(accountID)
 	if err != nil {
-		return nil, fmt.Errorf("账户不存在: %w", err)
+		return nil, err
 	}
 
-	if !acc.IsActive {
-		return nil, fmt.Errorf("账户已停用")
-	}
+	return uploadWithFallback(ctx, accountID, accounts, naming, meta, body, size, contentType)
+}
 
-	if !acc.CanAPIUpload() {
-		return nil, fmt.Errorf("账户没有 API 上传权限")
-	}
+// apiUploadAccounts 获取 API 上传的候选账户
+// 指定账户时检查 api_upload 权限，否则返回所有可自动上传的账户（按使用率升序）
+func apiUploadAccounts(accountID string) ([]store.Account, error) {
+	if accountID != "" {
+		acc, err := store.GetAccountByID(accountID)
+		if err != nil {
+			return nil, fmt.Errorf("账户不存在: %w", err)
+		}
 
-	bodyBytes, err := io.ReadAll(body)
-	if err != nil {
-		return nil, fmt.Errorf("读取文件内容失败: %w", err)
-	}
+		if !acc.IsActive {
+			return nil, fmt.Errorf("账户已停用")
+		}
 
-	return doUpload(ctx, acc, key, bodyBytes, contentType)
-}
+		if !acc.CanAPIUpload() {
+			return nil, fmt.Errorf("账户没有 API 上传权限")
+		}
 
-// SmartUploadForClient 前端智能上传文件（自动选择可用账户，失败自动重试其他账户）
-// 使用具有 client_upload 和 auto_upload 权限的账户
-func SmartUploadForClient(ctx context.Context, key string, body io.Reader, size int64, contentType string) (*UploadResult, error) {
-	accounts := store.GetAvailableAccountsForClientUpload()
+		return []store.Account{*acc}, nil
+	}
+
+	accounts := store.GetAvailableAccountsForAutoUpload()
 	if len(accounts) == 0 {
-		return nil, fmt.Errorf("没有可用的存储账户（需要 client_upload 权限）")
+		return nil, fmt.Errorf("没有可用的存储账户（需要 auto_upload 权限）")
 	}
 
 	// 按使用率排序，优先使用使用率低的账户
 	sort.Slice(accounts, func(i, j int) bool {
-		return accounts[i].GetUsagePercent() < accounts[j].GetUsagePercent()
+		return accounts[i].GetCommittedPercent() < accounts[j].GetCommittedPercent()
 	})
 
-	// 需要将 body 读取到内存，以便重试
-	bodyBytes, err := io.ReadAll(body)
+	return accounts, nil
+}
+
+// SmartUploadForClient 前端智能上传文件（自动选择可用账户，失败自动重试其他账户）
+// 使用具有 client_upload 和 auto_upload 权限的账户（指定账户池时为池成员），按放置策略决定尝试顺序
+func SmartUploadForClient(ctx context.Context, naming KeyNaming, meta ObjectMetadata, body io.Reader, size int64, contentType string) (*UploadResult, error) {
+	accounts, err := autoUploadAccounts(naming, true)
 	if err != nil {
-		return nil, fmt.Errorf("读取文件内容失败: %w", err)
+		return nil, err
 	}
-
-	var lastErr error
-	for _, acc := range accounts {
-		result, err := doUpload(ctx, &acc, key, bodyBytes, contentType)
-		if err == nil {
-			return result, nil
-		}
-		lastErr = err
-		log.Printf("上传到账户 %s 失败: %v，尝试下一个账户", acc.Name, err)
+	// 先移除图片元数据，分块上传同样使用处理后的数据
+	body, size, meta, err = stripImageMetadataForUpload(accounts, naming, meta, body, size)
+	if err != nil {
+		return nil, err
 	}
+	accounts = placeAccounts(accounts, naming, size)
 
-	return nil, fmt.Errorf("所有账户上传均失败: %w", lastErr)
+	// 文件超过任一账户的剩余空间时拆分到多个账户
+	if shouldStripe(accounts, size, meta) {
+		return stripedUpload(ctx, accounts, naming, meta, body, size, contentType)
+	}
+	return uploadWithFallback(ctx, "", accounts, naming, meta, body, size, contentType)
 }
 
 // UploadToAccountForClient 前端上传文件到指定账户
 // 检查账户是否具有 client_upload 权限
-func UploadToAccountForClient(ctx context.Context, accountID string, key string, body io.Reader, contentType string) (*UploadResult, error) {
-	acc, err := store.GetAccountByID(accountID)
+func UploadToAccountForClient(ctx context.Context, accountID string, naming KeyNaming, meta ObjectMetadata, body io.Reader, size int64, contentType string) (*UploadResult, error) {
+	accounts, err := clientUploadAccounts(accountID)
 	if err != nil {
-		return nil, fmt.Errorf("账户不存在: %w", err)
+		return nil, err
 	}
 
-	if !acc.IsActive {
-		return nil, fmt.Errorf("账户已停用")
+	return uploadWithFallback(ctx, accountID, accounts, naming, meta, body, size, contentType)
+}
+
+// clientUploadAccounts 获取前端上传的候选账户
+// 指定账户时检查 client_upload 权限，否则返回所有可用账户（按使用率升序）
+func clientUploadAccounts(accountID string) ([]store.Account, error) {
+	if accountID != "" {
+		acc, err := store.GetAccountByID(accountID)
+		if err != nil {
+			return nil, fmt.Errorf("账户不存在: %w", err)
+		}
+
+		if !acc.IsActive {
+			return nil, fmt.Errorf("账户已停用")
+		}
+
+		if !acc.CanClientUpload() {
+			return nil, fmt.Errorf("账户没有前端上传权限")
+		}
+
+		return []store.Account{*acc}, nil
+	}
+
+	accounts := store.GetAvailableAccountsForClientUpload()
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("没有可用的存储账户（需要 client_upload 权限）")
 	}
 
-	if !acc.CanClientUpload() {
-		return nil, fmt.Errorf("账户没有前端上传权限")
+	// 按使用率排序，优先使用使用率低的账户
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].GetCommittedPercent() < accounts[j].GetCommittedPercent()
+	})
+
+	return accounts, nil
+}
+
+// StoreObject 按指定路径写入账户（WebDAV 等由客户端决定路径的场景）
+// 不使用路径模板、内容去重和账户切换，数据只读取一遍；校验值在写入时计算，超过一个分片的文件不写入元数据
+func StoreObject(ctx context.Context, acc *store.Account, key string, meta ObjectMetadata, body io.Reader, size int64, contentType string) (*UploadResult, error) {
+	if shouldStripImageMetadata(meta.KeepImageMetadata, "", []store.Account{*acc}) {
+		var err error
+		if body, size, meta, err = stripUploadImageMetadata(body, size, meta); err != nil {
+			return nil, err
+		}
 	}
 
-	bodyBytes, err := io.ReadAll(body)
+	src, err := newUploadSource(body, size)
 	if err != nil {
-		return nil, fmt.Errorf("读取文件内容失败: %w", err)
+		return nil, err
 	}
+	defer src.close()
+	src.meta = meta
 
-	return doUpload(ctx, acc, key, bodyBytes, contentType)
+	// 路径由客户端指定，只记录预留供放置策略参考，不检查剩余空间
+	reservationID, err := reserveUpload(acc, size, meta.Source, key, true)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseReservation(reservationID)
+	return doUpload(ctx, acc, key, src, contentType)
 }
 
 // doUpload 上传文件到指定账户（内部函数）
-func doUpload(ctx context.Context, acc *store.Account, key string, body []byte, contentType string) (*UploadResult, error) {
-	client := getS3Client(acc)
-
-	input := &s3.PutObjectInput{
-		Bucket:      aws.String(acc.BucketName),
-		Key:         aws.String(key),
-		Body:        strings.NewReader(string(body)),
-		ContentType: aws.String(contentType),
+func doUpload(ctx context.Context, acc *store.Account, key string, src *uploadSource, contentType string) (*UploadResult, error) {
+	obj, err := streamUpload(ctx, acc, key, src, contentType)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err := client.PutObject(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("上传失败: %w", err)
+	sums := src.checksums()
+	if obj.Duplicate != nil {
+		result, err := duplicateUploadResult(ctx, obj.Duplicate)
+		if err != nil {
+			return nil, err
+		}
+		result.CRC32C = sums.CRC32C
+		if src.thumbnails != nil {
+			if dupAcc, err := store.GetAccountByID(result.ID); err == nil {
+				result.Thumbnails = listThumbnails(ctx, dupAcc, result.Key)
+			}
+		}
+		recordCustomMetadata(result, src.meta)
+		publishUploadEvent(result, src.meta.Source)
+		return result, nil
 	}
 
-	url := buildPublicURL(acc.PublicDomain, key)
+	// 加密账户中的对象不参与去重，也不生成明文缩略图
+	thumbnails := src.thumbnails
+	if acc.Encryption {
+		thumbnails = nil
+	} else if src.dedup {
+		registerFileObject(acc, key, sums.SHA256, obj)
+	}
 
-	return &UploadResult{
-		ID:          acc.ID,
-		AccountName: acc.Name,
-		Key:         key,
-		Size:        int64(len(body)),
-		URL:         url,
-	}, nil
+	result := newUploadResult(ctx, acc, key, obj.Size)
+	result.SHA256 = sums.SHA256
+	result.CRC32C = sums.CRC32C
+	result.Thumbnails = generateThumbnails(ctx, acc, key, src, obj.Size, thumbnails)
+	recordCustomMetadata(result, src.meta)
+	publishUploadEvent(result, src.meta.Source)
+	return result, nil
 }
 
 // ListFiles 列出账户指定前缀下的文件（懒加载+分页）
@@ -251,7 +324,8 @@ func ListFiles(ctx context.Context, acc *store.Account, prefix string, cursor st
 	for _, cp := range output.CommonPrefixes {
 		dirKey := aws.ToString(cp.Prefix)
 		name := strings.TrimSuffix(strings.TrimPrefix(dirKey, prefix), "/")
-		if name != "" {
+		// 分块对象目录不对外展示
+		if name != "" && !IsStripeChunkKey(dirKey) {
 			files = append(files, &FileNode{
 				Key:   dirKey,
 				Name:  name,
@@ -260,7 +334,8 @@ func ListFiles(ctx context.Context, acc *store.Account, prefix string, cursor st
 		}
 	}
 
-	// 添加文件（Contents）
+	// 添加文件（Contents），附带存储中记录的自定义元数据和标签
+	custom := store.GetFileMetadataByAccount(acc.ID)
 	for _, obj := range output.Contents {
 		key := aws.ToString(obj.Key)
 		// 跳过目录本身
@@ -269,15 +344,23 @@ func ListFiles(ctx context.Context, acc *store.Account, prefix string, cursor st
 		}
 		name := strings.TrimPrefix(key, prefix)
 		lastMod := aws.ToTime(obj.LastModified)
+		fm := custom[key]
 		files = append(files, &FileNode{
 			Key:          key,
 			Name:         name,
-			Size:         aws.ToInt64(obj.Size),
+			Size:         ListedObjectSize(acc, aws.ToInt64(obj.Size)),
 			LastModified: &lastMod,
 			IsDir:        false,
+			Metadata:     fm.Metadata,
+			Tags:         fm.Tags,
 		})
 	}
 
+	// 分块文件只存在于清单中，在第一页补充
+	if cursor == "" {
+		files = appendStripedNodes(files, acc.ID, prefix, custom)
+	}
+
 	// 排序：目录优先，然后按名称
 	sort.Slice(files, func(i, j int) bool {
 		if files[i].IsDir != files[j].IsDir {
@@ -297,12 +380,16 @@ func ListFiles(ctx context.Context, acc *store.Account, prefix string, cursor st
 	return result, nil
 }
 
-// ListAllAccountsFiles 列出所有激活账户的文件（懒加载+分页）
+// ListAllAccountsFiles 列出所有激活账户的文件（懒加载+分页），跳过熔断中的账户
 func ListAllAccountsFiles(ctx context.Context, prefix string, cursor string, limit int32) ([]AccountFiles, error) {
 	accounts := store.GetActiveAccounts()
 	var result []AccountFiles
 
 	for _, acc := range accounts {
+		if IsCircuitOpen(acc.ID) {
+			continue
+		}
+
 		listResult, err := ListFiles(ctx, &acc, prefix, cursor, limit)
 		if err != nil {
 			log.Printf("列出账户 %s 文件失败: %v", acc.Name, err)
@@ -322,7 +409,7 @@ func ListAllAccountsFiles(ctx context.Context, prefix string, cursor string, lim
 	return result, nil
 }
 
-// ListAccountsFilesByIDs 列出指定账户组的文件（懒加载+分页）
+// ListAccountsFilesByIDs 列出指定账户组的文件（懒加载+分页），跳过熔断中的账户
 func ListAccountsFilesByIDs(ctx context.Context, ids []string, prefix string, cursor string, limit int32) ([]AccountFiles, error) {
 	var result []AccountFiles
 
@@ -333,7 +420,7 @@ func ListAccountsFilesByIDs(ctx context.Context, ids []string, prefix string, cu
 			continue
 		}
 
-		if !acc.IsActive {
+		if !acc.IsActive || IsCircuitOpen(acc.ID) {
 			continue
 		}
 
@@ -357,7 +444,13 @@ func ListAccountsFilesByIDs(ctx context.Context, ids []string, prefix string, cu
 }
 
 // DeleteFile 删除指定账户的文件或目录
+// 直接删除物理对象，不考虑引用计数；按引用释放请使用 ReleaseFile
 func DeleteFile(ctx context.Context, accountID, key string) error {
+	return deleteFile(ctx, accountID, key, 0)
+}
+
+// deleteFile 删除文件或目录，size 为调用方已知的文件大小，0 表示未知
+func deleteFile(ctx context.Context, accountID, key string, size int64) error {
 	// 特殊处理：ImgBB 文件
 	if accountID == "imgbb" {
 		// ImgBB 文件的 key 就是 deleteUrl
@@ -373,10 +466,24 @@ func DeleteFile(ctx context.Context, accountID, key string) error {
 
 	// 检查是否为目录（以 / 结尾）
 	if strings.HasSuffix(key, "/") {
-		return deleteDirectory(ctx, client, acc.BucketName, key)
+		if err := deleteDirectory(ctx, client, acc, key); err != nil {
+			return err
+		}
+		store.DeleteFileMetadataByPrefix(acc.ID, key)
+		DeleteReplicas(ctx, acc.ID, key)
+		DeleteStripedFiles(ctx, acc.ID, key)
+		return store.DeleteFileObjectsByPrefix(acc.ID, key)
 	}
 
-	// 删除单个文件
+	// 分块文件没有同名的物理对象，删除分块和清单即可
+	if DeleteStripedFiles(ctx, acc.ID, key) {
+		return nil
+	}
+
+	// 删除单个文件，删除前获取大小用于更新容量计数
+	if size <= 0 {
+		size = knownObjectSize(ctx, client, acc, key)
+	}
 	input := &s3.DeleteObjectInput{
 		Bucket: aws.String(acc.BucketName),
 		Key:    aws.String(key),
@@ -386,12 +493,22 @@ func DeleteFile(ctx context.Context, accountID, key string) error {
 	if err != nil {
 		return fmt.Errorf("删除文件失败: %w", err)
 	}
+	RecordStoredBytes(acc.ID, -size)
+	deleteThumbnails(ctx, client, acc, key)
+
+	// 物理对象已删除，移除对应的内容索引和自定义元数据
+	if obj, err := store.GetFileObjectByKey(acc.ID, key); err == nil {
+		store.DeleteFileObject(obj.ID)
+	}
+	store.DeleteFileMetadata(acc.ID, key)
+	DeleteReplicas(ctx, acc.ID, key)
 
 	return nil
 }
 
-// deleteDirectory 递归删除目录下所有文件
-func deleteDirectory(ctx context.Context, client *s3.Client, bucket, prefix string) error {
+// deleteDirectory 递归删除目录下所有文件，并从账户的容量计数中扣除
+func deleteDirectory(ctx context.Context, client *s3.Client, acc *store.Account, prefix string) error {
+	bucket := acc.BucketName
 	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(bucket),
 		Prefix: aws.String(prefix),
@@ -411,10 +528,12 @@ func deleteDirectory(ctx context.Context, client *s3.Client, bucket, prefix stri
 
 		// 构建删除列表（每次最多 1000 个）
 		var objects []types.ObjectIdentifier
+		var size int64
 		for _, obj := range page.Contents {
 			objects = append(objects, types.ObjectIdentifier{
 				Key: obj.Key,
 			})
+			size += aws.ToInt64(obj.Size)
 		}
 
 		// 批量删除
@@ -430,6 +549,7 @@ func deleteDirectory(ctx context.Context, client *s3.Client, bucket, prefix stri
 		if err != nil {
 			return fmt.Errorf("删除目录文件失败: %w", err)
 		}
+		RecordStoredBytes(acc.ID, -size)
 
 		log.Printf("已删除目录 %s 下 %d 个文件", prefix, len(objects))
 	}
@@ -437,47 +557,38 @@ func deleteDirectory(ctx context.Context, client *s3.Client, bucket, prefix stri
 	return nil
 }
 
-// GetFileLink 获取文件直链
-func GetFileLink(accountID, key string) (string, error) {
-	// 特殊处理：ImgBB 文件
-	if accountID == "imgbb" {
-		// ImgBB 文件的 key 就是直接访问 URL
-		return key, nil
-	}
-
-	acc, err := store.GetAccountByID(accountID)
-	if err != nil {
-		return "", err
-	}
-
-	return buildPublicURL(acc.PublicDomain, key), nil
-}
-
-// buildPublicURL 构建公开访问 URL，处理 publicDomain 可能包含协议前缀的情况
-func buildPublicURL(publicDomain, key string) string {
-	// 去除 publicDomain 中的协议前缀（包括畸形格式）
+// publicDomainHost 去除 publicDomain 中的协议前缀（包括畸形格式）和尾部斜杠
+func publicDomainHost(publicDomain string) string {
 	domain := strings.TrimPrefix(publicDomain, "https://")
 	domain = strings.TrimPrefix(domain, "http://")
 	domain = strings.TrimPrefix(domain, "https//") // 处理缺少冒号的情况
 	domain = strings.TrimPrefix(domain, "http//")
 
 	// 去除可能的尾部斜杠
-	domain = strings.TrimSuffix(domain, "/")
+	return strings.TrimSuffix(domain, "/")
+}
+
+// publicDomainSubdomain 提取代理路径使用的子域名（如 pub-xxx.r2.dev -> pub-xxx）
+func publicDomainSubdomain(domain string) string {
+	if idx := strings.Index(domain, "."); idx > 0 {
+		return domain[:idx]
+	}
+	return domain
+}
+
+// buildPublicURL 构建公开访问 URL，处理 publicDomain 可能包含协议前缀的情况
+// 端点代理只转发 r2.dev 域名，只有 R2 账户的链接改写为代理地址
+func buildPublicURL(acc *store.Account, key string) string {
+	domain := publicDomainHost(acc.PublicDomain)
 
 	// 去除 key 可能的开头斜杠
 	key = strings.TrimPrefix(key, "/")
 
 	// 检查是否启用代理
 	settings := store.GetSettings()
-	if settings.EndpointProxy && settings.EndpointProxyURL != "" {
-		// 提取子域名（如 pub-xxx.r2.dev -> pub-xxx）
-		subdomain := domain
-		if idx := strings.Index(domain, "."); idx > 0 {
-			subdomain = domain[:idx]
-		}
-
+	if settings.EndpointProxy && settings.EndpointProxyURL != "" && acc.IsR2() {
 		proxyURL := strings.TrimSuffix(settings.EndpointProxyURL, "/")
-		return fmt.Sprintf("%s/%s/%s", proxyURL, subdomain, key)
+		return fmt.Sprintf("%s/%s/%s", proxyURL, publicDomainSubdomain(domain), key)
 	}
 
 	return fmt.Sprintf("https://%s/%s", domain, key)
@@ -493,7 +604,15 @@ func ClearBucket(ctx context.Context, accountID string) error {
 	client := getS3Client(acc)
 
 	// 复用 deleteDirectory，传入空前缀删除所有内容
-	return deleteDirectory(ctx, client, acc.BucketName, "")
+	if err := deleteDirectory(ctx, client, acc, ""); err != nil {
+		return err
+	}
+	store.DeleteFileMetadataByPrefix(acc.ID, "")
+	// 分块文件缺少任一分块都无法读取，其他账户中的分块一并删除
+	deleteAccountStripedFiles(ctx, acc.ID)
+	// 其他账户中的副本保留，由修复任务补齐副本数
+	store.RemoveReplicaAccount(acc.ID, "")
+	return store.DeleteFileObjectsByPrefix(acc.ID, "")
 }
 
 // DeleteOldFilesResult 删除旧文件结果
@@ -537,11 +656,17 @@ func DeleteOldFiles(ctx context.Context, accountID string, before time.Time) (*D
 
 		// 筛选早于指定时间的文件
 		var objects []types.ObjectIdentifier
+		var size int64
 		for _, obj := range page.Contents {
+			// 分块对象随分块文件一起删除，不单独清理
+			if IsStripeChunkKey(aws.ToString(obj.Key)) {
+				continue
+			}
 			if obj.LastModified != nil && obj.LastModified.Before(before) {
 				objects = append(objects, types.ObjectIdentifier{
 					Key: obj.Key,
 				})
+				size += aws.ToInt64(obj.Size)
 			}
 		}
 
@@ -569,7 +694,11 @@ func DeleteOldFiles(ctx context.Context, accountID string, before time.Time) (*D
 		}
 
 		totalDeleted += len(objects)
+		RecordStoredBytes(acc.ID, -size)
 		log.Printf("账户 %s: 已删除 %d 个旧文件", acc.Name, len(objects))
+		for _, obj := range objects {
+			store.RemoveReplicaAccount(acc.ID, aws.ToString(obj.Key))
+		}
 	}
 
 	return &DeleteOldFilesResult{
@@ -54,6 +54,30 @@ func StartScheduler() {
 		log.Printf("[Scheduler] 添加过期检查任务失败: %v", err)
 	}
 
+	// 未完成分片上传清理任务（与过期检查同频，避免额外的 Class A 操作）
+	_, err = scheduler.AddFunc(expCronExpr, func() {
+		log.Println("[Scheduler] 开始清理未完成的分片上传")
+		CleanupExpiredUploadSessions(context.Background())
+		CleanupExpiredPresignedUploads(context.Background())
+		CleanupStaleMultipartUploads(context.Background())
+		CleanupFinishedImportJobs()
+		CleanupWebhookDeliveries()
+		CleanupExpiredReservations()
+	})
+	if err != nil {
+		log.Printf("[Scheduler] 添加分片上传清理任务失败: %v", err)
+	}
+
+	// 副本修复任务
+	_, err = scheduler.AddFunc(replicaRepairSchedule, func() {
+		if _, err := RepairReplicas(context.Background(), false); err != nil {
+			log.Printf("[Scheduler] 跳过副本修复: %v", err)
+		}
+	})
+	if err != nil {
+		log.Printf("[Scheduler] 添加副本修复任务失败: %v", err)
+	}
+
 	scheduler.Start()
 	log.Printf("[Scheduler] 定时任务调度器已启动 (同步间隔: %d 分钟, 过期检查间隔: %d 分钟)", syncInterval, expCheckInterval)
 }
@@ -115,6 +139,32 @@ func ReloadScheduler() {
 		return
 	}
 
+	// 未完成分片上传清理任务
+	_, err = scheduler.AddFunc(expCronExpr, func() {
+		log.Println("[Scheduler] 开始清理未完成的分片上传")
+		CleanupExpiredUploadSessions(context.Background())
+		CleanupExpiredPresignedUploads(context.Background())
+		CleanupStaleMultipartUploads(context.Background())
+		CleanupFinishedImportJobs()
+		CleanupWebhookDeliveries()
+		CleanupExpiredReservations()
+	})
+	if err != nil {
+		log.Printf("[Scheduler] 添加分片上传清理任务失败: %v", err)
+		return
+	}
+
+	// 副本修复任务
+	_, err = scheduler.AddFunc(replicaRepairSchedule, func() {
+		if _, err := RepairReplicas(context.Background(), false); err != nil {
+			log.Printf("[Scheduler] 跳过副本修复: %v", err)
+		}
+	})
+	if err != nil {
+		log.Printf("[Scheduler] 添加副本修复任务失败: %v", err)
+		return
+	}
+
 	scheduler.Start()
 	log.Printf("[Scheduler] 定时任务调度器已重载 (同步间隔: %d 分钟, 过期检查间隔: %d 分钟)", syncInterval, expCheckInterval)
 }
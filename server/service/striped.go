@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+)
+
+const (
+	// StripeChunkSize 分块文件的最大分块大小（1 GiB）
+	StripeChunkSize int64 = 1 << 30
+	// MinStripeChunkSize 最小分块大小（最后一个分块除外），剩余空间更少的账户不再放置分块
+	MinStripeChunkSize int64 = 64 << 20
+	// StripeChunkPrefix 分块对象的路径前缀，文件列表、GC 和旧文件清理会跳过该目录
+	StripeChunkPrefix = ".stripes/"
+	// maxStripeChunks 单个文件的最大分块数量
+	maxStripeChunks = 10000
+)
+
+// ErrInsufficientSpace 所有账户的剩余空间合计仍不足以存放文件
+var ErrInsufficientSpace = errors.New("所有账户的剩余空间不足")
+
+// shouldStripe 是否以分块文件方式上传：调用方要求分块，或文件大小已知且超过任一账户的剩余空间
+func shouldStripe(accounts []store.Account, size int64, meta ObjectMetadata) bool {
+	if meta.Striped {
+		return true
+	}
+	if size <= 0 {
+		return false
+	}
+	for i := range accounts {
+		if accounts[i].FreeBytes() >= size {
+			return false
+		}
+	}
+	return true
+}
+
+// planStripes 规划分块位置：每个分块放到当前剩余空间最多的账户，分块不超过 StripeChunkSize
+func planStripes(accounts []store.Account, size int64) ([]store.StripedChunk, error) {
+	free := make([]int64, len(accounts))
+	for i := range accounts {
+		free[i] = accounts[i].FreeBytes()
+	}
+
+	var chunks []store.StripedChunk
+	var offset int64
+	for offset < size {
+		best := 0
+		for i := range free {
+			if free[i] > free[best] {
+				best = i
+			}
+		}
+		n := min(StripeChunkSize, size-offset, free[best])
+		if n <= 0 || (n < MinStripeChunkSize && n < size-offset) {
+			return nil, fmt.Errorf("%w: 无法放置剩余的 %d 字节", ErrInsufficientSpace, size-offset)
+		}
+		if len(chunks) == maxStripeChunks {
+			return nil, fmt.Errorf("分块数量超过上限 %d", maxStripeChunks)
+		}
+		chunks = append(chunks, store.StripedChunk{
+			Index:     len(chunks),
+			AccountID: accounts[best].ID,
+			Offset:    offset,
+			Size:      n,
+		})
+		free[best] -= n
+		offset += n
+	}
+	return chunks, nil
+}
+
+// stripedUpload 把文件拆分为多个分块写入不同账户，并在存储中记录分块清单
+// 虚拟文件显示在首个规划分块所在账户的路径下；数据源支持随机读取时（表单临时文件等）先计算整个文件的校验值，
+// 分块写入失败可以换账户重试，否则边上传边计算，任何分块失败都会删除已写入的分块
+func stripedUpload(ctx context.Context, accounts []store.Account, naming KeyNaming, meta ObjectMetadata, body io.Reader, size int64, contentType string) (*UploadResult, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("分块上传需要已知的文件大小")
+	}
+	chunks, err := planStripes(accounts, size)
+	if err != nil {
+		return nil, err
+	}
+
+	home, err := store.GetAccountByID(chunks[0].AccountID)
+	if err != nil {
+		return nil, err
+	}
+	// 分块前无法获得文件哈希，模板中的 {sha256} 使用随机值代替
+	key, err := buildObjectKey(ctx, home, naming, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := store.GetStripedFileByKey(home.ID, key); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyConflict, key)
+	}
+
+	sf := &store.StripedFile{
+		ID:           uuid.New().String(),
+		AccountID:    home.ID,
+		FileKey:      key,
+		OriginalName: meta.OriginalName,
+		ContentType:  contentType,
+		Size:         size,
+		Uploader:     meta.Uploader,
+	}
+
+	hash := sha256.New()
+	crc := &crc32cWriter{}
+	ra, randomAccess := body.(io.ReaderAt)
+	if randomAccess {
+		if _, err := io.Copy(io.MultiWriter(hash, crc), io.NewSectionReader(ra, 0, size)); err != nil {
+			return nil, fmt.Errorf("读取文件内容失败: %w", err)
+		}
+		if err := meta.Expected.verify(Checksums{SHA256: hex.EncodeToString(hash.Sum(nil)), CRC32C: crc32cHex(crc.crc)}); err != nil {
+			return nil, err
+		}
+	} else {
+		body = io.TeeReader(body, io.MultiWriter(hash, crc))
+	}
+
+	parent, _ := ctx.Value(uploadProgressKey{}).(UploadProgressFunc)
+	for i := range chunks {
+		chunk := &chunks[i]
+		chunk.Key = fmt.Sprintf("%s%s/%05d", StripeChunkPrefix, sf.ID, chunk.Index)
+
+		var reader io.Reader
+		if randomAccess {
+			reader = io.NewSectionReader(ra, chunk.Offset, chunk.Size)
+		} else {
+			reader = io.LimitReader(body, chunk.Size)
+		}
+
+		// 分块进度换算为整个文件的进度，按虚拟文件所在账户报告
+		chunkCtx := ctx
+		if parent != nil {
+			offset := chunk.Offset
+			chunkCtx = WithUploadProgress(ctx, func(_ string, uploaded int64) {
+				parent(home.ID, offset+uploaded)
+			})
+		}
+		if err := uploadStripeChunk(chunkCtx, accounts, chunk, reader, meta); err != nil {
+			deleteStripeChunks(ctx, chunks[:i])
+			return nil, fmt.Errorf("上传第 %d 个分块失败: %w", chunk.Index+1, err)
+		}
+	}
+
+	sf.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	sf.CRC32C = crc32cHex(crc.crc)
+	if !randomAccess {
+		var extra [1]byte
+		if n, _ := io.ReadFull(body, extra[:]); n > 0 {
+			deleteStripeChunks(ctx, chunks)
+			return nil, fmt.Errorf("%w: 实际数据超过声明大小 %d 字节", ErrIncompleteUpload, size)
+		}
+		if err := meta.Expected.verify(Checksums{SHA256: sf.SHA256, CRC32C: sf.CRC32C}); err != nil {
+			deleteStripeChunks(ctx, chunks)
+			return nil, err
+		}
+	}
+
+	sf.Chunks = chunks
+	if err := store.CreateStripedFile(sf); err != nil {
+		deleteStripeChunks(ctx, chunks)
+		return nil, fmt.Errorf("保存分块清单失败: %w", err)
+	}
+
+	link := stripedFileLink(sf)
+	result := &UploadResult{
+		ID:          home.ID,
+		AccountName: home.Name,
+		Key:         key,
+		Size:        size,
+		URL:         link.URL,
+		LinkMode:    link.Mode,
+		SHA256:      sf.SHA256,
+		CRC32C:      sf.CRC32C,
+		Chunks:      len(chunks),
+	}
+	recordCustomMetadata(result, meta)
+	publishUploadEvent(result, meta.Source)
+	return result, nil
+}
+
+// uploadStripeChunk 上传单个分块，优先写入规划的账户
+// 写入前预留分块大小的容量，失败后在数据可回退时换到其他剩余空间足够的账户重试，成功后记录实际所在账户和分块哈希
+func uploadStripeChunk(ctx context.Context, accounts []store.Account, chunk *store.StripedChunk, reader io.Reader, meta ObjectMetadata) error {
+	src, err := newUploadSource(reader, chunk.Size)
+	if err != nil {
+		return err
+	}
+	defer src.close()
+	src.meta = ObjectMetadata{Uploader: meta.Uploader, Source: meta.Source}
+
+	candidates := make([]*store.Account, 0, len(accounts))
+	for i := range accounts {
+		if accounts[i].ID == chunk.AccountID {
+			candidates = append([]*store.Account{&accounts[i]}, candidates...)
+		} else if accounts[i].FreeBytes() >= chunk.Size {
+			candidates = append(candidates, &accounts[i])
+		}
+	}
+
+	var lastErr error
+	for _, acc := range candidates {
+		if err := src.rewind(); err != nil {
+			return fmt.Errorf("%w（最后错误: %v）", err, lastErr)
+		}
+		reservationID, err := reserveUpload(acc, chunk.Size, meta.Source, chunk.Key, false)
+		if err != nil {
+			lastErr = err
+			log.Printf("[Stripe] 账户 %s 剩余空间不足: %v，尝试下一个账户", acc.Name, err)
+			continue
+		}
+		_, err = streamUpload(ctx, acc, chunk.Key, src, "application/octet-stream")
+		releaseReservation(reservationID)
+		if err != nil {
+			if errors.Is(err, ErrIncompleteUpload) {
+				return err
+			}
+			lastErr = err
+			log.Printf("[Stripe] 分块写入账户 %s 失败: %v，尝试下一个账户", acc.Name, err)
+			continue
+		}
+		chunk.AccountID = acc.ID
+		chunk.SHA256 = src.sum()
+		return nil
+	}
+	return lastErr
+}
+
+// deleteStripeChunks 删除分块对象，失败只记录日志
+func deleteStripeChunks(ctx context.Context, chunks []store.StripedChunk) {
+	for _, chunk := range chunks {
+		acc, err := store.GetAccountByID(chunk.AccountID)
+		if err != nil {
+			continue
+		}
+		_, err = getS3Client(acc).DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(acc.BucketName),
+			Key:    aws.String(chunk.Key),
+		})
+		if err != nil {
+			log.Printf("[Stripe] 删除账户 %s 的分块失败 (key=%s): %v", acc.Name, chunk.Key, err)
+			continue
+		}
+		RecordStoredBytes(acc.ID, -chunk.Size)
+	}
+}
+
+// stripedFileLink 分块文件的下载链接，指向需要认证的 FileFlow 下载接口
+func stripedFileLink(sf *store.StripedFile) *FileLink {
+	return downloadLink(sf.AccountID, sf.FileKey)
+}
+
+// IsStripeChunkKey 是否为分块对象路径
+func IsStripeChunkKey(key string) bool {
+	return strings.HasPrefix(key, StripeChunkPrefix)
+}
+
+// DeleteStripedFiles 删除分块文件的所有分块和清单，key 以 / 结尾时处理该目录下的所有分块文件
+// 返回是否存在对应的分块文件
+func DeleteStripedFiles(ctx context.Context, accountID, key string) bool {
+	var files []store.StripedFile
+	if strings.HasSuffix(key, "/") {
+		files = store.GetStripedFilesByPrefix(accountID, key)
+	} else if sf, err := store.GetStripedFileByKey(accountID, key); err == nil {
+		files = append(files, *sf)
+	}
+
+	for _, sf := range files {
+		deleteStripeChunks(ctx, sf.Chunks)
+		if err := store.DeleteStripedFile(sf.ID); err != nil {
+			log.Printf("[Stripe] 删除分块清单失败 (key=%s): %v", sf.FileKey, err)
+		}
+		store.DeleteFileMetadata(sf.AccountID, sf.FileKey)
+	}
+	return len(files) > 0
+}
+
+// deleteAccountStripedFiles 清空账户时删除显示在该账户下或有分块存放在该账户中的分块文件
+func deleteAccountStripedFiles(ctx context.Context, accountID string) {
+	files := store.GetStripedFilesByChunkAccount(accountID)
+	files = append(files, store.GetStripedFilesByPrefix(accountID, "")...)
+
+	seen := make(map[string]bool)
+	for _, sf := range files {
+		if seen[sf.ID] {
+			continue
+		}
+		seen[sf.ID] = true
+		deleteStripeChunks(ctx, sf.Chunks)
+		store.DeleteStripedFile(sf.ID)
+		store.DeleteFileMetadata(sf.AccountID, sf.FileKey)
+	}
+}
+
+// stripedFileStat 分块文件的元数据，自定义元数据和标签取自存储中的记录
+func stripedFileStat(sf *store.StripedFile) *FileStat {
+	stat := &FileStat{
+		AccountID:      sf.AccountID,
+		Key:            sf.FileKey,
+		Size:           sf.Size,
+		ContentType:    sf.ContentType,
+		ETag:           sf.SHA256,
+		LastModified:   sf.CreatedAt,
+		OriginalName:   sf.OriginalName,
+		Uploader:       sf.Uploader,
+		SHA256:         sf.SHA256,
+		CRC32C:         sf.CRC32C,
+		Metadata:       map[string]string{},
+		CustomMetadata: map[string]string{},
+		Tags:           []string{},
+		Chunks:         len(sf.Chunks),
+	}
+	if fm, err := store.GetFileMetadataByKey(sf.AccountID, sf.FileKey); err == nil {
+		stat.CustomMetadata = fm.Metadata
+		stat.Tags = fm.Tags
+	}
+	return stat
+}
+
+// stripedFileModTime 分块文件的修改时间（上传完成的时间）
+func stripedFileModTime(sf *store.StripedFile) time.Time {
+	if t, err := time.Parse(time.RFC3339, sf.CreatedAt); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+// appendStripedNodes 把 prefix 下的分块文件加入文件列表，更深层的分块文件以目录形式出现
+func appendStripedNodes(files []*FileNode, accountID, prefix string, custom map[string]store.FileMetadata) []*FileNode {
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		if f.IsDir {
+			dirs[f.Key] = true
+		}
+	}
+
+	for _, sf := range store.GetStripedFilesByPrefix(accountID, prefix) {
+		name := strings.TrimPrefix(sf.FileKey, prefix)
+		if i := strings.Index(name, "/"); i >= 0 {
+			dirKey := prefix + name[:i+1]
+			if !dirs[dirKey] {
+				dirs[dirKey] = true
+				files = append(files, &FileNode{Key: dirKey, Name: name[:i], IsDir: true})
+			}
+			continue
+		}
+		lastMod := stripedFileModTime(&sf)
+		fm := custom[sf.FileKey]
+		files = append(files, &FileNode{
+			Key:          sf.FileKey,
+			Name:         name,
+			Size:         sf.Size,
+			LastModified: &lastMod,
+			Metadata:     fm.Metadata,
+			Tags:         fm.Tags,
+			Striped:      true,
+		})
+	}
+	return files
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -63,20 +64,45 @@ var classAOperations = map[string]bool{
 	"ListMultipartUploads": true,
 }
 
-// SyncAccountUsage 同步单个账户的使用量
+// SyncAccountUsage 同步单个账户的使用量（容量和操作次数的获取方式见 UsageCollector）
+// 同步结果是权威数据：开始同步前本地记录的用量变化已包含在结果中，对账时扣除，同步期间新记录的变化保留
+// 无法获取操作次数时沿用上次同步的值，本地记录的操作次数不参与对账（进入新的月份时清零）；
+// 服务商没有统计接口时，本地记录的操作次数并入同步结果保存
 func SyncAccountUsage(ctx context.Context, acc *store.Account) error {
+	current, err := store.GetAccountByID(acc.ID)
+	if err != nil {
+		return err
+	}
+	reconciled := current.PendingUsage
+	collector := usageCollectorFor(acc)
+
 	// 获取存储容量
-	sizeBytes, err := GetAccountStorageSize(ctx, acc)
+	sizeBytes, err := collector.StorageSize(ctx, acc)
 	if err != nil {
 		return fmt.Errorf("获取存储容量失败: %w", err)
 	}
 
 	// 获取操作次数
-	classAOps, classBOps, err := getAccountOps(ctx, acc)
-	if err != nil {
-		log.Printf("获取账户 %s 操作次数失败: %v，使用默认值 0", acc.Name, err)
-		classAOps = 0
-		classBOps = 0
+	classAOps, classBOps, err := collector.Operations(ctx, acc)
+	if errors.Is(err, ErrOpsUnavailable) {
+		classAOps = current.Usage.ClassAOps + reconciled.ClassAOps
+		classBOps = current.Usage.ClassBOps + reconciled.ClassBOps
+		if current.Usage.LastSyncAt != "" && !sameBillingMonth(current.Usage.LastSyncAt, time.Now()) {
+			// 上次同步在之前的月份，本月只计入上次同步后的本地计数
+			classAOps, classBOps = reconciled.ClassAOps, reconciled.ClassBOps
+		}
+	} else if err != nil {
+		log.Printf("获取账户 %s 操作次数失败: %v，沿用上次同步的值和本地计数", acc.Name, err)
+		classAOps = current.Usage.ClassAOps
+		classBOps = current.Usage.ClassBOps
+		reconciled.ClassAOps = 0
+		reconciled.ClassBOps = 0
+		if !sameBillingMonth(current.Usage.LastSyncAt, time.Now()) {
+			// 操作次数按月计算，新的月份从 0 开始
+			classAOps, classBOps = 0, 0
+			reconciled.ClassAOps = current.PendingUsage.ClassAOps
+			reconciled.ClassBOps = current.PendingUsage.ClassBOps
+		}
 	}
 
 	// 更新使用量
@@ -86,16 +112,41 @@ func SyncAccountUsage(ctx context.Context, acc *store.Account) error {
 		ClassBOps: classBOps,
 	}
 
-	if err := store.UpdateAccountUsage(acc.ID, usage); err != nil {
+	before, err := store.GetAccountByID(acc.ID)
+	if err != nil {
+		return err
+	}
+	wasOver := before.IsOverQuota() || before.IsOverOps()
+	if err := store.UpdateAccountUsage(acc.ID, usage, reconciled); err != nil {
 		return fmt.Errorf("更新使用量失败: %w", err)
 	}
 
+	// 只在从正常变为超额时发送事件，避免每次同步重复通知
+	updated, err := store.GetAccountByID(acc.ID)
+	if err != nil {
+		return err
+	}
+	if !wasOver && (updated.IsOverQuota() || updated.IsOverOps()) {
+		PublishEvent(EventAccountOverQuota, newAccountEventData(updated))
+	}
+	checkOpsBudget(updated)
+
 	log.Printf("[Sync] 账户 %s 同步完成: 容量 %.2f MB, 写入操作 %d 次, 读取操作 %d 次",
 		acc.Name, float64(sizeBytes)/1024/1024, classAOps, classBOps)
 
 	return nil
 }
 
+// sameBillingMonth 检查上次同步时间是否与 now 在同一个自然月（UTC），没有同步记录时视为不同
+func sameBillingMonth(lastSyncAt string, now time.Time) bool {
+	last, err := time.Parse(time.RFC3339, lastSyncAt)
+	if err != nil {
+		return false
+	}
+	last, now = last.UTC(), now.UTC()
+	return last.Year() == now.Year() && last.Month() == now.Month()
+}
+
 // SyncAllAccountsUsage 同步所有账户的使用量
 func SyncAllAccountsUsage(ctx context.Context) {
 	accounts := store.GetAccounts()
@@ -107,6 +158,9 @@ func SyncAllAccountsUsage(ctx context.Context) {
 
 		if err := SyncAccountUsage(ctx, &acc); err != nil {
 			log.Printf("[Sync] 账户 %s 同步失败: %v", acc.Name, err)
+			data := newAccountEventData(&acc)
+			data.Error = err.Error()
+			PublishEvent(EventSyncFailed, data)
 		}
 	}
 
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// ThumbnailDirSuffix 缩略图与原图放在同一目录，存储在 <原图 key>.thumbs/ 下
+	ThumbnailDirSuffix = ".thumbs"
+	// MaxThumbnailSizes 最多配置的缩略图尺寸数量
+	MaxThumbnailSizes = 5
+	// MinThumbnailSize、MaxThumbnailSize 缩略图最长边的范围（像素）
+	MinThumbnailSize = 16
+	MaxThumbnailSize = 4096
+	// MaxThumbnailSourceSize 生成缩略图的原图大小上限，超过时跳过
+	MaxThumbnailSourceSize int64 = 32 << 20
+	// MaxThumbnailSourcePixels 原图像素上限，防止解码超大图片耗尽内存
+	MaxThumbnailSourcePixels = 50_000_000
+)
+
+// Thumbnail 缩略图信息
+type Thumbnail struct {
+	Size   int    `json:"size"`             // 配置的最长边尺寸
+	Width  int    `json:"width,omitempty"`  // 实际宽度（复用已有对象时为空）
+	Height int    `json:"height,omitempty"` // 实际高度（复用已有对象时为空）
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+}
+
+// ParseThumbnailSizes 解析缩略图尺寸设置（逗号分隔的最长边像素），返回去重后的升序列表
+func ParseThumbnailSizes(value string) ([]int, error) {
+	seen := make(map[int]bool)
+	var sizes []int
+	for _, item := range splitSettingList(value) {
+		size, err := strconv.Atoi(item)
+		if err != nil || size < MinThumbnailSize || size > MaxThumbnailSize {
+			return nil, fmt.Errorf("缩略图尺寸必须为 %d 到 %d 之间的整数: %s", MinThumbnailSize, MaxThumbnailSize, item)
+		}
+		if !seen[size] {
+			seen[size] = true
+			sizes = append(sizes, size)
+		}
+	}
+	if len(sizes) > MaxThumbnailSizes {
+		return nil, fmt.Errorf("最多配置 %d 个缩略图尺寸", MaxThumbnailSizes)
+	}
+	sort.Ints(sizes)
+	return sizes, nil
+}
+
+// thumbnailPrefix 原图对应的缩略图目录
+func thumbnailPrefix(key string) string {
+	return key + ThumbnailDirSuffix + "/"
+}
+
+// IsThumbnailKey 是否为缩略图对象
+func IsThumbnailKey(key string) bool {
+	return strings.HasSuffix(path.Dir(key), ThumbnailDirSuffix)
+}
+
+// thumbnailOptions 上传时生成缩略图的配置，未启用时为 nil
+type thumbnailOptions struct {
+	sizes   []int
+	quality int
+}
+
+// loadThumbnailOptions 读取系统设置中的缩略图配置
+func loadThumbnailOptions() *thumbnailOptions {
+	settings := store.GetSettings()
+	if !settings.ThumbnailEnabled {
+		return nil
+	}
+	sizes, err := ParseThumbnailSizes(settings.ThumbnailSizes)
+	if err != nil || len(sizes) == 0 {
+		return nil
+	}
+	return &thumbnailOptions{sizes: sizes, quality: settings.ThumbnailQuality}
+}
+
+// content 返回完整文件内容的 Reader，只在数据已在内存中或可回退时可用
+// 必须在上传完成后调用，调用后数据源不能再用于上传
+func (s *uploadSource) content() (io.Reader, bool) {
+	if s.headOnly {
+		return bytes.NewReader(s.head), true
+	}
+	if s.seeker == nil {
+		return nil, false
+	}
+	if _, err := s.seeker.Seek(s.restPos, io.SeekStart); err != nil {
+		return nil, false
+	}
+	s.readRest = true
+	return io.MultiReader(bytes.NewReader(s.head), s.body), true
+}
+
+// generateThumbnails 为刚上传的图片生成缩略图，写入 <key>.thumbs/<尺寸>.jpg（含透明通道时为 .png）
+// 支持 JPEG、PNG、GIF（首帧）和 WebP；不是图片、超过大小限制或数据不可重新读取时跳过。
+// 缩略图失败不影响原图上传，只记录日志
+func generateThumbnails(ctx context.Context, acc *store.Account, key string, src *uploadSource, size int64, opts *thumbnailOptions) []Thumbnail {
+	if opts == nil || size > MaxThumbnailSourceSize {
+		return nil
+	}
+
+	// 先只解析图片头，识别格式并检查尺寸
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(src.head))
+	if err != nil {
+		return nil
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxThumbnailSourcePixels {
+		log.Printf("[Thumbnail] 图片尺寸 %dx%d 超出限制，跳过 (key=%s)", cfg.Width, cfg.Height, key)
+		return nil
+	}
+
+	r, ok := src.content()
+	if !ok {
+		return nil
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		log.Printf("[Thumbnail] 解码图片失败 (key=%s): %v", key, err)
+		return nil
+	}
+
+	client := getS3Client(acc)
+	var thumbs []Thumbnail
+	for _, maxSize := range opts.sizes {
+		// 不放大：原图已小于该尺寸时跳过
+		width, height := fitSize(cfg.Width, cfg.Height, maxSize)
+		if width >= cfg.Width && height >= cfg.Height {
+			continue
+		}
+
+		data, ext, contentType, err := encodeThumbnail(img, width, height, opts.quality)
+		if err != nil {
+			log.Printf("[Thumbnail] 生成 %d 缩略图失败 (key=%s): %v", maxSize, key, err)
+			continue
+		}
+
+		thumbKey := thumbnailPrefix(key) + strconv.Itoa(maxSize) + ext
+		_, err = client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:            aws.String(acc.BucketName),
+			Key:               aws.String(thumbKey),
+			Body:              bytes.NewReader(data),
+			ContentLength:     aws.Int64(int64(len(data))),
+			ContentType:       aws.String(contentType),
+			ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+			ChecksumSHA256:    sha256Base64(data),
+		})
+		if err != nil {
+			log.Printf("[Thumbnail] 上传缩略图失败 (key=%s): %v", thumbKey, err)
+			continue
+		}
+
+		thumbs = append(thumbs, Thumbnail{
+			Size:   maxSize,
+			Width:  width,
+			Height: height,
+			Key:    thumbKey,
+			URL:    thumbnailURL(ctx, acc, thumbKey),
+		})
+	}
+	return thumbs
+}
+
+// fitSize 按比例缩放到最长边不超过 maxSize
+func fitSize(width, height, maxSize int) (int, int) {
+	if width >= height {
+		if width <= maxSize {
+			return width, height
+		}
+		return maxSize, max(1, height*maxSize/width)
+	}
+	if height <= maxSize {
+		return width, height
+	}
+	return max(1, width*maxSize/height), maxSize
+}
+
+// encodeThumbnail 缩放并编码缩略图，不透明图片使用 JPEG，含透明通道时使用 PNG
+func encodeThumbnail(img image.Image, width, height, quality int) ([]byte, string, string, error) {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var buf bytes.Buffer
+	if dst.Opaque() {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), ".jpg", "image/jpeg", nil
+	}
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), ".png", "image/png", nil
+}
+
+// thumbnailURL 生成缩略图链接，失败时返回空字符串
+func thumbnailURL(ctx context.Context, acc *store.Account, key string) string {
+	link, err := buildFileLink(ctx, acc, key, LinkOptions{})
+	if err != nil {
+		return ""
+	}
+	return link.URL
+}
+
+// listThumbnails 列出原图已有的缩略图（内容去重复用已有对象时使用）
+func listThumbnails(ctx context.Context, acc *store.Account, key string) []Thumbnail {
+	out, err := getS3Client(acc).ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(acc.BucketName),
+		Prefix: aws.String(thumbnailPrefix(key)),
+	})
+	if err != nil {
+		return nil
+	}
+
+	var thumbs []Thumbnail
+	for _, obj := range out.Contents {
+		thumbKey := aws.ToString(obj.Key)
+		name := path.Base(thumbKey)
+		size, err := strconv.Atoi(strings.TrimSuffix(name, path.Ext(name)))
+		if err != nil {
+			continue
+		}
+		thumbs = append(thumbs, Thumbnail{
+			Size: size,
+			Key:  thumbKey,
+			URL:  thumbnailURL(ctx, acc, thumbKey),
+		})
+	}
+	sort.Slice(thumbs, func(i, j int) bool { return thumbs[i].Size < thumbs[j].Size })
+	return thumbs
+}
+
+// deleteThumbnails 删除原图的所有缩略图，失败只记录日志
+func deleteThumbnails(ctx context.Context, client *s3.Client, acc *store.Account, key string) {
+	if IsThumbnailKey(key) {
+		return
+	}
+	if err := deleteDirectory(ctx, client, acc, thumbnailPrefix(key)); err != nil {
+		log.Printf("[Thumbnail] 删除缩略图失败 (key=%s): %v", key, err)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"fileflow/server/store"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// 本地用量计数
+// 用量同步之间，通过 SDK 发出的每个请求按 R2 计费类别计入账户的 A/B 类操作次数，
+// 写入和删除的字节数计入容量，使 IsAvailable、IsOverQuota 和放置策略使用估算的当前用量。
+// 计数只保存在内存中，下次同步时以同步结果为准（见 SyncAccountUsage）
+
+// classAOperationNames 计为 A 类操作的 SDK 操作（与 R2 的计费类别对应）
+var classAOperationNames = map[string]bool{
+	"ListBuckets":                     true,
+	"CreateBucket":                    true,
+	"ListObjects":                     true,
+	"ListObjectsV2":                   true,
+	"PutObject":                       true,
+	"CopyObject":                      true,
+	"CompleteMultipartUpload":         true,
+	"CreateMultipartUpload":           true,
+	"ListMultipartUploads":            true,
+	"UploadPart":                      true,
+	"UploadPartCopy":                  true,
+	"ListParts":                       true,
+	"PutBucketEncryption":             true,
+	"PutBucketCors":                   true,
+	"PutBucketLifecycleConfiguration": true,
+}
+
+// freeOperationNames 不计费的 SDK 操作，其余操作计为 B 类
+var freeOperationNames = map[string]bool{
+	"DeleteObject":         true,
+	"DeleteObjects":        true,
+	"DeleteBucket":         true,
+	"AbortMultipartUpload": true,
+}
+
+var (
+	multipartBytesMu sync.Mutex
+	// multipartBytes 进行中的分片上传已写入的字节数，中止时从容量中扣除
+	multipartBytes = make(map[string]int64)
+)
+
+// operationUsage 单个请求对应的操作次数
+func operationUsage(name string) store.UsageDelta {
+	switch {
+	case freeOperationNames[name]:
+		return store.UsageDelta{}
+	case classAOperationNames[name]:
+		return store.UsageDelta{ClassAOps: 1}
+	default:
+		return store.UsageDelta{ClassBOps: 1}
+	}
+}
+
+// WithUsageTracking 为账户的 S3 客户端启用本地用量计数
+// 每次 HTTP 请求（包括重试）计入一次操作；预签名不发送请求，不计入。
+// PutObject、UploadPart 成功后按请求的 ContentLength 计入容量，中止分片上传时扣除已写入的分片；
+// 覆盖同名对象、复制和删除的容量变化由调用方通过 RecordStoredBytes 记录
+func WithUsageTracking(accountID string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			if err := stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("FileFlowUsageOps",
+				func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+					out, metadata, err := next.HandleDeserialize(ctx, in)
+					recordUsage(accountID, operationUsage(awsmiddleware.GetOperationName(ctx)))
+					return out, metadata, err
+				}), middleware.After); err != nil {
+				return err
+			}
+			return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("FileFlowUsageBytes",
+				func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+					out, metadata, err := next.HandleInitialize(ctx, in)
+					if err == nil {
+						trackWrittenBytes(accountID, in.Parameters, out.Result)
+					}
+					return out, metadata, err
+				}), middleware.After)
+		})
+	}
+}
+
+// trackWrittenBytes 根据成功的写入请求更新容量计数，预签名请求的结果不是操作的输出类型，不计入
+func trackWrittenBytes(accountID string, params, result interface{}) {
+	switch input := params.(type) {
+	case *s3.PutObjectInput:
+		if _, ok := result.(*s3.PutObjectOutput); ok && input.ContentLength != nil {
+			RecordStoredBytes(accountID, *input.ContentLength)
+		}
+	case *s3.UploadPartInput:
+		if _, ok := result.(*s3.UploadPartOutput); ok && input.ContentLength != nil {
+			multipartBytesMu.Lock()
+			multipartBytes[aws.ToString(input.UploadId)] += *input.ContentLength
+			multipartBytesMu.Unlock()
+			RecordStoredBytes(accountID, *input.ContentLength)
+		}
+	case *s3.CompleteMultipartUploadInput:
+		if _, ok := result.(*s3.CompleteMultipartUploadOutput); ok {
+			multipartBytesMu.Lock()
+			delete(multipartBytes, aws.ToString(input.UploadId))
+			multipartBytesMu.Unlock()
+		}
+	case *s3.AbortMultipartUploadInput:
+		if _, ok := result.(*s3.AbortMultipartUploadOutput); ok {
+			multipartBytesMu.Lock()
+			written := multipartBytes[aws.ToString(input.UploadId)]
+			delete(multipartBytes, aws.ToString(input.UploadId))
+			multipartBytesMu.Unlock()
+			RecordStoredBytes(accountID, -written)
+		}
+	}
+}
+
+// RecordStoredBytes 记录账户容量的变化，删除对象时为负数
+func RecordStoredBytes(accountID string, bytes int64) {
+	if bytes != 0 {
+		recordUsage(accountID, store.UsageDelta{SizeBytes: bytes})
+	}
+}
+
+// recordUsage 累加本地用量计数，账户因此由可用变为超额时发送 account.over_quota 事件
+func recordUsage(accountID string, delta store.UsageDelta) {
+	if delta == (store.UsageDelta{}) {
+		return
+	}
+	if acc, crossed := store.RecordAccountUsage(accountID, delta); crossed {
+		PublishEvent(EventAccountOverQuota, newAccountEventData(acc))
+	}
+}
+
+// knownObjectSize 获取删除前用于更新容量计数的对象大小
+// 依次使用内容索引、到期记录、自定义元数据和副本集中记录的大小，都没有时才通过 HeadObject（Class B 操作）获取
+func knownObjectSize(ctx context.Context, client *s3.Client, acc *store.Account, key string) int64 {
+	if obj, err := store.GetFileObjectByKey(acc.ID, key); err == nil && obj.Size > 0 {
+		return obj.Size
+	}
+	if exp, err := store.GetFileExpirationByKey(acc.ID, key); err == nil && exp.Size > 0 {
+		return exp.Size
+	}
+	if meta, err := store.GetFileMetadataByKey(acc.ID, key); err == nil && meta.Size > 0 {
+		return meta.Size
+	}
+	if set, err := store.GetReplicaSetByKey(acc.ID, key); err == nil && set.Size > 0 {
+		return set.Size
+	}
+	return objectSize(ctx, client, acc.BucketName, key)
+}
+
+// objectSize 获取对象大小，用于删除前更新容量计数；获取失败时返回 0，由下次同步校正
+func objectSize(ctx context.Context, client *s3.Client, bucket, key string) int64 {
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0
+	}
+	return aws.ToInt64(head.ContentLength)
+}
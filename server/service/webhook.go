@@ -0,0 +1,422 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fileflow/server/store"
+
+	"github.com/google/uuid"
+)
+
+// Webhook 事件类型
+const (
+	EventFileUploaded            = "file.uploaded"              // 文件上传完成
+	EventFileDeleted             = "file.deleted"               // 文件被删除（API、WebDAV 或后台）
+	EventFileExpired             = "file.expired"               // 到期文件被自动删除
+	EventGCEvicted               = "gc.evicted"                 // 账户超出容量，GC 删除了最旧的文件
+	EventAccountOverQuota        = "account.over_quota"         // 账户超出容量或 Class A/B 操作数配额
+	EventAccountOpsBudgetWarning = "account.ops_budget_warning" // 同步后操作次数或月底预测值达到告警阈值
+	EventSyncFailed              = "sync.failed"                // 账户用量同步失败
+	EventWebhookTest             = "webhook.test"               // 手动发送的测试事件，只投递到指定 Webhook
+)
+
+// WebhookEvents 可订阅的事件类型
+var WebhookEvents = []string{
+	EventFileUploaded,
+	EventFileDeleted,
+	EventFileExpired,
+	EventGCEvicted,
+	EventAccountOverQuota,
+	EventAccountOpsBudgetWarning,
+	EventSyncFailed,
+}
+
+const (
+	// WebhookMaxAttempts 每次投递最多尝试的次数（含首次）
+	WebhookMaxAttempts = 8
+	// WebhookDeliveryRetention 已结束投递记录的保留时间
+	WebhookDeliveryRetention = 7 * 24 * time.Hour
+	// WebhookDeliveryWorkers 同时进行的投递请求数
+	WebhookDeliveryWorkers = 4
+	// webhookTimeout 单次投递的超时时间
+	webhookTimeout = 10 * time.Second
+	// webhookRetryBase 首次重试的等待时间，之后每次翻倍
+	webhookRetryBase = 30 * time.Second
+	// webhookRetryMax 重试等待时间上限
+	webhookRetryMax = time.Hour
+)
+
+// ErrWebhookNotFound Webhook 不存在
+var ErrWebhookNotFound = errors.New("Webhook 不存在")
+
+// webhookClient 禁止连接内网地址，不跟随重定向（3xx 视为投递失败）
+var webhookClient = NewSafeHTTPClient(webhookTimeout)
+
+var (
+	webhookRuns     = make(map[string]bool)
+	webhookRunsLock sync.Mutex
+	webhookSlots    = make(chan struct{}, WebhookDeliveryWorkers)
+)
+
+// WebhookEvent 投递给接收端的请求体
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt string      `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// FileEventData file.* 事件的数据
+type FileEventData struct {
+	AccountID string `json:"accountId"`
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	URL       string `json:"url,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+	Source    string `json:"source,omitempty"`
+}
+
+// GCEventData gc.evicted 事件的数据，一次 GC 只产生一个事件
+type GCEventData struct {
+	AccountID   string          `json:"accountId"`
+	AccountName string          `json:"accountName"`
+	Files       []GCEvictedFile `json:"files"`
+	FreedBytes  int64           `json:"freedBytes"`
+}
+
+// GCEvictedFile GC 删除的文件
+type GCEvictedFile struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// AccountEventData account.* 和 sync.* 事件的数据
+type AccountEventData struct {
+	AccountID    string      `json:"accountId"`
+	AccountName  string      `json:"accountName"`
+	Usage        store.Usage `json:"usage"`
+	Quota        store.Quota `json:"quota"`
+	UsagePercent float64     `json:"usagePercent"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// newAccountEventData 根据账户构建事件数据
+func newAccountEventData(acc *store.Account) AccountEventData {
+	return AccountEventData{
+		AccountID:    acc.ID,
+		AccountName:  acc.Name,
+		Usage:        acc.EstimatedUsage(),
+		Quota:        acc.Quota,
+		UsagePercent: acc.GetUsagePercent(),
+	}
+}
+
+// publishUploadEvent 发送 file.uploaded 事件
+func publishUploadEvent(result *UploadResult, source string) {
+	PublishEvent(EventFileUploaded, FileEventData{
+		AccountID: result.ID,
+		Key:       result.Key,
+		Size:      result.Size,
+		URL:       result.URL,
+		SHA256:    result.SHA256,
+		Source:    source,
+	})
+}
+
+// IsWebhookEvent 是否为可订阅的事件类型
+func IsWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribes Webhook 是否订阅了该事件，未选择任何事件表示订阅全部
+func subscribes(hook *store.Webhook, event string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, e := range hook.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// PublishEvent 向订阅了该事件的启用中的 Webhook 投递事件
+// 投递记录先写入存储再在后台发送，失败后按指数退避重试，服务重启后继续
+func PublishEvent(event string, data interface{}) {
+	var hooks []store.Webhook
+	for _, hook := range store.GetWebhooks() {
+		if hook.IsActive && subscribes(&hook, event) {
+			hooks = append(hooks, hook)
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	eventID, payload, err := newWebhookPayload(event, data)
+	if err != nil {
+		log.Printf("[Webhook] 生成 %s 事件失败: %v", event, err)
+		return
+	}
+
+	deliveries := make([]*store.WebhookDelivery, 0, len(hooks))
+	for _, hook := range hooks {
+		deliveries = append(deliveries, newWebhookDelivery(hook.ID, eventID, event, payload, WebhookMaxAttempts))
+	}
+	if err := store.CreateWebhookDeliveries(deliveries); err != nil {
+		log.Printf("[Webhook] 保存 %s 事件的投递记录失败: %v", event, err)
+		return
+	}
+	for _, d := range deliveries {
+		startWebhookDelivery(d.ID)
+	}
+}
+
+// SendTestWebhookEvent 向指定 Webhook 发送一次测试事件（不重试），返回投递记录
+// 停用的 Webhook 也可以发送测试事件
+func SendTestWebhookEvent(ctx context.Context, id string) (*store.WebhookDelivery, error) {
+	hook, err := store.GetWebhookByID(id)
+	if err != nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	eventID, payload, err := newWebhookPayload(EventWebhookTest, map[string]string{"webhookId": hook.ID, "name": hook.Name})
+	if err != nil {
+		return nil, err
+	}
+	d := newWebhookDelivery(hook.ID, eventID, EventWebhookTest, payload, 1)
+	if err := store.CreateWebhookDeliveries([]*store.WebhookDelivery{d}); err != nil {
+		return nil, err
+	}
+
+	code, err := sendWebhook(ctx, hook, d)
+	recordWebhookAttempt(d, code, err)
+	if err := store.UpdateWebhookDelivery(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ListWebhookDeliveries 获取 Webhook 的投递记录（按创建时间倒序），最多 limit 条
+func ListWebhookDeliveries(id string, limit int) ([]store.WebhookDelivery, error) {
+	if _, err := store.GetWebhookByID(id); err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	deliveries := store.GetWebhookDeliveries(id)
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt > deliveries[j].CreatedAt
+	})
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// StartWebhookDeliveries 恢复服务重启前未完成的投递
+func StartWebhookDeliveries() {
+	count := 0
+	for _, d := range store.GetWebhookDeliveries("") {
+		if d.Status == store.WebhookDeliveryPending {
+			startWebhookDelivery(d.ID)
+			count++
+		}
+	}
+	if count > 0 {
+		log.Printf("[Webhook] 已恢复 %d 个未完成的投递", count)
+	}
+}
+
+// CleanupWebhookDeliveries 删除超过保留时间的已结束投递记录
+func CleanupWebhookDeliveries() {
+	count, err := store.DeleteFinishedWebhookDeliveries(time.Now().Add(-WebhookDeliveryRetention))
+	if err != nil {
+		log.Printf("[Webhook] 清理投递记录失败: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("[Webhook] 已清理 %d 条投递记录", count)
+	}
+}
+
+// newWebhookPayload 生成事件请求体，返回事件 ID 和 JSON
+func newWebhookPayload(event string, data interface{}) (string, string, error) {
+	evt := WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      event,
+		CreatedAt: store.NowString(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return "", "", err
+	}
+	return evt.ID, string(payload), nil
+}
+
+// newWebhookDelivery 创建等待投递的记录
+func newWebhookDelivery(webhookID, eventID, event, payload string, maxAttempts int) *store.WebhookDelivery {
+	return &store.WebhookDelivery{
+		ID:            uuid.New().String(),
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		Event:         event,
+		Payload:       payload,
+		Status:        store.WebhookDeliveryPending,
+		MaxAttempts:   maxAttempts,
+		NextAttemptAt: store.NowString(),
+	}
+}
+
+// startWebhookDelivery 为投递启动后台协程，同一投递只会有一个协程
+func startWebhookDelivery(id string) {
+	webhookRunsLock.Lock()
+	defer webhookRunsLock.Unlock()
+
+	if webhookRuns[id] {
+		return
+	}
+	webhookRuns[id] = true
+
+	go func() {
+		defer func() {
+			webhookRunsLock.Lock()
+			delete(webhookRuns, id)
+			webhookRunsLock.Unlock()
+		}()
+		runWebhookDelivery(id)
+	}()
+}
+
+// runWebhookDelivery 按计划时间投递，直到送达、重试次数用尽或 Webhook 被删除
+func runWebhookDelivery(id string) {
+	for {
+		d, err := store.GetWebhookDeliveryByID(id)
+		if err != nil || d.Status != store.WebhookDeliveryPending {
+			return
+		}
+		if next, err := time.Parse(time.RFC3339, d.NextAttemptAt); err == nil {
+			if wait := time.Until(next); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		// 等待期间 Webhook 可能已被删除或停用
+		hook, err := store.GetWebhookByID(d.WebhookID)
+		if err != nil {
+			return
+		}
+		if d, err = store.GetWebhookDeliveryByID(id); err != nil || d.Status != store.WebhookDeliveryPending {
+			return
+		}
+		if !hook.IsActive {
+			d.Status = store.WebhookDeliveryFailed
+			d.Error = "Webhook 已停用"
+			d.FinishedAt = store.NowString()
+			if err := store.UpdateWebhookDelivery(d); err != nil {
+				log.Printf("[Webhook] 更新投递记录失败 (id=%s): %v", id, err)
+			}
+			return
+		}
+
+		webhookSlots <- struct{}{}
+		code, sendErr := sendWebhook(context.Background(), hook, d)
+		<-webhookSlots
+
+		recordWebhookAttempt(d, code, sendErr)
+		if err := store.UpdateWebhookDelivery(d); err != nil {
+			log.Printf("[Webhook] 更新投递记录失败 (id=%s): %v", id, err)
+			return
+		}
+		if sendErr != nil {
+			log.Printf("[Webhook] 第 %d 次投递 %s 到 %s 失败: %v", d.Attempts, d.Event, hook.Name, sendErr)
+		}
+	}
+}
+
+// recordWebhookAttempt 记录一次投递的结果，失败且未用尽次数时安排下次重试
+func recordWebhookAttempt(d *store.WebhookDelivery, code int, err error) {
+	d.Attempts++
+	d.ResponseCode = code
+	switch {
+	case err == nil:
+		d.Status = store.WebhookDeliverySucceeded
+		d.Error = ""
+		d.NextAttemptAt = ""
+		d.FinishedAt = store.NowString()
+	case d.Attempts >= d.MaxAttempts:
+		d.Status = store.WebhookDeliveryFailed
+		d.Error = err.Error()
+		d.NextAttemptAt = ""
+		d.FinishedAt = store.NowString()
+	default:
+		d.Error = err.Error()
+		d.NextAttemptAt = time.Now().Add(webhookRetryDelay(d.Attempts)).UTC().Format(time.RFC3339)
+	}
+}
+
+// webhookRetryDelay 第 attempts 次失败后的等待时间：30 秒起每次翻倍，最长 1 小时
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < attempts && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookRetryMax)
+}
+
+// sendWebhook 发送一次投递，返回响应状态码，接收端返回 2xx 视为成功
+func sendWebhook(ctx context.Context, hook *store.Webhook, d *store.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, strings.NewReader(d.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "FileFlow-Webhook")
+	req.Header.Set("X-FileFlow-Event", d.Event)
+	req.Header.Set("X-FileFlow-Delivery", d.ID)
+	req.Header.Set("X-FileFlow-Timestamp", timestamp)
+	req.Header.Set("X-FileFlow-Signature", "sha256="+SignWebhookPayload(hook.Secret, timestamp, d.Payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("接收端返回 %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload 计算签名：HMAC-SHA256(secret, timestamp + "." + 请求体)，十六进制
+// 签名包含时间戳，接收端可以拒绝时间相差过大的请求，防止重放
+func SignWebhookPayload(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
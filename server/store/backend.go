@@ -14,12 +14,59 @@ type Backend interface {
 	Init() error
 	// Load 加载全部数据到内存
 	Load() (*Data, error)
-	// Save 保存全部数据
+	// Save 保存全部数据（按行写入的表除外）
 	Save(data *Data) error
+	// SaveRows 逐行插入或替换按行写入的记录
+	// 记录类型为 UploadSession、FileObject、WebhookDelivery、FileMetadata、ReplicaSet 或 StripedFile
+	SaveRows(rows ...interface{}) error
+	// DeleteRows 按 ID 删除按行写入的记录
+	DeleteRows(table RowTable, ids ...string) error
 	// Close 关闭连接
 	Close() error
 }
 
+// RowTable 按行写入的数据表
+// 这些表的记录数随文件和上传数量增长且变更频繁，不参与 Save 的全量重写，由 SaveRows / DeleteRows 只写入变更的记录
+type RowTable string
+
+const (
+	TableUploadSessions    RowTable = "upload_sessions"
+	TableFileObjects       RowTable = "file_objects"
+	TableWebhookDeliveries RowTable = "webhook_deliveries"
+	TableFileMetadata      RowTable = "file_metadata"
+	TableReplicaSets       RowTable = "replica_sets"
+	TableStripedFiles      RowTable = "striped_files"
+)
+
+// validate 检查是否为按行写入的数据表（表名会拼接到 SQL 中）
+func (t RowTable) validate() error {
+	switch t {
+	case TableUploadSessions, TableFileObjects, TableWebhookDeliveries,
+		TableFileMetadata, TableReplicaSets, TableStripedFiles:
+		return nil
+	}
+	return fmt.Errorf("不支持按行写入的数据表: %s", t)
+}
+
+// rowTableOf 获取记录所属的数据表和 ID
+func rowTableOf(row interface{}) (RowTable, string, error) {
+	switch r := row.(type) {
+	case UploadSession:
+		return TableUploadSessions, r.ID, nil
+	case FileObject:
+		return TableFileObjects, r.ID, nil
+	case WebhookDelivery:
+		return TableWebhookDeliveries, r.ID, nil
+	case FileMetadata:
+		return TableFileMetadata, r.ID, nil
+	case ReplicaSet:
+		return TableReplicaSets, r.ID, nil
+	case StripedFile:
+		return TableStripedFiles, r.ID, nil
+	}
+	return "", "", fmt.Errorf("不支持按行写入的记录类型: %T", row)
+}
+
 // BackendType 数据库类型
 type BackendType string
 
@@ -98,3 +145,8 @@ func NewBackend() (Backend, error) {
 		return nil, fmt.Errorf("不支持的数据库类型: %s", backendType)
 	}
 }
+
+// isDuplicateColumnError 判断 ALTER TABLE ADD COLUMN 是否因列已存在而失败
+func isDuplicateColumnError(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "duplicate column")
+}
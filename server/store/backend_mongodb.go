@@ -17,6 +17,16 @@ const (
 	mongoSettingsColl          = "settings"
 	mongoWebDAVCredentialsColl = "webdav_credentials"
 	mongoFileExpirationsColl   = "file_expirations"
+	mongoUploadSessionsColl    = "upload_sessions"
+	mongoPresignedUploadsColl  = "presigned_uploads"
+	mongoFileObjectsColl       = "file_objects"
+	mongoImportJobsColl        = "import_jobs"
+	mongoWebhooksColl          = "webhooks"
+	mongoWebhookDeliveriesColl = "webhook_deliveries"
+	mongoFileMetadataColl      = "file_metadata"
+	mongoReplicaSetsColl       = "replica_sets"
+	mongoStripedFilesColl      = "striped_files"
+	mongoPoolsColl             = "pools"
 )
 
 // MongoBackend MongoDB 数据库后端
@@ -43,6 +53,7 @@ type MongoAccount struct {
 	Quota           struct {
 		MaxSizeBytes int64 `bson:"maxSizeBytes"`
 		MaxClassAOps int64 `bson:"maxClassAOps"`
+		MaxClassBOps int64 `bson:"maxClassBOps"`
 	} `bson:"quota"`
 	Usage struct {
 		SizeBytes  int64  `bson:"sizeBytes"`
@@ -56,17 +67,31 @@ type MongoAccount struct {
 		APIUpload    bool `bson:"apiUpload"`
 		ClientUpload bool `bson:"clientUpload"`
 	} `bson:"permissions"`
-	CreatedAt string `bson:"createdAt"`
-	UpdatedAt string `bson:"updatedAt"`
+	LinkMode           string        `bson:"linkMode"`
+	PresignTTL         int           `bson:"presignTtl"`
+	KeyTemplate        string        `bson:"keyTemplate"`
+	StripImageMetadata bool          `bson:"stripImageMetadata"`
+	Encryption         bool          `bson:"encryption"`
+	Weight             int           `bson:"weight"`
+	UsageHistory       []UsageSample `bson:"usageHistory"`
+	Provider           string        `bson:"provider"`
+	Region             string        `bson:"region"`
+	PathStyle          bool          `bson:"pathStyle"`
+	CreatedAt          string        `bson:"createdAt"`
+	UpdatedAt          string        `bson:"updatedAt"`
 }
 
 // MongoToken MongoDB 中的 Token 文档结构
 type MongoToken struct {
-	ID          string   `bson:"_id"`
-	Name        string   `bson:"name"`
-	Token       string   `bson:"token"`
-	Permissions []string `bson:"permissions"`
-	CreatedAt   string   `bson:"createdAt"`
+	ID                 string   `bson:"_id"`
+	Name               string   `bson:"name"`
+	Token              string   `bson:"token"`
+	Permissions        []string `bson:"permissions"`
+	KeyTemplate        string   `bson:"keyTemplate"`
+	StripImageMetadata bool     `bson:"stripImageMetadata"`
+	PlacementStrategy  string   `bson:"placementStrategy"`
+	Pools              []string `bson:"pools"`
+	CreatedAt          string   `bson:"createdAt"`
 }
 
 // MongoWebDAVCredential MongoDB 中的 WebDAVCredential 文档结构
@@ -87,10 +112,171 @@ type MongoFileExpiration struct {
 	ID        string `bson:"_id"`
 	AccountID string `bson:"accountId"`
 	FileKey   string `bson:"fileKey"`
+	Size      int64  `bson:"size"`
 	ExpiresAt string `bson:"expiresAt"`
 	CreatedAt string `bson:"createdAt"`
 }
 
+// MongoUploadSession MongoDB 中的 UploadSession 文档结构
+type MongoUploadSession struct {
+	ID             string       `bson:"_id"`
+	AccountID      string       `bson:"accountId"`
+	FileKey        string       `bson:"fileKey"`
+	UploadID       string       `bson:"uploadId"`
+	Size           int64        `bson:"size"`
+	Offset         int64        `bson:"offset"`
+	PartSize       int64        `bson:"partSize"`
+	Parts          []UploadPart `bson:"parts"`
+	ContentType    string       `bson:"contentType"`
+	FileName       string       `bson:"fileName"`
+	ExpirationDays int          `bson:"expirationDays"`
+	TokenID        string       `bson:"tokenId"`
+	Completed      bool         `bson:"completed"`
+	ExpiresAt      string       `bson:"expiresAt"`
+	CreatedAt      string       `bson:"createdAt"`
+	UpdatedAt      string       `bson:"updatedAt"`
+}
+
+// MongoPresignedUpload MongoDB 中的 PresignedUpload 文档结构
+type MongoPresignedUpload struct {
+	ID             string `bson:"_id"`
+	AccountID      string `bson:"accountId"`
+	FileKey        string `bson:"fileKey"`
+	UploadID       string `bson:"uploadId"`
+	Size           int64  `bson:"size"`
+	PartSize       int64  `bson:"partSize"`
+	ContentType    string `bson:"contentType"`
+	FileName       string `bson:"fileName"`
+	ExpirationDays int    `bson:"expirationDays"`
+	TokenID        string `bson:"tokenId"`
+	ExpiresAt      string `bson:"expiresAt"`
+	CreatedAt      string `bson:"createdAt"`
+}
+
+// MongoFileObject MongoDB 中的 FileObject 文档结构
+type MongoFileObject struct {
+	ID        string `bson:"_id"`
+	Hash      string `bson:"hash"`
+	Size      int64  `bson:"size"`
+	AccountID string `bson:"accountId"`
+	FileKey   string `bson:"fileKey"`
+	ETag      string `bson:"etag"`
+	RefCount  int    `bson:"refCount"`
+	CreatedAt string `bson:"createdAt"`
+	UpdatedAt string `bson:"updatedAt"`
+}
+
+// MongoImportJob MongoDB 中的 ImportJob 文档结构
+type MongoImportJob struct {
+	ID              string `bson:"_id"`
+	URL             string `bson:"url"`
+	Status          string `bson:"status"`
+	AccountID       string `bson:"accountId"`
+	Pool            string `bson:"pool"`
+	Path            string `bson:"path"`
+	ExpirationDays  int    `bson:"expirationDays"`
+	TokenID         string `bson:"tokenId"`
+	Uploader        string `bson:"uploader"`
+	Attempts        int    `bson:"attempts"`
+	MaxAttempts     int    `bson:"maxAttempts"`
+	TotalBytes      int64  `bson:"totalBytes"`
+	DownloadedBytes int64  `bson:"downloadedBytes"`
+	UploadedBytes   int64  `bson:"uploadedBytes"`
+	TargetAccountID string `bson:"targetAccountId"`
+	Result          string `bson:"result"`
+	Error           string `bson:"error"`
+	CreatedAt       string `bson:"createdAt"`
+	UpdatedAt       string `bson:"updatedAt"`
+	FinishedAt      string `bson:"finishedAt"`
+}
+
+// MongoWebhook MongoDB 中的 Webhook 文档结构
+type MongoWebhook struct {
+	ID        string   `bson:"_id"`
+	Name      string   `bson:"name"`
+	URL       string   `bson:"url"`
+	Secret    string   `bson:"secret"`
+	Events    []string `bson:"events"`
+	IsActive  bool     `bson:"isActive"`
+	CreatedAt string   `bson:"createdAt"`
+	UpdatedAt string   `bson:"updatedAt"`
+}
+
+// MongoWebhookDelivery MongoDB 中的 WebhookDelivery 文档结构
+type MongoWebhookDelivery struct {
+	ID            string `bson:"_id"`
+	WebhookID     string `bson:"webhookId"`
+	EventID       string `bson:"eventId"`
+	Event         string `bson:"event"`
+	Payload       string `bson:"payload"`
+	Status        string `bson:"status"`
+	Attempts      int    `bson:"attempts"`
+	MaxAttempts   int    `bson:"maxAttempts"`
+	ResponseCode  int    `bson:"responseCode"`
+	Error         string `bson:"error"`
+	NextAttemptAt string `bson:"nextAttemptAt"`
+	CreatedAt     string `bson:"createdAt"`
+	UpdatedAt     string `bson:"updatedAt"`
+	FinishedAt    string `bson:"finishedAt"`
+}
+
+// MongoFileMetadata MongoDB 中的 FileMetadata 文档结构
+type MongoFileMetadata struct {
+	ID        string            `bson:"_id"`
+	AccountID string            `bson:"accountId"`
+	FileKey   string            `bson:"fileKey"`
+	Size      int64             `bson:"size"`
+	Metadata  map[string]string `bson:"metadata"`
+	Tags      []string          `bson:"tags"`
+	CreatedAt string            `bson:"createdAt"`
+	UpdatedAt string            `bson:"updatedAt"`
+}
+
+// MongoReplicaSet MongoDB 中的 ReplicaSet 文档结构
+type MongoReplicaSet struct {
+	ID            string   `bson:"_id"`
+	AccountID     string   `bson:"accountId"`
+	FileKey       string   `bson:"fileKey"`
+	Size          int64    `bson:"size"`
+	SHA256        string   `bson:"sha256"`
+	Factor        int      `bson:"factor"`
+	Replicas      []string `bson:"replicas"`
+	Pool          string   `bson:"pool"`
+	Status        string   `bson:"status"`
+	LastError     string   `bson:"lastError"`
+	LastCheckedAt string   `bson:"lastCheckedAt"`
+	CreatedAt     string   `bson:"createdAt"`
+	UpdatedAt     string   `bson:"updatedAt"`
+}
+
+// MongoStripedFile MongoDB 中的 StripedFile 文档结构
+type MongoStripedFile struct {
+	ID           string         `bson:"_id"`
+	AccountID    string         `bson:"accountId"`
+	FileKey      string         `bson:"fileKey"`
+	OriginalName string         `bson:"originalName"`
+	ContentType  string         `bson:"contentType"`
+	Size         int64          `bson:"size"`
+	SHA256       string         `bson:"sha256"`
+	CRC32C       string         `bson:"crc32c"`
+	Chunks       []StripedChunk `bson:"chunks"`
+	Uploader     string         `bson:"uploader"`
+	CreatedAt    string         `bson:"createdAt"`
+	UpdatedAt    string         `bson:"updatedAt"`
+}
+
+// MongoPool MongoDB 中的 Pool 文档结构
+type MongoPool struct {
+	ID                string       `bson:"_id"`
+	Name              string       `bson:"name"`
+	Description       string       `bson:"description"`
+	Members           []PoolMember `bson:"members"`
+	PlacementStrategy string       `bson:"placementStrategy"`
+	ExpirationDays    int          `bson:"expirationDays"`
+	CreatedAt         string       `bson:"createdAt"`
+	UpdatedAt         string       `bson:"updatedAt"`
+}
+
 // NewMongoBackend 创建 MongoDB 后端
 func NewMongoBackend(connStr string) (*MongoBackend, error) {
 	return &MongoBackend{
@@ -164,6 +350,16 @@ func (b *MongoBackend) Load() (*Data, error) {
 		Tokens:            []Token{},
 		WebDAVCredentials: []WebDAVCredential{},
 		FileExpirations:   []FileExpiration{},
+		Pools:             []Pool{},
+		StripedFiles:      []StripedFile{},
+		ReplicaSets:       []ReplicaSet{},
+		FileMetadata:      []FileMetadata{},
+		WebhookDeliveries: []WebhookDelivery{},
+		Webhooks:          []Webhook{},
+		ImportJobs:        []ImportJob{},
+		FileObjects:       []FileObject{},
+		PresignedUploads:  []PresignedUpload{},
+		UploadSessions:    []UploadSession{},
 	}
 
 	// 加载 accounts
@@ -194,6 +390,7 @@ func (b *MongoBackend) Load() (*Data, error) {
 			Quota: Quota{
 				MaxSizeBytes: doc.Quota.MaxSizeBytes,
 				MaxClassAOps: doc.Quota.MaxClassAOps,
+				MaxClassBOps: doc.Quota.MaxClassBOps,
 			},
 			Usage: Usage{
 				SizeBytes:  doc.Usage.SizeBytes,
@@ -207,8 +404,18 @@ func (b *MongoBackend) Load() (*Data, error) {
 				APIUpload:    doc.Permissions.APIUpload,
 				ClientUpload: doc.Permissions.ClientUpload,
 			},
-			CreatedAt: doc.CreatedAt,
-			UpdatedAt: doc.UpdatedAt,
+			LinkMode:           doc.LinkMode,
+			PresignTTL:         doc.PresignTTL,
+			KeyTemplate:        doc.KeyTemplate,
+			StripImageMetadata: doc.StripImageMetadata,
+			Weight:             doc.Weight,
+			Encryption:         doc.Encryption,
+			UsageHistory:       doc.UsageHistory,
+			Provider:           doc.Provider,
+			Region:             doc.Region,
+			PathStyle:          doc.PathStyle,
+			CreatedAt:          doc.CreatedAt,
+			UpdatedAt:          doc.UpdatedAt,
 		}
 		// 对于旧数据，如果权限全为 false，则设置默认权限
 		if !acc.Permissions.WebDAV && !acc.Permissions.AutoUpload &&
@@ -232,11 +439,15 @@ func (b *MongoBackend) Load() (*Data, error) {
 			continue
 		}
 		t := Token{
-			ID:          doc.ID,
-			Name:        doc.Name,
-			Token:       doc.Token,
-			Permissions: doc.Permissions,
-			CreatedAt:   doc.CreatedAt,
+			ID:                 doc.ID,
+			Name:               doc.Name,
+			Token:              doc.Token,
+			Permissions:        doc.Permissions,
+			KeyTemplate:        doc.KeyTemplate,
+			StripImageMetadata: doc.StripImageMetadata,
+			PlacementStrategy:  doc.PlacementStrategy,
+			Pools:              doc.Pools,
+			CreatedAt:          doc.CreatedAt,
 		}
 		if t.Permissions == nil {
 			t.Permissions = []string{}
@@ -300,6 +511,148 @@ func (b *MongoBackend) Load() (*Data, error) {
 		data.Settings.ExpirationCheckMinutes = 720
 	}
 
+	var dedupEnabledDoc struct {
+		Key   string `bson:"_id"`
+		Value bool   `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "dedup_enabled"}).Decode(&dedupEnabledDoc)
+	if err == nil {
+		data.Settings.DedupEnabled = dedupEnabledDoc.Value
+	} else {
+		data.Settings.DedupEnabled = true
+	}
+
+	var keyTemplateDoc struct {
+		Key   string `bson:"_id"`
+		Value string `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "key_template"}).Decode(&keyTemplateDoc)
+	if err == nil {
+		data.Settings.KeyTemplate = keyTemplateDoc.Value
+	}
+
+	var keyCollisionDoc struct {
+		Key   string `bson:"_id"`
+		Value string `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "key_collision"}).Decode(&keyCollisionDoc)
+	if err == nil {
+		data.Settings.KeyCollision = keyCollisionDoc.Value
+	} else {
+		data.Settings.KeyCollision = KeyCollisionSuffix
+	}
+
+	var urlAllowedHostsDoc struct {
+		Key   string `bson:"_id"`
+		Value string `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "url_allowed_hosts"}).Decode(&urlAllowedHostsDoc)
+	if err == nil {
+		data.Settings.URLAllowedHosts = urlAllowedHostsDoc.Value
+	}
+
+	var urlMaxDownloadMbDoc struct {
+		Key   string `bson:"_id"`
+		Value int    `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "url_max_download_mb"}).Decode(&urlMaxDownloadMbDoc)
+	if err == nil {
+		data.Settings.URLMaxDownloadMB = urlMaxDownloadMbDoc.Value
+	} else {
+		data.Settings.URLMaxDownloadMB = DefaultURLMaxDownloadMB
+	}
+
+	var urlMaxRedirectsDoc struct {
+		Key   string `bson:"_id"`
+		Value int    `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "url_max_redirects"}).Decode(&urlMaxRedirectsDoc)
+	if err == nil {
+		data.Settings.URLMaxRedirects = urlMaxRedirectsDoc.Value
+	} else {
+		data.Settings.URLMaxRedirects = DefaultURLMaxRedirects
+	}
+
+	var urlAllowedTypesDoc struct {
+		Key   string `bson:"_id"`
+		Value string `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "url_allowed_types"}).Decode(&urlAllowedTypesDoc)
+	if err == nil {
+		data.Settings.URLAllowedTypes = urlAllowedTypesDoc.Value
+	}
+
+	var urlDeniedTypesDoc struct {
+		Key   string `bson:"_id"`
+		Value string `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "url_denied_types"}).Decode(&urlDeniedTypesDoc)
+	if err == nil {
+		data.Settings.URLDeniedTypes = urlDeniedTypesDoc.Value
+	}
+
+	var thumbnailEnabledDoc struct {
+		Key   string `bson:"_id"`
+		Value bool   `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "thumbnail_enabled"}).Decode(&thumbnailEnabledDoc)
+	if err == nil {
+		data.Settings.ThumbnailEnabled = thumbnailEnabledDoc.Value
+	}
+
+	var thumbnailSizesDoc struct {
+		Key   string `bson:"_id"`
+		Value string `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "thumbnail_sizes"}).Decode(&thumbnailSizesDoc)
+	if err == nil {
+		data.Settings.ThumbnailSizes = thumbnailSizesDoc.Value
+	} else {
+		data.Settings.ThumbnailSizes = DefaultThumbnailSizes
+	}
+
+	var thumbnailQualityDoc struct {
+		Key   string `bson:"_id"`
+		Value int    `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "thumbnail_quality"}).Decode(&thumbnailQualityDoc)
+	if err == nil {
+		data.Settings.ThumbnailQuality = thumbnailQualityDoc.Value
+	} else {
+		data.Settings.ThumbnailQuality = DefaultThumbnailQuality
+	}
+
+	var replicationFactorDoc struct {
+		Key   string `bson:"_id"`
+		Value int    `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "replication_factor"}).Decode(&replicationFactorDoc)
+	if err == nil {
+		data.Settings.ReplicationFactor = replicationFactorDoc.Value
+	} else {
+		data.Settings.ReplicationFactor = DefaultReplicationFactor
+	}
+
+	var opsAlertPercentDoc struct {
+		Key   string `bson:"_id"`
+		Value int    `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "ops_alert_percent"}).Decode(&opsAlertPercentDoc)
+	if err == nil {
+		data.Settings.OpsAlertPercent = opsAlertPercentDoc.Value
+	} else {
+		data.Settings.OpsAlertPercent = DefaultOpsAlertPercent
+	}
+
+	var placementStrategyDoc struct {
+		Key   string `bson:"_id"`
+		Value string `bson:"value"`
+	}
+	err = settingsColl.FindOne(b.ctx, bson.M{"_id": "placement_strategy"}).Decode(&placementStrategyDoc)
+	if err == nil {
+		data.Settings.PlacementStrategy = placementStrategyDoc.Value
+	}
+
 	// 加载 webdav_credentials
 	webdavCredsColl := b.db.Collection(mongoWebDAVCredentialsColl)
 	cursor, err = webdavCredsColl.Find(b.ctx, bson.M{})
@@ -347,16 +700,177 @@ func (b *MongoBackend) Load() (*Data, error) {
 			ID:        doc.ID,
 			AccountID: doc.AccountID,
 			FileKey:   doc.FileKey,
+			Size:      doc.Size,
 			ExpiresAt: doc.ExpiresAt,
 			CreatedAt: doc.CreatedAt,
 		}
 		data.FileExpirations = append(data.FileExpirations, exp)
 	}
 
+	// 加载 upload_sessions
+	uploadSessionsColl := b.db.Collection(mongoUploadSessionsColl)
+	cursor, err = uploadSessionsColl.Find(b.ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("查询 upload_sessions 失败: %w", err)
+	}
+	defer cursor.Close(b.ctx)
+
+	for cursor.Next(b.ctx) {
+		var doc MongoUploadSession
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		data.UploadSessions = append(data.UploadSessions, UploadSession(doc))
+	}
+
+	// 加载 presigned_uploads
+	presignedUploadsColl := b.db.Collection(mongoPresignedUploadsColl)
+	cursor, err = presignedUploadsColl.Find(b.ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("查询 presigned_uploads 失败: %w", err)
+	}
+	defer cursor.Close(b.ctx)
+
+	for cursor.Next(b.ctx) {
+		var doc MongoPresignedUpload
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		data.PresignedUploads = append(data.PresignedUploads, PresignedUpload(doc))
+	}
+
+	// 加载 file_objects
+	fileObjectsColl := b.db.Collection(mongoFileObjectsColl)
+	cursor, err = fileObjectsColl.Find(b.ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("查询 file_objects 失败: %w", err)
+	}
+	defer cursor.Close(b.ctx)
+
+	for cursor.Next(b.ctx) {
+		var doc MongoFileObject
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		data.FileObjects = append(data.FileObjects, FileObject(doc))
+	}
+
+	// 加载 import_jobs
+	importJobsColl := b.db.Collection(mongoImportJobsColl)
+	cursor, err = importJobsColl.Find(b.ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("查询 import_jobs 失败: %w", err)
+	}
+	defer cursor.Close(b.ctx)
+
+	for cursor.Next(b.ctx) {
+		var doc MongoImportJob
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		data.ImportJobs = append(data.ImportJobs, ImportJob(doc))
+	}
+
+	// 加载 webhooks
+	webhooksColl := b.db.Collection(mongoWebhooksColl)
+	cursor, err = webhooksColl.Find(b.ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("查询 webhooks 失败: %w", err)
+	}
+	defer cursor.Close(b.ctx)
+
+	for cursor.Next(b.ctx) {
+		var doc MongoWebhook
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		data.Webhooks = append(data.Webhooks, Webhook(doc))
+	}
+
+	// 加载 webhook_deliveries
+	webhookDeliveriesColl := b.db.Collection(mongoWebhookDeliveriesColl)
+	cursor, err = webhookDeliveriesColl.Find(b.ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("查询 webhook_deliveries 失败: %w", err)
+	}
+	defer cursor.Close(b.ctx)
+
+	for cursor.Next(b.ctx) {
+		var doc MongoWebhookDelivery
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		data.WebhookDeliveries = append(data.WebhookDeliveries, WebhookDelivery(doc))
+	}
+
+	// 加载 file_metadata
+	fileMetadataColl := b.db.Collection(mongoFileMetadataColl)
+	cursor, err = fileMetadataColl.Find(b.ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("查询 file_metadata 失败: %w", err)
+	}
+	defer cursor.Close(b.ctx)
+
+	for cursor.Next(b.ctx) {
+		var doc MongoFileMetadata
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		data.FileMetadata = append(data.FileMetadata, FileMetadata(doc))
+	}
+
+	// 加载 replica_sets
+	replicaSetsColl := b.db.Collection(mongoReplicaSetsColl)
+	cursor, err = replicaSetsColl.Find(b.ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("查询 replica_sets 失败: %w", err)
+	}
+	defer cursor.Close(b.ctx)
+
+	for cursor.Next(b.ctx) {
+		var doc MongoReplicaSet
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		data.ReplicaSets = append(data.ReplicaSets, ReplicaSet(doc))
+	}
+
+	// 加载 striped_files
+	stripedFilesColl := b.db.Collection(mongoStripedFilesColl)
+	cursor, err = stripedFilesColl.Find(b.ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("查询 striped_files 失败: %w", err)
+	}
+	defer cursor.Close(b.ctx)
+
+	for cursor.Next(b.ctx) {
+		var doc MongoStripedFile
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		data.StripedFiles = append(data.StripedFiles, StripedFile(doc))
+	}
+
+	// 加载 pools
+	poolsColl := b.db.Collection(mongoPoolsColl)
+	cursor, err = poolsColl.Find(b.ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("查询 pools 失败: %w", err)
+	}
+	defer cursor.Close(b.ctx)
+
+	for cursor.Next(b.ctx) {
+		var doc MongoPool
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		data.Pools = append(data.Pools, Pool(doc))
+	}
+
 	return data, nil
 }
 
-// Save 保存全部数据到 MongoDB
+// Save 保存全部数据到 MongoDB（按行写入的表由 SaveRows 写入）
 func (b *MongoBackend) Save(data *Data) error {
 	// 使用事务（如果 MongoDB 支持）
 	session, err := b.client.StartSession()
@@ -391,9 +905,11 @@ func (b *MongoBackend) Save(data *Data) error {
 					Quota: struct {
 						MaxSizeBytes int64 `bson:"maxSizeBytes"`
 						MaxClassAOps int64 `bson:"maxClassAOps"`
+						MaxClassBOps int64 `bson:"maxClassBOps"`
 					}{
 						MaxSizeBytes: acc.Quota.MaxSizeBytes,
 						MaxClassAOps: acc.Quota.MaxClassAOps,
+						MaxClassBOps: acc.Quota.MaxClassBOps,
 					},
 					Usage: struct {
 						SizeBytes  int64  `bson:"sizeBytes"`
@@ -417,8 +933,18 @@ func (b *MongoBackend) Save(data *Data) error {
 						APIUpload:    acc.Permissions.APIUpload,
 						ClientUpload: acc.Permissions.ClientUpload,
 					},
-					CreatedAt: acc.CreatedAt,
-					UpdatedAt: acc.UpdatedAt,
+					LinkMode:           acc.LinkMode,
+					PresignTTL:         acc.PresignTTL,
+					KeyTemplate:        acc.KeyTemplate,
+					StripImageMetadata: acc.StripImageMetadata,
+					Weight:             acc.Weight,
+					Encryption:         acc.Encryption,
+					UsageHistory:       acc.UsageHistory,
+					Provider:           acc.Provider,
+					Region:             acc.Region,
+					PathStyle:          acc.PathStyle,
+					CreatedAt:          acc.CreatedAt,
+					UpdatedAt:          acc.UpdatedAt,
 				}
 			}
 			if _, err := accountsColl.InsertMany(sessCtx, docs); err != nil {
@@ -436,11 +962,15 @@ func (b *MongoBackend) Save(data *Data) error {
 			docs := make([]interface{}, len(data.Tokens))
 			for i, t := range data.Tokens {
 				docs[i] = MongoToken{
-					ID:          t.ID,
-					Name:        t.Name,
-					Token:       t.Token,
-					Permissions: t.Permissions,
-					CreatedAt:   t.CreatedAt,
+					ID:                 t.ID,
+					Name:               t.Name,
+					Token:              t.Token,
+					Permissions:        t.Permissions,
+					KeyTemplate:        t.KeyTemplate,
+					StripImageMetadata: t.StripImageMetadata,
+					PlacementStrategy:  t.PlacementStrategy,
+					Pools:              t.Pools,
+					CreatedAt:          t.CreatedAt,
 				}
 			}
 			if _, err := tokensColl.InsertMany(sessCtx, docs); err != nil {
@@ -490,6 +1020,118 @@ func (b *MongoBackend) Save(data *Data) error {
 			return nil, fmt.Errorf("保存 settings 失败: %w", err)
 		}
 
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "dedup_enabled"},
+			bson.M{"$set": bson.M{"value": data.Settings.DedupEnabled}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "key_template"},
+			bson.M{"$set": bson.M{"value": data.Settings.KeyTemplate}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "key_collision"},
+			bson.M{"$set": bson.M{"value": data.Settings.KeyCollision}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "url_allowed_hosts"},
+			bson.M{"$set": bson.M{"value": data.Settings.URLAllowedHosts}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "url_max_download_mb"},
+			bson.M{"$set": bson.M{"value": data.Settings.URLMaxDownloadMB}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "url_max_redirects"},
+			bson.M{"$set": bson.M{"value": data.Settings.URLMaxRedirects}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "url_allowed_types"},
+			bson.M{"$set": bson.M{"value": data.Settings.URLAllowedTypes}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "url_denied_types"},
+			bson.M{"$set": bson.M{"value": data.Settings.URLDeniedTypes}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "thumbnail_enabled"},
+			bson.M{"$set": bson.M{"value": data.Settings.ThumbnailEnabled}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "thumbnail_sizes"},
+			bson.M{"$set": bson.M{"value": data.Settings.ThumbnailSizes}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "thumbnail_quality"},
+			bson.M{"$set": bson.M{"value": data.Settings.ThumbnailQuality}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "replication_factor"},
+			bson.M{"$set": bson.M{"value": data.Settings.ReplicationFactor}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "ops_alert_percent"},
+			bson.M{"$set": bson.M{"value": data.Settings.OpsAlertPercent}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
+		_, err = settingsColl.UpdateOne(sessCtx,
+			bson.M{"_id": "placement_strategy"},
+			bson.M{"$set": bson.M{"value": data.Settings.PlacementStrategy}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("保存 settings 失败: %w", err)
+		}
+
 		// 清空并重新插入 webdav_credentials
 		webdavCredsColl := b.db.Collection(mongoWebDAVCredentialsColl)
 		if _, err := webdavCredsColl.DeleteMany(sessCtx, bson.M{}); err != nil {
@@ -529,6 +1171,7 @@ func (b *MongoBackend) Save(data *Data) error {
 					ID:        exp.ID,
 					AccountID: exp.AccountID,
 					FileKey:   exp.FileKey,
+					Size:      exp.Size,
 					ExpiresAt: exp.ExpiresAt,
 					CreatedAt: exp.CreatedAt,
 				}
@@ -538,6 +1181,22 @@ func (b *MongoBackend) Save(data *Data) error {
 			}
 		}
 
+		if err := b.savePresignedUploads(sessCtx, data); err != nil {
+			return nil, err
+		}
+
+		if err := b.saveImportJobs(sessCtx, data); err != nil {
+			return nil, err
+		}
+
+		if err := b.saveWebhooks(sessCtx, data); err != nil {
+			return nil, err
+		}
+
+		if err := b.savePools(sessCtx, data); err != nil {
+			return nil, err
+		}
+
 		return nil, nil
 	})
 
@@ -570,9 +1229,11 @@ func (b *MongoBackend) saveWithoutTransaction(data *Data) error {
 				Quota: struct {
 					MaxSizeBytes int64 `bson:"maxSizeBytes"`
 					MaxClassAOps int64 `bson:"maxClassAOps"`
+					MaxClassBOps int64 `bson:"maxClassBOps"`
 				}{
 					MaxSizeBytes: acc.Quota.MaxSizeBytes,
 					MaxClassAOps: acc.Quota.MaxClassAOps,
+					MaxClassBOps: acc.Quota.MaxClassBOps,
 				},
 				Usage: struct {
 					SizeBytes  int64  `bson:"sizeBytes"`
@@ -596,8 +1257,18 @@ func (b *MongoBackend) saveWithoutTransaction(data *Data) error {
 					APIUpload:    acc.Permissions.APIUpload,
 					ClientUpload: acc.Permissions.ClientUpload,
 				},
-				CreatedAt: acc.CreatedAt,
-				UpdatedAt: acc.UpdatedAt,
+				LinkMode:           acc.LinkMode,
+				PresignTTL:         acc.PresignTTL,
+				KeyTemplate:        acc.KeyTemplate,
+				StripImageMetadata: acc.StripImageMetadata,
+				Weight:             acc.Weight,
+				Encryption:         acc.Encryption,
+				UsageHistory:       acc.UsageHistory,
+				Provider:           acc.Provider,
+				Region:             acc.Region,
+				PathStyle:          acc.PathStyle,
+				CreatedAt:          acc.CreatedAt,
+				UpdatedAt:          acc.UpdatedAt,
 			}
 		}
 		if _, err := accountsColl.InsertMany(b.ctx, docs); err != nil {
@@ -615,11 +1286,15 @@ func (b *MongoBackend) saveWithoutTransaction(data *Data) error {
 		docs := make([]interface{}, len(data.Tokens))
 		for i, t := range data.Tokens {
 			docs[i] = MongoToken{
-				ID:          t.ID,
-				Name:        t.Name,
-				Token:       t.Token,
-				Permissions: t.Permissions,
-				CreatedAt:   t.CreatedAt,
+				ID:                 t.ID,
+				Name:               t.Name,
+				Token:              t.Token,
+				Permissions:        t.Permissions,
+				KeyTemplate:        t.KeyTemplate,
+				StripImageMetadata: t.StripImageMetadata,
+				PlacementStrategy:  t.PlacementStrategy,
+				Pools:              t.Pools,
+				CreatedAt:          t.CreatedAt,
 			}
 		}
 		if _, err := tokensColl.InsertMany(b.ctx, docs); err != nil {
@@ -669,6 +1344,118 @@ func (b *MongoBackend) saveWithoutTransaction(data *Data) error {
 		return fmt.Errorf("保存 settings 失败: %w", err)
 	}
 
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "dedup_enabled"},
+		bson.M{"$set": bson.M{"value": data.Settings.DedupEnabled}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "key_template"},
+		bson.M{"$set": bson.M{"value": data.Settings.KeyTemplate}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "key_collision"},
+		bson.M{"$set": bson.M{"value": data.Settings.KeyCollision}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "url_allowed_hosts"},
+		bson.M{"$set": bson.M{"value": data.Settings.URLAllowedHosts}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "url_max_download_mb"},
+		bson.M{"$set": bson.M{"value": data.Settings.URLMaxDownloadMB}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "url_max_redirects"},
+		bson.M{"$set": bson.M{"value": data.Settings.URLMaxRedirects}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "url_allowed_types"},
+		bson.M{"$set": bson.M{"value": data.Settings.URLAllowedTypes}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "url_denied_types"},
+		bson.M{"$set": bson.M{"value": data.Settings.URLDeniedTypes}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "thumbnail_enabled"},
+		bson.M{"$set": bson.M{"value": data.Settings.ThumbnailEnabled}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "thumbnail_sizes"},
+		bson.M{"$set": bson.M{"value": data.Settings.ThumbnailSizes}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "thumbnail_quality"},
+		bson.M{"$set": bson.M{"value": data.Settings.ThumbnailQuality}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "replication_factor"},
+		bson.M{"$set": bson.M{"value": data.Settings.ReplicationFactor}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "ops_alert_percent"},
+		bson.M{"$set": bson.M{"value": data.Settings.OpsAlertPercent}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = settingsColl.UpdateOne(b.ctx,
+		bson.M{"_id": "placement_strategy"},
+		bson.M{"$set": bson.M{"value": data.Settings.PlacementStrategy}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
 	// 清空并重新插入 webdav_credentials
 	webdavCredsColl := b.db.Collection(mongoWebDAVCredentialsColl)
 	if _, err := webdavCredsColl.DeleteMany(b.ctx, bson.M{}); err != nil {
@@ -708,6 +1495,7 @@ func (b *MongoBackend) saveWithoutTransaction(data *Data) error {
 				ID:        exp.ID,
 				AccountID: exp.AccountID,
 				FileKey:   exp.FileKey,
+				Size:      exp.Size,
 				ExpiresAt: exp.ExpiresAt,
 				CreatedAt: exp.CreatedAt,
 			}
@@ -717,6 +1505,160 @@ func (b *MongoBackend) saveWithoutTransaction(data *Data) error {
 		}
 	}
 
+	if err := b.savePresignedUploads(b.ctx, data); err != nil {
+		return err
+	}
+
+	if err := b.saveImportJobs(b.ctx, data); err != nil {
+		return err
+	}
+
+	if err := b.saveWebhooks(b.ctx, data); err != nil {
+		return err
+	}
+
+	if err := b.savePools(b.ctx, data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// savePresignedUploads 清空并重新插入 presigned_uploads
+func (b *MongoBackend) savePresignedUploads(ctx context.Context, data *Data) error {
+	presignedUploadsColl := b.db.Collection(mongoPresignedUploadsColl)
+	if _, err := presignedUploadsColl.DeleteMany(ctx, bson.M{}); err != nil {
+		return fmt.Errorf("清空 presigned_uploads 失败: %w", err)
+	}
+
+	if len(data.PresignedUploads) > 0 {
+		docs := make([]interface{}, len(data.PresignedUploads))
+		for i, pu := range data.PresignedUploads {
+			docs[i] = MongoPresignedUpload(pu)
+		}
+		if _, err := presignedUploadsColl.InsertMany(ctx, docs); err != nil {
+			return fmt.Errorf("插入 presigned_uploads 失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// saveImportJobs 清空并重新插入 import_jobs
+func (b *MongoBackend) saveImportJobs(ctx context.Context, data *Data) error {
+	importJobsColl := b.db.Collection(mongoImportJobsColl)
+	if _, err := importJobsColl.DeleteMany(ctx, bson.M{}); err != nil {
+		return fmt.Errorf("清空 import_jobs 失败: %w", err)
+	}
+
+	if len(data.ImportJobs) > 0 {
+		docs := make([]interface{}, len(data.ImportJobs))
+		for i, job := range data.ImportJobs {
+			docs[i] = MongoImportJob(job)
+		}
+		if _, err := importJobsColl.InsertMany(ctx, docs); err != nil {
+			return fmt.Errorf("插入 import_jobs 失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// saveWebhooks 清空并重新插入 webhooks
+func (b *MongoBackend) saveWebhooks(ctx context.Context, data *Data) error {
+	webhooksColl := b.db.Collection(mongoWebhooksColl)
+	if _, err := webhooksColl.DeleteMany(ctx, bson.M{}); err != nil {
+		return fmt.Errorf("清空 webhooks 失败: %w", err)
+	}
+
+	if len(data.Webhooks) > 0 {
+		docs := make([]interface{}, len(data.Webhooks))
+		for i, hook := range data.Webhooks {
+			docs[i] = MongoWebhook(hook)
+		}
+		if _, err := webhooksColl.InsertMany(ctx, docs); err != nil {
+			return fmt.Errorf("插入 webhooks 失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// savePools 清空并重新插入 pools
+func (b *MongoBackend) savePools(ctx context.Context, data *Data) error {
+	poolsColl := b.db.Collection(mongoPoolsColl)
+	if _, err := poolsColl.DeleteMany(ctx, bson.M{}); err != nil {
+		return fmt.Errorf("清空 pools 失败: %w", err)
+	}
+
+	if len(data.Pools) > 0 {
+		docs := make([]interface{}, len(data.Pools))
+		for i, pool := range data.Pools {
+			docs[i] = MongoPool(pool)
+		}
+		if _, err := poolsColl.InsertMany(ctx, docs); err != nil {
+			return fmt.Errorf("插入 pools 失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// mongoRowCollections 按行写入的数据表对应的集合
+var mongoRowCollections = map[RowTable]string{
+	TableUploadSessions:    mongoUploadSessionsColl,
+	TableFileObjects:       mongoFileObjectsColl,
+	TableWebhookDeliveries: mongoWebhookDeliveriesColl,
+	TableFileMetadata:      mongoFileMetadataColl,
+	TableReplicaSets:       mongoReplicaSetsColl,
+	TableStripedFiles:      mongoStripedFilesColl,
+}
+
+// SaveRows 逐行插入或替换按行写入的记录
+func (b *MongoBackend) SaveRows(rows ...interface{}) error {
+	for _, row := range rows {
+		table, id, err := rowTableOf(row)
+		if err != nil {
+			return err
+		}
+
+		var doc interface{}
+		switch r := row.(type) {
+		case UploadSession:
+			doc = MongoUploadSession(r)
+		case FileObject:
+			doc = MongoFileObject(r)
+		case WebhookDelivery:
+			doc = MongoWebhookDelivery(r)
+		case FileMetadata:
+			doc = MongoFileMetadata(r)
+		case ReplicaSet:
+			doc = MongoReplicaSet(r)
+		case StripedFile:
+			doc = MongoStripedFile(r)
+		}
+
+		coll := b.db.Collection(mongoRowCollections[table])
+		if _, err := coll.ReplaceOne(b.ctx, bson.M{"_id": id}, doc, options.Replace().SetUpsert(true)); err != nil {
+			return fmt.Errorf("保存 %s 记录失败: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// DeleteRows 按 ID 删除按行写入的记录
+func (b *MongoBackend) DeleteRows(table RowTable, ids ...string) error {
+	if err := table.validate(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	coll := b.db.Collection(mongoRowCollections[table])
+	if _, err := coll.DeleteMany(b.ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return fmt.Errorf("删除 %s 记录失败: %w", table, err)
+	}
 	return nil
 }
 
@@ -14,6 +14,16 @@ const (
 	redisSettingsKey          = "fileflow:settings"
 	redisWebDAVCredentialsKey = "fileflow:webdav_credentials"
 	redisFileExpirationsKey   = "fileflow:file_expirations"
+	redisUploadSessionsKey    = "fileflow:upload_sessions"
+	redisPresignedUploadsKey  = "fileflow:presigned_uploads"
+	redisFileObjectsKey       = "fileflow:file_objects"
+	redisImportJobsKey        = "fileflow:import_jobs"
+	redisWebhooksKey          = "fileflow:webhooks"
+	redisWebhookDeliveriesKey = "fileflow:webhook_deliveries"
+	redisFileMetadataKey      = "fileflow:file_metadata"
+	redisReplicaSetsKey       = "fileflow:replica_sets"
+	redisStripedFilesKey      = "fileflow:striped_files"
+	redisPoolsKey             = "fileflow:pools"
 )
 
 // RedisBackend Redis 数据库后端
@@ -55,6 +65,16 @@ func (b *RedisBackend) Load() (*Data, error) {
 		Tokens:            []Token{},
 		WebDAVCredentials: []WebDAVCredential{},
 		FileExpirations:   []FileExpiration{},
+		Pools:             []Pool{},
+		StripedFiles:      []StripedFile{},
+		ReplicaSets:       []ReplicaSet{},
+		FileMetadata:      []FileMetadata{},
+		WebhookDeliveries: []WebhookDelivery{},
+		Webhooks:          []Webhook{},
+		ImportJobs:        []ImportJob{},
+		FileObjects:       []FileObject{},
+		PresignedUploads:  []PresignedUpload{},
+		UploadSessions:    []UploadSession{},
 	}
 
 	// 加载 accounts
@@ -103,6 +123,64 @@ func (b *RedisBackend) Load() (*Data, error) {
 		if v, ok := settingsMap["expiration_check_minutes"]; ok {
 			fmt.Sscanf(v, "%d", &data.Settings.ExpirationCheckMinutes)
 		}
+		if v, ok := settingsMap["dedup_enabled"]; ok {
+			data.Settings.DedupEnabled = v == "true"
+		} else {
+			data.Settings.DedupEnabled = true
+		}
+		if v, ok := settingsMap["key_template"]; ok {
+			data.Settings.KeyTemplate = v
+		}
+		if v, ok := settingsMap["key_collision"]; ok {
+			data.Settings.KeyCollision = v
+		} else {
+			data.Settings.KeyCollision = KeyCollisionSuffix
+		}
+		if v, ok := settingsMap["url_allowed_hosts"]; ok {
+			data.Settings.URLAllowedHosts = v
+		}
+		if v, ok := settingsMap["url_max_download_mb"]; ok {
+			fmt.Sscanf(v, "%d", &data.Settings.URLMaxDownloadMB)
+		} else {
+			data.Settings.URLMaxDownloadMB = DefaultURLMaxDownloadMB
+		}
+		if v, ok := settingsMap["url_max_redirects"]; ok {
+			fmt.Sscanf(v, "%d", &data.Settings.URLMaxRedirects)
+		} else {
+			data.Settings.URLMaxRedirects = DefaultURLMaxRedirects
+		}
+		if v, ok := settingsMap["url_allowed_types"]; ok {
+			data.Settings.URLAllowedTypes = v
+		}
+		if v, ok := settingsMap["url_denied_types"]; ok {
+			data.Settings.URLDeniedTypes = v
+		}
+		if v, ok := settingsMap["thumbnail_enabled"]; ok {
+			data.Settings.ThumbnailEnabled = v == "true"
+		}
+		if v, ok := settingsMap["thumbnail_sizes"]; ok {
+			data.Settings.ThumbnailSizes = v
+		} else {
+			data.Settings.ThumbnailSizes = DefaultThumbnailSizes
+		}
+		if v, ok := settingsMap["thumbnail_quality"]; ok {
+			fmt.Sscanf(v, "%d", &data.Settings.ThumbnailQuality)
+		} else {
+			data.Settings.ThumbnailQuality = DefaultThumbnailQuality
+		}
+		if v, ok := settingsMap["replication_factor"]; ok {
+			fmt.Sscanf(v, "%d", &data.Settings.ReplicationFactor)
+		} else {
+			data.Settings.ReplicationFactor = DefaultReplicationFactor
+		}
+		if v, ok := settingsMap["ops_alert_percent"]; ok {
+			fmt.Sscanf(v, "%d", &data.Settings.OpsAlertPercent)
+		} else {
+			data.Settings.OpsAlertPercent = DefaultOpsAlertPercent
+		}
+		if v, ok := settingsMap["placement_strategy"]; ok {
+			data.Settings.PlacementStrategy = v
+		}
 	}
 	if data.Settings.SyncInterval <= 0 {
 		data.Settings.SyncInterval = 5
@@ -142,10 +220,150 @@ func (b *RedisBackend) Load() (*Data, error) {
 		data.FileExpirations = append(data.FileExpirations, exp)
 	}
 
+	// 加载 upload_sessions
+	uploadSessionsMap, err := b.client.HGetAll(b.ctx, redisUploadSessionsKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("加载 upload_sessions 失败: %w", err)
+	}
+
+	for _, jsonStr := range uploadSessionsMap {
+		var sess UploadSession
+		if err := json.Unmarshal([]byte(jsonStr), &sess); err != nil {
+			continue
+		}
+		data.UploadSessions = append(data.UploadSessions, sess)
+	}
+
+	// 加载 presigned_uploads
+	presignedUploadsMap, err := b.client.HGetAll(b.ctx, redisPresignedUploadsKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("加载 presigned_uploads 失败: %w", err)
+	}
+
+	for _, jsonStr := range presignedUploadsMap {
+		var pu PresignedUpload
+		if err := json.Unmarshal([]byte(jsonStr), &pu); err != nil {
+			continue
+		}
+		data.PresignedUploads = append(data.PresignedUploads, pu)
+	}
+
+	// 加载 file_objects
+	fileObjectsMap, err := b.client.HGetAll(b.ctx, redisFileObjectsKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("加载 file_objects 失败: %w", err)
+	}
+
+	for _, jsonStr := range fileObjectsMap {
+		var obj FileObject
+		if err := json.Unmarshal([]byte(jsonStr), &obj); err != nil {
+			continue
+		}
+		data.FileObjects = append(data.FileObjects, obj)
+	}
+
+	// 加载 import_jobs
+	importJobsMap, err := b.client.HGetAll(b.ctx, redisImportJobsKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("加载 import_jobs 失败: %w", err)
+	}
+
+	for _, jsonStr := range importJobsMap {
+		var job ImportJob
+		if err := json.Unmarshal([]byte(jsonStr), &job); err != nil {
+			continue
+		}
+		data.ImportJobs = append(data.ImportJobs, job)
+	}
+
+	// 加载 webhooks
+	webhooksMap, err := b.client.HGetAll(b.ctx, redisWebhooksKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("加载 webhooks 失败: %w", err)
+	}
+
+	for _, jsonStr := range webhooksMap {
+		var hook Webhook
+		if err := json.Unmarshal([]byte(jsonStr), &hook); err != nil {
+			continue
+		}
+		data.Webhooks = append(data.Webhooks, hook)
+	}
+
+	// 加载 webhook_deliveries
+	webhookDeliveriesMap, err := b.client.HGetAll(b.ctx, redisWebhookDeliveriesKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("加载 webhook_deliveries 失败: %w", err)
+	}
+
+	for _, jsonStr := range webhookDeliveriesMap {
+		var delivery WebhookDelivery
+		if err := json.Unmarshal([]byte(jsonStr), &delivery); err != nil {
+			continue
+		}
+		data.WebhookDeliveries = append(data.WebhookDeliveries, delivery)
+	}
+
+	// 加载 file_metadata
+	fileMetadataMap, err := b.client.HGetAll(b.ctx, redisFileMetadataKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("加载 file_metadata 失败: %w", err)
+	}
+
+	for _, jsonStr := range fileMetadataMap {
+		var fm FileMetadata
+		if err := json.Unmarshal([]byte(jsonStr), &fm); err != nil {
+			continue
+		}
+		data.FileMetadata = append(data.FileMetadata, fm)
+	}
+
+	// 加载 replica_sets
+	replicaSetsMap, err := b.client.HGetAll(b.ctx, redisReplicaSetsKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("加载 replica_sets 失败: %w", err)
+	}
+
+	for _, jsonStr := range replicaSetsMap {
+		var rs ReplicaSet
+		if err := json.Unmarshal([]byte(jsonStr), &rs); err != nil {
+			continue
+		}
+		data.ReplicaSets = append(data.ReplicaSets, rs)
+	}
+
+	// 加载 striped_files
+	stripedFilesMap, err := b.client.HGetAll(b.ctx, redisStripedFilesKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("加载 striped_files 失败: %w", err)
+	}
+
+	for _, jsonStr := range stripedFilesMap {
+		var sf StripedFile
+		if err := json.Unmarshal([]byte(jsonStr), &sf); err != nil {
+			continue
+		}
+		data.StripedFiles = append(data.StripedFiles, sf)
+	}
+
+	// 加载 pools
+	poolsMap, err := b.client.HGetAll(b.ctx, redisPoolsKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("加载 pools 失败: %w", err)
+	}
+
+	for _, jsonStr := range poolsMap {
+		var pool Pool
+		if err := json.Unmarshal([]byte(jsonStr), &pool); err != nil {
+			continue
+		}
+		data.Pools = append(data.Pools, pool)
+	}
+
 	return data, nil
 }
 
-// Save 保存全部数据到 Redis
+// Save 保存全部数据到 Redis（按行写入的表由 SaveRows 写入）
 func (b *RedisBackend) Save(data *Data) error {
 	pipe := b.client.Pipeline()
 
@@ -154,6 +372,10 @@ func (b *RedisBackend) Save(data *Data) error {
 	pipe.Del(b.ctx, redisTokensKey)
 	pipe.Del(b.ctx, redisWebDAVCredentialsKey)
 	pipe.Del(b.ctx, redisFileExpirationsKey)
+	pipe.Del(b.ctx, redisPresignedUploadsKey)
+	pipe.Del(b.ctx, redisImportJobsKey)
+	pipe.Del(b.ctx, redisWebhooksKey)
+	pipe.Del(b.ctx, redisPoolsKey)
 
 	// 保存 accounts
 	if len(data.Accounts) > 0 {
@@ -191,6 +413,28 @@ func (b *RedisBackend) Save(data *Data) error {
 	pipe.HSet(b.ctx, redisSettingsKey, "endpoint_proxy_url", data.Settings.EndpointProxyURL)
 	pipe.HSet(b.ctx, redisSettingsKey, "default_expiration_days", fmt.Sprintf("%d", data.Settings.DefaultExpirationDays))
 	pipe.HSet(b.ctx, redisSettingsKey, "expiration_check_minutes", fmt.Sprintf("%d", data.Settings.ExpirationCheckMinutes))
+	dedupEnabledVal := "false"
+	if data.Settings.DedupEnabled {
+		dedupEnabledVal = "true"
+	}
+	pipe.HSet(b.ctx, redisSettingsKey, "dedup_enabled", dedupEnabledVal)
+	pipe.HSet(b.ctx, redisSettingsKey, "key_template", data.Settings.KeyTemplate)
+	pipe.HSet(b.ctx, redisSettingsKey, "key_collision", data.Settings.KeyCollision)
+	pipe.HSet(b.ctx, redisSettingsKey, "url_allowed_hosts", data.Settings.URLAllowedHosts)
+	pipe.HSet(b.ctx, redisSettingsKey, "url_max_download_mb", fmt.Sprintf("%d", data.Settings.URLMaxDownloadMB))
+	pipe.HSet(b.ctx, redisSettingsKey, "url_max_redirects", fmt.Sprintf("%d", data.Settings.URLMaxRedirects))
+	pipe.HSet(b.ctx, redisSettingsKey, "url_allowed_types", data.Settings.URLAllowedTypes)
+	pipe.HSet(b.ctx, redisSettingsKey, "url_denied_types", data.Settings.URLDeniedTypes)
+	thumbnailEnabledVal := "false"
+	if data.Settings.ThumbnailEnabled {
+		thumbnailEnabledVal = "true"
+	}
+	pipe.HSet(b.ctx, redisSettingsKey, "thumbnail_enabled", thumbnailEnabledVal)
+	pipe.HSet(b.ctx, redisSettingsKey, "thumbnail_sizes", data.Settings.ThumbnailSizes)
+	pipe.HSet(b.ctx, redisSettingsKey, "thumbnail_quality", fmt.Sprintf("%d", data.Settings.ThumbnailQuality))
+	pipe.HSet(b.ctx, redisSettingsKey, "replication_factor", fmt.Sprintf("%d", data.Settings.ReplicationFactor))
+	pipe.HSet(b.ctx, redisSettingsKey, "ops_alert_percent", fmt.Sprintf("%d", data.Settings.OpsAlertPercent))
+	pipe.HSet(b.ctx, redisSettingsKey, "placement_strategy", data.Settings.PlacementStrategy)
 
 	// 保存 webdav_credentials
 	if len(data.WebDAVCredentials) > 0 {
@@ -218,6 +462,58 @@ func (b *RedisBackend) Save(data *Data) error {
 		pipe.HSet(b.ctx, redisFileExpirationsKey, fileExpMap)
 	}
 
+	// 保存 presigned_uploads
+	if len(data.PresignedUploads) > 0 {
+		presignedUploadsMap := make(map[string]string)
+		for _, pu := range data.PresignedUploads {
+			jsonBytes, err := json.Marshal(pu)
+			if err != nil {
+				return fmt.Errorf("序列化 presigned_upload 失败: %w", err)
+			}
+			presignedUploadsMap[pu.ID] = string(jsonBytes)
+		}
+		pipe.HSet(b.ctx, redisPresignedUploadsKey, presignedUploadsMap)
+	}
+
+	// 保存 import_jobs
+	if len(data.ImportJobs) > 0 {
+		importJobsMap := make(map[string]string)
+		for _, job := range data.ImportJobs {
+			jsonBytes, err := json.Marshal(job)
+			if err != nil {
+				return fmt.Errorf("序列化 import_job 失败: %w", err)
+			}
+			importJobsMap[job.ID] = string(jsonBytes)
+		}
+		pipe.HSet(b.ctx, redisImportJobsKey, importJobsMap)
+	}
+
+	// 保存 webhooks
+	if len(data.Webhooks) > 0 {
+		webhooksMap := make(map[string]string)
+		for _, hook := range data.Webhooks {
+			jsonBytes, err := json.Marshal(hook)
+			if err != nil {
+				return fmt.Errorf("序列化 webhook 失败: %w", err)
+			}
+			webhooksMap[hook.ID] = string(jsonBytes)
+		}
+		pipe.HSet(b.ctx, redisWebhooksKey, webhooksMap)
+	}
+
+	// 保存 pools
+	if len(data.Pools) > 0 {
+		poolsMap := make(map[string]string)
+		for _, pool := range data.Pools {
+			jsonBytes, err := json.Marshal(pool)
+			if err != nil {
+				return fmt.Errorf("序列化 pool 失败: %w", err)
+			}
+			poolsMap[pool.ID] = string(jsonBytes)
+		}
+		pipe.HSet(b.ctx, redisPoolsKey, poolsMap)
+	}
+
 	_, err := pipe.Exec(b.ctx)
 	if err != nil {
 		return fmt.Errorf("保存到 Redis 失败: %w", err)
@@ -226,6 +522,51 @@ func (b *RedisBackend) Save(data *Data) error {
 	return nil
 }
 
+// redisRowKeys 按行写入的数据表对应的 Hash 键
+var redisRowKeys = map[RowTable]string{
+	TableUploadSessions:    redisUploadSessionsKey,
+	TableFileObjects:       redisFileObjectsKey,
+	TableWebhookDeliveries: redisWebhookDeliveriesKey,
+	TableFileMetadata:      redisFileMetadataKey,
+	TableReplicaSets:       redisReplicaSetsKey,
+	TableStripedFiles:      redisStripedFilesKey,
+}
+
+// SaveRows 逐行写入或替换按行写入的记录
+func (b *RedisBackend) SaveRows(rows ...interface{}) error {
+	pipe := b.client.Pipeline()
+	for _, row := range rows {
+		table, id, err := rowTableOf(row)
+		if err != nil {
+			return err
+		}
+		jsonBytes, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("序列化 %s 记录失败: %w", table, err)
+		}
+		pipe.HSet(b.ctx, redisRowKeys[table], id, string(jsonBytes))
+	}
+
+	if _, err := pipe.Exec(b.ctx); err != nil {
+		return fmt.Errorf("保存到 Redis 失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteRows 按 ID 删除按行写入的记录
+func (b *RedisBackend) DeleteRows(table RowTable, ids ...string) error {
+	if err := table.validate(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := b.client.HDel(b.ctx, redisRowKeys[table], ids...).Err(); err != nil {
+		return fmt.Errorf("删除 Redis 记录失败: %w", err)
+	}
+	return nil
+}
+
 // Close 关闭 Redis 连接
 func (b *RedisBackend) Close() error {
 	if b.client != nil {
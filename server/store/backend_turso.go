@@ -55,6 +55,11 @@ func (b *TursoBackend) Init() error {
 		return fmt.Errorf("创建表结构失败: %w", err)
 	}
 
+	// 为旧版本创建的表补充新增列
+	if err := b.migrateTables(); err != nil {
+		return fmt.Errorf("迁移表结构失败: %w", err)
+	}
+
 	return nil
 }
 
@@ -89,6 +94,7 @@ func (b *TursoBackend) createTables() error {
 			api_token TEXT,
 			quota_max_size_bytes INTEGER DEFAULT 0,
 			quota_max_class_a_ops INTEGER DEFAULT 0,
+			quota_max_class_b_ops INTEGER DEFAULT 0,
 			usage_size_bytes INTEGER DEFAULT 0,
 			usage_class_a_ops INTEGER DEFAULT 0,
 			usage_class_b_ops INTEGER DEFAULT 0,
@@ -97,6 +103,16 @@ func (b *TursoBackend) createTables() error {
 			perm_auto_upload INTEGER DEFAULT 1,
 			perm_api_upload INTEGER DEFAULT 1,
 			perm_client_upload INTEGER DEFAULT 1,
+			link_mode TEXT,
+			presign_ttl INTEGER DEFAULT 0,
+			key_template TEXT,
+			strip_image_metadata INTEGER DEFAULT 0,
+			encryption INTEGER DEFAULT 0,
+			weight INTEGER DEFAULT 0,
+			usage_history TEXT,
+			provider TEXT,
+			region TEXT,
+			path_style INTEGER DEFAULT 0,
 			created_at TEXT,
 			updated_at TEXT
 		)
@@ -112,6 +128,10 @@ func (b *TursoBackend) createTables() error {
 			name TEXT NOT NULL,
 			token TEXT UNIQUE NOT NULL,
 			permissions TEXT,
+			key_template TEXT,
+			strip_image_metadata INTEGER DEFAULT 0,
+			placement_strategy TEXT,
+			pools TEXT,
 			created_at TEXT
 		)
 	`)
@@ -154,14 +174,257 @@ func (b *TursoBackend) createTables() error {
 			id TEXT PRIMARY KEY,
 			account_id TEXT NOT NULL,
 			file_key TEXT NOT NULL,
+			size INTEGER DEFAULT 0,
 			expires_at TEXT NOT NULL,
 			created_at TEXT,
 			UNIQUE(account_id, file_key)
 		)
 	`)
+	if err != nil {
+		return err
+	}
+
+	// 创建 upload_sessions 表
+	_, err = b.db.Exec(`
+		CREATE TABLE IF NOT EXISTS upload_sessions (
+			id TEXT PRIMARY KEY,
+			account_id TEXT NOT NULL,
+			file_key TEXT NOT NULL,
+			upload_id TEXT,
+			size INTEGER DEFAULT 0,
+			offset_bytes INTEGER DEFAULT 0,
+			part_size INTEGER DEFAULT 0,
+			parts TEXT,
+			content_type TEXT,
+			file_name TEXT,
+			expiration_days INTEGER DEFAULT 0,
+			token_id TEXT,
+			completed INTEGER DEFAULT 0,
+			expires_at TEXT NOT NULL,
+			created_at TEXT,
+			updated_at TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 创建 presigned_uploads 表
+	_, err = b.db.Exec(`
+		CREATE TABLE IF NOT EXISTS presigned_uploads (
+			id TEXT PRIMARY KEY,
+			account_id TEXT NOT NULL,
+			file_key TEXT NOT NULL,
+			upload_id TEXT,
+			size INTEGER DEFAULT 0,
+			part_size INTEGER DEFAULT 0,
+			content_type TEXT,
+			file_name TEXT,
+			expiration_days INTEGER DEFAULT 0,
+			token_id TEXT,
+			expires_at TEXT NOT NULL,
+			created_at TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 创建 file_objects 表
+	_, err = b.db.Exec(`
+		CREATE TABLE IF NOT EXISTS file_objects (
+			id TEXT PRIMARY KEY,
+			hash TEXT NOT NULL,
+			size INTEGER DEFAULT 0,
+			account_id TEXT NOT NULL,
+			file_key TEXT NOT NULL,
+			etag TEXT,
+			ref_count INTEGER DEFAULT 0,
+			created_at TEXT,
+			updated_at TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 创建 import_jobs 表
+	_, err = b.db.Exec(`
+		CREATE TABLE IF NOT EXISTS import_jobs (
+			id TEXT PRIMARY KEY,
+			url TEXT NOT NULL,
+			status TEXT NOT NULL,
+			account_id TEXT,
+			pool TEXT,
+			path TEXT,
+			expiration_days INTEGER DEFAULT 0,
+			token_id TEXT,
+			uploader TEXT,
+			attempts INTEGER DEFAULT 0,
+			max_attempts INTEGER DEFAULT 0,
+			total_bytes INTEGER DEFAULT 0,
+			downloaded_bytes INTEGER DEFAULT 0,
+			uploaded_bytes INTEGER DEFAULT 0,
+			target_account_id TEXT,
+			result TEXT,
+			error TEXT,
+			created_at TEXT,
+			updated_at TEXT,
+			finished_at TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 创建 webhooks 表
+	_, err = b.db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT,
+			events TEXT,
+			is_active INTEGER DEFAULT 1,
+			created_at TEXT,
+			updated_at TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 创建 webhook_deliveries 表
+	_, err = b.db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id TEXT PRIMARY KEY,
+			webhook_id TEXT NOT NULL,
+			event_id TEXT,
+			event TEXT NOT NULL,
+			payload TEXT,
+			status TEXT NOT NULL,
+			attempts INTEGER DEFAULT 0,
+			max_attempts INTEGER DEFAULT 0,
+			response_code INTEGER DEFAULT 0,
+			error TEXT,
+			next_attempt_at TEXT,
+			created_at TEXT,
+			updated_at TEXT,
+			finished_at TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 创建 file_metadata 表
+	_, err = b.db.Exec(`
+		CREATE TABLE IF NOT EXISTS file_metadata (
+			id TEXT PRIMARY KEY,
+			account_id TEXT NOT NULL,
+			file_key TEXT NOT NULL,
+			size INTEGER DEFAULT 0,
+			metadata TEXT,
+			tags TEXT,
+			created_at TEXT,
+			updated_at TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 创建 replica_sets 表
+	_, err = b.db.Exec(`
+		CREATE TABLE IF NOT EXISTS replica_sets (
+			id TEXT PRIMARY KEY,
+			account_id TEXT NOT NULL,
+			file_key TEXT NOT NULL,
+			size INTEGER DEFAULT 0,
+			sha256 TEXT,
+			factor INTEGER DEFAULT 0,
+			replicas TEXT,
+			pool TEXT,
+			status TEXT,
+			last_error TEXT,
+			last_checked_at TEXT,
+			created_at TEXT,
+			updated_at TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 创建 striped_files 表
+	_, err = b.db.Exec(`
+		CREATE TABLE IF NOT EXISTS striped_files (
+			id TEXT PRIMARY KEY,
+			account_id TEXT NOT NULL,
+			file_key TEXT NOT NULL,
+			original_name TEXT,
+			content_type TEXT,
+			size INTEGER DEFAULT 0,
+			sha256 TEXT,
+			crc32c TEXT,
+			chunks TEXT,
+			uploader TEXT,
+			created_at TEXT,
+			updated_at TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 创建 pools 表
+	_, err = b.db.Exec(`
+		CREATE TABLE IF NOT EXISTS pools (
+			id TEXT PRIMARY KEY,
+			name TEXT UNIQUE NOT NULL,
+			description TEXT,
+			members TEXT,
+			placement_strategy TEXT,
+			expiration_days INTEGER DEFAULT -1,
+			created_at TEXT,
+			updated_at TEXT
+		)
+	`)
 	return err
 }
 
+// migrateTables 为旧版本创建的表补充新增列
+func (b *TursoBackend) migrateTables() error {
+	columns := []struct{ table, column, definition string }{
+		{"file_expirations", "size", "INTEGER DEFAULT 0"},
+		{"replica_sets", "pool", "TEXT"},
+		{"accounts", "provider", "TEXT"},
+		{"accounts", "region", "TEXT"},
+		{"accounts", "path_style", "INTEGER DEFAULT 0"},
+		{"accounts", "quota_max_class_b_ops", "INTEGER DEFAULT 0"},
+		{"accounts", "usage_history", "TEXT"},
+		{"tokens", "placement_strategy", "TEXT"},
+		{"tokens", "pools", "TEXT"},
+		{"accounts", "weight", "INTEGER DEFAULT 0"},
+		{"accounts", "encryption", "INTEGER DEFAULT 0"},
+		{"accounts", "strip_image_metadata", "INTEGER DEFAULT 0"},
+		{"accounts", "key_template", "TEXT"},
+		{"accounts", "link_mode", "TEXT"},
+		{"accounts", "presign_ttl", "INTEGER DEFAULT 0"},
+		{"tokens", "key_template", "TEXT"},
+		{"tokens", "strip_image_metadata", "INTEGER DEFAULT 0"},
+		{"import_jobs", "pool", "TEXT"},
+	}
+
+	for _, col := range columns {
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.table, col.column, col.definition)
+		if _, err := b.db.Exec(stmt); err != nil && !isDuplicateColumnError(err) {
+			return fmt.Errorf("添加列 %s.%s 失败: %w", col.table, col.column, err)
+		}
+	}
+	return nil
+}
+
 // Load 从数据库加载全部数据
 func (b *TursoBackend) Load() (*Data, error) {
 	data := &Data{
@@ -169,16 +432,33 @@ func (b *TursoBackend) Load() (*Data, error) {
 		Tokens:            []Token{},
 		WebDAVCredentials: []WebDAVCredential{},
 		FileExpirations:   []FileExpiration{},
+		Pools:             []Pool{},
+		StripedFiles:      []StripedFile{},
+		ReplicaSets:       []ReplicaSet{},
+		FileMetadata:      []FileMetadata{},
+		WebhookDeliveries: []WebhookDelivery{},
+		Webhooks:          []Webhook{},
+		ImportJobs:        []ImportJob{},
+		FileObjects:       []FileObject{},
+		PresignedUploads:  []PresignedUpload{},
+		UploadSessions:    []UploadSession{},
 	}
 
 	// 加载 accounts
 	rows, err := b.db.Query(`
 		SELECT id, name, is_active, description, account_id, access_key_id,
 			secret_access_key, bucket_name, endpoint, public_domain, api_token,
-			quota_max_size_bytes, quota_max_class_a_ops,
+			quota_max_size_bytes, quota_max_class_a_ops, COALESCE(quota_max_class_b_ops, 0),
 			usage_size_bytes, usage_class_a_ops, usage_class_b_ops, usage_last_sync_at,
 			COALESCE(perm_webdav, 1), COALESCE(perm_auto_upload, 1),
 			COALESCE(perm_api_upload, 1), COALESCE(perm_client_upload, 1),
+			COALESCE(link_mode, ''), COALESCE(presign_ttl, 0),
+			COALESCE(key_template, ''),
+			COALESCE(strip_image_metadata, 0),
+			COALESCE(encryption, 0),
+			COALESCE(weight, 0),
+			COALESCE(usage_history, ''),
+			COALESCE(provider, ''), COALESCE(region, ''), COALESCE(path_style, 0),
 			created_at, updated_at
 		FROM accounts
 	`)
@@ -194,13 +474,24 @@ func (b *TursoBackend) Load() (*Data, error) {
 		var description, accountID, accessKeyID, secretAccessKey sql.NullString
 		var bucketName, endpoint, publicDomain, apiToken sql.NullString
 		var usageLastSyncAt, createdAt, updatedAt sql.NullString
+		var usageHistory string
+		var pathStyle int
+		var stripImageMetadata int
+		var encryption int
 
 		err := rows.Scan(
 			&acc.ID, &acc.Name, &isActive, &description, &accountID, &accessKeyID,
 			&secretAccessKey, &bucketName, &endpoint, &publicDomain, &apiToken,
-			&acc.Quota.MaxSizeBytes, &acc.Quota.MaxClassAOps,
+			&acc.Quota.MaxSizeBytes, &acc.Quota.MaxClassAOps, &acc.Quota.MaxClassBOps,
 			&acc.Usage.SizeBytes, &acc.Usage.ClassAOps, &acc.Usage.ClassBOps, &usageLastSyncAt,
 			&permWebDAV, &permAutoUpload, &permAPIUpload, &permClientUpload,
+			&acc.LinkMode, &acc.PresignTTL,
+			&acc.KeyTemplate,
+			&stripImageMetadata,
+			&encryption,
+			&acc.Weight,
+			&usageHistory,
+			&acc.Provider, &acc.Region, &pathStyle,
 			&createdAt, &updatedAt,
 		)
 		if err != nil {
@@ -217,18 +508,26 @@ func (b *TursoBackend) Load() (*Data, error) {
 		acc.PublicDomain = publicDomain.String
 		acc.APIToken = apiToken.String
 		acc.Usage.LastSyncAt = usageLastSyncAt.String
+		if usageHistory != "" {
+			if err := json.Unmarshal([]byte(usageHistory), &acc.UsageHistory); err != nil {
+				acc.UsageHistory = nil
+			}
+		}
 		acc.Permissions.WebDAV = permWebDAV == 1
 		acc.Permissions.AutoUpload = permAutoUpload == 1
 		acc.Permissions.APIUpload = permAPIUpload == 1
 		acc.Permissions.ClientUpload = permClientUpload == 1
 		acc.CreatedAt = createdAt.String
 		acc.UpdatedAt = updatedAt.String
+		acc.StripImageMetadata = stripImageMetadata == 1
+		acc.Encryption = encryption == 1
+		acc.PathStyle = pathStyle == 1
 
 		data.Accounts = append(data.Accounts, acc)
 	}
 
 	// 加载 tokens
-	rows, err = b.db.Query(`SELECT id, name, token, permissions, created_at FROM tokens`)
+	rows, err = b.db.Query(`SELECT id, name, token, permissions, COALESCE(key_template, ''), COALESCE(strip_image_metadata, 0), COALESCE(placement_strategy, ''), pools, created_at FROM tokens`)
 	if err != nil {
 		return nil, fmt.Errorf("查询 tokens 失败: %w", err)
 	}
@@ -236,10 +535,10 @@ func (b *TursoBackend) Load() (*Data, error) {
 
 	for rows.Next() {
 		var t Token
-		var permissions sql.NullString
+		var permissions, pools sql.NullString
 		var createdAt sql.NullString
 
-		err := rows.Scan(&t.ID, &t.Name, &t.Token, &permissions, &createdAt)
+		err := rows.Scan(&t.ID, &t.Name, &t.Token, &permissions, &t.KeyTemplate, &t.StripImageMetadata, &t.PlacementStrategy, &pools, &createdAt)
 		if err != nil {
 			return nil, fmt.Errorf("扫描 token 行失败: %w", err)
 		}
@@ -251,6 +550,11 @@ func (b *TursoBackend) Load() (*Data, error) {
 		} else {
 			t.Permissions = []string{}
 		}
+		if pools.Valid && pools.String != "" {
+			if err := json.Unmarshal([]byte(pools.String), &t.Pools); err != nil {
+				t.Pools = nil
+			}
+		}
 		t.CreatedAt = createdAt.String
 
 		data.Tokens = append(data.Tokens, t)
@@ -296,6 +600,106 @@ func (b *TursoBackend) Load() (*Data, error) {
 		data.Settings.ExpirationCheckMinutes = 720
 	}
 
+	var dedupEnabled sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'dedup_enabled'`).Scan(&dedupEnabled)
+	if err == nil && dedupEnabled.Valid {
+		data.Settings.DedupEnabled = dedupEnabled.String == "true"
+	} else {
+		data.Settings.DedupEnabled = true
+	}
+
+	var keyTemplate sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'key_template'`).Scan(&keyTemplate)
+	if err == nil && keyTemplate.Valid {
+		data.Settings.KeyTemplate = keyTemplate.String
+	}
+
+	var keyCollision sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'key_collision'`).Scan(&keyCollision)
+	if err == nil && keyCollision.Valid {
+		data.Settings.KeyCollision = keyCollision.String
+	} else {
+		data.Settings.KeyCollision = KeyCollisionSuffix
+	}
+
+	var urlAllowedHosts sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'url_allowed_hosts'`).Scan(&urlAllowedHosts)
+	if err == nil && urlAllowedHosts.Valid {
+		data.Settings.URLAllowedHosts = urlAllowedHosts.String
+	}
+
+	var urlMaxDownloadMb sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'url_max_download_mb'`).Scan(&urlMaxDownloadMb)
+	if err == nil && urlMaxDownloadMb.Valid {
+		fmt.Sscanf(urlMaxDownloadMb.String, "%d", &data.Settings.URLMaxDownloadMB)
+	} else {
+		data.Settings.URLMaxDownloadMB = DefaultURLMaxDownloadMB
+	}
+
+	var urlMaxRedirects sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'url_max_redirects'`).Scan(&urlMaxRedirects)
+	if err == nil && urlMaxRedirects.Valid {
+		fmt.Sscanf(urlMaxRedirects.String, "%d", &data.Settings.URLMaxRedirects)
+	} else {
+		data.Settings.URLMaxRedirects = DefaultURLMaxRedirects
+	}
+
+	var urlAllowedTypes sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'url_allowed_types'`).Scan(&urlAllowedTypes)
+	if err == nil && urlAllowedTypes.Valid {
+		data.Settings.URLAllowedTypes = urlAllowedTypes.String
+	}
+
+	var urlDeniedTypes sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'url_denied_types'`).Scan(&urlDeniedTypes)
+	if err == nil && urlDeniedTypes.Valid {
+		data.Settings.URLDeniedTypes = urlDeniedTypes.String
+	}
+
+	var thumbnailEnabled sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'thumbnail_enabled'`).Scan(&thumbnailEnabled)
+	if err == nil && thumbnailEnabled.Valid {
+		data.Settings.ThumbnailEnabled = thumbnailEnabled.String == "true"
+	}
+
+	var thumbnailSizes sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'thumbnail_sizes'`).Scan(&thumbnailSizes)
+	if err == nil && thumbnailSizes.Valid {
+		data.Settings.ThumbnailSizes = thumbnailSizes.String
+	} else {
+		data.Settings.ThumbnailSizes = DefaultThumbnailSizes
+	}
+
+	var thumbnailQuality sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'thumbnail_quality'`).Scan(&thumbnailQuality)
+	if err == nil && thumbnailQuality.Valid {
+		fmt.Sscanf(thumbnailQuality.String, "%d", &data.Settings.ThumbnailQuality)
+	} else {
+		data.Settings.ThumbnailQuality = DefaultThumbnailQuality
+	}
+
+	var replicationFactor sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'replication_factor'`).Scan(&replicationFactor)
+	if err == nil && replicationFactor.Valid {
+		fmt.Sscanf(replicationFactor.String, "%d", &data.Settings.ReplicationFactor)
+	} else {
+		data.Settings.ReplicationFactor = DefaultReplicationFactor
+	}
+
+	var opsAlertPercent sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'ops_alert_percent'`).Scan(&opsAlertPercent)
+	if err == nil && opsAlertPercent.Valid {
+		fmt.Sscanf(opsAlertPercent.String, "%d", &data.Settings.OpsAlertPercent)
+	} else {
+		data.Settings.OpsAlertPercent = DefaultOpsAlertPercent
+	}
+
+	var placementStrategy sql.NullString
+	err = b.db.QueryRow(`SELECT value FROM settings WHERE key = 'placement_strategy'`).Scan(&placementStrategy)
+	if err == nil && placementStrategy.Valid {
+		data.Settings.PlacementStrategy = placementStrategy.String
+	}
+
 	// 加载 webdav_credentials
 	rows, err = b.db.Query(`
 		SELECT id, username, password, account_id, description,
@@ -337,7 +741,7 @@ func (b *TursoBackend) Load() (*Data, error) {
 
 	// 加载 file_expirations
 	rows, err = b.db.Query(`
-		SELECT id, account_id, file_key, expires_at, created_at
+		SELECT id, account_id, file_key, size, expires_at, created_at
 		FROM file_expirations
 	`)
 	if err != nil {
@@ -349,7 +753,7 @@ func (b *TursoBackend) Load() (*Data, error) {
 		var exp FileExpiration
 		var createdAt sql.NullString
 
-		err := rows.Scan(&exp.ID, &exp.AccountID, &exp.FileKey, &exp.ExpiresAt, &createdAt)
+		err := rows.Scan(&exp.ID, &exp.AccountID, &exp.FileKey, &exp.Size, &exp.ExpiresAt, &createdAt)
 		if err != nil {
 			return nil, fmt.Errorf("扫描 file_expiration 行失败: %w", err)
 		}
@@ -358,105 +762,522 @@ func (b *TursoBackend) Load() (*Data, error) {
 		data.FileExpirations = append(data.FileExpirations, exp)
 	}
 
-	return data, nil
-}
-
-// Save 保存全部数据到数据库
-func (b *TursoBackend) Save(data *Data) error {
-	tx, err := b.db.Begin()
+	// 加载 upload_sessions
+	rows, err = b.db.Query(`
+		SELECT id, account_id, file_key, upload_id, size, offset_bytes, part_size,
+			parts, content_type, file_name, expiration_days, token_id, completed,
+			expires_at, created_at, updated_at
+		FROM upload_sessions
+	`)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
-	}
-	defer tx.Rollback()
-
-	// 清空并重新插入 accounts
-	if _, err := tx.Exec("DELETE FROM accounts"); err != nil {
-		return fmt.Errorf("清空 accounts 失败: %w", err)
+		return nil, fmt.Errorf("查询 upload_sessions 失败: %w", err)
 	}
+	defer rows.Close()
 
-	for _, acc := range data.Accounts {
-		isActive := 0
-		if acc.IsActive {
-			isActive = 1
-		}
-		permWebDAV, permAutoUpload, permAPIUpload, permClientUpload := 0, 0, 0, 0
-		if acc.Permissions.WebDAV {
-			permWebDAV = 1
-		}
-		if acc.Permissions.AutoUpload {
-			permAutoUpload = 1
-		}
-		if acc.Permissions.APIUpload {
-			permAPIUpload = 1
-		}
-		if acc.Permissions.ClientUpload {
-			permClientUpload = 1
-		}
+	for rows.Next() {
+		var sess UploadSession
+		var uploadID, parts, contentType, fileName, tokenID, createdAt, updatedAt sql.NullString
+		var completed int
 
-		_, err := tx.Exec(`
-			INSERT INTO accounts (
-				id, name, is_active, description, account_id, access_key_id,
-				secret_access_key, bucket_name, endpoint, public_domain, api_token,
-				quota_max_size_bytes, quota_max_class_a_ops,
-				usage_size_bytes, usage_class_a_ops, usage_class_b_ops, usage_last_sync_at,
-				perm_webdav, perm_auto_upload, perm_api_upload, perm_client_upload,
-				created_at, updated_at
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`,
-			acc.ID, acc.Name, isActive, acc.Description, acc.AccountID, acc.AccessKeyId,
-			acc.SecretAccessKey, acc.BucketName, acc.Endpoint, acc.PublicDomain, acc.APIToken,
-			acc.Quota.MaxSizeBytes, acc.Quota.MaxClassAOps,
-			acc.Usage.SizeBytes, acc.Usage.ClassAOps, acc.Usage.ClassBOps, acc.Usage.LastSyncAt,
-			permWebDAV, permAutoUpload, permAPIUpload, permClientUpload,
-			acc.CreatedAt, acc.UpdatedAt,
+		err := rows.Scan(
+			&sess.ID, &sess.AccountID, &sess.FileKey, &uploadID, &sess.Size, &sess.Offset,
+			&sess.PartSize, &parts, &contentType, &fileName, &sess.ExpirationDays, &tokenID,
+			&completed, &sess.ExpiresAt, &createdAt, &updatedAt,
 		)
 		if err != nil {
-			return fmt.Errorf("插入 account 失败: %w", err)
+			return nil, fmt.Errorf("扫描 upload_session 行失败: %w", err)
+		}
+
+		sess.UploadID = uploadID.String
+		if parts.Valid && parts.String != "" {
+			if err := json.Unmarshal([]byte(parts.String), &sess.Parts); err != nil {
+				sess.Parts = []UploadPart{}
+			}
+		} else {
+			sess.Parts = []UploadPart{}
 		}
+		sess.ContentType = contentType.String
+		sess.FileName = fileName.String
+		sess.TokenID = tokenID.String
+		sess.Completed = completed == 1
+		sess.CreatedAt = createdAt.String
+		sess.UpdatedAt = updatedAt.String
+
+		data.UploadSessions = append(data.UploadSessions, sess)
 	}
 
-	// 清空并重新插入 tokens
-	if _, err := tx.Exec("DELETE FROM tokens"); err != nil {
-		return fmt.Errorf("清空 tokens 失败: %w", err)
+	// 加载 presigned_uploads
+	rows, err = b.db.Query(`
+		SELECT id, account_id, file_key, upload_id, size, part_size, content_type,
+			file_name, expiration_days, token_id, expires_at, created_at
+		FROM presigned_uploads
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询 presigned_uploads 失败: %w", err)
 	}
+	defer rows.Close()
 
-	for _, t := range data.Tokens {
-		permissions, _ := json.Marshal(t.Permissions)
+	for rows.Next() {
+		var pu PresignedUpload
+		var uploadID, contentType, fileName, tokenID, createdAt sql.NullString
 
-		_, err := tx.Exec(`
-			INSERT INTO tokens (id, name, token, permissions, created_at)
-			VALUES (?, ?, ?, ?, ?)
-		`, t.ID, t.Name, t.Token, string(permissions), t.CreatedAt)
+		err := rows.Scan(
+			&pu.ID, &pu.AccountID, &pu.FileKey, &uploadID, &pu.Size, &pu.PartSize,
+			&contentType, &fileName, &pu.ExpirationDays, &tokenID, &pu.ExpiresAt, &createdAt,
+		)
 		if err != nil {
-			return fmt.Errorf("插入 token 失败: %w", err)
+			return nil, fmt.Errorf("扫描 presigned_upload 行失败: %w", err)
 		}
-	}
 
-	// 保存 settings
-	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('sync_interval', ?)`,
-		fmt.Sprintf("%d", data.Settings.SyncInterval))
-	if err != nil {
-		return fmt.Errorf("保存 settings 失败: %w", err)
-	}
+		pu.UploadID = uploadID.String
+		pu.ContentType = contentType.String
+		pu.FileName = fileName.String
+		pu.TokenID = tokenID.String
+		pu.CreatedAt = createdAt.String
 
-	endpointProxyVal := "false"
-	if data.Settings.EndpointProxy {
-		endpointProxyVal = "true"
+		data.PresignedUploads = append(data.PresignedUploads, pu)
 	}
-	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('endpoint_proxy', ?)`, endpointProxyVal)
+
+	// 加载 file_objects
+	rows, err = b.db.Query(`
+		SELECT id, hash, size, account_id, file_key, etag, ref_count, created_at,
+			updated_at
+		FROM file_objects
+	`)
 	if err != nil {
-		return fmt.Errorf("保存 settings 失败: %w", err)
+		return nil, fmt.Errorf("查询 file_objects 失败: %w", err)
 	}
+	defer rows.Close()
 
-	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('endpoint_proxy_url', ?)`, data.Settings.EndpointProxyURL)
-	if err != nil {
-		return fmt.Errorf("保存 settings 失败: %w", err)
+	for rows.Next() {
+		var obj FileObject
+		var etag, createdAt, updatedAt sql.NullString
+
+		err := rows.Scan(
+			&obj.ID, &obj.Hash, &obj.Size, &obj.AccountID, &obj.FileKey, &etag,
+			&obj.RefCount, &createdAt, &updatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 file_object 行失败: %w", err)
+		}
+
+		obj.ETag = etag.String
+		obj.CreatedAt = createdAt.String
+		obj.UpdatedAt = updatedAt.String
+
+		data.FileObjects = append(data.FileObjects, obj)
 	}
 
-	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('default_expiration_days', ?)`,
-		fmt.Sprintf("%d", data.Settings.DefaultExpirationDays))
+	// 加载 import_jobs
+	rows, err = b.db.Query(`
+		SELECT id, url, status, account_id, pool, path, expiration_days, token_id, uploader,
+			attempts, max_attempts, total_bytes, downloaded_bytes, uploaded_bytes,
+			target_account_id, result, error, created_at, updated_at, finished_at
+		FROM import_jobs
+	`)
 	if err != nil {
-		return fmt.Errorf("保存 settings 失败: %w", err)
+		return nil, fmt.Errorf("查询 import_jobs 失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var job ImportJob
+		var accountID, pool, path, tokenID, uploader, targetAccountID, result, errMsg, createdAt, updatedAt, finishedAt sql.NullString
+
+		err := rows.Scan(
+			&job.ID, &job.URL, &job.Status, &accountID, &pool, &path, &job.ExpirationDays, &tokenID,
+			&uploader, &job.Attempts, &job.MaxAttempts, &job.TotalBytes,
+			&job.DownloadedBytes, &job.UploadedBytes, &targetAccountID, &result, &errMsg,
+			&createdAt, &updatedAt, &finishedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 import_job 行失败: %w", err)
+		}
+
+		job.AccountID = accountID.String
+		job.Pool = pool.String
+		job.Path = path.String
+		job.TokenID = tokenID.String
+		job.Uploader = uploader.String
+		job.TargetAccountID = targetAccountID.String
+		job.Result = result.String
+		job.Error = errMsg.String
+		job.CreatedAt = createdAt.String
+		job.UpdatedAt = updatedAt.String
+		job.FinishedAt = finishedAt.String
+
+		data.ImportJobs = append(data.ImportJobs, job)
+	}
+
+	// 加载 webhooks
+	rows, err = b.db.Query(`
+		SELECT id, name, url, secret, events, is_active, created_at, updated_at
+		FROM webhooks
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询 webhooks 失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hook Webhook
+		var secret, events, createdAt, updatedAt sql.NullString
+		var isActive int
+
+		err := rows.Scan(
+			&hook.ID, &hook.Name, &hook.URL, &secret, &events, &isActive, &createdAt,
+			&updatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 webhook 行失败: %w", err)
+		}
+
+		hook.Secret = secret.String
+		if events.Valid && events.String != "" {
+			if err := json.Unmarshal([]byte(events.String), &hook.Events); err != nil {
+				hook.Events = []string{}
+			}
+		} else {
+			hook.Events = []string{}
+		}
+		hook.IsActive = isActive == 1
+		hook.CreatedAt = createdAt.String
+		hook.UpdatedAt = updatedAt.String
+
+		data.Webhooks = append(data.Webhooks, hook)
+	}
+
+	// 加载 webhook_deliveries
+	rows, err = b.db.Query(`
+		SELECT id, webhook_id, event_id, event, payload, status, attempts, max_attempts,
+			response_code, error, next_attempt_at, created_at, updated_at,
+			finished_at
+		FROM webhook_deliveries
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询 webhook_deliveries 失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+		var eventID, payload, errMsg, nextAttemptAt, createdAt, updatedAt, finishedAt sql.NullString
+
+		err := rows.Scan(
+			&delivery.ID, &delivery.WebhookID, &eventID, &delivery.Event, &payload,
+			&delivery.Status, &delivery.Attempts, &delivery.MaxAttempts,
+			&delivery.ResponseCode, &errMsg, &nextAttemptAt, &createdAt, &updatedAt,
+			&finishedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 webhook_delivery 行失败: %w", err)
+		}
+
+		delivery.EventID = eventID.String
+		delivery.Payload = payload.String
+		delivery.Error = errMsg.String
+		delivery.NextAttemptAt = nextAttemptAt.String
+		delivery.CreatedAt = createdAt.String
+		delivery.UpdatedAt = updatedAt.String
+		delivery.FinishedAt = finishedAt.String
+
+		data.WebhookDeliveries = append(data.WebhookDeliveries, delivery)
+	}
+
+	// 加载 file_metadata
+	rows, err = b.db.Query(`
+		SELECT id, account_id, file_key, size, metadata, tags, created_at, updated_at
+		FROM file_metadata
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询 file_metadata 失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fm FileMetadata
+		var metadata, tags, createdAt, updatedAt sql.NullString
+
+		err := rows.Scan(
+			&fm.ID, &fm.AccountID, &fm.FileKey, &fm.Size, &metadata, &tags, &createdAt,
+			&updatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 file metadata 行失败: %w", err)
+		}
+
+		if metadata.Valid && metadata.String != "" {
+			if err := json.Unmarshal([]byte(metadata.String), &fm.Metadata); err != nil {
+				fm.Metadata = map[string]string{}
+			}
+		} else {
+			fm.Metadata = map[string]string{}
+		}
+		if tags.Valid && tags.String != "" {
+			if err := json.Unmarshal([]byte(tags.String), &fm.Tags); err != nil {
+				fm.Tags = []string{}
+			}
+		} else {
+			fm.Tags = []string{}
+		}
+		fm.CreatedAt = createdAt.String
+		fm.UpdatedAt = updatedAt.String
+
+		data.FileMetadata = append(data.FileMetadata, fm)
+	}
+
+	// 加载 replica_sets
+	rows, err = b.db.Query(`
+		SELECT id, account_id, file_key, size, sha256, factor, replicas, pool, status,
+			last_error, last_checked_at, created_at, updated_at
+		FROM replica_sets
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询 replica_sets 失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rs ReplicaSet
+		var sha256, replicas, pool, status, lastError, lastCheckedAt, createdAt, updatedAt sql.NullString
+
+		err := rows.Scan(
+			&rs.ID, &rs.AccountID, &rs.FileKey, &rs.Size, &sha256, &rs.Factor, &replicas, &pool,
+			&status, &lastError, &lastCheckedAt, &createdAt, &updatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 replica set 行失败: %w", err)
+		}
+
+		rs.SHA256 = sha256.String
+		if replicas.Valid && replicas.String != "" {
+			if err := json.Unmarshal([]byte(replicas.String), &rs.Replicas); err != nil {
+				rs.Replicas = []string{}
+			}
+		} else {
+			rs.Replicas = []string{}
+		}
+		rs.Pool = pool.String
+		rs.Status = status.String
+		rs.LastError = lastError.String
+		rs.LastCheckedAt = lastCheckedAt.String
+		rs.CreatedAt = createdAt.String
+		rs.UpdatedAt = updatedAt.String
+
+		data.ReplicaSets = append(data.ReplicaSets, rs)
+	}
+
+	// 加载 striped_files
+	rows, err = b.db.Query(`
+		SELECT id, account_id, file_key, original_name, content_type, size, sha256,
+			crc32c, chunks, uploader, created_at, updated_at
+		FROM striped_files
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询 striped_files 失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sf StripedFile
+		var originalName, contentType, sha256, crc32c, chunks, uploader, createdAt, updatedAt sql.NullString
+
+		err := rows.Scan(
+			&sf.ID, &sf.AccountID, &sf.FileKey, &originalName, &contentType, &sf.Size,
+			&sha256, &crc32c, &chunks, &uploader, &createdAt, &updatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 striped file 行失败: %w", err)
+		}
+
+		sf.OriginalName = originalName.String
+		sf.ContentType = contentType.String
+		sf.SHA256 = sha256.String
+		sf.CRC32C = crc32c.String
+		if chunks.Valid && chunks.String != "" {
+			if err := json.Unmarshal([]byte(chunks.String), &sf.Chunks); err != nil {
+				sf.Chunks = []StripedChunk{}
+			}
+		} else {
+			sf.Chunks = []StripedChunk{}
+		}
+		sf.Uploader = uploader.String
+		sf.CreatedAt = createdAt.String
+		sf.UpdatedAt = updatedAt.String
+
+		data.StripedFiles = append(data.StripedFiles, sf)
+	}
+
+	// 加载 pools
+	rows, err = b.db.Query(`
+		SELECT id, name, description, members, placement_strategy, expiration_days,
+			created_at, updated_at
+		FROM pools
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询 pools 失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pool Pool
+		var description, members, placementStrategy, createdAt, updatedAt sql.NullString
+
+		err := rows.Scan(
+			&pool.ID, &pool.Name, &description, &members, &placementStrategy,
+			&pool.ExpirationDays, &createdAt, &updatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 pool 行失败: %w", err)
+		}
+
+		pool.Description = description.String
+		if members.Valid && members.String != "" {
+			if err := json.Unmarshal([]byte(members.String), &pool.Members); err != nil {
+				pool.Members = []PoolMember{}
+			}
+		} else {
+			pool.Members = []PoolMember{}
+		}
+		pool.PlacementStrategy = placementStrategy.String
+		pool.CreatedAt = createdAt.String
+		pool.UpdatedAt = updatedAt.String
+
+		data.Pools = append(data.Pools, pool)
+	}
+
+	return data, nil
+}
+
+// Save 保存全部数据到数据库（按行写入的表由 SaveRows 写入）
+func (b *TursoBackend) Save(data *Data) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 清空并重新插入 accounts
+	if _, err := tx.Exec("DELETE FROM accounts"); err != nil {
+		return fmt.Errorf("清空 accounts 失败: %w", err)
+	}
+
+	for _, acc := range data.Accounts {
+		stripImageMetadata := 0
+		if acc.StripImageMetadata {
+			stripImageMetadata = 1
+		}
+		encryption := 0
+		if acc.Encryption {
+			encryption = 1
+		}
+		pathStyle := 0
+		if acc.PathStyle {
+			pathStyle = 1
+		}
+		isActive := 0
+		if acc.IsActive {
+			isActive = 1
+		}
+		permWebDAV, permAutoUpload, permAPIUpload, permClientUpload := 0, 0, 0, 0
+		if acc.Permissions.WebDAV {
+			permWebDAV = 1
+		}
+		if acc.Permissions.AutoUpload {
+			permAutoUpload = 1
+		}
+		if acc.Permissions.APIUpload {
+			permAPIUpload = 1
+		}
+		if acc.Permissions.ClientUpload {
+			permClientUpload = 1
+		}
+
+		usageHistory, _ := json.Marshal(acc.UsageHistory)
+
+		_, err := tx.Exec(`
+			INSERT INTO accounts (
+				id, name, is_active, description, account_id, access_key_id,
+				secret_access_key, bucket_name, endpoint, public_domain, api_token,
+				quota_max_size_bytes, quota_max_class_a_ops, quota_max_class_b_ops,
+				usage_size_bytes, usage_class_a_ops, usage_class_b_ops, usage_last_sync_at,
+				perm_webdav, perm_auto_upload, perm_api_upload, perm_client_upload,
+				link_mode, presign_ttl,
+				key_template,
+				strip_image_metadata,
+				encryption,
+				weight,
+				usage_history,
+				provider, region, path_style,
+				created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			acc.ID, acc.Name, isActive, acc.Description, acc.AccountID, acc.AccessKeyId,
+			acc.SecretAccessKey, acc.BucketName, acc.Endpoint, acc.PublicDomain, acc.APIToken,
+			acc.Quota.MaxSizeBytes, acc.Quota.MaxClassAOps, acc.Quota.MaxClassBOps,
+			acc.Usage.SizeBytes, acc.Usage.ClassAOps, acc.Usage.ClassBOps, acc.Usage.LastSyncAt,
+			permWebDAV, permAutoUpload, permAPIUpload, permClientUpload,
+			acc.LinkMode, acc.PresignTTL,
+			acc.KeyTemplate,
+			stripImageMetadata,
+			encryption,
+			acc.Weight,
+			string(usageHistory),
+			acc.Provider, acc.Region, pathStyle,
+			acc.CreatedAt, acc.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("插入 account 失败: %w", err)
+		}
+	}
+
+	// 清空并重新插入 tokens
+	if _, err := tx.Exec("DELETE FROM tokens"); err != nil {
+		return fmt.Errorf("清空 tokens 失败: %w", err)
+	}
+
+	for _, t := range data.Tokens {
+		stripImageMetadata := 0
+		if t.StripImageMetadata {
+			stripImageMetadata = 1
+		}
+		permissions, _ := json.Marshal(t.Permissions)
+		pools, _ := json.Marshal(t.Pools)
+
+		_, err := tx.Exec(`
+			INSERT INTO tokens (id, name, token, permissions, key_template, strip_image_metadata, placement_strategy, pools, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, t.ID, t.Name, t.Token, string(permissions), t.KeyTemplate, stripImageMetadata, t.PlacementStrategy, string(pools), t.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("插入 token 失败: %w", err)
+		}
+	}
+
+	// 保存 settings
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('sync_interval', ?)`,
+		fmt.Sprintf("%d", data.Settings.SyncInterval))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	endpointProxyVal := "false"
+	if data.Settings.EndpointProxy {
+		endpointProxyVal = "true"
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('endpoint_proxy', ?)`, endpointProxyVal)
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('endpoint_proxy_url', ?)`, data.Settings.EndpointProxyURL)
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('default_expiration_days', ?)`,
+		fmt.Sprintf("%d", data.Settings.DefaultExpirationDays))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
 	}
 
 	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('expiration_check_minutes', ?)`,
@@ -465,6 +1286,84 @@ func (b *TursoBackend) Save(data *Data) error {
 		return fmt.Errorf("保存 settings 失败: %w", err)
 	}
 
+	dedupEnabledVal := "false"
+	if data.Settings.DedupEnabled {
+		dedupEnabledVal = "true"
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('dedup_enabled', ?)`, dedupEnabledVal)
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('key_template', ?)`, data.Settings.KeyTemplate)
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('key_collision', ?)`, data.Settings.KeyCollision)
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('url_allowed_hosts', ?)`, data.Settings.URLAllowedHosts)
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('url_max_download_mb', ?)`, fmt.Sprintf("%d", data.Settings.URLMaxDownloadMB))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('url_max_redirects', ?)`, fmt.Sprintf("%d", data.Settings.URLMaxRedirects))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('url_allowed_types', ?)`, data.Settings.URLAllowedTypes)
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('url_denied_types', ?)`, data.Settings.URLDeniedTypes)
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	thumbnailEnabledVal := "false"
+	if data.Settings.ThumbnailEnabled {
+		thumbnailEnabledVal = "true"
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('thumbnail_enabled', ?)`, thumbnailEnabledVal)
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('thumbnail_sizes', ?)`, data.Settings.ThumbnailSizes)
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('thumbnail_quality', ?)`, fmt.Sprintf("%d", data.Settings.ThumbnailQuality))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('replication_factor', ?)`, fmt.Sprintf("%d", data.Settings.ReplicationFactor))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('ops_alert_percent', ?)`, fmt.Sprintf("%d", data.Settings.OpsAlertPercent))
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('placement_strategy', ?)`, data.Settings.PlacementStrategy)
+	if err != nil {
+		return fmt.Errorf("保存 settings 失败: %w", err)
+	}
+
 	// 清空并重新插入 webdav_credentials
 	if _, err := tx.Exec("DELETE FROM webdav_credentials"); err != nil {
 		return fmt.Errorf("清空 webdav_credentials 失败: %w", err)
@@ -498,17 +1397,268 @@ func (b *TursoBackend) Save(data *Data) error {
 
 	for _, exp := range data.FileExpirations {
 		_, err := tx.Exec(`
-			INSERT INTO file_expirations (id, account_id, file_key, expires_at, created_at)
-			VALUES (?, ?, ?, ?, ?)
-		`, exp.ID, exp.AccountID, exp.FileKey, exp.ExpiresAt, exp.CreatedAt)
+			INSERT INTO file_expirations (id, account_id, file_key, size, expires_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, exp.ID, exp.AccountID, exp.FileKey, exp.Size, exp.ExpiresAt, exp.CreatedAt)
 		if err != nil {
 			return fmt.Errorf("插入 file_expiration 失败: %w", err)
 		}
 	}
 
+	// 清空并重新插入 presigned_uploads
+	if _, err := tx.Exec("DELETE FROM presigned_uploads"); err != nil {
+		return fmt.Errorf("清空 presigned_uploads 失败: %w", err)
+	}
+
+	for _, pu := range data.PresignedUploads {
+		_, err := tx.Exec(`
+			INSERT INTO presigned_uploads (
+				id, account_id, file_key, upload_id, size, part_size, content_type,
+				file_name, expiration_days, token_id, expires_at, created_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			pu.ID, pu.AccountID, pu.FileKey, pu.UploadID, pu.Size, pu.PartSize,
+			pu.ContentType, pu.FileName, pu.ExpirationDays, pu.TokenID, pu.ExpiresAt,
+			pu.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("插入 presigned_upload 失败: %w", err)
+		}
+	}
+
+	// 清空并重新插入 import_jobs
+	if _, err := tx.Exec("DELETE FROM import_jobs"); err != nil {
+		return fmt.Errorf("清空 import_jobs 失败: %w", err)
+	}
+
+	for _, job := range data.ImportJobs {
+		_, err := tx.Exec(`
+			INSERT INTO import_jobs (
+				id, url, status, account_id, pool, path, expiration_days, token_id, uploader,
+				attempts, max_attempts, total_bytes, downloaded_bytes, uploaded_bytes,
+				target_account_id, result, error, created_at, updated_at, finished_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			job.ID, job.URL, job.Status, job.AccountID, job.Pool, job.Path, job.ExpirationDays,
+			job.TokenID, job.Uploader, job.Attempts, job.MaxAttempts, job.TotalBytes,
+			job.DownloadedBytes, job.UploadedBytes, job.TargetAccountID, job.Result,
+			job.Error, job.CreatedAt, job.UpdatedAt, job.FinishedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("插入 import_job 失败: %w", err)
+		}
+	}
+
+	// 清空并重新插入 webhooks
+	if _, err := tx.Exec("DELETE FROM webhooks"); err != nil {
+		return fmt.Errorf("清空 webhooks 失败: %w", err)
+	}
+
+	for _, hook := range data.Webhooks {
+		events, _ := json.Marshal(hook.Events)
+		isActive := 0
+		if hook.IsActive {
+			isActive = 1
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO webhooks (
+				id, name, url, secret, events, is_active, created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			hook.ID, hook.Name, hook.URL, hook.Secret, string(events), isActive,
+			hook.CreatedAt, hook.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("插入 webhook 失败: %w", err)
+		}
+	}
+
+	// 清空并重新插入 pools
+	if _, err := tx.Exec("DELETE FROM pools"); err != nil {
+		return fmt.Errorf("清空 pools 失败: %w", err)
+	}
+
+	for _, pool := range data.Pools {
+		members, _ := json.Marshal(pool.Members)
+
+		_, err := tx.Exec(`
+			INSERT INTO pools (
+				id, name, description, members, placement_strategy, expiration_days,
+				created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			pool.ID, pool.Name, pool.Description, string(members), pool.PlacementStrategy,
+			pool.ExpirationDays, pool.CreatedAt, pool.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("插入 pool 失败: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SaveRows 逐行插入或替换按行写入的记录
+func (b *TursoBackend) SaveRows(rows ...interface{}) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		table, id, err := rowTableOf(row)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM "+string(table)+" WHERE id = ?", id); err != nil {
+			return fmt.Errorf("删除 %s 记录失败: %w", table, err)
+		}
+		if err := b.insertRow(tx, row); err != nil {
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
+// DeleteRows 按 ID 删除按行写入的记录
+func (b *TursoBackend) DeleteRows(table RowTable, ids ...string) error {
+	if err := table.validate(); err != nil {
+		return err
+	}
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if _, err := tx.Exec("DELETE FROM "+string(table)+" WHERE id = ?", id); err != nil {
+			return fmt.Errorf("删除 %s 记录失败: %w", table, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertRow 插入单条按行写入的记录
+func (b *TursoBackend) insertRow(tx *sql.Tx, row interface{}) error {
+	switch r := row.(type) {
+	case UploadSession:
+		sess := r
+		parts, _ := json.Marshal(sess.Parts)
+		completed := 0
+		if sess.Completed {
+			completed = 1
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO upload_sessions (
+				id, account_id, file_key, upload_id, size, offset_bytes, part_size,
+				parts, content_type, file_name, expiration_days, token_id, completed,
+				expires_at, created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			sess.ID, sess.AccountID, sess.FileKey, sess.UploadID, sess.Size, sess.Offset,
+			sess.PartSize, string(parts), sess.ContentType, sess.FileName,
+			sess.ExpirationDays, sess.TokenID, completed, sess.ExpiresAt, sess.CreatedAt,
+			sess.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("插入 upload_session 失败: %w", err)
+		}
+
+	case FileObject:
+		obj := r
+		_, err := tx.Exec(`
+			INSERT INTO file_objects (
+				id, hash, size, account_id, file_key, etag, ref_count, created_at,
+				updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			obj.ID, obj.Hash, obj.Size, obj.AccountID, obj.FileKey, obj.ETag, obj.RefCount,
+			obj.CreatedAt, obj.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("插入 file_object 失败: %w", err)
+		}
+
+	case WebhookDelivery:
+		delivery := r
+		_, err := tx.Exec(`
+			INSERT INTO webhook_deliveries (
+				id, webhook_id, event_id, event, payload, status, attempts, max_attempts,
+				response_code, error, next_attempt_at, created_at, updated_at,
+				finished_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			delivery.ID, delivery.WebhookID, delivery.EventID, delivery.Event,
+			delivery.Payload, delivery.Status, delivery.Attempts, delivery.MaxAttempts,
+			delivery.ResponseCode, delivery.Error, delivery.NextAttemptAt,
+			delivery.CreatedAt, delivery.UpdatedAt, delivery.FinishedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("插入 webhook_delivery 失败: %w", err)
+		}
+
+	case FileMetadata:
+		fm := r
+		metadata, _ := json.Marshal(fm.Metadata)
+		tags, _ := json.Marshal(fm.Tags)
+
+		_, err := tx.Exec(`
+			INSERT INTO file_metadata (
+				id, account_id, file_key, size, metadata, tags, created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			fm.ID, fm.AccountID, fm.FileKey, fm.Size, string(metadata), string(tags),
+			fm.CreatedAt, fm.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("插入 file metadata 失败: %w", err)
+		}
+
+	case ReplicaSet:
+		rs := r
+		replicas, _ := json.Marshal(rs.Replicas)
+
+		_, err := tx.Exec(`
+			INSERT INTO replica_sets (
+				id, account_id, file_key, size, sha256, factor, replicas, pool, status,
+				last_error, last_checked_at, created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			rs.ID, rs.AccountID, rs.FileKey, rs.Size, rs.SHA256, rs.Factor, string(replicas),
+			rs.Pool, rs.Status, rs.LastError, rs.LastCheckedAt, rs.CreatedAt, rs.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("插入 replica set 失败: %w", err)
+		}
+
+	case StripedFile:
+		sf := r
+		chunks, _ := json.Marshal(sf.Chunks)
+
+		_, err := tx.Exec(`
+			INSERT INTO striped_files (
+				id, account_id, file_key, original_name, content_type, size, sha256,
+				crc32c, chunks, uploader, created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			sf.ID, sf.AccountID, sf.FileKey, sf.OriginalName, sf.ContentType, sf.Size,
+			sf.SHA256, sf.CRC32C, string(chunks), sf.Uploader, sf.CreatedAt, sf.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("插入 striped file 失败: %w", err)
+		}
+	default:
+		return fmt.Errorf("不支持按行写入的记录类型: %T", row)
+	}
+	return nil
+}
+
 // Close 关闭数据库连接
 func (b *TursoBackend) Close() error {
 	if b.db != nil {
@@ -516,4 +1666,3 @@ func (b *TursoBackend) Close() error {
 	}
 	return nil
 }
-
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// GetAllFileMetadata 获取所有自定义元数据记录
+func GetAllFileMetadata() []FileMetadata {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	if data.FileMetadata == nil {
+		return []FileMetadata{}
+	}
+
+	result := make([]FileMetadata, len(data.FileMetadata))
+	copy(result, data.FileMetadata)
+	return result
+}
+
+// GetFileMetadataByAccount 获取账户的所有自定义元数据记录，按文件路径索引
+func GetFileMetadataByAccount(accountID string) map[string]FileMetadata {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	result := make(map[string]FileMetadata)
+	for _, fm := range data.FileMetadata {
+		if fm.AccountID == accountID {
+			result[fm.FileKey] = fm
+		}
+	}
+	return result
+}
+
+// GetFileMetadataByKey 按文件位置获取自定义元数据记录
+func GetFileMetadataByKey(accountID, fileKey string) (*FileMetadata, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, fm := range data.FileMetadata {
+		if fm.AccountID == accountID && fm.FileKey == fileKey {
+			result := fm
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("自定义元数据记录不存在")
+}
+
+// SetFileMetadata 保存文件的自定义元数据和标签，同一位置已有记录时替换
+// 元数据和标签都为空时删除记录
+func SetFileMetadata(fm *FileMetadata) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	if fm.Metadata == nil {
+		fm.Metadata = map[string]string{}
+	}
+	if fm.Tags == nil {
+		fm.Tags = []string{}
+	}
+	empty := len(fm.Metadata) == 0 && len(fm.Tags) == 0
+	now := NowString()
+
+	for i, existing := range data.FileMetadata {
+		if existing.AccountID == fm.AccountID && existing.FileKey == fm.FileKey {
+			if empty {
+				data.FileMetadata = append(data.FileMetadata[:i], data.FileMetadata[i+1:]...)
+				return deleteRows(TableFileMetadata, existing.ID)
+			}
+			fm.ID = existing.ID
+			fm.CreatedAt = existing.CreatedAt
+			fm.UpdatedAt = now
+			data.FileMetadata[i] = *fm
+			return saveRows(*fm)
+		}
+	}
+
+	if empty {
+		return nil
+	}
+	fm.ID = uuid.New().String()
+	fm.CreatedAt = now
+	fm.UpdatedAt = now
+	data.FileMetadata = append(data.FileMetadata, *fm)
+	return saveRows(*fm)
+}
+
+// DeleteFileMetadata 删除文件的自定义元数据记录，不存在时忽略
+func DeleteFileMetadata(accountID, fileKey string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, fm := range data.FileMetadata {
+		if fm.AccountID == accountID && fm.FileKey == fileKey {
+			data.FileMetadata = append(data.FileMetadata[:i], data.FileMetadata[i+1:]...)
+			return deleteRows(TableFileMetadata, fm.ID)
+		}
+	}
+	return nil
+}
+
+// MoveFileMetadata 文件或目录移动后更新自定义元数据记录的路径
+// from 以 / 结尾时视为目录，移动其下的所有记录
+func MoveFileMetadata(accountID, from, to string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	var moved []interface{}
+	for i := range data.FileMetadata {
+		fm := &data.FileMetadata[i]
+		if fm.AccountID != accountID {
+			continue
+		}
+		switch {
+		case fm.FileKey == from:
+			fm.FileKey = to
+		case strings.HasSuffix(from, "/") && strings.HasPrefix(fm.FileKey, from):
+			fm.FileKey = to + strings.TrimPrefix(fm.FileKey, from)
+		default:
+			continue
+		}
+		fm.UpdatedAt = NowString()
+		moved = append(moved, *fm)
+	}
+
+	return saveRows(moved...)
+}
+
+// DeleteFileMetadataByPrefix 删除指定账户中路径以 prefix 开头的自定义元数据记录
+// prefix 为空时删除该账户的全部记录
+func DeleteFileMetadataByPrefix(accountID, prefix string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	kept := data.FileMetadata[:0]
+	var removed []string
+	for _, fm := range data.FileMetadata {
+		if fm.AccountID == accountID && strings.HasPrefix(fm.FileKey, prefix) {
+			removed = append(removed, fm.ID)
+			continue
+		}
+		kept = append(kept, fm)
+	}
+	data.FileMetadata = kept
+
+	return deleteRows(TableFileMetadata, removed...)
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// GetFileObjects 获取所有内容索引记录
+func GetFileObjects() []FileObject {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	if data.FileObjects == nil {
+		return []FileObject{}
+	}
+
+	result := make([]FileObject, len(data.FileObjects))
+	copy(result, data.FileObjects)
+	return result
+}
+
+// GetFileObjectsByHash 获取指定 SHA-256 的所有内容索引记录
+func GetFileObjectsByHash(hash string) []FileObject {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	var result []FileObject
+	for _, obj := range data.FileObjects {
+		if obj.Hash == hash {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+// GetFileObjectByKey 按物理对象位置获取内容索引记录
+func GetFileObjectByKey(accountID, fileKey string) (*FileObject, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, obj := range data.FileObjects {
+		if obj.AccountID == accountID && obj.FileKey == fileKey {
+			result := obj
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("内容索引记录不存在")
+}
+
+// CreateFileObject 创建内容索引记录
+// 同一位置已有记录时（覆盖写入）更新哈希和大小，原有引用仍指向该路径，引用计数在原有基础上累加
+func CreateFileObject(obj *FileObject) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	if obj.RefCount <= 0 {
+		obj.RefCount = 1
+	}
+	obj.UpdatedAt = NowString()
+
+	for i, o := range data.FileObjects {
+		if o.AccountID == obj.AccountID && o.FileKey == obj.FileKey {
+			obj.ID = o.ID
+			obj.RefCount += o.RefCount
+			obj.CreatedAt = o.CreatedAt
+			data.FileObjects[i] = *obj
+			return saveRows(*obj)
+		}
+	}
+
+	if obj.ID == "" {
+		obj.ID = uuid.New().String()
+	}
+	obj.CreatedAt = obj.UpdatedAt
+	data.FileObjects = append(data.FileObjects, *obj)
+	return saveRows(*obj)
+}
+
+// AddFileObjectRef 增加内容索引记录的引用计数
+func AddFileObjectRef(id string) (*FileObject, error) {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i := range data.FileObjects {
+		if data.FileObjects[i].ID == id {
+			data.FileObjects[i].RefCount++
+			data.FileObjects[i].UpdatedAt = NowString()
+			result := data.FileObjects[i]
+			return &result, saveRows(result)
+		}
+	}
+	return nil, fmt.Errorf("内容索引记录不存在")
+}
+
+// ReleaseFileObject 减少指定位置的引用计数，返回剩余引用数
+// 引用归零时删除记录；不存在记录时返回 0，表示可以直接删除物理对象
+func ReleaseFileObject(accountID, fileKey string) (int, error) {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i := range data.FileObjects {
+		obj := &data.FileObjects[i]
+		if obj.AccountID != accountID || obj.FileKey != fileKey {
+			continue
+		}
+		obj.RefCount--
+		if obj.RefCount > 0 {
+			obj.UpdatedAt = NowString()
+			return obj.RefCount, saveRows(*obj)
+		}
+		id := obj.ID
+		data.FileObjects = append(data.FileObjects[:i], data.FileObjects[i+1:]...)
+		return 0, deleteRows(TableFileObjects, id)
+	}
+	return 0, nil
+}
+
+// DeleteFileObject 按 ID 删除内容索引记录
+func DeleteFileObject(id string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, obj := range data.FileObjects {
+		if obj.ID == id {
+			data.FileObjects = append(data.FileObjects[:i], data.FileObjects[i+1:]...)
+			return deleteRows(TableFileObjects, id)
+		}
+	}
+	return nil
+}
+
+// DeleteFileObjectsByPrefix 删除指定账户中路径以 prefix 开头的内容索引记录
+// prefix 为空时删除该账户的全部记录
+func DeleteFileObjectsByPrefix(accountID, prefix string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	var remaining []FileObject
+	var removed []string
+	for _, obj := range data.FileObjects {
+		if obj.AccountID == accountID && strings.HasPrefix(obj.FileKey, prefix) {
+			removed = append(removed, obj.ID)
+			continue
+		}
+		remaining = append(remaining, obj)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	data.FileObjects = remaining
+	return deleteRows(TableFileObjects, removed...)
+}
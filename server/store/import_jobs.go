@@ -0,0 +1,92 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetImportJobs 获取所有 URL 导入任务
+func GetImportJobs() []ImportJob {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	if data.ImportJobs == nil {
+		return []ImportJob{}
+	}
+
+	result := make([]ImportJob, len(data.ImportJobs))
+	copy(result, data.ImportJobs)
+	return result
+}
+
+// GetImportJobByID 按 ID 获取 URL 导入任务
+func GetImportJobByID(id string) (*ImportJob, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, job := range data.ImportJobs {
+		if job.ID == id {
+			result := job
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("导入任务不存在")
+}
+
+// CreateImportJobs 批量创建 URL 导入任务，只保存一次
+func CreateImportJobs(jobs []*ImportJob) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	now := NowString()
+	for _, job := range jobs {
+		if job.ID == "" {
+			job.ID = uuid.New().String()
+		}
+		job.CreatedAt = now
+		job.UpdatedAt = now
+		data.ImportJobs = append(data.ImportJobs, *job)
+	}
+	return save()
+}
+
+// UpdateImportJob 更新 URL 导入任务
+func UpdateImportJob(job *ImportJob) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, j := range data.ImportJobs {
+		if j.ID == job.ID {
+			job.UpdatedAt = NowString()
+			data.ImportJobs[i] = *job
+			return save()
+		}
+	}
+	return fmt.Errorf("导入任务不存在")
+}
+
+// DeleteFinishedImportJobs 删除结束时间早于 before 的已结束任务，返回删除数量
+func DeleteFinishedImportJobs(before time.Time) (int, error) {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	kept := data.ImportJobs[:0]
+	removed := 0
+	for _, job := range data.ImportJobs {
+		if job.FinishedAt != "" {
+			if finished, err := time.Parse(time.RFC3339, job.FinishedAt); err == nil && finished.Before(before) {
+				removed++
+				continue
+			}
+		}
+		kept = append(kept, job)
+	}
+	data.ImportJobs = kept
+
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, save()
+}
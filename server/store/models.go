@@ -1,6 +1,9 @@
 package store
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // AccountPermissions 账户权限配置
 type AccountPermissions struct {
@@ -10,6 +13,71 @@ type AccountPermissions struct {
 	ClientUpload bool `json:"clientUpload"` // 是否允许前端客户端上传
 }
 
+const (
+	// LinkModePublic 通过公开访问域名生成文件链接
+	LinkModePublic = "public"
+	// LinkModePrivate 通过 S3 预签名 GET 请求生成限时链接，适用于私有存储桶
+	LinkModePrivate = "private"
+)
+
+// 存储服务商
+const (
+	// ProviderR2 Cloudflare R2，未设置服务商的旧账户按 R2 处理
+	ProviderR2 = "r2"
+	// ProviderAWS Amazon S3
+	ProviderAWS = "aws"
+	// ProviderMinIO MinIO
+	ProviderMinIO = "minio"
+	// ProviderB2 Backblaze B2 的 S3 兼容接口
+	ProviderB2 = "b2"
+	// ProviderWasabi Wasabi
+	ProviderWasabi = "wasabi"
+	// ProviderGeneric 其他 S3 兼容服务
+	ProviderGeneric = "generic"
+)
+
+// DefaultS3Region 非 R2 服务商未配置区域时使用的区域
+const DefaultS3Region = "us-east-1"
+
+const (
+	// KeyCollisionSuffix 目标路径已存在时在文件名后追加 -1、-2 等序号
+	KeyCollisionSuffix = "suffix"
+	// KeyCollisionOverwrite 目标路径已存在时直接覆盖
+	KeyCollisionOverwrite = "overwrite"
+	// KeyCollisionReject 目标路径已存在时拒绝上传
+	KeyCollisionReject = "reject"
+)
+
+const (
+	// PlacementLeastUsed 按容量使用率从低到高选择账户
+	PlacementLeastUsed = "least-used"
+	// PlacementLeastOps 按 A 类操作次数使用率从低到高选择账户
+	PlacementLeastOps = "least-ops"
+	// PlacementWeightedRoundRobin 按账户权重轮流选择
+	PlacementWeightedRoundRobin = "weighted-round-robin"
+	// PlacementConsistentHash 按路径前缀一致性哈希选择，同一目录的文件写入同一账户
+	PlacementConsistentHash = "consistent-hash"
+	// PlacementRandom 在剩余空间足够的账户中按权重随机选择
+	PlacementRandom = "random"
+)
+
+const (
+	// DefaultURLMaxDownloadMB URL 上传默认的最大下载大小（MB）
+	DefaultURLMaxDownloadMB = 1024
+	// DefaultURLMaxRedirects URL 上传默认最多跟随的重定向次数
+	DefaultURLMaxRedirects = 5
+	// DefaultThumbnailSizes 默认缩略图尺寸（最长边像素）
+	DefaultThumbnailSizes = "200,800"
+	// DefaultThumbnailQuality 默认缩略图 JPEG 质量
+	DefaultThumbnailQuality = 80
+	// DefaultReplicationFactor 默认副本数，1 表示不复制
+	DefaultReplicationFactor = 1
+	// MaxReplicationFactor 副本数上限
+	MaxReplicationFactor = 5
+	// DefaultOpsAlertPercent 默认的操作次数告警阈值（占配额的百分比）
+	DefaultOpsAlertPercent = 80
+)
+
 // DefaultAccountPermissions 返回默认权限配置（全部启用）
 func DefaultAccountPermissions() AccountPermissions {
 	return AccountPermissions{
@@ -20,30 +88,46 @@ func DefaultAccountPermissions() AccountPermissions {
 	}
 }
 
-// Account R2 账户
+// Account 存储账户（一个 S3 兼容的存储桶）
 type Account struct {
-	ID              string             `json:"id"`
-	Name            string             `json:"name"`
-	IsActive        bool               `json:"isActive"`
-	Description     string             `json:"description"`
-	AccountID       string             `json:"accountId"`       // Cloudflare Account ID
-	AccessKeyId     string             `json:"accessKeyId"`     // R2 Access Key ID
-	SecretAccessKey string             `json:"secretAccessKey"` // R2 Secret Access Key
-	BucketName      string             `json:"bucketName"`
-	Endpoint        string             `json:"endpoint"`     // R2 Endpoint URL
-	PublicDomain    string             `json:"publicDomain"` // 公开访问域名
-	APIToken        string             `json:"apiToken"`     // Cloudflare API Token (用于 GraphQL 查询)
-	Quota           Quota              `json:"quota"`
-	Usage           Usage              `json:"usage"`
-	Permissions     AccountPermissions `json:"permissions"` // 账户权限配置
-	CreatedAt       string             `json:"createdAt"`
-	UpdatedAt       string             `json:"updatedAt"`
+	ID                 string             `json:"id"`
+	Name               string             `json:"name"`
+	IsActive           bool               `json:"isActive"`
+	Description        string             `json:"description"`
+	Provider           string             `json:"provider"`        // 存储服务商，为空表示 R2
+	AccountID          string             `json:"accountId"`       // Cloudflare Account ID（仅 R2）
+	AccessKeyId        string             `json:"accessKeyId"`     // Access Key ID
+	SecretAccessKey    string             `json:"secretAccessKey"` // Secret Access Key
+	BucketName         string             `json:"bucketName"`
+	Endpoint           string             `json:"endpoint"`     // S3 Endpoint URL，AWS 可为空（按区域解析）
+	Region             string             `json:"region"`       // 签名区域，为空时按服务商默认
+	PathStyle          bool               `json:"pathStyle"`    // 使用路径风格访问存储桶（MinIO 等未配置虚拟主机的服务）
+	PublicDomain       string             `json:"publicDomain"` // 公开访问域名
+	APIToken           string             `json:"apiToken"`     // Cloudflare API Token (用于 GraphQL 查询，仅 R2)
+	Quota              Quota              `json:"quota"`
+	Usage              Usage              `json:"usage"`
+	Permissions        AccountPermissions `json:"permissions"`        // 账户权限配置
+	LinkMode           string             `json:"linkMode"`           // 链接模式：public（公开域名）或 private（预签名链接）
+	PresignTTL         int                `json:"presignTtl"`         // 预签名链接有效期（秒），0 表示使用默认值
+	KeyTemplate        string             `json:"keyTemplate"`        // 存储路径模板，为空时使用系统设置
+	StripImageMetadata bool               `json:"stripImageMetadata"` // 写入前移除图片的 EXIF、XMP 和 GPS 信息
+	Encryption         bool               `json:"encryption"`         // 写入前使用 FileFlow 管理的密钥加密对象内容
+	Weight             int                `json:"weight"`             // 放置权重（加权轮询、一致性哈希和随机策略），0 视为 1
+	UsageHistory       []UsageSample      `json:"usageHistory"`       // 最近的用量同步结果，用于预测本月操作次数
+	CreatedAt          string             `json:"createdAt"`
+	UpdatedAt          string             `json:"updatedAt"`
+
+	// PendingUsage 上次同步后本地记录的用量变化，只保存在内存中，同步时与权威数据对账
+	PendingUsage UsageDelta `json:"-"`
+	// ReservedBytes 进行中的上传在该账户上预留的容量合计，只保存在内存中（见 reservations.go）
+	ReservedBytes int64 `json:"-"`
 }
 
 // Quota 账户配额限制（用户手动配置）
 type Quota struct {
-	MaxSizeBytes  int64 `json:"maxSizeBytes"`  // 最大存储容量（字节）
-	MaxClassAOps  int64 `json:"maxClassAOps"`  // 最大 Class A 操作数
+	MaxSizeBytes int64 `json:"maxSizeBytes"` // 最大存储容量（字节）
+	MaxClassAOps int64 `json:"maxClassAOps"` // 最大 Class A 操作数
+	MaxClassBOps int64 `json:"maxClassBOps"` // 每月最大 Class B 操作数，0 表示不限制
 }
 
 // Usage 账户使用量（通过 R2 API 动态获取）
@@ -54,21 +138,64 @@ type Usage struct {
 	LastSyncAt string `json:"lastSyncAt"` // 上次同步时间
 }
 
+// UsageSample 一次用量同步的结果
+type UsageSample struct {
+	SyncedAt  string `json:"syncedAt"`  // 同步时间 (ISO 8601)
+	ClassAOps int64  `json:"classAOps"` // 本月累计 Class A 操作数
+	ClassBOps int64  `json:"classBOps"` // 本月累计 Class B 操作数
+}
+
+// UsageDelta 本地记录的用量变化：上传、删除、复制的字节数和发出的 A/B 类操作次数
+type UsageDelta struct {
+	SizeBytes int64 `json:"sizeBytes"`
+	ClassAOps int64 `json:"classAOps"`
+	ClassBOps int64 `json:"classBOps"`
+}
+
+// Add 累加另一个用量变化
+func (d *UsageDelta) Add(other UsageDelta) {
+	d.SizeBytes += other.SizeBytes
+	d.ClassAOps += other.ClassAOps
+	d.ClassBOps += other.ClassBOps
+}
+
 // Token API 访问令牌
 type Token struct {
-	ID          string   `json:"id"`
-	Name        string   `json:"name"`
-	Token       string   `json:"token"`
-	Permissions []string `json:"permissions"` // read, write, delete
-	CreatedAt   string   `json:"createdAt"`
+	ID                 string   `json:"id"`
+	Name               string   `json:"name"`
+	Token              string   `json:"token"`
+	Permissions        []string `json:"permissions"`        // read, write, delete
+	KeyTemplate        string   `json:"keyTemplate"`        // 存储路径模板，为空时使用账户或系统设置
+	PlacementStrategy  string   `json:"placementStrategy"`  // 智能上传的账户放置策略，为空时使用系统设置
+	StripImageMetadata bool     `json:"stripImageMetadata"` // 通过该 Token 上传的图片移除 EXIF、XMP 和 GPS 信息
+	Pools              []string `json:"pools"`              // 允许访问的账户池名称，为空时不限制；未指定账户或账户池的上传写入第一个池
+	CreatedAt          string   `json:"createdAt"`
+}
+
+// Pool 账户池，上传、列表、删除等接口和 Token 的访问范围按名称引用
+type Pool struct {
+	ID                string       `json:"id"`
+	Name              string       `json:"name"`              // 池名称，唯一
+	Description       string       `json:"description"`       // 描述
+	Members           []PoolMember `json:"members"`           // 成员账户
+	PlacementStrategy string       `json:"placementStrategy"` // 池内的放置策略，为空时按 Token、系统设置选择
+	ExpirationDays    int          `json:"expirationDays"`    // 上传到该池的默认到期天数，-1 使用系统设置，0 表示永久
+	CreatedAt         string       `json:"createdAt"`         // 创建时间
+	UpdatedAt         string       `json:"updatedAt"`         // 更新时间
+}
+
+// PoolMember 账户池成员
+type PoolMember struct {
+	AccountID string `json:"accountId"` // 账户ID
+	Priority  int    `json:"priority"`  // 优先级，数值小的成员优先写入，相同优先级的成员按放置策略排列
 }
 
 // WebDAVCredential WebDAV 访问凭证
 type WebDAVCredential struct {
 	ID          string   `json:"id"`
-	Username    string   `json:"username"`    // WebDAV 用户名
-	Password    string   `json:"password"`    // WebDAV 密码
-	AccountID   string   `json:"accountId"`   // 关联的账户 ID
+	Username    string   `json:"username"`  // WebDAV 用户名
+	Password    string   `json:"password"`  // WebDAV 密码
+	AccountID   string   `json:"accountId"` // 关联的账户 ID
 	Description string   `json:"description"`
 	Permissions []string `json:"permissions"` // read, write, delete
 	IsActive    bool     `json:"isActive"`
@@ -91,20 +218,212 @@ type FileExpiration struct {
 	ID        string `json:"id"`        // 记录ID
 	AccountID string `json:"accountId"` // 所属账户ID
 	FileKey   string `json:"fileKey"`   // S3中的文件路径
+	Size      int64  `json:"size"`      // 文件大小（字节），删除时用于更新容量计数，0 表示未知
 	ExpiresAt string `json:"expiresAt"` // 到期时间 (ISO 8601)
 	CreatedAt string `json:"createdAt"` // 创建时间
 }
 
+// FileObject 内容寻址索引，记录 SHA-256 对应的物理对象及引用计数
+type FileObject struct {
+	ID        string `json:"id"`
+	Hash      string `json:"hash"`      // 文件内容的 SHA-256（十六进制）
+	Size      int64  `json:"size"`      // 文件大小（字节）
+	AccountID string `json:"accountId"` // 物理对象所在账户
+	FileKey   string `json:"fileKey"`   // 物理对象路径
+	ETag      string `json:"etag"`      // 上传完成时的 ETag，用于确认对象未被覆盖
+	RefCount  int    `json:"refCount"`  // 引用计数，归零时删除物理对象
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// FileMetadata 上传时附加或之后编辑的自定义元数据和标签
+// 同时写入对象的用户元数据，这里的记录用于列表展示和筛选
+type FileMetadata struct {
+	ID        string            `json:"id"`
+	AccountID string            `json:"accountId"` // 文件所在账户
+	FileKey   string            `json:"fileKey"`   // 文件路径
+	Size      int64             `json:"size"`      // 文件大小（字节），按标签筛选时返回
+	Metadata  map[string]string `json:"metadata"`  // 自定义键值对
+	Tags      []string          `json:"tags"`      // 标签
+	CreatedAt string            `json:"createdAt"`
+	UpdatedAt string            `json:"updatedAt"`
+}
+
+// StripedChunk 分块文件的一个分块，保存为所在账户中的独立对象
+type StripedChunk struct {
+	Index     int    `json:"index"`     // 分块序号，从 0 开始
+	AccountID string `json:"accountId"` // 分块所在账户
+	Key       string `json:"key"`       // 分块对象路径
+	Offset    int64  `json:"offset"`    // 分块在文件中的起始位置
+	Size      int64  `json:"size"`      // 分块大小（字节）
+	SHA256    string `json:"sha256"`    // 分块内容的 SHA-256
+}
+
+// StripedFile 跨多个账户分块存储的虚拟大文件
+// 文件显示在 AccountID 账户的 FileKey 路径下，下载时按分块顺序重新拼接
+type StripedFile struct {
+	ID           string         `json:"id"`
+	AccountID    string         `json:"accountId"`    // 虚拟文件显示在该账户下（规划的首个分块账户）
+	FileKey      string         `json:"fileKey"`      // 虚拟文件路径
+	OriginalName string         `json:"originalName"` // 原始文件名
+	ContentType  string         `json:"contentType"`  // 文件类型
+	Size         int64          `json:"size"`         // 文件大小（字节）
+	SHA256       string         `json:"sha256"`       // 整个文件的 SHA-256
+	CRC32C       string         `json:"crc32c"`       // 整个文件的 CRC32C
+	Chunks       []StripedChunk `json:"chunks"`       // 按顺序排列的分块
+	Uploader     string         `json:"uploader"`     // 上传者
+	CreatedAt    string         `json:"createdAt"`
+	UpdatedAt    string         `json:"updatedAt"`
+}
+
+// 副本集状态
+const (
+	ReplicaSetHealthy  = "healthy"  // 副本数已满足要求
+	ReplicaSetDegraded = "degraded" // 副本数不足，等待修复
+	ReplicaSetLost     = "lost"     // 没有可用副本
+)
+
+// ReplicaSet 智能上传写入多个账户的副本记录
+// 所有副本使用相同的文件路径，Replicas 的第一个账户为首次写入的账户
+type ReplicaSet struct {
+	ID            string   `json:"id"`
+	AccountID     string   `json:"accountId"`     // 首次写入的账户
+	FileKey       string   `json:"fileKey"`       // 文件路径
+	Size          int64    `json:"size"`          // 文件大小（字节）
+	SHA256        string   `json:"sha256"`        // 内容 SHA-256，用于校验副本
+	Factor        int      `json:"factor"`        // 要求的副本数
+	Replicas      []string `json:"replicas"`      // 持有有效副本的账户ID
+	Pool          string   `json:"pool"`          // 上传时指定的账户池，补齐副本时只在池成员中选择
+	Status        string   `json:"status"`        // 副本集状态
+	LastError     string   `json:"lastError"`     // 最近一次修复失败的原因
+	LastCheckedAt string   `json:"lastCheckedAt"` // 最近一次校验全部副本的时间
+	CreatedAt     string   `json:"createdAt"`
+	UpdatedAt     string   `json:"updatedAt"`
+}
+
 // ImgBBFile ImgBB 上传文件记录
 type ImgBBFile struct {
-	ID        string `json:"id"`        // 记录ID
-	FileName  string `json:"fileName"`  // 原始文件名
-	URL       string `json:"url"`       // 直接访问链接
-	DeleteURL string `json:"deleteUrl"` // 删除链接
-	Size      int64  `json:"size"`      // 文件大小（字节）
+	ID         string `json:"id"`         // 记录ID
+	FileName   string `json:"fileName"`   // 原始文件名
+	URL        string `json:"url"`        // 直接访问链接
+	DeleteURL  string `json:"deleteUrl"`  // 删除链接
+	Size       int64  `json:"size"`       // 文件大小（字节）
 	UploadedAt string `json:"uploadedAt"` // 上传时间 (ISO 8601)
 }
 
+// UploadSession 可恢复上传会话（tus 协议），映射到 S3 分片上传
+type UploadSession struct {
+	ID             string       `json:"id"`             // 会话ID
+	AccountID      string       `json:"accountId"`      // 目标账户ID
+	FileKey        string       `json:"fileKey"`        // 目标文件路径
+	UploadID       string       `json:"uploadId"`       // S3 分片上传ID
+	Size           int64        `json:"size"`           // 文件总大小（字节）
+	Offset         int64        `json:"offset"`         // 已接收字节数
+	PartSize       int64        `json:"partSize"`       // 分片大小（字节）
+	Parts          []UploadPart `json:"parts"`          // 已上传的分片
+	ContentType    string       `json:"contentType"`    // 文件类型
+	FileName       string       `json:"fileName"`       // 原始文件名
+	ExpirationDays int          `json:"expirationDays"` // 文件到期天数，0 表示永久
+	TokenID        string       `json:"tokenId"`        // 创建会话的 API Token ID（后台登录为空）
+	Completed      bool         `json:"completed"`      // 是否已完成上传
+	ExpiresAt      string       `json:"expiresAt"`      // 会话过期时间 (ISO 8601)
+	CreatedAt      string       `json:"createdAt"`      // 创建时间
+	UpdatedAt      string       `json:"updatedAt"`      // 更新时间
+}
+
+// UploadPart 已上传的分片
+type UploadPart struct {
+	PartNumber int32  `json:"partNumber"`
+	ETag       string `json:"etag"`
+	Size       int64  `json:"size"`
+}
+
+// PresignedUpload 预签名直传预留记录，客户端直接上传到 R2 后由服务端确认完成
+type PresignedUpload struct {
+	ID             string `json:"id"`             // 记录ID
+	AccountID      string `json:"accountId"`      // 目标账户ID
+	FileKey        string `json:"fileKey"`        // 目标文件路径
+	UploadID       string `json:"uploadId"`       // S3 分片上传ID（单次 PUT 为空）
+	Size           int64  `json:"size"`           // 声明的文件大小（字节）
+	PartSize       int64  `json:"partSize"`       // 分片大小（字节），单次 PUT 为 0
+	ContentType    string `json:"contentType"`    // 文件类型
+	FileName       string `json:"fileName"`       // 原始文件名
+	ExpirationDays int    `json:"expirationDays"` // 文件到期天数，0 表示永久
+	TokenID        string `json:"tokenId"`        // 创建预留的 API Token ID（后台登录为空）
+	ExpiresAt      string `json:"expiresAt"`      // 预留过期时间 (ISO 8601)
+	CreatedAt      string `json:"createdAt"`      // 创建时间
+}
+
+// 远程 URL 导入任务状态
+const (
+	ImportJobPending   = "pending"   // 等待执行（含等待重试）
+	ImportJobRunning   = "running"   // 正在下载并上传
+	ImportJobSucceeded = "succeeded" // 已完成
+	ImportJobFailed    = "failed"    // 重试次数用尽后失败
+	ImportJobCanceled  = "canceled"  // 已取消
+)
+
+// ImportJob 远程 URL 导入任务，后台下载后上传到 R2
+type ImportJob struct {
+	ID              string `json:"id"`              // 任务ID
+	URL             string `json:"url"`             // 源地址
+	Status          string `json:"status"`          // 任务状态
+	AccountID       string `json:"accountId"`       // 指定的账户ID（为空时智能选择）
+	Pool            string `json:"pool"`            // 指定的账户池名称（为空时在所有账户中选择）
+	Path            string `json:"path"`            // 自定义存储目录
+	ExpirationDays  int    `json:"expirationDays"`  // 文件到期天数，0 表示永久
+	TokenID         string `json:"tokenId"`         // 创建任务的 API Token ID（后台登录为空）
+	Uploader        string `json:"uploader"`        // 上传者：token:<名称> 或 user:<用户名>
+	Attempts        int    `json:"attempts"`        // 已执行次数
+	MaxAttempts     int    `json:"maxAttempts"`     // 最多执行次数
+	TotalBytes      int64  `json:"totalBytes"`      // 源文件大小，未知时为 -1
+	DownloadedBytes int64  `json:"downloadedBytes"` // 已下载字节数
+	UploadedBytes   int64  `json:"uploadedBytes"`   // 已上传字节数
+	TargetAccountID string `json:"targetAccountId"` // 实际写入的账户ID
+	Result          string `json:"result"`          // 上传结果（JSON），完成后填写
+	Error           string `json:"error"`           // 最近一次失败原因
+	CreatedAt       string `json:"createdAt"`       // 创建时间
+	UpdatedAt       string `json:"updatedAt"`       // 更新时间
+	FinishedAt      string `json:"finishedAt"`      // 结束时间（成功、失败或取消）
+}
+
+// Webhook 事件通知地址
+type Webhook struct {
+	ID        string   `json:"id"`        // Webhook ID
+	Name      string   `json:"name"`      // 名称
+	URL       string   `json:"url"`       // 接收地址（http/https）
+	Secret    string   `json:"secret"`    // HMAC-SHA256 签名密钥
+	Events    []string `json:"events"`    // 订阅的事件类型，为空表示全部
+	IsActive  bool     `json:"isActive"`  // 是否启用
+	CreatedAt string   `json:"createdAt"` // 创建时间
+	UpdatedAt string   `json:"updatedAt"` // 更新时间
+}
+
+// Webhook 投递状态
+const (
+	WebhookDeliveryPending   = "pending"   // 等待投递（含等待重试）
+	WebhookDeliverySucceeded = "succeeded" // 已送达（接收端返回 2xx）
+	WebhookDeliveryFailed    = "failed"    // 重试次数用尽后失败
+)
+
+// WebhookDelivery Webhook 投递记录
+type WebhookDelivery struct {
+	ID            string `json:"id"`            // 投递ID
+	WebhookID     string `json:"webhookId"`     // Webhook ID
+	EventID       string `json:"eventId"`       // 事件ID，同一事件投递到多个 Webhook 时相同
+	Event         string `json:"event"`         // 事件类型
+	Payload       string `json:"payload"`       // 请求体（JSON）
+	Status        string `json:"status"`        // 投递状态
+	Attempts      int    `json:"attempts"`      // 已投递次数
+	MaxAttempts   int    `json:"maxAttempts"`   // 最多投递次数
+	ResponseCode  int    `json:"responseCode"`  // 最近一次响应的状态码，未收到响应时为 0
+	Error         string `json:"error"`         // 最近一次失败原因
+	NextAttemptAt string `json:"nextAttemptAt"` // 下次投递时间
+	CreatedAt     string `json:"createdAt"`     // 创建时间
+	UpdatedAt     string `json:"updatedAt"`     // 更新时间
+	FinishedAt    string `json:"finishedAt"`    // 结束时间（送达或失败）
+}
+
 // Settings 系统设置
 type Settings struct {
 	SyncInterval           int    `json:"syncInterval"`           // 同步间隔（分钟），默认 5
@@ -114,6 +433,20 @@ type Settings struct {
 	ExpirationCheckMinutes int    `json:"expirationCheckMinutes"` // 到期检查间隔（分钟），默认 720（12小时）
 	ImgBBEnabled           bool   `json:"imgbbEnabled"`           // 启用 ImgBB 上传接口
 	ImgBBPriority          bool   `json:"imgbbPriority"`          // ImgBB 优先（启用时优先使用 ImgBB）
+	DedupEnabled           bool   `json:"dedupEnabled"`           // 启用内容去重（按 SHA-256 复用已存在的对象）
+	KeyTemplate            string `json:"keyTemplate"`            // 存储路径模板，为空时使用默认模板
+	KeyCollision           string `json:"keyCollision"`           // 路径冲突处理：suffix、overwrite 或 reject
+	URLAllowedHosts        string `json:"urlAllowedHosts"`        // URL 上传允许的主机（逗号或换行分隔，支持 *.example.com），为空时不限制
+	URLMaxDownloadMB       int    `json:"urlMaxDownloadMb"`       // URL 上传的最大下载大小（MB），默认 1024
+	URLMaxRedirects        int    `json:"urlMaxRedirects"`        // URL 上传最多跟随的重定向次数，默认 5
+	URLAllowedTypes        string `json:"urlAllowedTypes"`        // 允许的 Content-Type（逗号分隔，支持 image/*），为空时不限制
+	URLDeniedTypes         string `json:"urlDeniedTypes"`         // 拒绝的 Content-Type（逗号分隔，支持 image/*）
+	ThumbnailEnabled       bool   `json:"thumbnailEnabled"`       // 上传图片时生成缩略图
+	ThumbnailSizes         string `json:"thumbnailSizes"`         // 缩略图尺寸（最长边像素，逗号分隔），默认 200,800
+	ThumbnailQuality       int    `json:"thumbnailQuality"`       // 缩略图 JPEG 质量（1-100），默认 80
+	ReplicationFactor      int    `json:"replicationFactor"`      // 智能上传写入的副本数（1-5），默认 1 表示不复制
+	PlacementStrategy      string `json:"placementStrategy"`      // 智能上传的账户放置策略，默认 least-used
+	OpsAlertPercent        int    `json:"opsAlertPercent"`        // 操作次数达到或预计月底达到配额的该百分比时告警（1-100），默认 80
 }
 
 // Data 存储的完整数据结构
@@ -121,11 +454,53 @@ type Data struct {
 	Accounts          []Account          `json:"accounts"`
 	Tokens            []Token            `json:"tokens"`
 	WebDAVCredentials []WebDAVCredential `json:"webdavCredentials"`
+	Pools             []Pool             `json:"pools"`
 	FileExpirations   []FileExpiration   `json:"fileExpirations"`
 	ImgBBFiles        []ImgBBFile        `json:"imgbbFiles"`
+	UploadSessions    []UploadSession    `json:"uploadSessions"`
+	PresignedUploads  []PresignedUpload  `json:"presignedUploads"`
+	FileObjects       []FileObject       `json:"fileObjects"`
+	FileMetadata      []FileMetadata     `json:"fileMetadata"`
+	ReplicaSets       []ReplicaSet       `json:"replicaSets"`
+	StripedFiles      []StripedFile      `json:"stripedFiles"`
+	ImportJobs        []ImportJob        `json:"importJobs"`
+	Webhooks          []Webhook          `json:"webhooks"`
+	WebhookDeliveries []WebhookDelivery  `json:"webhookDeliveries"`
 	Settings          Settings           `json:"settings"`
 }
 
+// HasPool 检查 Token 是否可以访问指定账户池，未限定账户池的 Token 可以访问所有池
+func (t *Token) HasPool(name string) bool {
+	if len(t.Pools) == 0 {
+		return true
+	}
+	for _, p := range t.Pools {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasMember 检查账户是否为池成员
+func (p *Pool) HasMember(accountID string) bool {
+	for _, m := range p.Members {
+		if m.AccountID == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+// AccountIDs 获取成员账户ID（按成员顺序）
+func (p *Pool) AccountIDs() []string {
+	ids := make([]string, 0, len(p.Members))
+	for _, m := range p.Members {
+		ids = append(ids, m.AccountID)
+	}
+	return ids
+}
+
 // HasPermission 检查 Token 是否有指定权限
 func (t *Token) HasPermission(perm string) bool {
 	for _, p := range t.Permissions {
@@ -136,14 +511,28 @@ func (t *Token) HasPermission(perm string) bool {
 	return false
 }
 
-// IsOverQuota 检查账户是否超过配额
+// EstimatedUsage 估算的当前使用量：上次同步的使用量加上之后本地记录的变化
+func (a *Account) EstimatedUsage() Usage {
+	usage := a.Usage
+	usage.SizeBytes = max(usage.SizeBytes+a.PendingUsage.SizeBytes, 0)
+	usage.ClassAOps = max(usage.ClassAOps+a.PendingUsage.ClassAOps, 0)
+	usage.ClassBOps = max(usage.ClassBOps+a.PendingUsage.ClassBOps, 0)
+	return usage
+}
+
+// IsOverQuota 检查账户是否超过配额（按估算的使用量）
 func (a *Account) IsOverQuota() bool {
-	return a.Usage.SizeBytes >= a.Quota.MaxSizeBytes
+	return a.EstimatedUsage().SizeBytes >= a.Quota.MaxSizeBytes
 }
 
-// IsOverOps 检查账户是否超过操作次数限制
+// IsOverOps 检查账户是否超过 A 类或 B 类操作次数限制（按估算的使用量）
 func (a *Account) IsOverOps() bool {
-	return a.Usage.ClassAOps >= a.Quota.MaxClassAOps
+	return a.EstimatedUsage().ClassAOps >= a.Quota.MaxClassAOps || a.IsOverReadOps()
+}
+
+// IsOverReadOps 检查账户是否超过 B 类操作次数限制，未设置限制时返回 false
+func (a *Account) IsOverReadOps() bool {
+	return a.Quota.MaxClassBOps > 0 && a.EstimatedUsage().ClassBOps >= a.Quota.MaxClassBOps
 }
 
 // IsAvailable 检查账户是否可用于上传
@@ -186,12 +575,85 @@ func (a *Account) IsAvailableForClientUpload() bool {
 	return a.IsAvailable() && a.CanClientUpload()
 }
 
+// IsPrivateLink 检查账户是否使用预签名链接（未配置时按公开域名处理）
+func (a *Account) IsPrivateLink() bool {
+	return a.LinkMode == LinkModePrivate
+}
+
+// GetProvider 获取存储服务商，未设置时为 R2
+func (a *Account) GetProvider() string {
+	if a.Provider == "" {
+		return ProviderR2
+	}
+	return a.Provider
+}
+
+// IsR2 是否为 Cloudflare R2 账户
+func (a *Account) IsR2() bool {
+	return a.GetProvider() == ProviderR2
+}
+
+// GetRegion 获取签名区域：未配置时 R2 为 auto，B2 和 Wasabi 从端点（如 s3.us-west-004.backblazeb2.com）提取，
+// 其他服务商为 us-east-1
+func (a *Account) GetRegion() string {
+	if a.Region != "" {
+		return a.Region
+	}
+	switch a.GetProvider() {
+	case ProviderR2:
+		return "auto"
+	case ProviderB2, ProviderWasabi:
+		host := strings.TrimPrefix(strings.TrimPrefix(a.Endpoint, "https://"), "http://")
+		if parts := strings.Split(host, "."); len(parts) > 3 && parts[0] == "s3" {
+			return parts[1]
+		}
+	}
+	return DefaultS3Region
+}
+
+// FreeBytes 按配额计算的剩余空间，已扣除进行中上传的预留
+func (a *Account) FreeBytes() int64 {
+	return max(a.Quota.MaxSizeBytes-a.EstimatedUsage().SizeBytes-a.ReservedBytes, 0)
+}
+
 // GetUsagePercent 获取容量使用百分比
 func (a *Account) GetUsagePercent() float64 {
 	if a.Quota.MaxSizeBytes == 0 {
 		return 0
 	}
-	return float64(a.Usage.SizeBytes) / float64(a.Quota.MaxSizeBytes) * 100
+	return float64(a.EstimatedUsage().SizeBytes) / float64(a.Quota.MaxSizeBytes) * 100
+}
+
+// GetCommittedPercent 获取包含进行中上传预留的容量使用百分比，选择上传账户时按此排序
+func (a *Account) GetCommittedPercent() float64 {
+	if a.Quota.MaxSizeBytes == 0 {
+		return 0
+	}
+	return float64(a.EstimatedUsage().SizeBytes+a.ReservedBytes) / float64(a.Quota.MaxSizeBytes) * 100
+}
+
+// GetOpsPercent 获取 A 类操作次数使用百分比
+func (a *Account) GetOpsPercent() float64 {
+	if a.Quota.MaxClassAOps == 0 {
+		return 0
+	}
+	return float64(a.EstimatedUsage().ClassAOps) / float64(a.Quota.MaxClassAOps) * 100
+}
+
+// GetReadOpsPercent 获取 B 类操作次数使用百分比，未设置限制时为 0
+func (a *Account) GetReadOpsPercent() float64 {
+	if a.Quota.MaxClassBOps == 0 {
+		return 0
+	}
+	return float64(a.EstimatedUsage().ClassBOps) / float64(a.Quota.MaxClassBOps) * 100
+}
+
+// GetWeight 获取放置权重，未设置时为 1
+func (a *Account) GetWeight() int {
+	if a.Weight <= 0 {
+		return 1
+	}
+	return a.Weight
 }
 
 // NowString 获取当前时间的 ISO 字符串
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// GetPools 获取所有账户池
+func GetPools() []Pool {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	if data.Pools == nil {
+		return []Pool{}
+	}
+
+	result := make([]Pool, len(data.Pools))
+	copy(result, data.Pools)
+	return result
+}
+
+// GetPoolByID 按 ID 获取账户池
+func GetPoolByID(id string) (*Pool, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, pool := range data.Pools {
+		if pool.ID == id {
+			result := pool
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("账户池不存在: %s", id)
+}
+
+// GetPoolByName 按名称获取账户池
+func GetPoolByName(name string) (*Pool, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, pool := range data.Pools {
+		if pool.Name == name {
+			result := pool
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("账户池不存在: %s", name)
+}
+
+// poolNameTaken 检查名称是否已被其他账户池使用（调用方持有锁）
+func poolNameTaken(name, exceptID string) bool {
+	for _, pool := range data.Pools {
+		if pool.Name == name && pool.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatePool 创建账户池
+func CreatePool(pool *Pool) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	if poolNameTaken(pool.Name, "") {
+		return fmt.Errorf("账户池名称已存在: %s", pool.Name)
+	}
+
+	pool.ID = uuid.New().String()
+	if pool.Members == nil {
+		pool.Members = []PoolMember{}
+	}
+	pool.CreatedAt = NowString()
+	pool.UpdatedAt = pool.CreatedAt
+
+	data.Pools = append(data.Pools, *pool)
+	return save()
+}
+
+// UpdatePool 更新账户池，重命名时同步更新 Token 中引用的池名称
+func UpdatePool(pool *Pool) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, existing := range data.Pools {
+		if existing.ID != pool.ID {
+			continue
+		}
+		if poolNameTaken(pool.Name, pool.ID) {
+			return fmt.Errorf("账户池名称已存在: %s", pool.Name)
+		}
+
+		if existing.Name != pool.Name {
+			for j := range data.Tokens {
+				for k, name := range data.Tokens[j].Pools {
+					if name == existing.Name {
+						data.Tokens[j].Pools[k] = pool.Name
+					}
+				}
+			}
+		}
+
+		if pool.Members == nil {
+			pool.Members = []PoolMember{}
+		}
+		pool.CreatedAt = existing.CreatedAt
+		pool.UpdatedAt = NowString()
+		data.Pools[i] = *pool
+		return save()
+	}
+	return fmt.Errorf("账户池不存在: %s", pool.ID)
+}
+
+// DeletePool 删除账户池，仍被 Token 引用的账户池不能删除
+func DeletePool(id string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, pool := range data.Pools {
+		if pool.ID != id {
+			continue
+		}
+		for _, t := range data.Tokens {
+			if len(t.Pools) > 0 && t.HasPool(pool.Name) {
+				return fmt.Errorf("账户池 %s 正在被 Token %s 使用", pool.Name, t.Name)
+			}
+		}
+
+		data.Pools = append(data.Pools[:i], data.Pools[i+1:]...)
+		return save()
+	}
+	return fmt.Errorf("账户池不存在: %s", id)
+}
+
+// removePoolMember 从所有账户池中移除账户（调用方持有锁）
+func removePoolMember(accountID string) {
+	for i := range data.Pools {
+		kept := data.Pools[i].Members[:0]
+		for _, m := range data.Pools[i].Members {
+			if m.AccountID != accountID {
+				kept = append(kept, m)
+			}
+		}
+		data.Pools[i].Members = kept
+	}
+}
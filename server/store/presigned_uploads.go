@@ -0,0 +1,63 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// GetPresignedUploads 获取所有预签名直传预留记录
+func GetPresignedUploads() []PresignedUpload {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	if data.PresignedUploads == nil {
+		return []PresignedUpload{}
+	}
+
+	result := make([]PresignedUpload, len(data.PresignedUploads))
+	copy(result, data.PresignedUploads)
+	return result
+}
+
+// GetPresignedUploadByID 按 ID 获取预签名直传预留记录
+func GetPresignedUploadByID(id string) (*PresignedUpload, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, pu := range data.PresignedUploads {
+		if pu.ID == id {
+			result := pu
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("直传预留记录不存在")
+}
+
+// CreatePresignedUpload 创建预签名直传预留记录
+func CreatePresignedUpload(pu *PresignedUpload) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	if pu.ID == "" {
+		pu.ID = uuid.New().String()
+	}
+	pu.CreatedAt = NowString()
+
+	data.PresignedUploads = append(data.PresignedUploads, *pu)
+	return save()
+}
+
+// DeletePresignedUpload 删除预签名直传预留记录
+func DeletePresignedUpload(id string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, pu := range data.PresignedUploads {
+		if pu.ID == id {
+			data.PresignedUploads = append(data.PresignedUploads[:i], data.PresignedUploads[i+1:]...)
+			return save()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// GetReplicaSets 获取所有副本集
+func GetReplicaSets() []ReplicaSet {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	if data.ReplicaSets == nil {
+		return []ReplicaSet{}
+	}
+
+	result := make([]ReplicaSet, len(data.ReplicaSets))
+	copy(result, data.ReplicaSets)
+	return result
+}
+
+// GetReplicaSetByID 按 ID 获取副本集
+func GetReplicaSetByID(id string) (*ReplicaSet, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, rs := range data.ReplicaSets {
+		if rs.ID == id {
+			result := rs
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("副本集不存在: %s", id)
+}
+
+// HasReplica 账户是否在副本集中
+func (rs *ReplicaSet) HasReplica(accountID string) bool {
+	for _, id := range rs.Replicas {
+		if id == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetReplicaSetByKey 获取包含指定账户中该文件的副本集
+func GetReplicaSetByKey(accountID, fileKey string) (*ReplicaSet, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, rs := range data.ReplicaSets {
+		if rs.FileKey == fileKey && (rs.AccountID == accountID || rs.HasReplica(accountID)) {
+			result := rs
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("副本集不存在")
+}
+
+// GetReplicaSetsByPrefix 获取包含指定账户、路径以 prefix 开头的副本集
+func GetReplicaSetsByPrefix(accountID, prefix string) []ReplicaSet {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	result := []ReplicaSet{}
+	for _, rs := range data.ReplicaSets {
+		if strings.HasPrefix(rs.FileKey, prefix) && (rs.AccountID == accountID || rs.HasReplica(accountID)) {
+			result = append(result, rs)
+		}
+	}
+	return result
+}
+
+// CreateReplicaSet 创建副本集，同一首次写入账户和路径已有记录时替换
+func CreateReplicaSet(rs *ReplicaSet) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	if rs.Replicas == nil {
+		rs.Replicas = []string{}
+	}
+	now := NowString()
+	rs.ID = uuid.New().String()
+	rs.CreatedAt = now
+	rs.UpdatedAt = now
+	if rs.LastCheckedAt == "" {
+		rs.LastCheckedAt = now
+	}
+
+	for i, existing := range data.ReplicaSets {
+		if existing.AccountID == rs.AccountID && existing.FileKey == rs.FileKey {
+			data.ReplicaSets[i] = *rs
+			if err := deleteRows(TableReplicaSets, existing.ID); err != nil {
+				return err
+			}
+			return saveRows(*rs)
+		}
+	}
+	data.ReplicaSets = append(data.ReplicaSets, *rs)
+	return saveRows(*rs)
+}
+
+// UpdateReplicaSet 更新副本集
+func UpdateReplicaSet(rs *ReplicaSet) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, existing := range data.ReplicaSets {
+		if existing.ID == rs.ID {
+			if rs.Replicas == nil {
+				rs.Replicas = []string{}
+			}
+			rs.CreatedAt = existing.CreatedAt
+			rs.UpdatedAt = NowString()
+			data.ReplicaSets[i] = *rs
+			return saveRows(*rs)
+		}
+	}
+	return fmt.Errorf("副本集不存在: %s", rs.ID)
+}
+
+// DeleteReplicaSets 批量删除副本集，只保存一次
+func DeleteReplicaSets(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	kept := data.ReplicaSets[:0]
+	var removed []string
+	for _, rs := range data.ReplicaSets {
+		if remove[rs.ID] {
+			removed = append(removed, rs.ID)
+			continue
+		}
+		kept = append(kept, rs)
+	}
+	data.ReplicaSets = kept
+
+	return deleteRows(TableReplicaSets, removed...)
+}
+
+// RemoveReplicaAccount 从副本集中移除账户持有的副本，并按剩余副本数更新状态
+// fileKey 为空时处理该账户参与的全部副本集，以 / 结尾时处理该目录下的副本集，否则只处理该文件
+func RemoveReplicaAccount(accountID, fileKey string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	var changed []interface{}
+	for i := range data.ReplicaSets {
+		rs := &data.ReplicaSets[i]
+		if !rs.HasReplica(accountID) {
+			continue
+		}
+		if fileKey != "" && rs.FileKey != fileKey && !(strings.HasSuffix(fileKey, "/") && strings.HasPrefix(rs.FileKey, fileKey)) {
+			continue
+		}
+
+		replicas := []string{}
+		for _, id := range rs.Replicas {
+			if id != accountID {
+				replicas = append(replicas, id)
+			}
+		}
+		rs.Replicas = replicas
+		switch {
+		case len(replicas) == 0:
+			rs.Status = ReplicaSetLost
+		case len(replicas) < rs.Factor:
+			rs.Status = ReplicaSetDegraded
+		}
+		rs.UpdatedAt = NowString()
+		changed = append(changed, *rs)
+	}
+
+	return saveRows(changed...)
+}
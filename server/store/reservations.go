@@ -0,0 +1,152 @@
+package store
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reservation 上传前在账户上预留的容量
+// 预留只保存在内存中，不持久化；写入完成或失败后释放，超过到期时间未释放的预留自动失效
+type Reservation struct {
+	ID        string `json:"id"`
+	AccountID string `json:"accountId"`
+	Size      int64  `json:"size"`   // 尚未写入的预留字节数
+	Source    string `json:"source"` // 上传来源：file、url、tus、presign、webdav 等
+	Key       string `json:"key"`    // 目标文件路径（已知时）
+	CreatedAt string `json:"createdAt"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// reservations 进行中的预留（由 dataLock 保护），账户的 ReservedBytes 为其合计
+var reservations = make(map[string]*Reservation)
+
+// ReserveAccountSpace 在账户上预留容量：估算用量加上已有预留和本次预留不超过配额时成功
+// r.ID 为空时自动生成；成功时 r 被填充并返回 true，空间不足或账户不存在时返回 false
+func ReserveAccountSpace(r *Reservation, ttl time.Duration) bool {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	pruneExpiredReservations()
+	acc := findAccount(r.AccountID)
+	if acc == nil || acc.FreeBytes() < r.Size {
+		return false
+	}
+	addReservation(acc, r, ttl)
+	return true
+}
+
+// AddReservation 不检查剩余空间直接记录预留，用于调用方指定账户的上传和恢复服务重启前的预留
+func AddReservation(r *Reservation, ttl time.Duration) {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	if acc := findAccount(r.AccountID); acc != nil && r.Size > 0 {
+		addReservation(acc, r, ttl)
+	}
+}
+
+// UpdateReservation 更新预留中尚未写入的字节数并顺延到期时间，size 不大于 0 时释放预留
+func UpdateReservation(id string, size int64, ttl time.Duration) {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	r, ok := reservations[id]
+	if !ok {
+		return
+	}
+	if size <= 0 {
+		removeReservation(r)
+		return
+	}
+	if acc := findAccount(r.AccountID); acc != nil {
+		acc.ReservedBytes += size - r.Size
+	}
+	r.Size = size
+	r.ExpiresAt = time.Now().Add(ttl).UTC().Format(time.RFC3339)
+}
+
+// ReleaseReservation 释放预留，预留不存在时不做任何处理
+func ReleaseReservation(id string) bool {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	r, ok := reservations[id]
+	if ok {
+		removeReservation(r)
+	}
+	return ok
+}
+
+// GetReservations 获取所有未过期的预留，按创建时间排序
+func GetReservations() []Reservation {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	pruneExpiredReservations()
+	result := make([]Reservation, 0, len(reservations))
+	for _, r := range reservations {
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt < result[j].CreatedAt
+	})
+	return result
+}
+
+// PruneExpiredReservations 释放已过期的预留，返回释放的数量
+func PruneExpiredReservations() int {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	return pruneExpiredReservations()
+}
+
+// findAccount 按 ID 查找账户（调用方持有锁）
+func findAccount(id string) *Account {
+	for i := range data.Accounts {
+		if data.Accounts[i].ID == id {
+			return &data.Accounts[i]
+		}
+	}
+	return nil
+}
+
+// addReservation 记录预留并累加到账户（调用方持有锁）
+func addReservation(acc *Account, r *Reservation, ttl time.Duration) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	if old, ok := reservations[r.ID]; ok {
+		removeReservation(old)
+	}
+	now := time.Now()
+	r.CreatedAt = now.UTC().Format(time.RFC3339)
+	r.ExpiresAt = now.Add(ttl).UTC().Format(time.RFC3339)
+
+	stored := *r
+	reservations[r.ID] = &stored
+	acc.ReservedBytes += r.Size
+}
+
+// removeReservation 移除预留并从账户中扣除（调用方持有锁）
+func removeReservation(r *Reservation) {
+	delete(reservations, r.ID)
+	if acc := findAccount(r.AccountID); acc != nil {
+		acc.ReservedBytes = max(acc.ReservedBytes-r.Size, 0)
+	}
+}
+
+// pruneExpiredReservations 释放已过期的预留（调用方持有锁）
+func pruneExpiredReservations() int {
+	now := NowString()
+	count := 0
+	for _, r := range reservations {
+		if r.ExpiresAt <= now {
+			removeReservation(r)
+			count++
+		}
+	}
+	return count
+}
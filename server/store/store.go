@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"fileflow/server/config"
 
@@ -77,6 +78,28 @@ func save() error {
 	return nil
 }
 
+// saveRows 只保存变更的记录（按行写入的表，内部使用，需要在锁内调用）
+func saveRows(rows ...interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := backend.SaveRows(rows...); err != nil {
+		return fmt.Errorf("保存数据失败: %w", err)
+	}
+	return nil
+}
+
+// deleteRows 删除按行写入的记录（内部使用，需要在锁内调用）
+func deleteRows(table RowTable, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := backend.DeleteRows(table, ids...); err != nil {
+		return fmt.Errorf("保存数据失败: %w", err)
+	}
+	return nil
+}
+
 // GetAccounts 获取所有账户
 func GetAccounts() []Account {
 	dataLock.RLock()
@@ -166,10 +189,11 @@ func GetAccountsStats() AccountsStats {
 		if acc.IsAvailable() {
 			stats.AvailableCount++
 		}
-		stats.TotalSizeBytes += acc.Usage.SizeBytes
+		usage := acc.EstimatedUsage()
+		stats.TotalSizeBytes += usage.SizeBytes
 		stats.TotalQuotaBytes += acc.Quota.MaxSizeBytes
-		stats.TotalWriteOps += acc.Usage.ClassAOps
-		stats.TotalReadOps += acc.Usage.ClassBOps
+		stats.TotalWriteOps += usage.ClassAOps
+		stats.TotalReadOps += usage.ClassBOps
 	}
 
 	return stats
@@ -266,7 +290,10 @@ func UpdateAccount(acc *Account) error {
 	for i, a := range data.Accounts {
 		if a.ID == acc.ID {
 			acc.UpdatedAt = NowString()
-			acc.CreatedAt = a.CreatedAt // 保留创建时间
+			acc.CreatedAt = a.CreatedAt         // 保留创建时间
+			acc.PendingUsage = a.PendingUsage   // 保留本地记录的用量变化
+			acc.ReservedBytes = a.ReservedBytes // 保留进行中上传的预留
+			acc.UsageHistory = a.UsageHistory   // 保留用量同步历史
 			data.Accounts[i] = *acc
 			return save()
 		}
@@ -275,14 +302,21 @@ func UpdateAccount(acc *Account) error {
 }
 
 // UpdateAccountUsage 更新账户使用量
-func UpdateAccountUsage(id string, usage Usage) error {
+// reconciled 为同步开始时本地记录的用量变化，已包含在同步结果中，从本地记录中扣除；之后记录的变化保留
+func UpdateAccountUsage(id string, usage Usage, reconciled UsageDelta) error {
 	dataLock.Lock()
 	defer dataLock.Unlock()
 
 	for i, a := range data.Accounts {
 		if a.ID == id {
 			data.Accounts[i].Usage = usage
+			data.Accounts[i].PendingUsage.Add(UsageDelta{
+				SizeBytes: -reconciled.SizeBytes,
+				ClassAOps: -reconciled.ClassAOps,
+				ClassBOps: -reconciled.ClassBOps,
+			})
 			data.Accounts[i].Usage.LastSyncAt = NowString()
+			data.Accounts[i].UsageHistory = appendUsageSample(a.UsageHistory, usage, time.Now())
 			data.Accounts[i].UpdatedAt = NowString()
 			return save()
 		}
@@ -290,7 +324,58 @@ func UpdateAccountUsage(id string, usage Usage) error {
 	return fmt.Errorf("账户不存在: %s", id)
 }
 
-// DeleteAccount 删除账户
+const (
+	// UsageSampleInterval 用量历史的最小采样间隔，间隔内的同步结果替换最后一个样本
+	UsageSampleInterval = time.Hour
+	// UsageHistoryRetention 用量历史的保留时间
+	UsageHistoryRetention = 48 * time.Hour
+)
+
+// appendUsageSample 记录一次同步结果，丢弃超过保留时间的样本
+func appendUsageSample(history []UsageSample, usage Usage, now time.Time) []UsageSample {
+	sample := UsageSample{
+		SyncedAt:  now.UTC().Format(time.RFC3339),
+		ClassAOps: usage.ClassAOps,
+		ClassBOps: usage.ClassBOps,
+	}
+
+	cutoff := now.Add(-UsageHistoryRetention).UTC().Format(time.RFC3339)
+	result := make([]UsageSample, 0, len(history)+1)
+	for _, s := range history {
+		if s.SyncedAt >= cutoff {
+			result = append(result, s)
+		}
+	}
+	if n := len(result); n > 1 {
+		// 保留间隔内的第一个样本作为起点，之后的同步结果只更新最后一个样本
+		if prev, err := time.Parse(time.RFC3339, result[n-2].SyncedAt); err == nil && now.Sub(prev) < UsageSampleInterval {
+			result[n-1] = sample
+			return result
+		}
+	}
+	return append(result, sample)
+}
+
+// RecordAccountUsage 累加账户在两次同步之间的用量变化
+// 只修改内存中的数据不保存，返回更新后的账户以及账户是否因此由可用变为超额
+func RecordAccountUsage(id string, delta UsageDelta) (*Account, bool) {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i := range data.Accounts {
+		acc := &data.Accounts[i]
+		if acc.ID != id {
+			continue
+		}
+		wasOver := acc.IsOverQuota() || acc.IsOverOps()
+		acc.PendingUsage.Add(delta)
+		result := *acc
+		return &result, !wasOver && (acc.IsOverQuota() || acc.IsOverOps())
+	}
+	return nil, false
+}
+
+// DeleteAccount 删除账户，并从所有账户池中移除
 func DeleteAccount(id string) error {
 	dataLock.Lock()
 	defer dataLock.Unlock()
@@ -298,6 +383,7 @@ func DeleteAccount(id string) error {
 	for i, acc := range data.Accounts {
 		if acc.ID == id {
 			data.Accounts = append(data.Accounts[:i], data.Accounts[i+1:]...)
+			removePoolMember(id)
 			return save()
 		}
 	}
@@ -314,6 +400,20 @@ func GetTokens() []Token {
 	return result
 }
 
+// GetTokenByID 根据 ID 获取 Token
+func GetTokenByID(id string) (*Token, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, t := range data.Tokens {
+		if t.ID == id {
+			result := t
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("Token 不存在: %s", id)
+}
+
 // GetTokenByValue 根据 Token 值获取 Token
 func GetTokenByValue(tokenValue string) (*Token, error) {
 	dataLock.RLock()
@@ -341,6 +441,22 @@ func CreateToken(t *Token) error {
 	return save()
 }
 
+// UpdateToken 更新 Token 的名称、权限和路径模板，Token 值和创建时间保持不变
+func UpdateToken(t *Token) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, existing := range data.Tokens {
+		if existing.ID == t.ID {
+			t.Token = existing.Token
+			t.CreatedAt = existing.CreatedAt
+			data.Tokens[i] = *t
+			return save()
+		}
+	}
+	return fmt.Errorf("Token 不存在: %s", t.ID)
+}
+
 // generateRandomString 生成指定长度的随机字符串（大小写字母和数字）
 func generateRandomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
@@ -387,6 +503,30 @@ func GetSettings() Settings {
 	if settings.ExpirationCheckMinutes <= 0 {
 		settings.ExpirationCheckMinutes = 720
 	}
+	if settings.KeyCollision == "" {
+		settings.KeyCollision = KeyCollisionSuffix
+	}
+	if settings.URLMaxDownloadMB <= 0 {
+		settings.URLMaxDownloadMB = DefaultURLMaxDownloadMB
+	}
+	if settings.URLMaxRedirects <= 0 {
+		settings.URLMaxRedirects = DefaultURLMaxRedirects
+	}
+	if settings.ThumbnailSizes == "" {
+		settings.ThumbnailSizes = DefaultThumbnailSizes
+	}
+	if settings.ThumbnailQuality <= 0 {
+		settings.ThumbnailQuality = DefaultThumbnailQuality
+	}
+	if settings.ReplicationFactor <= 0 {
+		settings.ReplicationFactor = DefaultReplicationFactor
+	}
+	if settings.OpsAlertPercent <= 0 {
+		settings.OpsAlertPercent = DefaultOpsAlertPercent
+	}
+	if settings.PlacementStrategy == "" {
+		settings.PlacementStrategy = PlacementLeastUsed
+	}
 	return settings
 }
 
@@ -425,6 +565,20 @@ func GetFileExpirations() []FileExpiration {
 	return result
 }
 
+// GetFileExpirationByKey 获取指定账户和文件的到期记录
+func GetFileExpirationByKey(accountID, fileKey string) (*FileExpiration, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, exp := range data.FileExpirations {
+		if exp.AccountID == accountID && exp.FileKey == fileKey {
+			result := exp
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("到期记录不存在")
+}
+
 // GetExpiredFiles 获取已过期的文件列表
 func GetExpiredFiles() []FileExpiration {
 	dataLock.RLock()
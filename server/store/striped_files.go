@@ -0,0 +1,132 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// GetStripedFiles 获取所有分块文件
+func GetStripedFiles() []StripedFile {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	if data.StripedFiles == nil {
+		return []StripedFile{}
+	}
+
+	result := make([]StripedFile, len(data.StripedFiles))
+	copy(result, data.StripedFiles)
+	return result
+}
+
+// GetStripedFileByKey 按虚拟文件位置获取分块文件
+func GetStripedFileByKey(accountID, fileKey string) (*StripedFile, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, sf := range data.StripedFiles {
+		if sf.AccountID == accountID && sf.FileKey == fileKey {
+			result := sf
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("分块文件不存在")
+}
+
+// GetStripedFilesByPrefix 获取账户中虚拟路径以 prefix 开头的分块文件
+func GetStripedFilesByPrefix(accountID, prefix string) []StripedFile {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	result := []StripedFile{}
+	for _, sf := range data.StripedFiles {
+		if sf.AccountID == accountID && strings.HasPrefix(sf.FileKey, prefix) {
+			result = append(result, sf)
+		}
+	}
+	return result
+}
+
+// GetStripedFilesByChunkAccount 获取有分块存放在指定账户中的分块文件
+func GetStripedFilesByChunkAccount(accountID string) []StripedFile {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	result := []StripedFile{}
+	for _, sf := range data.StripedFiles {
+		for _, chunk := range sf.Chunks {
+			if chunk.AccountID == accountID {
+				result = append(result, sf)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// CreateStripedFile 创建分块文件记录，未指定 ID 时自动生成
+func CreateStripedFile(sf *StripedFile) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for _, existing := range data.StripedFiles {
+		if existing.AccountID == sf.AccountID && existing.FileKey == sf.FileKey {
+			return fmt.Errorf("分块文件已存在: %s", sf.FileKey)
+		}
+	}
+
+	if sf.ID == "" {
+		sf.ID = uuid.New().String()
+	}
+	if sf.Chunks == nil {
+		sf.Chunks = []StripedChunk{}
+	}
+	sf.CreatedAt = NowString()
+	sf.UpdatedAt = sf.CreatedAt
+
+	data.StripedFiles = append(data.StripedFiles, *sf)
+	return saveRows(*sf)
+}
+
+// MoveStripedFiles 虚拟文件或目录移动后更新分块文件的路径，分块对象保持不变
+// from 以 / 结尾时视为目录，移动其下的所有分块文件
+func MoveStripedFiles(accountID, from, to string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	var moved []interface{}
+	for i := range data.StripedFiles {
+		sf := &data.StripedFiles[i]
+		if sf.AccountID != accountID {
+			continue
+		}
+		switch {
+		case sf.FileKey == from:
+			sf.FileKey = to
+		case strings.HasSuffix(from, "/") && strings.HasPrefix(sf.FileKey, from):
+			sf.FileKey = to + strings.TrimPrefix(sf.FileKey, from)
+		default:
+			continue
+		}
+		sf.UpdatedAt = NowString()
+		moved = append(moved, *sf)
+	}
+
+	return saveRows(moved...)
+}
+
+// DeleteStripedFile 删除分块文件记录
+func DeleteStripedFile(id string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, sf := range data.StripedFiles {
+		if sf.ID == id {
+			data.StripedFiles = append(data.StripedFiles[:i], data.StripedFiles[i+1:]...)
+			return deleteRows(TableStripedFiles, id)
+		}
+	}
+	return fmt.Errorf("分块文件不存在: %s", id)
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// GetUploadSessions 获取所有可恢复上传会话
+func GetUploadSessions() []UploadSession {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	if data.UploadSessions == nil {
+		return []UploadSession{}
+	}
+
+	result := make([]UploadSession, len(data.UploadSessions))
+	copy(result, data.UploadSessions)
+	return result
+}
+
+// GetUploadSessionByID 按 ID 获取可恢复上传会话
+func GetUploadSessionByID(id string) (*UploadSession, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, sess := range data.UploadSessions {
+		if sess.ID == id {
+			result := sess
+			result.Parts = append([]UploadPart(nil), sess.Parts...)
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("上传会话不存在")
+}
+
+// CreateUploadSession 创建可恢复上传会话
+func CreateUploadSession(sess *UploadSession) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	if sess.ID == "" {
+		sess.ID = uuid.New().String()
+	}
+	if sess.Parts == nil {
+		sess.Parts = []UploadPart{}
+	}
+	now := NowString()
+	sess.CreatedAt = now
+	sess.UpdatedAt = now
+
+	data.UploadSessions = append(data.UploadSessions, *sess)
+	return saveRows(*sess)
+}
+
+// UpdateUploadSession 更新可恢复上传会话
+func UpdateUploadSession(sess *UploadSession) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, s := range data.UploadSessions {
+		if s.ID == sess.ID {
+			sess.UpdatedAt = NowString()
+			updated := *sess
+			updated.Parts = append([]UploadPart(nil), sess.Parts...)
+			data.UploadSessions[i] = updated
+			return saveRows(updated)
+		}
+	}
+	return fmt.Errorf("上传会话不存在")
+}
+
+// DeleteUploadSession 删除可恢复上传会话
+func DeleteUploadSession(id string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, sess := range data.UploadSessions {
+		if sess.ID == id {
+			data.UploadSessions = append(data.UploadSessions[:i], data.UploadSessions[i+1:]...)
+			return deleteRows(TableUploadSessions, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetWebhooks 获取所有 Webhook
+func GetWebhooks() []Webhook {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	if data.Webhooks == nil {
+		return []Webhook{}
+	}
+
+	result := make([]Webhook, len(data.Webhooks))
+	copy(result, data.Webhooks)
+	return result
+}
+
+// GetWebhookByID 按 ID 获取 Webhook
+func GetWebhookByID(id string) (*Webhook, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, hook := range data.Webhooks {
+		if hook.ID == id {
+			result := hook
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("Webhook 不存在: %s", id)
+}
+
+// CreateWebhook 创建 Webhook，未提供签名密钥时自动生成
+func CreateWebhook(hook *Webhook) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	hook.ID = uuid.New().String()
+	if hook.Secret == "" {
+		hook.Secret = "whsec_" + generateRandomString(40)
+	}
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	hook.CreatedAt = NowString()
+	hook.UpdatedAt = hook.CreatedAt
+
+	data.Webhooks = append(data.Webhooks, *hook)
+	return save()
+}
+
+// UpdateWebhook 更新 Webhook，签名密钥为空时保留原值
+func UpdateWebhook(hook *Webhook) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, existing := range data.Webhooks {
+		if existing.ID == hook.ID {
+			if hook.Secret == "" {
+				hook.Secret = existing.Secret
+			}
+			if hook.Events == nil {
+				hook.Events = []string{}
+			}
+			hook.CreatedAt = existing.CreatedAt
+			hook.UpdatedAt = NowString()
+			data.Webhooks[i] = *hook
+			return save()
+		}
+	}
+	return fmt.Errorf("Webhook 不存在: %s", hook.ID)
+}
+
+// DeleteWebhook 删除 Webhook 及其投递记录
+func DeleteWebhook(id string) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, hook := range data.Webhooks {
+		if hook.ID == id {
+			data.Webhooks = append(data.Webhooks[:i], data.Webhooks[i+1:]...)
+
+			kept := data.WebhookDeliveries[:0]
+			var removed []string
+			for _, d := range data.WebhookDeliveries {
+				if d.WebhookID != id {
+					kept = append(kept, d)
+				} else {
+					removed = append(removed, d.ID)
+				}
+			}
+			data.WebhookDeliveries = kept
+			if err := save(); err != nil {
+				return err
+			}
+			return deleteRows(TableWebhookDeliveries, removed...)
+		}
+	}
+	return fmt.Errorf("Webhook 不存在: %s", id)
+}
+
+// GetWebhookDeliveries 获取投递记录，webhookID 非空时只返回该 Webhook 的记录
+func GetWebhookDeliveries(webhookID string) []WebhookDelivery {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	result := []WebhookDelivery{}
+	for _, d := range data.WebhookDeliveries {
+		if webhookID == "" || d.WebhookID == webhookID {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// GetWebhookDeliveryByID 按 ID 获取投递记录
+func GetWebhookDeliveryByID(id string) (*WebhookDelivery, error) {
+	dataLock.RLock()
+	defer dataLock.RUnlock()
+
+	for _, d := range data.WebhookDeliveries {
+		if d.ID == id {
+			result := d
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("投递记录不存在")
+}
+
+// CreateWebhookDeliveries 批量创建投递记录，只保存一次
+func CreateWebhookDeliveries(deliveries []*WebhookDelivery) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	now := NowString()
+	rows := make([]interface{}, 0, len(deliveries))
+	for _, d := range deliveries {
+		if d.ID == "" {
+			d.ID = uuid.New().String()
+		}
+		d.CreatedAt = now
+		d.UpdatedAt = now
+		data.WebhookDeliveries = append(data.WebhookDeliveries, *d)
+		rows = append(rows, *d)
+	}
+	return saveRows(rows...)
+}
+
+// UpdateWebhookDelivery 更新投递记录
+func UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	for i, d := range data.WebhookDeliveries {
+		if d.ID == delivery.ID {
+			delivery.UpdatedAt = NowString()
+			data.WebhookDeliveries[i] = *delivery
+			return saveRows(*delivery)
+		}
+	}
+	return fmt.Errorf("投递记录不存在")
+}
+
+// DeleteFinishedWebhookDeliveries 删除结束时间早于 before 的投递记录，返回删除数量
+func DeleteFinishedWebhookDeliveries(before time.Time) (int, error) {
+	dataLock.Lock()
+	defer dataLock.Unlock()
+
+	kept := data.WebhookDeliveries[:0]
+	var removed []string
+	for _, d := range data.WebhookDeliveries {
+		if d.FinishedAt != "" {
+			if finished, err := time.Parse(time.RFC3339, d.FinishedAt); err == nil && finished.Before(before) {
+				removed = append(removed, d.ID)
+				continue
+			}
+		}
+		kept = append(kept, d)
+	}
+	data.WebhookDeliveries = kept
+
+	return len(removed), deleteRows(TableWebhookDeliveries, removed...)
+}
@@ -6,6 +6,7 @@ package webdav
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +15,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"fileflow/server/service"
 )
 
 // Handler is a WebDAV request handler.
@@ -162,6 +165,11 @@ func (h *Handler) handleGetHead(w http.ResponseWriter, r *http.Request, storage
 	if etag != "" {
 		w.Header().Set("ETag", etag)
 	}
+	if ci, ok := fi.(ChecksumInfo); ok {
+		if checksum := ci.GetChecksums().OCChecksum(); checksum != "" {
+			w.Header().Set("OC-Checksum", checksum)
+		}
+	}
 
 	body, size, err := storage.Open(ctx, reqPath)
 	if err != nil {
@@ -173,6 +181,13 @@ func (h *Handler) handleGetHead(w http.ResponseWriter, r *http.Request, storage
 	if fi.GetContentType() == "" {
 		w.Header().Set("Content-Type", "application/octet-stream")
 	}
+
+	// 可 Seek 的读取流（分块文件）支持 Range 请求
+	if rs, ok := body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, fi.GetName(), fi.ModTime(), rs)
+		return 0, nil
+	}
+
 	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
 	w.Header().Set("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
 
@@ -231,13 +246,29 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, storage Stor
 		contentType = "application/octet-stream"
 	}
 
+	expected, err := parseExpectedChecksums(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
 	// Check if exists
 	_, err = storage.Get(ctx, reqPath)
 	exists := err == nil
 
-	if err := storage.Put(ctx, reqPath, r.Body, size, contentType); err != nil {
+	// X-Keep-Metadata: true 时保留图片原始元数据
+	keepMetadata := r.Header.Get("X-Keep-Metadata") == "true"
+
+	checksums, err := storage.Put(ctx, reqPath, r.Body, size, contentType, expected, keepMetadata)
+	if err != nil {
+		if errors.Is(err, service.ErrChecksumMismatch) || errors.Is(err, service.ErrIncompleteUpload) ||
+			errors.Is(err, service.ErrStripImageMetadata) {
+			return http.StatusBadRequest, err
+		}
 		return http.StatusInternalServerError, err
 	}
+	if checksum := checksums.OCChecksum(); checksum != "" {
+		w.Header().Set("OC-Checksum", checksum)
+	}
 
 	if exists {
 		return http.StatusNoContent, nil
@@ -245,6 +276,15 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, storage Stor
 	return http.StatusCreated, nil
 }
 
+// parseExpectedChecksums 读取客户端提供的期望校验值
+// 支持 ownCloud/Nextcloud 客户端的 OC-Checksum 头，以及 X-Checksum-SHA256 / X-Checksum-CRC32C
+func parseExpectedChecksums(r *http.Request) (service.Checksums, error) {
+	if oc := r.Header.Get("OC-Checksum"); oc != "" {
+		return service.ParseOCChecksum(oc)
+	}
+	return service.ParseChecksums(r.Header.Get("X-Checksum-SHA256"), r.Header.Get("X-Checksum-CRC32C"))
+}
+
 func (h *Handler) handleMkcol(w http.ResponseWriter, r *http.Request, storage Storage, user User) (status int, err error) {
 	reqPath, status, err := h.stripPrefix(r.URL.Path)
 	if err != nil {
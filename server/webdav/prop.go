@@ -139,6 +139,10 @@ var liveProps = map[xml.Name]struct {
 		dir:    false,
 	},
 	{Space: "DAV:", Local: "lockdiscovery"}: {},
+	{Space: "http://owncloud.org/ns", Local: "checksums"}: {
+		findFn: findChecksums,
+		dir:    false,
+	},
 	{Space: "DAV:", Local: "supportedlock"}: {
 		findFn: findSupportedLock,
 		dir:    true,
@@ -340,6 +344,22 @@ func findETag(ctx context.Context, ls LockSystem, name string, fi FileInfo) (str
 	return fmt.Sprintf(`"%x%x"`, fi.ModTime().UnixNano(), fi.GetSize()), nil
 }
 
+// findChecksums returns the content checksums recorded at upload time in the
+// ownCloud format, e.g. <oc:checksum>SHA256:... CRC32C:...</oc:checksum>.
+// Objects written without checksums (or listed without metadata) yield an
+// empty value.
+func findChecksums(ctx context.Context, ls LockSystem, name string, fi FileInfo) (string, error) {
+	ci, ok := fi.(ChecksumInfo)
+	if !ok {
+		return "", nil
+	}
+	checksum := ci.GetChecksums().OCChecksum()
+	if checksum == "" {
+		return "", nil
+	}
+	return `<oc:checksum xmlns:oc="http://owncloud.org/ns">` + escapeXML(checksum) + `</oc:checksum>`, nil
+}
+
 func findSupportedLock(ctx context.Context, ls LockSystem, name string, fi FileInfo) (string, error) {
 	return `` +
 		`<D:lockentry xmlns:D="DAV:">` +
@@ -8,10 +8,10 @@ import (
 	"strings"
 	"time"
 
+	"fileflow/server/service"
 	"fileflow/server/store"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
@@ -28,6 +28,11 @@ type FileInfo interface {
 	GetContentType() string
 }
 
+// ChecksumInfo 可提供内容校验值的文件信息（上传时写入对象元数据的 SHA-256 和 CRC32C）
+type ChecksumInfo interface {
+	GetChecksums() service.Checksums
+}
+
 // Storage 存储操作接口
 type Storage interface {
 	// List 列出目录内容
@@ -36,8 +41,8 @@ type Storage interface {
 	Get(ctx context.Context, path string) (FileInfo, error)
 	// Open 打开文件获取读取流
 	Open(ctx context.Context, path string) (io.ReadCloser, int64, error)
-	// Put 上传文件
-	Put(ctx context.Context, path string, reader io.Reader, size int64, contentType string) error
+	// Put 上传文件，expected 为客户端提供的期望校验值，返回实际的校验值
+	Put(ctx context.Context, path string, reader io.Reader, size int64, contentType string, expected service.Checksums, keepImageMetadata bool) (service.Checksums, error)
 	// MakeDir 创建目录
 	MakeDir(ctx context.Context, path string) error
 	// Remove 删除文件或目录
@@ -57,6 +62,7 @@ type S3FileInfo struct {
 	isDir       bool
 	etag        string
 	contentType string
+	checksums   service.Checksums
 }
 
 func (f *S3FileInfo) GetName() string        { return f.name }
@@ -68,30 +74,21 @@ func (f *S3FileInfo) IsDir() bool            { return f.isDir }
 func (f *S3FileInfo) GetETag() string        { return f.etag }
 func (f *S3FileInfo) GetContentType() string { return f.contentType }
 
+func (f *S3FileInfo) GetChecksums() service.Checksums { return f.checksums }
+
 // S3Storage S3 存储实现
 type S3Storage struct {
 	client     *s3.Client
 	bucketName string
+	account    *store.Account
 }
 
 // NewS3Storage 创建 S3 存储适配器
 func NewS3Storage(acc *store.Account) (*S3Storage, error) {
-	cfg := aws.Config{
-		Region: "auto",
-		Credentials: credentials.NewStaticCredentialsProvider(
-			acc.AccessKeyId,
-			acc.SecretAccessKey,
-			"",
-		),
-	}
-
-	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(acc.Endpoint)
-	})
-
 	return &S3Storage{
-		client:     client,
+		client:     service.NewS3Client(acc),
 		bucketName: acc.BucketName,
+		account:    acc,
 	}, nil
 }
 
@@ -136,7 +133,8 @@ func (s *S3Storage) List(ctx context.Context, dirPath string) ([]FileInfo, error
 		for _, cp := range output.CommonPrefixes {
 			name := strings.TrimPrefix(*cp.Prefix, prefix)
 			name = strings.TrimSuffix(name, "/")
-			if name == "" {
+			// 分块对象目录不对外展示
+			if name == "" || service.IsStripeChunkKey(*cp.Prefix) {
 				continue
 			}
 			files = append(files, &S3FileInfo{
@@ -171,7 +169,7 @@ func (s *S3Storage) List(ctx context.Context, dirPath string) ([]FileInfo, error
 
 			files = append(files, &S3FileInfo{
 				name:    name,
-				size:    *obj.Size,
+				size:    service.ListedObjectSize(s.account, *obj.Size),
 				path:    keyToPath(key),
 				modTime: modTime,
 				isDir:   false,
@@ -185,7 +183,52 @@ func (s *S3Storage) List(ctx context.Context, dirPath string) ([]FileInfo, error
 		continuationToken = output.NextContinuationToken
 	}
 
-	return files, nil
+	return s.appendStripedFiles(files, prefix), nil
+}
+
+// appendStripedFiles 把 prefix 下的分块文件加入目录列表，更深层的分块文件以目录形式出现
+func (s *S3Storage) appendStripedFiles(files []FileInfo, prefix string) []FileInfo {
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		if f.IsDir() {
+			dirs[f.GetName()] = true
+		}
+	}
+
+	for _, sf := range store.GetStripedFilesByPrefix(s.account.ID, prefix) {
+		name := strings.TrimPrefix(sf.FileKey, prefix)
+		if i := strings.Index(name, "/"); i >= 0 {
+			if !dirs[name[:i]] {
+				dirs[name[:i]] = true
+				files = append(files, &S3FileInfo{
+					name:    name[:i],
+					path:    keyToPath(prefix + name[:i+1]),
+					isDir:   true,
+					modTime: time.Now(),
+				})
+			}
+			continue
+		}
+		files = append(files, stripedFileInfo(&sf))
+	}
+	return files
+}
+
+// stripedFileInfo 分块文件的文件信息，取自分块清单
+func stripedFileInfo(sf *store.StripedFile) *S3FileInfo {
+	modTime, err := time.Parse(time.RFC3339, sf.CreatedAt)
+	if err != nil {
+		modTime = time.Now()
+	}
+	return &S3FileInfo{
+		name:        path.Base(sf.FileKey),
+		size:        sf.Size,
+		path:        keyToPath(sf.FileKey),
+		modTime:     modTime,
+		etag:        sf.SHA256,
+		contentType: sf.ContentType,
+		checksums:   service.Checksums{SHA256: sf.SHA256, CRC32C: sf.CRC32C},
+	}
 }
 
 // Get 获取文件/目录信息
@@ -235,6 +278,9 @@ func (s *S3Storage) Get(ctx context.Context, filePath string) (FileInfo, error)
 		if headOutput.ContentLength != nil {
 			size = *headOutput.ContentLength
 		}
+		if service.IsEncryptedObject(headOutput.Metadata) {
+			size = service.PlaintextSize(size)
+		}
 
 		return &S3FileInfo{
 			name:        path.Base(filePath),
@@ -244,9 +290,18 @@ func (s *S3Storage) Get(ctx context.Context, filePath string) (FileInfo, error)
 			isDir:       false,
 			etag:        etag,
 			contentType: contentType,
+			checksums: service.Checksums{
+				SHA256: headOutput.Metadata[service.MetaSHA256],
+				CRC32C: headOutput.Metadata[service.MetaCRC32C],
+			},
 		}, nil
 	}
 
+	// 分块文件没有同名的物理对象，从分块清单获取
+	if sf, err := store.GetStripedFileByKey(s.account.ID, key); err == nil {
+		return stripedFileInfo(sf), nil
+	}
+
 	// 检查是否为目录
 	prefix := key
 	if !strings.HasSuffix(prefix, "/") {
@@ -263,7 +318,8 @@ func (s *S3Storage) Get(ctx context.Context, filePath string) (FileInfo, error)
 		return nil, fmt.Errorf("get object failed: %w", err)
 	}
 
-	if len(listOutput.Contents) > 0 || len(listOutput.CommonPrefixes) > 0 {
+	if len(listOutput.Contents) > 0 || len(listOutput.CommonPrefixes) > 0 ||
+		len(store.GetStripedFilesByPrefix(s.account.ID, prefix)) > 0 {
 		return &S3FileInfo{
 			name:    path.Base(filePath),
 			path:    keyToPath(key),
@@ -276,51 +332,38 @@ func (s *S3Storage) Get(ctx context.Context, filePath string) (FileInfo, error)
 }
 
 // Open 打开文件获取读取流
+// 返回可 Seek 的读取器：分块文件从各分块所在账户读取后拼接，加密对象读取时解密
 func (s *S3Storage) Open(ctx context.Context, filePath string) (io.ReadCloser, int64, error) {
-	key := pathToKey(filePath)
-
-	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(key),
-	})
-
+	file, err := service.OpenFile(ctx, s.account.ID, pathToKey(filePath))
 	if err != nil {
 		return nil, 0, fmt.Errorf("get object failed: %w", err)
 	}
-
-	size := int64(0)
-	if output.ContentLength != nil {
-		size = *output.ContentLength
-	}
-
-	return output.Body, size, nil
+	return file, file.Size, nil
 }
 
 // Put 上传文件
-func (s *S3Storage) Put(ctx context.Context, filePath string, reader io.Reader, size int64, contentType string) error {
+// 通过 service 的流式上传写入：计算并记录 SHA-256/CRC32C，大文件自动分片，
+// 声明大小或期望校验值不一致时不会生成对象；账户开启隐私选项时图片元数据在写入前移除
+func (s *S3Storage) Put(ctx context.Context, filePath string, reader io.Reader, size int64, contentType string, expected service.Checksums, keepImageMetadata bool) (service.Checksums, error) {
 	key := pathToKey(filePath)
 
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 
-	input := &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucketName),
-		Key:         aws.String(key),
-		Body:        reader,
-		ContentType: aws.String(contentType),
-	}
-
-	if size > 0 {
-		input.ContentLength = aws.Int64(size)
+	meta := service.ObjectMetadata{
+		Source:            service.UploadSourceWebDAV,
+		Expected:          expected,
+		KeepImageMetadata: keepImageMetadata,
 	}
-
-	_, err := s.client.PutObject(ctx, input)
+	result, err := service.StoreObject(ctx, s.account, key, meta, reader, size, contentType)
 	if err != nil {
-		return fmt.Errorf("put object failed: %w", err)
+		return service.Checksums{}, fmt.Errorf("put object failed: %w", err)
 	}
+	// 覆盖同路径的分块文件时删除原有分块
+	service.DeleteStripedFiles(ctx, s.account.ID, key)
 
-	return nil
+	return service.Checksums{SHA256: result.SHA256, CRC32C: result.CRC32C}, nil
 }
 
 // MakeDir 创建目录
@@ -344,19 +387,45 @@ func (s *S3Storage) MakeDir(ctx context.Context, dirPath string) error {
 	return nil
 }
 
-// Remove 删除文件或目录
+// Remove 删除文件或目录，删除单个文件时发送 file.deleted 事件
 func (s *S3Storage) Remove(ctx context.Context, filePath string) error {
+	// 分块文件删除所有分块和清单
+	if key := pathToKey(filePath); service.DeleteStripedFiles(ctx, s.account.ID, key) {
+		service.PublishEvent(service.EventFileDeleted, service.FileEventData{AccountID: s.account.ID, Key: key, Source: service.UploadSourceWebDAV})
+		return nil
+	}
+
+	removed, isDir, err := s.remove(ctx, filePath)
+	if err != nil {
+		return err
+	}
+	service.DeleteReplicas(ctx, s.account.ID, removed)
+	if isDir {
+		service.DeleteStripedFiles(ctx, s.account.ID, removed)
+		store.DeleteFileMetadataByPrefix(s.account.ID, removed)
+		return nil
+	}
+	store.DeleteFileMetadata(s.account.ID, removed)
+	service.PublishEvent(service.EventFileDeleted, service.FileEventData{AccountID: s.account.ID, Key: removed, Source: service.UploadSourceWebDAV})
+	return nil
+}
+
+// remove 删除文件或目录，返回被删除的 key（目录以 / 结尾）及是否为目录
+func (s *S3Storage) remove(ctx context.Context, filePath string) (string, bool, error) {
 	key := pathToKey(filePath)
 
 	// 检查是否为目录
 	info, err := s.Get(ctx, filePath)
 	if err != nil {
-		return err
+		return "", false, err
 	}
 
 	if info.IsDir() {
 		// 删除目录下所有内容
-		return s.removeDir(ctx, key)
+		if !strings.HasSuffix(key, "/") {
+			key += "/"
+		}
+		return key, true, s.removeDir(ctx, key)
 	}
 
 	// 删除单个文件
@@ -366,10 +435,11 @@ func (s *S3Storage) Remove(ctx context.Context, filePath string) error {
 	})
 
 	if err != nil {
-		return fmt.Errorf("delete object failed: %w", err)
+		return "", false, fmt.Errorf("delete object failed: %w", err)
 	}
+	service.RecordStoredBytes(s.account.ID, -info.GetSize())
 
-	return nil
+	return key, false, nil
 }
 
 // removeDir 递归删除目录
@@ -380,6 +450,7 @@ func (s *S3Storage) removeDir(ctx context.Context, prefix string) error {
 
 	var continuationToken *string
 	var objects []types.ObjectIdentifier
+	var sizes []int64
 
 	for {
 		listOutput, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
@@ -396,6 +467,7 @@ func (s *S3Storage) removeDir(ctx context.Context, prefix string) error {
 			objects = append(objects, types.ObjectIdentifier{
 				Key: obj.Key,
 			})
+			sizes = append(sizes, aws.ToInt64(obj.Size))
 		}
 
 		if !*listOutput.IsTruncated {
@@ -426,6 +498,11 @@ func (s *S3Storage) removeDir(ctx context.Context, prefix string) error {
 		if err != nil {
 			return fmt.Errorf("batch delete failed: %w", err)
 		}
+		var freed int64
+		for _, size := range sizes[i:end] {
+			freed += size
+		}
+		service.RecordStoredBytes(s.account.ID, -freed)
 	}
 
 	return nil
@@ -433,12 +510,42 @@ func (s *S3Storage) removeDir(ctx context.Context, prefix string) error {
 
 // Move 移动文件或目录
 func (s *S3Storage) Move(ctx context.Context, src, dst string) error {
+	// 分块文件只需修改清单中的路径，分块对象保持不变
+	if srcKey := pathToKey(src); s.isStriped(srcKey) {
+		dstKey := pathToKey(dst)
+		if err := store.MoveStripedFiles(s.account.ID, srcKey, dstKey); err != nil {
+			return fmt.Errorf("move striped file failed: %w", err)
+		}
+		return store.MoveFileMetadata(s.account.ID, srcKey, dstKey)
+	}
+
 	// 先复制
 	if err := s.Copy(ctx, src, dst); err != nil {
 		return err
 	}
-	// 再删除源
-	return s.Remove(ctx, src)
+	// 再删除源（移动不是删除，不发送事件）
+	removed, isDir, err := s.remove(ctx, src)
+	if err != nil {
+		return err
+	}
+	// 对象复制时已带上用户元数据，自定义元数据记录和其他账户中的副本随之移动
+	dstKey := pathToKey(dst)
+	if isDir && !strings.HasSuffix(dstKey, "/") {
+		dstKey += "/"
+	}
+	service.MoveReplicas(ctx, s.account.ID, removed, dstKey)
+	if isDir {
+		if err := store.MoveStripedFiles(s.account.ID, removed, dstKey); err != nil {
+			return err
+		}
+	}
+	return store.MoveFileMetadata(s.account.ID, removed, dstKey)
+}
+
+// isStriped 是否为分块文件
+func (s *S3Storage) isStriped(key string) bool {
+	_, err := store.GetStripedFileByKey(s.account.ID, key)
+	return err == nil
 }
 
 // Copy 复制文件或目录
@@ -455,6 +562,9 @@ func (s *S3Storage) Copy(ctx context.Context, src, dst string) error {
 	if info.IsDir() {
 		return s.copyDir(ctx, srcKey, dstKey)
 	}
+	if s.isStriped(srcKey) {
+		return fmt.Errorf("copy striped file not supported: %s", src)
+	}
 
 	// 复制单个文件
 	_, err = s.client.CopyObject(ctx, &s3.CopyObjectInput{
@@ -466,6 +576,7 @@ func (s *S3Storage) Copy(ctx context.Context, src, dst string) error {
 	if err != nil {
 		return fmt.Errorf("copy object failed: %w", err)
 	}
+	service.RecordStoredBytes(s.account.ID, info.GetSize())
 
 	return nil
 }
@@ -506,6 +617,7 @@ func (s *S3Storage) copyDir(ctx context.Context, srcPrefix, dstPrefix string) er
 			if err != nil {
 				return fmt.Errorf("copy object %s failed: %w", srcKey, err)
 			}
+			service.RecordStoredBytes(s.account.ID, aws.ToInt64(obj.Size))
 		}
 
 		if !*listOutput.IsTruncated {